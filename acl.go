@@ -0,0 +1,159 @@
+package memfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// ACLTag is the type of an entry of an access control list.
+type ACLTag int
+
+const (
+	ACLUserObj  ACLTag = iota // the owner of the entry
+	ACLUser                   // the user of the ID
+	ACLGroupObj               // the group owning the entry
+	ACLGroup                  // the group of the ID
+	ACLMask                   // the most the ACLUser, ACLGroupObj and ACLGroup entries grant
+	ACLOther                  // the users no other entry matches
+)
+
+// ACLEntry is an entry of a POSIX access control list, granting Perm, a combination of
+// the read (4), write (2) and execute (1) bits, to the users it matches.
+type ACLEntry struct {
+	Tag  ACLTag
+	ID   int // of the user or the group, for ACLUser and ACLGroup
+	Perm os.FileMode
+}
+
+// GetACL returns the access control list of the entry at path, like acl_get_file, in the
+// order getfacl lists it. The entries without an extended list have the minimal one of
+// their permission bits.
+func (f *FS) GetACL(path string) (_ []ACLEntry, err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpStat, path); err != nil {
+		return nil, err
+	}
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return nil, err
+	}
+	n.lock()
+	perm := n.perm.Perm()
+	n.unlock()
+
+	acl := []ACLEntry{{Tag: ACLUserObj, Perm: perm >> 6 & 7}}
+	if extended := n.extendedACL(); extended != nil {
+		acl = append(acl, extended...)
+		acl = append(acl, ACLEntry{Tag: ACLMask, Perm: perm >> 3 & 7})
+	} else {
+		acl = append(acl, ACLEntry{Tag: ACLGroupObj, Perm: perm >> 3 & 7})
+	}
+	return append(acl, ACLEntry{Tag: ACLOther, Perm: perm & 7}), nil
+}
+
+// SetACL replaces the access control list of the entry at path, like acl_set_file. The
+// list has one ACLUserObj, ACLGroupObj and ACLOther entry, and an ACLMask entry when it
+// has ACLUser or ACLGroup entries. The permission bits of the entry become those of the
+// ACLUserObj, ACLMask, or ACLGroupObj without a mask, and ACLOther entries, so Chmod
+// changes the mask, like on Linux. Through a view created by As, only the owner of the
+// entry and the superuser can. The lists are kept by checkpoints, namespaces and forks,
+// not by copies and exports.
+func (f *FS) SetACL(path string, acl []ACLEntry) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpChmod, path); err != nil {
+		return err
+	}
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return err
+	}
+
+	var counts [ACLOther + 1]int
+	var perms [ACLOther + 1]os.FileMode
+	var extended []ACLEntry
+	named := make(map[ACLEntry]bool)
+	for _, e := range acl {
+		if e.Tag < ACLUserObj || e.Tag > ACLOther || e.Perm&^7 != 0 {
+			return fmt.Errorf("invalid ACL entry %v: %s: %w", e, path, os.ErrInvalid)
+		}
+		counts[e.Tag]++
+		perms[e.Tag] = e.Perm
+		if e.Tag == ACLUser || e.Tag == ACLGroup {
+			key := ACLEntry{Tag: e.Tag, ID: e.ID}
+			if named[key] {
+				return fmt.Errorf("duplicate ACL entry %v: %s: %w", e, path, os.ErrInvalid)
+			}
+			named[key] = true
+			extended = append(extended, e)
+		}
+	}
+	if counts[ACLUserObj] != 1 || counts[ACLGroupObj] != 1 || counts[ACLOther] != 1 ||
+		counts[ACLMask] > 1 || len(extended) > 0 && counts[ACLMask] == 0 {
+		return fmt.Errorf("invalid ACL: %s: %w", path, os.ErrInvalid)
+	}
+	group := perms[ACLGroupObj]
+	if counts[ACLMask] == 1 {
+		group = perms[ACLMask]
+		extended = append(extended, ACLEntry{Tag: ACLGroupObj, Perm: perms[ACLGroupObj]})
+		sort.Slice(extended, func(i, j int) bool {
+			if extended[i].Tag != extended[j].Tag {
+				return extended[i].Tag < extended[j].Tag
+			}
+			return extended[i].ID < extended[j].ID
+		})
+	}
+
+	n.lock()
+	defer n.unlock()
+	if !f.owns(n) {
+		return fmt.Errorf("not the owner: %s: %w", path, os.ErrPermission)
+	}
+	n.perm = n.perm&^fs.ModePerm | perms[ACLUserObj]<<6 | group<<3 | perms[ACLOther]
+	f.changed(n)
+	if counts[ACLMask] == 0 && n.getExt() == nil {
+		return nil
+	}
+	if counts[ACLMask] == 0 {
+		extended = nil
+	}
+	e := n.extension()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.acl = extended
+	return nil
+}
+
+// extendedACL returns the ACLUser, ACLGroupObj and ACLGroup entries of the access control
+// list of the node, nil when it has none beyond its permission bits.
+func (f *fsNode) extendedACL() []ACLEntry {
+	e := f.getExt()
+	if e == nil {
+		return nil
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.acl
+}
+
+// aclAccess returns whether the extended entries of the access control list of n, limited
+// by mask, grant want to the identity of f, and whether one of them matched it, in the
+// order POSIX access control lists are evaluated after the owner.
+func (f *FS) aclAccess(n *fsNode, extended []ACLEntry, mask, want os.FileMode) (granted, matched bool) {
+	for _, e := range extended {
+		if e.Tag == ACLUser && e.ID == f.uid {
+			return e.Perm&mask&want == want, true
+		}
+	}
+	_, gid := n.owner()
+	for _, e := range extended {
+		if e.Tag == ACLGroupObj && f.gid == gid || e.Tag == ACLGroup && e.ID == f.gid {
+			matched = true
+			if e.Perm&mask&want == want {
+				return true, true
+			}
+		}
+	}
+	return false, matched
+}
@@ -0,0 +1,127 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func Test_ACL(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/data", 0755))
+	assert.Nil(t, mfs.WriteFile("/data/file", []byte("x"), 0640))
+	assert.Nil(t, mfs.Chown("/data/file", 1000, 100))
+
+	acl, err := mfs.GetACL("/data/file")
+	assert.Nil(t, err)
+	assert.Equal(t, []ACLEntry{
+		{Tag: ACLUserObj, Perm: 6},
+		{Tag: ACLGroupObj, Perm: 4},
+		{Tag: ACLOther, Perm: 0},
+	}, acl)
+
+	assert.Nil(t, mfs.As(1000, 100).SetACL("/data/file", []ACLEntry{
+		{Tag: ACLOther, Perm: 0},
+		{Tag: ACLGroup, ID: 300, Perm: 6},
+		{Tag: ACLUser, ID: 2000, Perm: 6},
+		{Tag: ACLGroupObj, Perm: 4},
+		{Tag: ACLUserObj, Perm: 6},
+		{Tag: ACLMask, Perm: 6},
+		{Tag: ACLUser, ID: 1500, Perm: 4},
+	}))
+	acl, err = mfs.GetACL("/data/file")
+	assert.Nil(t, err)
+	assert.Equal(t, []ACLEntry{
+		{Tag: ACLUserObj, Perm: 6},
+		{Tag: ACLUser, ID: 1500, Perm: 4},
+		{Tag: ACLUser, ID: 2000, Perm: 6},
+		{Tag: ACLGroupObj, Perm: 4},
+		{Tag: ACLGroup, ID: 300, Perm: 6},
+		{Tag: ACLMask, Perm: 6},
+		{Tag: ACLOther, Perm: 0},
+	}, acl)
+	fi, err := mfs.Stat("/data/file")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0660), fi.Mode())
+
+	canWrite := func(uid, gid int) bool {
+		f, err := mfs.As(uid, gid).OpenFile("/data/file", os.O_RDWR, 0)
+		if err != nil {
+			assert.True(t, errors.Is(err, os.ErrPermission))
+			return false
+		}
+		assert.Nil(t, f.Close())
+		return true
+	}
+	assert.True(t, canWrite(2000, 2000))
+	assert.False(t, canWrite(1500, 300)) // the named user entry wins over the group
+	assert.True(t, canWrite(3000, 300))
+	assert.False(t, canWrite(3000, 100))
+	assert.False(t, canWrite(3000, 3000))
+	assert.Nil(t, mfs.As(3000, 3000).Access("/data/file", 0))
+	assert.NotNil(t, mfs.As(3000, 3000).Access("/data/file", 4))
+
+	// Chmod changes the mask
+	assert.Nil(t, mfs.Chmod("/data/file", 0640))
+	assert.False(t, canWrite(2000, 2000))
+	assert.Nil(t, mfs.As(2000, 2000).Access("/data/file", 4))
+	acl, err = mfs.GetACL("/data/file")
+	assert.Nil(t, err)
+	assert.Equal(t, ACLEntry{Tag: ACLMask, Perm: 4}, acl[5])
+
+	// only the owner can set the list, which must be valid
+	assert.True(t, errors.Is(mfs.As(2000, 2000).SetACL("/data/file", acl), os.ErrPermission))
+	assert.True(t, errors.Is(mfs.SetACL("/data/file", acl[1:]), os.ErrInvalid))
+	assert.True(t, errors.Is(mfs.SetACL("/data/file", append(acl[:5:5], acl[6])), os.ErrInvalid))
+	assert.True(t, errors.Is(mfs.SetACL("/data/file", append(acl, acl[1])), os.ErrInvalid))
+	assert.True(t, errors.Is(mfs.SetACL("/data/file", []ACLEntry{
+		{Tag: ACLUserObj, Perm: 8}, {Tag: ACLGroupObj}, {Tag: ACLOther},
+	}), os.ErrInvalid))
+	assert.True(t, errors.Is(mfs.SetACL("/missing", acl), os.ErrNotExist))
+
+	// a minimal list drops the extended entries
+	assert.Nil(t, mfs.SetACL("/data/file", []ACLEntry{
+		{Tag: ACLUserObj, Perm: 7}, {Tag: ACLGroupObj, Perm: 5}, {Tag: ACLOther, Perm: 1},
+	}))
+	fi, err = mfs.Stat("/data/file")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0751), fi.Mode())
+	acl, err = mfs.GetACL("/data/file")
+	assert.Nil(t, err)
+	assert.Len(t, acl, 3)
+	assert.False(t, canWrite(2000, 2000))
+}
+
+func Test_ACL_Copies(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.WriteFile("/file", []byte("x"), 0640))
+	acl := []ACLEntry{
+		{Tag: ACLUserObj, Perm: 6},
+		{Tag: ACLUser, ID: 2000, Perm: 6},
+		{Tag: ACLGroupObj, Perm: 4},
+		{Tag: ACLMask, Perm: 6},
+		{Tag: ACLOther, Perm: 0},
+	}
+	assert.Nil(t, mfs.SetACL("/file", acl))
+
+	// kept by checkpoints, namespaces and forks
+	mfs.Checkpoint()
+	assert.Nil(t, mfs.WriteFile("/other", nil, 0644))
+	assert.Nil(t, mfs.Undo())
+	assert.Nil(t, mfs.Redo())
+	for _, fsys := range []*FS{mfs, mfs.TestNamespace(t), Template(mfs).Fork()} {
+		got, err := fsys.GetACL("/file")
+		assert.Nil(t, err)
+		assert.Equal(t, acl, got)
+		assert.Nil(t, fsys.As(2000, 2000).Access("/file", 2))
+	}
+
+	// the copies have lists of their own
+	ns := mfs.TestNamespace(t)
+	assert.Nil(t, ns.SetACL("/file", []ACLEntry{
+		{Tag: ACLUserObj, Perm: 6}, {Tag: ACLGroupObj, Perm: 4}, {Tag: ACLOther, Perm: 0},
+	}))
+	assert.NotNil(t, ns.As(2000, 2000).Access("/file", 2))
+	assert.Nil(t, mfs.As(2000, 2000).Access("/file", 2))
+}
@@ -0,0 +1,102 @@
+package memfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+// AtRemoveDir makes UnlinkAt remove a directory rather than a file, like AT_REMOVEDIR.
+const AtRemoveDir = 0x200
+
+// at returns the view and the path name resolves to relative to the directory dir, like
+// the *at system calls resolve names relative to a directory descriptor. Absolute names
+// and a nil dir, for AT_FDCWD, resolve in f. Directories have no link to their parent in
+// memfs, so names whose ".." elements climb above dir are rejected.
+func (f *FS) at(dir *File, name string) (_ *FS, _ string, err error) {
+	defer f.mapErrno(&err)
+	if dir == nil || strings.HasPrefix(f.toSlash(name), "/") {
+		return f, name, nil
+	}
+	if dir.closed {
+		return nil, "", fmt.Errorf("file closed: %s: %w", dir.Name(), os.ErrClosed)
+	}
+	if dir.fs.baseFS() != f.baseFS() {
+		return nil, "", fmt.Errorf("directory of another filesystem: %s: %w", dir.Name(), os.ErrInvalid)
+	}
+	if !dir.isDir() {
+		return nil, "", fmt.Errorf("not a directory: %s: %w", dir.Name(), syscall.ENOTDIR)
+	}
+	if dir.node.unlinked {
+		return nil, "", fmt.Errorf("directory removed: %s: %w", dir.Name(), os.ErrNotExist)
+	}
+	local := path.Clean(f.toSlash(name))
+	if name == "" || !fs.ValidPath(local) {
+		return nil, "", fmt.Errorf("path escapes from directory: %s: %w", name, os.ErrInvalid)
+	}
+	return f.view(dir.node), "/" + local, nil
+}
+
+// OpenAt opens the file at name relative to the directory dir, like openat.
+func (f *FS) OpenAt(dir *File, name string, flag int, perm os.FileMode) (*File, error) {
+	v, p, err := f.at(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	return v.OpenFile(p, flag, perm)
+}
+
+// MkdirAt creates the directory name relative to the directory dir, like mkdirat.
+func (f *FS) MkdirAt(dir *File, name string, perm os.FileMode) error {
+	v, p, err := f.at(dir, name)
+	if err != nil {
+		return err
+	}
+	return v.Mkdir(p, perm)
+}
+
+// UnlinkAt removes the file name relative to the directory dir, or the empty directory
+// when flags has AtRemoveDir, like unlinkat.
+func (f *FS) UnlinkAt(dir *File, name string, flags int) error {
+	v, p, err := f.at(dir, name)
+	if err != nil {
+		return err
+	}
+	fi, err := v.Stat(p)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() && flags&AtRemoveDir == 0 {
+		return fmt.Errorf("is a directory: %s: %w", name, syscall.EISDIR)
+	}
+	if !fi.IsDir() && flags&AtRemoveDir != 0 {
+		return fmt.Errorf("not a directory: %s: %w", name, syscall.ENOTDIR)
+	}
+	return v.Remove(p)
+}
+
+// RenameAt moves the entry oldname relative to the directory olddir to newname relative
+// to the directory newdir, like renameat.
+func (f *FS) RenameAt(olddir *File, oldname string, newdir *File, newname string) error {
+	oldfs, oldpath, err := f.at(olddir, oldname)
+	if err != nil {
+		return err
+	}
+	newfs, newpath, err := f.at(newdir, newname)
+	if err != nil {
+		return err
+	}
+	return rename(oldfs, oldpath, newfs, newpath)
+}
+
+// StatAt returns the FileInfo of the entry name relative to the directory dir, like fstatat.
+func (f *FS) StatAt(dir *File, name string) (FileInfo, error) {
+	v, p, err := f.at(dir, name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return v.Stat(p)
+}
@@ -0,0 +1,72 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func Test_At(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/a/b/file": []byte("data"), "/c/other": nil})
+	assert.Nil(t, err)
+	dir, err := mfs.Open("/a")
+	assert.Nil(t, err)
+	defer dir.Close()
+
+	f, err := mfs.OpenAt(dir, "b/file", os.O_RDONLY, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	fi, err := mfs.StatAt(dir, "b/../b/file")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), fi.Size())
+	fi, err = mfs.StatAt(dir, ".")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	// absolute names and a nil directory resolve in the filesystem
+	_, err = mfs.StatAt(dir, "/c/other")
+	assert.Nil(t, err)
+	_, err = mfs.StatAt(nil, "/a/b/file")
+	assert.Nil(t, err)
+	_, err = mfs.StatAt(dir, "../c/other")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	assert.Nil(t, mfs.MkdirAt(dir, "new", 0755))
+	_, err = mfs.Stat("/a/new")
+	assert.Nil(t, err)
+
+	assert.True(t, errors.Is(mfs.UnlinkAt(dir, "new", 0), syscall.EISDIR))
+	assert.True(t, errors.Is(mfs.UnlinkAt(dir, "b/file", AtRemoveDir), syscall.ENOTDIR))
+	assert.Nil(t, mfs.UnlinkAt(dir, "new", AtRemoveDir))
+
+	// the directory keeps its entries when renamed
+	assert.Nil(t, mfs.Rename("/a", "/renamed"))
+	_, err = mfs.StatAt(dir, "b/file")
+	assert.Nil(t, err)
+
+	other, err := mfs.Open("/c")
+	assert.Nil(t, err)
+	defer other.Close()
+	assert.Nil(t, mfs.RenameAt(dir, "b/file", other, "moved"))
+	assert.Equal(t, "data", readAll(t, mfs, "/c/moved"))
+	assert.True(t, errors.Is(mfs.RenameAt(dir, "b", dir, "b/sub"), syscall.EINVAL))
+	assert.Nil(t, mfs.MkdirAll("/renamed/b/sub", 0755))
+	sub, err := mfs.Open("/renamed/b/sub")
+	assert.Nil(t, err)
+	defer sub.Close()
+	assert.True(t, errors.Is(mfs.RenameAt(dir, "b", sub, "inside"), syscall.EINVAL))
+
+	file, err := mfs.Open("/c/moved")
+	assert.Nil(t, err)
+	_, err = mfs.StatAt(file, "x")
+	assert.True(t, errors.Is(err, syscall.ENOTDIR))
+	assert.Nil(t, file.Close())
+	_, err = mfs.StatAt(file, "x")
+	assert.True(t, errors.Is(err, os.ErrClosed))
+
+	assert.Nil(t, mfs.RemoveAll("/renamed"))
+	_, err = mfs.OpenAt(dir, "file", os.O_RDWR|os.O_CREATE, 0644)
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
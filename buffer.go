@@ -0,0 +1,44 @@
+package memfs
+
+import (
+	"math/bits"
+	"sync"
+)
+
+const (
+	minBufferShift = 9  // 512 bytes, the smallest pooled buffer
+	maxBufferShift = 22 // 4 MiB, larger buffers are left to the garbage collector
+)
+
+// bufferPools recycle the content buffers files outgrow, one pool per power of two capacity.
+var bufferPools [maxBufferShift - minBufferShift + 1]sync.Pool
+
+func bufferClass(size int) int {
+	shift := bits.Len(uint(size - 1))
+	if shift < minBufferShift {
+		shift = minBufferShift
+	}
+	return shift - minBufferShift
+}
+
+// getBuffer returns a buffer of length size, its content is not zeroed.
+func getBuffer(size int) []byte {
+	if size > 1<<maxBufferShift {
+		return make([]byte, size)
+	}
+	class := bufferClass(size)
+	if b, ok := bufferPools[class].Get().(*[]byte); ok {
+		return (*b)[:size]
+	}
+	return make([]byte, size, 1<<(class+minBufferShift))
+}
+
+// putBuffer makes the buffer available for reuse, nothing may reference it afterwards.
+func putBuffer(b []byte) {
+	c := cap(b)
+	if c < 1<<minBufferShift || c > 1<<maxBufferShift || c&(c-1) != 0 {
+		return
+	}
+	b = b[:0]
+	bufferPools[bufferClass(c)].Put(&b)
+}
@@ -0,0 +1,128 @@
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func Test_Buffer_Pool(t *testing.T) {
+	b := getBuffer(100)
+	assert.Len(t, b, 100)
+	assert.Equal(t, 512, cap(b))
+	b = getBuffer(513)
+	assert.Equal(t, 1024, cap(b))
+	b = getBuffer(5 << 20)
+	assert.Equal(t, 5<<20, cap(b))
+
+	// buffers not sized by the pool are not kept
+	putBuffer(make([]byte, 10, 600))
+	putBuffer(make([]byte, 10, 100))
+	putBuffer(getBuffer(100))
+}
+
+func Test_Write_Growth(t *testing.T) {
+	mfs := New()
+	f, err := mfs.Create("/grow")
+	assert.Nil(t, err)
+
+	var expected bytes.Buffer
+	allocations := 0
+	lastCap := 0
+	for i := 0; i < 1000; i++ {
+		p := []byte{byte(i), byte(i >> 8)}
+		_, err = f.Write(p)
+		assert.Nil(t, err)
+		expected.Write(p)
+		if c := cap(f.node.content); c != lastCap {
+			allocations++
+			lastCap = c
+		}
+	}
+	assert.Equal(t, expected.Bytes(), f.node.content)
+	assert.Less(t, allocations, 10)
+
+	// writing past the end zero fills the gap, even when the buffer came from the pool
+	dirty := getBuffer(8192)
+	for i := range dirty {
+		dirty[i] = 0xff
+	}
+	putBuffer(dirty)
+	_, err = f.WriteAt([]byte{1}, 5000)
+	assert.Nil(t, err)
+	assert.Equal(t, make([]byte, 5000-expected.Len()), f.node.content[expected.Len():5000])
+	assert.Nil(t, f.Close())
+}
+
+func Test_Write_Growth_Shared(t *testing.T) {
+	mfs := New()
+	f, err := mfs.Create("/a")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("hello"))
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(" world"))
+	assert.Nil(t, err)
+
+	ns := mfs.view(mfs.root.clone())
+	_, err = f.Write([]byte("!"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	c, err := ns.Open("/a")
+	assert.Nil(t, err)
+	b := make([]byte, 20)
+	n, _ := c.Read(b)
+	assert.Equal(t, "hello world", string(b[:n]))
+	assert.Nil(t, c.Close())
+}
+
+func Benchmark_Small_Writes(b *testing.B) {
+	mfs := New()
+	p := make([]byte, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f, _ := mfs.Create("/bench")
+		for j := 0; j < 1024; j++ {
+			_, _ = f.Write(p)
+		}
+		_ = f.Close()
+	}
+}
+
+func Test_Grow(t *testing.T) {
+	mfs := New()
+	f, err := mfs.Create("/grow")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("head"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, f.Grow(10000))
+	assert.Equal(t, 4, len(f.node.content))
+	assert.GreaterOrEqual(t, cap(f.node.content), 10004)
+	content := &f.node.content[0]
+	for i := 0; i < 1000; i++ {
+		_, err = f.Write([]byte("0123456789"))
+		assert.Nil(t, err)
+	}
+	assert.Same(t, content, &f.node.content[0])
+	fi, err := f.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10004), fi.Size())
+
+	// shared content is copied
+	assert.Nil(t, mfs.Copy("/grow", "/copy"))
+	c, err := mfs.OpenFile("/copy", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Grow(0))
+	assert.NotSame(t, &f.node.content[0], &c.node.content[0])
+	assert.Equal(t, f.node.content, c.node.content)
+
+	assert.True(t, errors.Is(f.Grow(-1), os.ErrInvalid))
+	assert.Nil(t, f.Close())
+	assert.True(t, errors.Is(f.Grow(1), os.ErrClosed))
+	r, err := mfs.Open("/grow")
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(r.Grow(1), os.ErrInvalid))
+}
@@ -0,0 +1,137 @@
+package memfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrChecksumMismatch is wrapped by the errors of VerifyChecksums for files whose content
+// doesn't match the manifest, or which are missing from it.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Checksums returns a manifest of the files beneath root in the format of sha256sum and
+// its siblings, one "<hex digest>  <path>" line per file in path order, with the paths
+// relative to root and "/" separators. The package implementing algo must be linked in,
+// crypto/sha256 always is.
+func (f *FS) Checksums(root string, algo crypto.Hash) ([]byte, error) {
+	sums, err := f.checksums(root, algo)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(sums))
+	for p := range sums {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var manifest bytes.Buffer
+	for _, p := range paths {
+		fmt.Fprintf(&manifest, "%s  %s\n", sums[p], p)
+	}
+	return manifest.Bytes(), nil
+}
+
+// VerifyChecksums checks the files beneath root against a manifest written by Checksums
+// or sha256sum. Files whose content differs, files missing from the tree and files
+// missing from the manifest are all reported, in path order.
+func (f *FS) VerifyChecksums(root string, algo crypto.Hash, manifest []byte) error {
+	expected := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for line := 1; scanner.Scan(); line++ {
+		if scanner.Text() == "" {
+			continue
+		}
+		sum, p, ok := strings.Cut(scanner.Text(), " ")
+		// sha256sum marks files read in binary mode with '*' instead of a second space
+		if !ok || (!strings.HasPrefix(p, " ") && !strings.HasPrefix(p, "*")) {
+			return fmt.Errorf("invalid manifest line %d: %w", line, os.ErrInvalid)
+		}
+		expected[p[1:]] = strings.ToLower(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	sums, err := f.checksums(root, algo)
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(sums)+len(expected))
+	for p := range sums {
+		paths = append(paths, p)
+	}
+	for p := range expected {
+		if _, exists := sums[p]; !exists {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, p := range paths {
+		sum, exists := sums[p]
+		want, listed := expected[p]
+		switch {
+		case !exists:
+			errs = append(errs, fmt.Errorf("file missing: %s: %w", p, os.ErrNotExist))
+		case !listed:
+			errs = append(errs, fmt.Errorf("file not in manifest: %s: %w", p, ErrChecksumMismatch))
+		case sum != want:
+			errs = append(errs, fmt.Errorf("content differs: %s: %w", p, ErrChecksumMismatch))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checksums returns the hex digests of the files beneath root, keyed by their path
+// relative to root with "/" separators.
+func (f *FS) checksums(root string, algo crypto.Hash) (map[string]string, error) {
+	if !algo.Available() {
+		return nil, fmt.Errorf("hash function not linked in: %s: %w", algo, os.ErrInvalid)
+	}
+	prefix := f.getAbsolutePath(root)
+	var mutex sync.Mutex
+	sums := make(map[string]string)
+	err := f.WalkParallel(root, runtime.GOMAXPROCS(0), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		n := d.(DirEntry).node.target()
+		if err = f.checkAccess(path, n, accessRead); err != nil {
+			return err
+		}
+		var sum []byte
+		if algo == crypto.SHA256 {
+			s := n.contentHash()
+			sum = s[:]
+		} else {
+			h := algo.New()
+			n.lockContent()
+			h.Write(n.getContent())
+			n.unlockContent()
+			sum = h.Sum(nil)
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(f.getAbsolutePath(path), prefix), "/")
+		if rel == "" {
+			// root is a file
+			rel = baseName(prefix)
+		}
+		mutex.Lock()
+		sums[rel] = hex.EncodeToString(sum)
+		mutex.Unlock()
+		return nil
+	})
+	return sums, err
+}
@@ -0,0 +1,62 @@
+package memfs
+
+import (
+	"crypto"
+	_ "crypto/sha512"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Checksums(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/out/bin/app":   []byte("binary"),
+		"/out/readme.md": []byte("hello\n"),
+		"/out/empty":     nil,
+	})
+	assert.Nil(t, err)
+
+	manifest, err := mfs.Checksums("/out", crypto.SHA256)
+	assert.Nil(t, err)
+	// as written by: cd out && sha256sum bin/app empty readme.md
+	assert.Equal(t, ""+
+		"9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd  bin/app\n"+
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  empty\n"+
+		"5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03  readme.md\n",
+		string(manifest))
+	assert.Nil(t, mfs.VerifyChecksums("/out", crypto.SHA256, manifest))
+
+	sha512, err := mfs.Checksums("/out/bin", crypto.SHA512)
+	assert.Nil(t, err)
+	assert.Equal(t, 128+len("  app\n"), len(sha512))
+	// the binary mode marker of sha256sum is accepted
+	binary := strings.Replace(string(sha512), "  app", " *app", 1)
+	assert.Nil(t, mfs.VerifyChecksums("/out/bin", crypto.SHA512, []byte(binary)))
+
+	// changed, removed and added files are all reported
+	f, err := mfs.OpenFile("/out/readme.md", os.O_WRONLY|os.O_APPEND, 0)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("more"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Nil(t, mfs.Remove("/out/empty"))
+	f, err = mfs.Create("/out/bin/extra")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	err = mfs.VerifyChecksums("/out", crypto.SHA256, manifest)
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Equal(t, "file not in manifest: bin/extra: checksum mismatch\n"+
+		"file missing: empty: file does not exist\n"+
+		"content differs: readme.md: checksum mismatch", err.Error())
+
+	err = mfs.VerifyChecksums("/out", crypto.SHA256, []byte("not a manifest\n"))
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+	_, err = mfs.Checksums("/out", crypto.MD4)
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+	_, err = mfs.Checksums("/missing", crypto.SHA256)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mbordner/memfs"
+)
+
+// entry describes a file or directory independently of where the tree is held.
+type entry struct {
+	Name    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	IsDir   bool
+}
+
+// backend is the tree the shell operates on, either a local filesystem loaded from an
+// image or a remote one served by memfs.FS.Handler.
+type backend interface {
+	Stat(path string) (entry, error)
+	ReadDir(path string) ([]entry, error)
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte) error
+	Mkdir(path string) error
+	Remove(path string, recursive bool) error
+}
+
+type localBackend struct {
+	fs *memfs.FS
+}
+
+func newEntry(fi os.FileInfo) entry {
+	return entry{Name: fi.Name(), Size: fi.Size(), Mode: fi.Mode(), ModTime: fi.ModTime(), IsDir: fi.IsDir()}
+}
+
+func (b *localBackend) Stat(path string) (entry, error) {
+	fi, err := b.fs.Stat(path)
+	if err != nil {
+		return entry{}, err
+	}
+	return newEntry(fi), nil
+}
+
+func (b *localBackend) ReadDir(path string) ([]entry, error) {
+	dirEntries, err := b.fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]entry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		fi, err := de.Info()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, newEntry(fi))
+	}
+	return entries, nil
+}
+
+func (b *localBackend) ReadFile(path string) ([]byte, error) {
+	f, err := b.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return io.ReadAll(f)
+}
+
+func (b *localBackend) WriteFile(path string, data []byte) error {
+	f, err := b.fs.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (b *localBackend) Mkdir(path string) error {
+	return b.fs.Mkdir(path, fs.ModePerm)
+}
+
+func (b *localBackend) Remove(path string, recursive bool) error {
+	if recursive {
+		return b.fs.RemoveAll(path)
+	}
+	return b.fs.Remove(path)
+}
+
+type remoteBackend struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func newRemoteBackend(rawURL string) (*remoteBackend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &remoteBackend{base: u, client: http.DefaultClient}, nil
+}
+
+func (b *remoteBackend) do(method, path string, query string, body io.Reader, header http.Header) (*http.Response, error) {
+	u := *b.base
+	u.Path += path
+	u.RawQuery = query
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, statusError(resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func statusError(code int, msg string) error {
+	var sentinel error
+	switch code {
+	case http.StatusNotFound:
+		sentinel = fs.ErrNotExist
+	case http.StatusForbidden:
+		sentinel = fs.ErrPermission
+	case http.StatusConflict:
+		sentinel = fs.ErrExist
+	case http.StatusBadRequest:
+		sentinel = fs.ErrInvalid
+	default:
+		return fmt.Errorf("%s: %s", http.StatusText(code), msg)
+	}
+	return fmt.Errorf("%s: %w", msg, sentinel)
+}
+
+func (b *remoteBackend) propfind(path, depth string) ([]entry, error) {
+	resp, err := b.do("PROPFIND", path, "", nil, http.Header{"Depth": {depth}})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var listing []memfs.HTTPEntry
+	if err = json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, err
+	}
+	entries := make([]entry, len(listing))
+	for i, e := range listing {
+		entries[i] = entry{Name: e.Name, Size: e.Size, Mode: e.Mode, ModTime: e.ModTime, IsDir: e.IsDir}
+	}
+	return entries, nil
+}
+
+func (b *remoteBackend) Stat(path string) (entry, error) {
+	entries, err := b.propfind(path, "0")
+	if err != nil {
+		return entry{}, err
+	}
+	if len(entries) != 1 {
+		return entry{}, errors.New("unexpected listing from server")
+	}
+	return entries[0], nil
+}
+
+func (b *remoteBackend) ReadDir(path string) ([]entry, error) {
+	return b.propfind(path, "1")
+}
+
+func (b *remoteBackend) ReadFile(path string) ([]byte, error) {
+	resp, err := b.do(http.MethodGet, path, "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	return io.ReadAll(resp.Body)
+}
+
+func (b *remoteBackend) WriteFile(path string, data []byte) error {
+	resp, err := b.do(http.MethodPut, path, "", bytes.NewReader(data), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (b *remoteBackend) Mkdir(path string) error {
+	resp, err := b.do("MKCOL", path, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (b *remoteBackend) Remove(path string, recursive bool) error {
+	query := ""
+	if recursive {
+		query = "recursive=true"
+	}
+	resp, err := b.do(http.MethodDelete, path, query, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,82 @@
+// Command memfs is an interactive shell for exploring memfs trees, either ones saved as
+// tar images by memfs.FS.ExportTar or live ones served over HTTP by memfs.FS.Handler.
+//
+//	memfs -image fixtures.tar
+//	memfs -url http://localhost:8080/
+//
+// Commands are read from standard input, so scripts can be piped in as well.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/mbordner/memfs"
+)
+
+func main() {
+	image := flag.String("image", "", "tar image to load, it is created by save when it does not exist")
+	serverURL := flag.String("url", "", "URL of a memfs HTTP server to attach to")
+	flag.Parse()
+
+	s, err := attach(*image, *serverURL)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "memfs: %v\n", err)
+		os.Exit(1)
+	}
+
+	fi, err := os.Stdin.Stat()
+	s.run(os.Stdin, err == nil && fi.Mode()&fs.ModeCharDevice != 0)
+}
+
+func attach(image, serverURL string) (*shell, error) {
+	if serverURL != "" {
+		if image != "" {
+			return nil, errors.New("only one of -image and -url can be used")
+		}
+		b, err := newRemoteBackend(serverURL)
+		if err != nil {
+			return nil, err
+		}
+		return newShell(b, os.Stdout), nil
+	}
+
+	mfs := memfs.New()
+	if image != "" {
+		if err := loadImage(mfs, image); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	s := newShell(&localBackend{fs: mfs}, os.Stdout)
+	s.image = image
+	s.save = func(image string) error {
+		return saveImage(mfs, image)
+	}
+	return s, nil
+}
+
+func loadImage(mfs *memfs.FS, image string) error {
+	f, err := os.Open(image)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return mfs.ImportTar(f, "/")
+}
+
+func saveImage(mfs *memfs.FS, image string) error {
+	f, err := os.Create(image)
+	if err != nil {
+		return err
+	}
+	err = mfs.ExportTar(f, "/")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// shell interprets commands against a backend, printing results to out.
+type shell struct {
+	backend backend
+	out     io.Writer
+	cwd     string
+	save    func(image string) error // nil when the backend cannot be saved
+	image   string
+}
+
+type command struct {
+	usage string
+	run   func(s *shell, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":   {"help", (*shell).help},
+		"pwd":    {"pwd", (*shell).pwd},
+		"cd":     {"cd <dir>", (*shell).cd},
+		"ls":     {"ls [path]", (*shell).ls},
+		"cat":    {"cat <file>...", (*shell).cat},
+		"stat":   {"stat <path>...", (*shell).stat},
+		"tree":   {"tree [path]", (*shell).tree},
+		"mkdir":  {"mkdir <dir>...", (*shell).mkdir},
+		"cp":     {"cp <src> <dst>", (*shell).cp},
+		"rm":     {"rm [-r] <path>...", (*shell).rm},
+		"import": {"import <host path> <path>", (*shell).importHost},
+		"export": {"export <path> <host path>", (*shell).exportHost},
+		"save":   {"save [image]", (*shell).saveImage},
+	}
+}
+
+func newShell(b backend, out io.Writer) *shell {
+	return &shell{backend: b, out: out, cwd: "/"}
+}
+
+// run reads commands from in until it is exhausted or exit is entered. When prompt
+// is set a prompt is printed before every command.
+func (s *shell) run(in io.Reader, prompt bool) {
+	scanner := bufio.NewScanner(in)
+	for {
+		if prompt {
+			_, _ = fmt.Fprintf(s.out, "memfs:%s> ", s.cwd)
+		}
+		if !scanner.Scan() {
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "exit" || line == "quit" {
+			return
+		}
+		if err := s.exec(line); err != nil {
+			_, _ = fmt.Fprintf(s.out, "error: %v\n", err)
+		}
+	}
+}
+
+func (s *shell) exec(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+	cmd, exists := commands[fields[0]]
+	if !exists {
+		return fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	return cmd.run(s, fields[1:])
+}
+
+func (s *shell) resolve(p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(s.cwd, p)
+	}
+	return path.Clean(p)
+}
+
+func (s *shell) printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(s.out, format, args...)
+}
+
+func usageError(name string) error {
+	return fmt.Errorf("usage: %s", commands[name].usage)
+}
+
+func (s *shell) help(args []string) error {
+	for _, name := range []string{"ls", "cd", "pwd", "cat", "stat", "tree", "mkdir", "cp", "rm", "import", "export", "save", "help"} {
+		s.printf("  %s\n", commands[name].usage)
+	}
+	s.printf("  exit\n")
+	return nil
+}
+
+func (s *shell) pwd(args []string) error {
+	s.printf("%s\n", s.cwd)
+	return nil
+}
+
+func (s *shell) cd(args []string) error {
+	if len(args) != 1 {
+		return usageError("cd")
+	}
+	dir := s.resolve(args[0])
+	e, err := s.backend.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !e.IsDir {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+	s.cwd = dir
+	return nil
+}
+
+func (s *shell) ls(args []string) error {
+	if len(args) > 1 {
+		return usageError("ls")
+	}
+	p := s.cwd
+	if len(args) == 1 {
+		p = s.resolve(args[0])
+	}
+	e, err := s.backend.Stat(p)
+	if err != nil {
+		return err
+	}
+	entries := []entry{e}
+	if e.IsDir {
+		if entries, err = s.backend.ReadDir(p); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		s.printEntry(e, e.Name)
+	}
+	return nil
+}
+
+func (s *shell) printEntry(e entry, name string) {
+	mode := e.Mode
+	if e.IsDir {
+		mode |= fs.ModeDir
+		name += "/"
+	}
+	s.printf("%s %8d %s %s\n", mode, e.Size, e.ModTime.Format("2006-01-02 15:04:05"), name)
+}
+
+func (s *shell) cat(args []string) error {
+	if len(args) == 0 {
+		return usageError("cat")
+	}
+	for _, p := range args {
+		data, err := s.backend.ReadFile(s.resolve(p))
+		if err != nil {
+			return err
+		}
+		_, _ = s.out.Write(data)
+	}
+	return nil
+}
+
+func (s *shell) stat(args []string) error {
+	if len(args) == 0 {
+		return usageError("stat")
+	}
+	for _, p := range args {
+		p = s.resolve(p)
+		e, err := s.backend.Stat(p)
+		if err != nil {
+			return err
+		}
+		kind := "regular file"
+		if e.IsDir {
+			kind = "directory"
+		}
+		s.printf("  Path: %s\n  Type: %s\n  Size: %d\n  Mode: %s\nModify: %s\n", p, kind, e.Size, e.Mode, e.ModTime)
+	}
+	return nil
+}
+
+func (s *shell) tree(args []string) error {
+	if len(args) > 1 {
+		return usageError("tree")
+	}
+	p := s.cwd
+	if len(args) == 1 {
+		p = s.resolve(args[0])
+	}
+	s.printf("%s\n", p)
+	return s.printTree(p, "")
+}
+
+func (s *shell) printTree(dir, indent string) error {
+	entries, err := s.backend.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		branch, next := "├── ", "│   "
+		if i == len(entries)-1 {
+			branch, next = "└── ", "    "
+		}
+		name := e.Name
+		if e.IsDir {
+			name += "/"
+		}
+		s.printf("%s%s%s\n", indent, branch, name)
+		if e.IsDir {
+			if err = s.printTree(path.Join(dir, e.Name), indent+next); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *shell) mkdir(args []string) error {
+	if len(args) == 0 {
+		return usageError("mkdir")
+	}
+	for _, p := range args {
+		if err := s.backend.Mkdir(s.resolve(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *shell) cp(args []string) error {
+	if len(args) != 2 {
+		return usageError("cp")
+	}
+	src, dst := s.resolve(args[0]), s.resolve(args[1])
+	if e, err := s.backend.Stat(dst); err == nil && e.IsDir {
+		dst = path.Join(dst, path.Base(src))
+	}
+	data, err := s.backend.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return s.backend.WriteFile(dst, data)
+}
+
+func (s *shell) rm(args []string) error {
+	recursive := len(args) > 0 && args[0] == "-r"
+	if recursive {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return usageError("rm")
+	}
+	for _, p := range args {
+		if err := s.backend.Remove(s.resolve(p), recursive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importHost copies a file or directory tree from the host filesystem into the tree.
+func (s *shell) importHost(args []string) error {
+	if len(args) != 2 {
+		return usageError("import")
+	}
+	hostRoot, root := args[0], s.resolve(args[1])
+	return filepath.WalkDir(hostRoot, func(hostPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(hostRoot, hostPath)
+		if err != nil {
+			return err
+		}
+		target := path.Join(root, filepath.ToSlash(rel))
+		if d.IsDir() {
+			if err = s.backend.Mkdir(target); err != nil && !errors.Is(err, fs.ErrExist) {
+				return err
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(hostPath)
+		if err != nil {
+			return err
+		}
+		return s.backend.WriteFile(target, data)
+	})
+}
+
+// exportHost copies a file or directory tree from the tree to the host filesystem.
+func (s *shell) exportHost(args []string) error {
+	if len(args) != 2 {
+		return usageError("export")
+	}
+	return s.export(s.resolve(args[0]), args[1])
+}
+
+func (s *shell) export(p, hostPath string) error {
+	e, err := s.backend.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !e.IsDir {
+		data, err := s.backend.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(hostPath, data, e.Mode.Perm())
+	}
+	if err = os.MkdirAll(hostPath, 0755); err != nil {
+		return err
+	}
+	entries, err := s.backend.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	for _, child := range entries {
+		if err = s.export(path.Join(p, child.Name), filepath.Join(hostPath, child.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *shell) saveImage(args []string) error {
+	if len(args) > 1 {
+		return usageError("save")
+	}
+	if s.save == nil {
+		return errors.New("save is only supported for images")
+	}
+	image := s.image
+	if len(args) == 1 {
+		image = args[0]
+	}
+	if image == "" {
+		return errors.New("no image to save to, give one as an argument")
+	}
+	if err := s.save(image); err != nil {
+		return err
+	}
+	s.image = image
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mbordner/memfs"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const script = `
+mkdir /data
+cd /data
+import %s in
+ls in
+cat in/hello.txt
+cp in/hello.txt copy.txt
+stat copy.txt
+tree /data
+rm in
+rm -r in
+ls
+export /data/copy.txt %s
+bogus
+`
+
+func runScript(t *testing.T, b backend) string {
+	hostDir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(hostDir, "in"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(hostDir, "in", "hello.txt"), []byte("hello\n"), 0644))
+
+	var out strings.Builder
+	s := newShell(b, &out)
+	s.run(strings.NewReader(fmt.Sprintf(script, filepath.Join(hostDir, "in"), filepath.Join(hostDir, "out.txt"))), false)
+
+	exported, err := os.ReadFile(filepath.Join(hostDir, "out.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", string(exported))
+
+	return out.String()
+}
+
+func checkOutput(t *testing.T, out string) {
+	assert.Contains(t, out, "hello.txt\n")
+	assert.Contains(t, out, "hello\n")
+	assert.Contains(t, out, "  Path: /data/copy.txt\n  Type: regular file\n  Size: 6\n")
+	assert.Contains(t, out, "/data\n├── copy.txt\n└── in/\n    └── hello.txt\n")
+	assert.Contains(t, out, `error: unknown command "bogus", try help`)
+	assert.Equal(t, 2, strings.Count(out, "error: "))
+}
+
+func Test_Shell_Local(t *testing.T) {
+	out := runScript(t, &localBackend{fs: memfs.New()})
+	checkOutput(t, out)
+}
+
+func Test_Shell_Remote(t *testing.T) {
+	server := httptest.NewServer(memfs.New().Handler())
+	defer server.Close()
+
+	b, err := newRemoteBackend(server.URL)
+	assert.Nil(t, err)
+	out := runScript(t, b)
+	checkOutput(t, out)
+}
+
+func Test_Shell_Image(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "image.tar")
+
+	s, err := attach(image, "")
+	assert.Nil(t, err)
+	var out strings.Builder
+	s.out = &out
+	s.run(strings.NewReader("mkdir /saved\nsave\n"), false)
+	assert.Equal(t, "", out.String())
+
+	s, err = attach(image, "")
+	assert.Nil(t, err)
+	s.out = &out
+	s.run(strings.NewReader("ls /saved\nstat /saved\n"), false)
+	assert.Contains(t, out.String(), "Type: directory")
+
+	_, err = attach(image, "http://localhost")
+	assert.NotNil(t, err)
+}
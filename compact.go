@@ -0,0 +1,78 @@
+package memfs
+
+// CompactStats describes memory held by the filesystem that Compact can release.
+type CompactStats struct {
+	UnlinkedBytes int64 // content of removed files that was still mapped when removed
+	SlackBytes    int64 // buffer capacity allocated beyond the length of file content
+	Directories   int   // directories whose entry tables can be trimmed after removals
+}
+
+// Bytes returns the total number of reclaimable content bytes.
+func (s CompactStats) Bytes() int64 {
+	return s.UnlinkedBytes + s.SlackBytes
+}
+
+// Reclaimable reports how much memory Compact would currently release.
+func (f *FS) Reclaimable() CompactStats {
+	return f.compact(false)
+}
+
+// Compact releases memory held by removed files that are still open, shrinks over allocated
+// content buffers and rebuilds directory entry tables that entries were removed from. It
+// returns what was reclaimed. Handles to removed files cannot be read from, so releasing
+// their content is not observable.
+func (f *FS) Compact() CompactStats {
+	return f.compact(true)
+}
+
+func (f *FS) compact(reclaim bool) CompactStats {
+	var stats CompactStats
+
+	for _, file := range f.openFiles() {
+		n := file.node
+		if n.isDir() {
+			continue
+		}
+		n.lockContent()
+		if n.unlinked && n.content != nil && n.mapped == 0 {
+			stats.UnlinkedBytes += int64(cap(n.content))
+			if reclaim {
+				n.setContent(nil)
+			}
+		}
+		n.unlockContent()
+	}
+
+	f.root.compact(reclaim, &stats)
+
+	return stats
+}
+
+func (f *fsNode) compact(reclaim bool, stats *CompactStats) {
+	f.lock()
+	if !f.isDir() {
+		if f.blob == nil && f.mapped == 0 && cap(f.content) > len(f.content) {
+			stats.SlackBytes += int64(cap(f.content) - len(f.content))
+			if reclaim {
+				c := make([]byte, len(f.content))
+				copy(c, f.content)
+				f.content = c
+			}
+		}
+		f.unlock()
+		return
+	}
+
+	if f.entries.removed > 0 {
+		stats.Directories++
+		if reclaim {
+			f.entries.compact()
+		}
+	}
+	children := f.entries.list()
+	f.unlock()
+
+	for _, e := range children {
+		e.compact(reclaim, stats)
+	}
+}
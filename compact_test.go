@@ -0,0 +1,54 @@
+package memfs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Compact(t *testing.T) {
+	mfs := New()
+
+	assert.Equal(t, CompactStats{}, mfs.Reclaimable())
+
+	f, err := mfs.Create("/removed")
+	assert.Nil(t, err)
+	_, err = f.Write(make([]byte, 100))
+	assert.Nil(t, err)
+	_, err = f.Map()
+	assert.Nil(t, err)
+
+	assert.Nil(t, mfs.Mkdir("/dir", 0777))
+	for _, name := range []string{"/dir/a", "/dir/b"} {
+		nf, err := mfs.Create(name)
+		assert.Nil(t, err)
+		assert.Nil(t, nf.Close())
+	}
+	assert.Nil(t, mfs.Remove("/dir/a"))
+
+	_, slackNode, _, err := mfs.getEntry("/dir/b")
+	assert.Nil(t, err)
+	slackNode.content = make([]byte, 10, 64)
+
+	assert.Nil(t, mfs.Remove("/removed"))
+	assert.Nil(t, f.Unmap())
+
+	stats := mfs.Reclaimable()
+	assert.Equal(t, int64(100), stats.UnlinkedBytes)
+	assert.Equal(t, int64(54), stats.SlackBytes)
+	assert.Equal(t, int64(154), stats.Bytes())
+	assert.Equal(t, 2, stats.Directories)
+
+	assert.Equal(t, stats, mfs.Compact())
+	assert.Equal(t, CompactStats{}, mfs.Reclaimable())
+
+	assert.Len(t, slackNode.content, 10)
+	assert.Equal(t, 10, cap(slackNode.content))
+
+	entries, err := mfs.ReadDir("/dir")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	// once closed the removed file is no longer tracked
+	assert.Nil(t, f.Close())
+	assert.Equal(t, 0, len(mfs.openFiles()))
+}
@@ -0,0 +1,172 @@
+package memfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoding compresses files for a Content-Encoding of HTTP responses.
+type Encoding struct {
+	Name      string // the Content-Encoding, "gzip" or "br" for instance
+	Extension string // the extension of pre-compressed files, ".gz" or ".br" for instance
+
+	// NewWriter returns a writer compressing to w. When it is nil, only files compressed
+	// in advance, named with Extension after the name of the file, are served.
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+// GzipEncoding returns the gzip Encoding, compressing with the best compression.
+func GzipEncoding() Encoding {
+	return Encoding{
+		Name:      "gzip",
+		Extension: ".gz",
+		NewWriter: func(w io.Writer) io.WriteCloser {
+			zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+			return zw
+		},
+	}
+}
+
+// WithPrecompression makes ServeFile and the HTTP handler serve files compressed with the
+// first of encodings accepted by the request. A file compressed in advance, with the name
+// of the file followed by the extension of the encoding, is served when there is one,
+// otherwise the file is compressed the first time it is requested, and again when its
+// content changed since. Brotli has no encoder in the standard library, an Encoding
+// wrapping one can be given, or one without NewWriter to serve ".br" files only.
+func WithPrecompression(encodings ...Encoding) Option {
+	return func(f *FS) {
+		f.precompressor = &precompressor{
+			encodings: encodings,
+			variants:  make(map[variantKey]variant),
+		}
+	}
+}
+
+// precompressor holds the compressed variants of the files served.
+type precompressor struct {
+	encodings []Encoding
+	mutex     sync.Mutex
+	variants  map[variantKey]variant
+}
+
+type variantKey struct {
+	name     string
+	encoding string
+}
+
+// variant is the content of a file compressed with an encoding.
+type variant struct {
+	sum  [sha256.Size]byte // hash of the uncompressed content
+	data []byte
+}
+
+// negotiate returns the encodings accepted by the Accept-Encoding header, in order of preference.
+func (p *precompressor) negotiate(header string) []Encoding {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[name] = q > 0
+	}
+	var encodings []Encoding
+	for _, e := range p.encodings {
+		if ok, listed := accepted[e.Name]; ok || (!listed && accepted["*"]) {
+			encodings = append(encodings, e)
+		}
+	}
+	return encodings
+}
+
+// compressed returns the content of the file at name compressed with e, compressing it
+// again when the content changed since it was last compressed.
+func (p *precompressor) compressed(n *fsNode, name string, e Encoding) []byte {
+	sum := n.contentHash()
+	key := variantKey{name: name, encoding: e.Name}
+	p.mutex.Lock()
+	v, exists := p.variants[key]
+	p.mutex.Unlock()
+	if exists && v.sum == sum {
+		return v.data
+	}
+
+	var buf bytes.Buffer
+	w := e.NewWriter(&buf)
+	n.lockContent()
+	_, _ = w.Write(n.getContent())
+	n.unlockContent()
+	_ = w.Close()
+
+	p.mutex.Lock()
+	p.variants[key] = variant{sum: sum, data: buf.Bytes()}
+	p.mutex.Unlock()
+	return buf.Bytes()
+}
+
+// serveCompressed replies with the file compressed with an encoding accepted by the
+// request, it reports false when there is none and the file must be served as is.
+func (f *FS) serveCompressed(w http.ResponseWriter, r *http.Request, file *File, name string) bool {
+	p := f.baseFS().precompressor
+	if p == nil {
+		return false
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	encodings := p.negotiate(r.Header.Get("Accept-Encoding"))
+	if len(encodings) == 0 {
+		return false
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		writeHTTPError(w, err)
+		return true
+	}
+	var (
+		e       Encoding
+		content io.ReadSeeker
+	)
+	for _, e = range encodings {
+		if pre, err := f.Open(name + e.Extension); err == nil {
+			defer pre.Close()
+			if !pre.isDir() {
+				w.Header().Set("ETag", fmt.Sprintf(`"%x-%s"`, pre.node.contentHash(), e.Name))
+				content = pre
+				break
+			}
+		}
+		if e.NewWriter != nil {
+			w.Header().Set("ETag", fmt.Sprintf(`"%x-%s"`, file.node.contentHash(), e.Name))
+			content = bytes.NewReader(p.compressed(file.node, f.getAbsolutePath(name), e))
+			break
+		}
+	}
+	if content == nil {
+		return false
+	}
+
+	// the type is that of the uncompressed content, which ServeContent can't sniff
+	ctype := mime.TypeByExtension(path.Ext(fi.Name()))
+	if ctype == "" {
+		var sniff [512]byte
+		n, _ := file.ReadAt(sniff[:], 0)
+		ctype = http.DetectContentType(sniff[:n])
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Encoding", e.Name)
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), content)
+	return true
+}
@@ -0,0 +1,67 @@
+package memfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func gunzip(t *testing.T, data []byte) string {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	assert.Nil(t, err)
+	content, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	return string(content)
+}
+
+func Test_Precompression(t *testing.T) {
+	br := Encoding{Name: "br", Extension: ".br"}
+	mfs := New(WithPrecompression(br, GzipEncoding()))
+	h := mfs.Handler()
+	css := strings.Repeat("body { color: red; }\n", 100)
+	doRequest(t, h, http.MethodPut, "/site.css", strings.NewReader(css), nil)
+	doRequest(t, h, http.MethodPut, "/page", strings.NewReader("<html><p>hi</p></html>"), nil)
+
+	w := doRequest(t, h, http.MethodGet, "/site.css", nil, map[string]string{"Accept-Encoding": "gzip, deflate"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasSuffix(w.Header().Get("ETag"), `-gzip"`))
+	assert.Less(t, w.Body.Len(), len(css))
+	assert.Equal(t, css, gunzip(t, w.Body.Bytes()))
+
+	etag := w.Header().Get("ETag")
+	w = doRequest(t, h, http.MethodGet, "/site.css", nil, map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// a modified file is compressed again
+	doRequest(t, h, http.MethodPut, "/site.css", strings.NewReader("p {}"), nil)
+	w = doRequest(t, h, http.MethodGet, "/site.css", nil, map[string]string{"Accept-Encoding": "gzip"})
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, "p {}", gunzip(t, w.Body.Bytes()))
+
+	// the content type of files without a known extension is sniffed from the uncompressed content
+	w = doRequest(t, h, http.MethodGet, "/page", nil, map[string]string{"Accept-Encoding": "*"})
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+	// files compressed in advance are served for encodings without a writer
+	doRequest(t, h, http.MethodPut, "/site.css.br", strings.NewReader("brotli bytes"), nil)
+	w = doRequest(t, h, http.MethodGet, "/site.css", nil, map[string]string{"Accept-Encoding": "gzip, br"})
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "brotli bytes", w.Body.String())
+
+	for _, accept := range []string{"", "identity", "gzip;q=0, br;q=0"} {
+		w = doRequest(t, h, http.MethodGet, "/site.css", nil, map[string]string{"Accept-Encoding": accept})
+		assert.Equal(t, "", w.Header().Get("Content-Encoding"), accept)
+		assert.Equal(t, "p {}", w.Body.String(), accept)
+	}
+
+	w = doRequest(t, New().Handler(), http.MethodGet, "/tmp", nil, map[string]string{"Accept-Encoding": "gzip"})
+	assert.Equal(t, "", w.Header().Get("Vary"))
+}
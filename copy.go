@@ -0,0 +1,187 @@
+package memfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"syscall"
+)
+
+// ReadFrom implements io.ReaderFrom, so io.Copy between two memfs files doesn't stream
+// through a buffer. A copy of a whole file into an empty one shares the content until
+// either file is modified, any other copy is a single copy of the bytes remaining in src.
+func (f *File) ReadFrom(r io.Reader) (n int64, err error) {
+	defer f.fs.mapErrno(&err)
+	src, ok := r.(*File)
+	if !ok || src.isDir() || !src.flag.canRead() || src.closed || src.node.unlinked || src.device != nil || f.device != nil ||
+		src.staged != nil || f.staged != nil {
+		// let the source report its errors, without looking for a ReaderFrom again
+		return io.Copy(struct{ io.Writer }{f}, r)
+	}
+	if f.node.unlinked {
+		return 0, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+	if f.isDir() {
+		return 0, fmt.Errorf("is a directory: %s: %w", f.Name(), syscall.EISDIR)
+	}
+	if !f.flag.canWrite() {
+		return 0, fmt.Errorf("cannot write: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+	if f.closed {
+		return 0, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
+	}
+	if err = f.checkDeadline(f.writeDeadline.Load()); err != nil {
+		return 0, err
+	}
+	if err = src.checkDeadline(src.readDeadline.Load()); err != nil {
+		return 0, err
+	}
+
+	// the source content is taken as a shared blob, so it can be read without holding
+	// the source lock while the destination lock is held
+	src.node.lockContent()
+	srcPos := src.crws.pos
+	if srcPos >= src.node.size() {
+		src.node.unlockContent()
+		return 0, nil
+	}
+	b := src.node.sharedContent()
+	content := b.data
+	src.crws.pos = len(content)
+	src.node.unlockContent()
+	src.fs.accessed(src.node)
+	f.fs.written(f.node)
+
+	f.node.lockContent()
+	if f.flag.isAppend() {
+		f.crws.pos = f.node.size()
+	}
+	// a mapped destination keeps its content in place, the bytes are copied into it
+	if srcPos == 0 && f.crws.pos == 0 && f.node.size() <= len(content) && f.node.mapped == 0 {
+		if f.node.blob == nil {
+			putBuffer(f.node.content)
+		}
+		f.node.setContent(content)
+		f.node.blob = b
+		f.crws.pos = len(content)
+		f.crws.ranges.add(0, int64(len(content)))
+		f.node.unlockContent()
+		return int64(len(content)), nil
+	}
+	written, _ := f.crws.write(content[srcPos:])
+	f.node.unlockContent()
+	b.release()
+	return int64(written), nil
+}
+
+// Copy copies the file at src to dst, creating dst with the permissions of src or
+// truncating it. The content is shared by both files until either is modified.
+func (f *FS) Copy(src, dst string) error {
+	in, err := f.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if in.isDir() {
+		return fmt.Errorf("is a directory: %s: %w", src, syscall.EISDIR)
+	}
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := f.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = out.ReadFrom(in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// CopyRange copies n bytes of src from srcOff to dst at dstOff, like copy_file_range,
+// without moving the offsets of either file. It returns the number of bytes copied, fewer
+// than n when src ends first. A range covering the whole of src, copied to the start of a
+// file no longer than src, is shared by both files until either is modified. The ranges
+// must not overlap when src and dst are the same file.
+func (f *FS) CopyRange(dst *File, dstOff int64, src *File, srcOff int64, n int64) (int64, error) {
+	if dstOff < 0 || srcOff < 0 || n < 0 {
+		return 0, fmt.Errorf("negative offset or length: %w", fs.ErrInvalid)
+	}
+	for _, file := range []*File{src, dst} {
+		if file.node.unlinked {
+			return 0, fmt.Errorf("file unlinked: %s: %w", file.Name(), fs.ErrInvalid)
+		}
+		if file.isDir() {
+			return 0, fmt.Errorf("is a directory: %s: %w", file.Name(), syscall.EISDIR)
+		}
+		if file.closed {
+			return 0, fmt.Errorf("file closed: %s: %w", file.Name(), fs.ErrClosed)
+		}
+		if file.device != nil {
+			return 0, fmt.Errorf("cannot copy a range of a device: %s: %w", file.Name(), syscall.EINVAL)
+		}
+	}
+	if !src.flag.canRead() {
+		return 0, fmt.Errorf("cannot read: %s: %w", src.Name(), fs.ErrInvalid)
+	}
+	if !dst.flag.canWrite() {
+		return 0, fmt.Errorf("cannot write: %s: %w", dst.Name(), fs.ErrInvalid)
+	}
+	if dst.flag.isAppend() {
+		return 0, fmt.Errorf("append only file %s: %w", dst.Name(), fs.ErrInvalid)
+	}
+	// the ranges are compared by the distance between them, as n can be near the largest
+	// int64 like the lengths passed to copy_file_range
+	if src.node == dst.node && srcOff-dstOff < n && dstOff-srcOff < n {
+		return 0, fmt.Errorf("overlapping ranges: %s: %w", src.Name(), fs.ErrInvalid)
+	}
+	if err := src.checkDeadline(src.readDeadline.Load()); err != nil {
+		return 0, err
+	}
+	if err := dst.checkDeadline(dst.writeDeadline.Load()); err != nil {
+		return 0, err
+	}
+
+	if src.staged != nil || dst.staged != nil {
+		// the staged content is copied through the handles
+		return io.Copy(io.NewOffsetWriter(dst, dstOff), io.NewSectionReader(src, srcOff, n))
+	}
+
+	// as in ReadFrom, the source content is taken as a shared blob
+	src.node.lockContent()
+	if srcOff >= int64(src.node.size()) || n == 0 {
+		src.node.unlockContent()
+		return 0, nil
+	}
+	b := src.node.sharedContent()
+	content := b.data
+	src.node.unlockContent()
+	src.fs.accessed(src.node)
+	dst.fs.written(dst.node)
+
+	n = min(n, int64(len(content))-srcOff, math.MaxInt-dstOff)
+	end := srcOff + n
+	dst.node.lockContent()
+	// a mapped destination keeps its content in place, the bytes are copied into it
+	if srcOff == 0 && dstOff == 0 && end == int64(len(content)) && dst.node.size() <= len(content) && dst.node.mapped == 0 {
+		if dst.node.blob == nil {
+			putBuffer(dst.node.content)
+		}
+		dst.node.setContent(content)
+		dst.node.blob = b
+		dst.crws.ranges.add(0, end)
+		dst.node.unlockContent()
+		return end, nil
+	}
+	pos := dst.crws.pos
+	dst.crws.pos = int(dstOff)
+	written, _ := dst.crws.write(content[srcOff:end])
+	dst.crws.pos = pos
+	dst.node.unlockContent()
+	b.release()
+	return int64(written), nil
+}
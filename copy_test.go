@@ -0,0 +1,216 @@
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func readAll(t *testing.T, mfs *FS, path string) string {
+	f, err := mfs.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func Test_Copy_Shares_Content(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	mfs, err := NewFromMap(map[string][]byte{"/src": data})
+	assert.Nil(t, err)
+
+	src, err := mfs.Open("/src")
+	assert.Nil(t, err)
+	dst, err := mfs.Create("/dst")
+	assert.Nil(t, err)
+	n, err := io.Copy(dst, src)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Same(t, &src.node.content[0], &dst.node.content[0])
+
+	// both reads are at their end
+	n, err = io.Copy(dst, src)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+
+	// modifying the copy leaves the source untouched
+	_, err = dst.WriteAt([]byte("x"), 0)
+	assert.Nil(t, err)
+	assert.Nil(t, dst.Close())
+	assert.Nil(t, src.Close())
+	assert.Equal(t, "x"+string(data[1:]), readAll(t, mfs, "/dst"))
+	assert.Equal(t, string(data), readAll(t, mfs, "/src"))
+
+	// a mapped file keeps its content, the source is copied into it
+	mapped, err := mfs.OpenFile("/dst", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	m, err := mapped.Map()
+	assert.Nil(t, err)
+	src, err = mfs.Open("/src")
+	assert.Nil(t, err)
+	_, err = io.Copy(mapped, src)
+	assert.Nil(t, err)
+	assert.Equal(t, data, m)
+	assert.NotSame(t, &src.node.content[0], &mapped.node.content[0])
+	assert.Nil(t, src.Close())
+	assert.Nil(t, mapped.Close())
+}
+
+func Test_Copy_Partial(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/src": []byte("hello world"), "/dst": []byte("0123456789abcdef")})
+	assert.Nil(t, err)
+
+	src, err := mfs.Open("/src")
+	assert.Nil(t, err)
+	_, err = src.Seek(6, io.SeekStart)
+	assert.Nil(t, err)
+	dst, err := mfs.OpenFile("/dst", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	_, err = dst.Seek(2, io.SeekStart)
+	assert.Nil(t, err)
+	n, err := io.Copy(dst, src)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Nil(t, dst.Close())
+	assert.Equal(t, "01world789abcdef", readAll(t, mfs, "/dst"))
+
+	// appending copies after the end
+	_, err = src.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	dst, err = mfs.OpenFile("/dst", os.O_WRONLY|os.O_APPEND, 0)
+	assert.Nil(t, err)
+	_, err = io.Copy(dst, src)
+	assert.Nil(t, err)
+	assert.Nil(t, dst.Close())
+	assert.Equal(t, "01world789abcdefhello world", readAll(t, mfs, "/dst"))
+
+	// other readers are streamed
+	dst, err = mfs.Create("/dst")
+	assert.Nil(t, err)
+	n, err = io.Copy(dst, strings.NewReader("plain"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Nil(t, dst.Close())
+	assert.Equal(t, "plain", readAll(t, mfs, "/dst"))
+
+	// errors of the source are reported
+	assert.Nil(t, src.Close())
+	dst, err = mfs.Create("/dst")
+	assert.Nil(t, err)
+	_, err = io.Copy(dst, src)
+	assert.True(t, errors.Is(err, os.ErrClosed))
+	assert.Nil(t, dst.Close())
+}
+
+func Test_FS_Copy(t *testing.T) {
+	mfs, err := NewFromEntries(map[string]Entry{
+		"/src":  {Data: []byte("data"), Mode: 0640},
+		"/long": {Data: []byte("longer data")},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.Copy("/src", "/dst"))
+	assert.Equal(t, "data", readAll(t, mfs, "/dst"))
+	fi, err := mfs.Stat("/dst")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode())
+
+	// a shorter source replaces the whole content
+	assert.Nil(t, mfs.Copy("/long", "/dst"))
+	assert.Nil(t, mfs.Copy("/src", "/dst"))
+	assert.Equal(t, "data", readAll(t, mfs, "/dst"))
+	assert.True(t, errors.Is(mfs.Copy("/missing", "/dst"), os.ErrNotExist))
+	assert.True(t, errors.Is(mfs.Copy("/tmp", "/dst"), syscall.EISDIR))
+}
+
+func Test_CopyRange(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/src": []byte("0123456789"), "/dst": []byte("abcdef")})
+	assert.Nil(t, err)
+	src, err := mfs.Open("/src")
+	assert.Nil(t, err)
+	defer src.Close()
+	dst, err := mfs.OpenFile("/dst", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	defer dst.Close()
+
+	n, err := mfs.CopyRange(dst, 2, src, 4, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, "ab456f", readAll(t, mfs, "/dst"))
+
+	// short at the end of src, zero filled past the end of dst
+	n, err = mfs.CopyRange(dst, 8, src, 8, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, "ab456f\x00\x0089", readAll(t, mfs, "/dst"))
+	n, err = mfs.CopyRange(dst, 0, src, 10, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+
+	// the offsets of the files don't move
+	pos, err := dst.Seek(0, io.SeekCurrent)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), pos)
+
+	// the whole of src to an empty file is shared
+	empty, err := mfs.Create("/empty")
+	assert.Nil(t, err)
+	n, err = mfs.CopyRange(empty, 0, src, 0, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), n)
+	assert.Same(t, src.node.blob, empty.node.blob)
+	assert.Nil(t, empty.Close())
+
+	// lengths up to the largest int64 copy up to the end of src
+	n, err = mfs.CopyRange(dst, 0, src, 1, math.MaxInt64)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(9), n)
+	assert.Equal(t, "1234567899", readAll(t, mfs, "/dst"))
+	n, err = mfs.CopyRange(dst, 0, src, 8, math.MaxInt64)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	_, err = mfs.CopyRange(dst, 1, dst, 0, math.MaxInt64)
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+	assert.Nil(t, mfs.WriteFile("/dst", []byte("ab456f\x00\x0089"), 0644))
+
+	// a mapped file keeps its content, the whole of src is copied into it
+	mapped, err := mfs.OpenFile("/empty", os.O_RDWR|os.O_TRUNC, 0)
+	assert.Nil(t, err)
+	_, err = mapped.Write([]byte("abcdefghij"))
+	assert.Nil(t, err)
+	m, err := mapped.Map()
+	assert.Nil(t, err)
+	n, err = mfs.CopyRange(mapped, 0, src, 0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), n)
+	assert.Equal(t, "0123456789", string(m))
+	assert.Nil(t, mapped.Close())
+
+	// within a file, when the ranges don't overlap
+	n, err = mfs.CopyRange(dst, 0, dst, 8, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, "89456f\x00\x0089", readAll(t, mfs, "/dst"))
+	_, err = mfs.CopyRange(dst, 0, dst, 1, 2)
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	_, err = mfs.CopyRange(src, 0, dst, 0, 1)
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+	_, err = mfs.CopyRange(dst, -1, src, 0, 1)
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+	dir, err := mfs.Open("/tmp")
+	assert.Nil(t, err)
+	_, err = mfs.CopyRange(dst, 0, dir, 0, 1)
+	assert.True(t, errors.Is(err, syscall.EISDIR))
+	assert.Nil(t, dir.Close())
+	assert.Nil(t, src.Close())
+	_, err = mfs.CopyRange(dst, 0, src, 0, 1)
+	assert.True(t, errors.Is(err, fs.ErrClosed))
+}
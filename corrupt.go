@@ -0,0 +1,115 @@
+package memfs
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+)
+
+// ErrCorrupted is wrapped by the errors of opening files whose content doesn't match the
+// checksum recorded WithVerifyOnRead.
+var ErrCorrupted = errors.New("content corrupted")
+
+// WithVerifyOnRead makes the filesystem record the checksum of files when a handle that
+// can write them is closed, and check the content of files opened for reading against
+// it, so damage done by Corrupt surfaces as errors wrapping ErrCorrupted. Files open for
+// writing are not checked until closed.
+func WithVerifyOnRead() Option {
+	return func(f *FS) {
+		f.verifyOnRead = true
+	}
+}
+
+// Corruption is the damage Corrupt does to files.
+type Corruption struct {
+	Pattern     string  // path.Match pattern of the absolute paths, with "/" separators, of the files
+	Seed        int64   // of the random choices, the same seed damages the same files the same way
+	Probability float64 // of each file matching the pattern being damaged, all of them when 0
+	FlipBytes   int     // number of bytes of each file flipped
+	Truncate    bool    // truncate the files at a random size, before flipping bytes
+}
+
+// Corrupt damages the files matching c.Pattern, like a failing disk would, without changing
+// their modification time nor the checksums recorded WithVerifyOnRead, and returns their
+// paths in path order. Integrity checks of the code under test can then be exercised.
+func (f *FS) Corrupt(c Corruption) ([]string, error) {
+	if _, err := path.Match(c.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %s: %w", c.Pattern, err)
+	}
+	paths := make(map[string]*fsNode)
+	var matched []string
+	for n, p := range f.nodePaths() {
+		if n.isDir() || n.device() != nil || n.target() != n {
+			continue
+		}
+		if ok, _ := path.Match(c.Pattern, p); ok {
+			paths[p] = n
+			matched = append(matched, p)
+		}
+	}
+	// sorted, so the seed makes the same choices
+	sort.Strings(matched)
+
+	r := rand.New(rand.NewSource(c.Seed))
+	var corrupted []string
+	for _, p := range matched {
+		if c.Probability > 0 && r.Float64() >= c.Probability {
+			continue
+		}
+		n := paths[p]
+		n.lockContent()
+		if c.Truncate && n.size() > 0 {
+			n.truncateContent(r.Intn(n.size()))
+		}
+		if c.FlipBytes > 0 && n.size() > 0 {
+			content := n.getMutableContent()
+			for i := 0; i < c.FlipBytes; i++ {
+				content[r.Intn(len(content))] ^= byte(1 + r.Intn(255))
+			}
+		}
+		n.unlockContent()
+		corrupted = append(corrupted, p)
+	}
+	return corrupted, nil
+}
+
+// storeChecksum records the checksum of the content of the file n WithVerifyOnRead.
+func (f *FS) storeChecksum(n *fsNode) {
+	if !f.baseFS().verifyOnRead || n.isDir() {
+		return
+	}
+	sum := n.contentHash()
+	e := n.extension()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.sum, e.summed = sum, true
+}
+
+// verifyOpen checks the content of the file n, opened at path for reading, against its
+// recorded checksum. The checksum of files opened for writing is dropped, it is recorded
+// again when they are closed.
+func (f *FS) verifyOpen(path string, n *fsNode, flag fileFlags) error {
+	if !f.baseFS().verifyOnRead || n.isDir() {
+		return nil
+	}
+	var sum [sha256.Size]byte
+	var recorded bool
+	if e := n.getExt(); e != nil {
+		e.mutex.Lock()
+		sum, recorded = e.sum, e.summed
+		if flag.canWrite() {
+			e.summed = false
+		}
+		e.mutex.Unlock()
+	}
+	if !recorded || flag.canWrite() {
+		return nil
+	}
+	if n.contentHash() != sum {
+		return fmt.Errorf("checksum mismatch: %s: %w", path, ErrCorrupted)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func Test_Corrupt(t *testing.T) {
+	files := map[string][]byte{
+		"/data/a.bin": []byte("0123456789"),
+		"/data/b.bin": []byte("abcdefghij"),
+		"/data/c.txt": []byte("untouched"),
+	}
+	mfs, err := NewFromMap(files, WithVerifyOnRead())
+	assert.Nil(t, err)
+
+	corrupted, err := mfs.Corrupt(Corruption{Pattern: "/data/*.bin", Seed: 1, FlipBytes: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/data/a.bin", "/data/b.bin"}, corrupted)
+	for _, p := range corrupted {
+		_, err = mfs.Open(p)
+		assert.True(t, errors.Is(err, ErrCorrupted))
+		fi, err := mfs.Stat(p)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), fi.Size())
+	}
+	assert.Equal(t, "untouched", readAll(t, mfs, "/data/c.txt"))
+
+	// rewriting a file records its checksum again
+	f, err := mfs.OpenFile("/data/a.bin", os.O_WRONLY|os.O_TRUNC, 0)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("fixed"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, "fixed", readAll(t, mfs, "/data/a.bin"))
+
+	// the same seed does the same damage
+	damaged := make([]string, 2)
+	for i := range damaged {
+		other, err := NewFromMap(files)
+		assert.Nil(t, err)
+		_, err = other.Corrupt(Corruption{Pattern: "/data/*.bin", Seed: 1, FlipBytes: 2})
+		assert.Nil(t, err)
+		damaged[i] = readAll(t, other, "/data/b.bin")
+	}
+	assert.NotEqual(t, "abcdefghij", damaged[0])
+	assert.Equal(t, damaged[0], damaged[1])
+
+	truncated, err := NewFromMap(files)
+	assert.Nil(t, err)
+	corrupted, err = truncated.Corrupt(Corruption{Pattern: "/data/c.*", Seed: 3, Truncate: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/data/c.txt"}, corrupted)
+	fi, err := truncated.Stat("/data/c.txt")
+	assert.Nil(t, err)
+	assert.Less(t, fi.Size(), int64(9))
+
+	_, err = mfs.Corrupt(Corruption{Pattern: "["})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,58 @@
+package memfs
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// SetDeadline sets the read and write deadlines of the file, like os.File.SetDeadline.
+func (f *File) SetDeadline(t time.Time) error {
+	if err := f.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return f.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the time after which reads fail with os.ErrDeadlineExceeded, a
+// zero time clears it. Reads never block, so the deadline is checked against the clock
+// of the filesystem when a read starts, which lets timeouts be tested with WithClock.
+func (f *File) SetReadDeadline(t time.Time) error {
+	if f.closed {
+		return fmt.Errorf("file closed: %s: %w", f.Name(), os.ErrClosed)
+	}
+	f.readDeadline.Store(deadlineNanos(t))
+	return nil
+}
+
+// SetWriteDeadline sets the time after which writes fail with os.ErrDeadlineExceeded, a
+// zero time clears it.
+func (f *File) SetWriteDeadline(t time.Time) error {
+	if f.closed {
+		return fmt.Errorf("file closed: %s: %w", f.Name(), os.ErrClosed)
+	}
+	f.writeDeadline.Store(deadlineNanos(t))
+	return nil
+}
+
+func deadlineNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// checkDeadline returns os.ErrDeadlineExceeded when the deadline, in nanoseconds, has passed.
+func (f *File) checkDeadline(deadline int64) error {
+	if deadline == 0 {
+		return nil
+	}
+	now := time.Now()
+	if f.fs != nil {
+		now = f.fs.peekNow()
+	}
+	if now.UnixNano() >= deadline {
+		return fmt.Errorf("i/o timeout: %s: %w", f.Name(), os.ErrDeadlineExceeded)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_Deadlines(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewStepClock(start, 0)
+	mfs := New(WithClock(clock))
+	f, err := mfs.OpenFile("/f", os.O_RDWR|os.O_CREATE, 0666)
+	assert.Nil(t, err)
+
+	assert.Nil(t, f.SetDeadline(start.Add(time.Second)))
+	_, err = f.Write([]byte("data"))
+	assert.Nil(t, err)
+
+	clock.Advance(time.Second)
+	_, err = f.Write([]byte("more"))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	_, err = f.WriteAt([]byte("more"), 0)
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+	_, err = f.ReadAt(make([]byte, 4), 0)
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+
+	// deadlines are set separately and cleared with a zero time
+	assert.Nil(t, f.SetReadDeadline(time.Time{}))
+	data := make([]byte, 4)
+	_, err = f.ReadAt(data, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+	_, err = f.Write([]byte("more"))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+
+	dst, err := mfs.Create("/g")
+	assert.Nil(t, err)
+	assert.Nil(t, dst.SetWriteDeadline(start))
+	_, err = f.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	_, err = io.Copy(dst, f)
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+
+	assert.Nil(t, f.SetDeadline(time.Time{}))
+	_, err = f.Write([]byte("more"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, f.Close())
+	assert.True(t, errors.Is(f.SetDeadline(start), os.ErrClosed))
+}
+
+func Test_Deadlines_Step_Clock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewStepClock(start, time.Second)
+	mfs := New(WithClock(clock))
+	f, err := mfs.OpenFile("/f", os.O_RDWR|os.O_CREATE, 0666)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	// checking the deadline doesn't advance the clock
+	assert.Nil(t, f.SetDeadline(start.Add(time.Hour)))
+	next := clock.Peek()
+	for i := 0; i < 10; i++ {
+		_, err = f.ReadAt(make([]byte, 1), 0)
+		assert.True(t, errors.Is(err, io.EOF))
+	}
+	assert.Equal(t, next, clock.Peek())
+}
@@ -0,0 +1,114 @@
+package memfs
+
+import (
+	"io"
+	"io/fs"
+)
+
+// deviceMode is the type of the device nodes, character devices like their OS counterparts.
+const deviceMode = fs.ModeDevice | fs.ModeCharDevice
+
+// WithDevices creates /dev/null, discarding writes and reading as empty, /dev/zero,
+// reading zeros, and /dev/urandom, reading bytes drawn from the random source of the
+// filesystem, set WithRandSource for reproducible reads. Writes to /dev/zero and
+// /dev/urandom are discarded as well.
+func WithDevices() Option {
+	return func(f *FS) {
+		f.special = append(f.special, (*FS).createDevices)
+	}
+}
+
+// device is the behavior of a device node, in place of content. Devices have no offset,
+// reads and writes at an offset behave as the others.
+type device interface {
+	read(p []byte) (int, error)
+	write(p []byte) (int, error)
+}
+
+type nullDevice struct{}
+
+func (nullDevice) read([]byte) (int, error)    { return 0, io.EOF }
+func (nullDevice) write(p []byte) (int, error) { return len(p), nil }
+
+type zeroDevice struct{}
+
+func (zeroDevice) read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func (zeroDevice) write(p []byte) (int, error) { return len(p), nil }
+
+// randomDevice reads from the random source of the filesystem its files are opened from,
+// so the devices of forks and copies read from theirs.
+type randomDevice struct{}
+
+func (randomDevice) read([]byte) (int, error)    { return 0, io.EOF }
+func (randomDevice) write(p []byte) (int, error) { return len(p), nil }
+
+func (randomDevice) open(f *FS, _ fileFlags) (device, error) {
+	return randomReader{fs: f.baseFS()}, nil
+}
+
+type randomReader struct {
+	randomDevice
+	fs *FS // the base filesystem, holding the random source
+}
+
+func (r randomReader) read(p []byte) (int, error) {
+	r.fs.lock()
+	defer r.fs.unlock()
+	return r.fs.rand.Read(p)
+}
+
+// createDevices creates the device nodes in /dev, f must be the base filesystem.
+func (f *FS) createDevices() {
+	for name, d := range map[string]device{"null": nullDevice{}, "zero": zeroDevice{}, "urandom": randomDevice{}} {
+		f.createSpecial("/dev", name, deviceMode|0666, d)
+	}
+}
+
+// createSpecial creates the node name in dir, whose files read and write d, f must be
+// the base filesystem.
+func (f *FS) createSpecial(dir, name string, perm fs.FileMode, d device) {
+	_ = f.MkdirAll(dir, 0755)
+	_, parent, _, _ := f.getEntry(dir)
+	n := f.newNode(name, perm, false)
+	n.setDevice(d)
+	parent.entries.set(n)
+}
+
+// deviceOpener is a device whose files get a device of their own when opened from f with
+// flag, or fail to open with the error it returns.
+type deviceOpener interface {
+	open(f *FS, flag fileFlags) (device, error)
+}
+
+// setDevice makes the node a special file whose files read and write d.
+func (f *fsNode) setDevice(d device) {
+	e := f.extension()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.device = d
+}
+
+// device returns the device of the node, nil when the node is not a special file.
+func (f *fsNode) device() device {
+	e := f.getExt()
+	if e == nil {
+		return nil
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.device
+}
+
+// openDevice returns the device the files of the node opened from f with flag read and
+// write, or nil when the node is not a special file.
+func (f *FS) openDevice(n *fsNode, flag fileFlags) (device, error) {
+	d := n.device()
+	if o, ok := d.(deviceOpener); ok {
+		return o.open(f, flag)
+	}
+	return d, nil
+}
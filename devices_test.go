@@ -0,0 +1,102 @@
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func Test_Devices(t *testing.T) {
+	mfs := New(WithDevices(), WithRandSource(constSource(7)))
+
+	null, err := mfs.OpenFile("/dev/null", os.O_RDWR|os.O_TRUNC, 0)
+	assert.Nil(t, err)
+	n, err := null.Write([]byte("discarded"))
+	assert.Nil(t, err)
+	assert.Equal(t, 9, n)
+	_, err = null.Read(make([]byte, 4))
+	assert.Equal(t, io.EOF, err)
+	fi, err := null.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), fi.Size())
+	assert.Equal(t, fs.ModeDevice|fs.ModeCharDevice|0666, fi.Mode())
+	assert.True(t, errors.Is(null.Truncate(0), fs.ErrInvalid))
+	assert.Nil(t, null.Close())
+
+	zero, err := mfs.Open("/dev/zero")
+	assert.Nil(t, err)
+	data := []byte("not zeros")
+	_, err = zero.Read(data)
+	assert.Nil(t, err)
+	assert.Equal(t, make([]byte, 9), data)
+	assert.Nil(t, zero.Close())
+
+	// io.Copy doesn't share content with devices
+	f, err := mfs.Create("/file")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("content"))
+	assert.Nil(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	null, err = mfs.OpenFile("/dev/null", os.O_WRONLY, 0)
+	assert.Nil(t, err)
+	copied, err := io.Copy(null, f)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), copied)
+	assert.Nil(t, null.Close())
+
+	random := func(mfs *FS) []byte {
+		f, err := mfs.Open("/dev/urandom")
+		assert.Nil(t, err)
+		defer f.Close()
+		data := make([]byte, 16)
+		_, err = io.ReadFull(f, data)
+		assert.Nil(t, err)
+		return data
+	}
+	first := random(mfs)
+	assert.NotEqual(t, make([]byte, 16), first)
+	// the same source draws the same bytes
+	assert.True(t, bytes.Equal(first, random(New(WithDevices(), WithRandSource(constSource(7))))))
+
+	_, err = New().Stat("/dev/null")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	// forks draw from their own source
+	fork := Template(New(WithDevices())).Fork(WithRandSource(constSource(7)))
+	assert.Equal(t, first, random(fork))
+}
+
+func Test_Devices_Copies(t *testing.T) {
+	check := func(mfs *FS) {
+		null, err := mfs.OpenFile("/dev/null", os.O_RDWR, 0)
+		assert.Nil(t, err)
+		_, err = null.Write([]byte("discarded"))
+		assert.Nil(t, err)
+		_, err = null.Read(make([]byte, 4))
+		assert.Equal(t, io.EOF, err)
+		assert.Nil(t, null.Close())
+		assert.Equal(t, "", readAll(t, mfs, "/dev/null"))
+
+		zero, err := mfs.Open("/dev/zero")
+		assert.Nil(t, err)
+		data := []byte("not zeros")
+		_, err = io.ReadFull(zero, data)
+		assert.Nil(t, err)
+		assert.Equal(t, make([]byte, 9), data)
+		assert.Nil(t, zero.Close())
+	}
+
+	mfs := New(WithDevices())
+	mfs.Checkpoint()
+	assert.Nil(t, mfs.Undo())
+	check(mfs)
+	assert.Nil(t, mfs.Redo())
+	check(mfs)
+	check(mfs.TestNamespace(t))
+	check(Template(mfs).Fork())
+}
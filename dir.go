@@ -0,0 +1,142 @@
+package memfs
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"unique"
+)
+
+// internName returns the canonical copy of an entry name, so names repeated throughout a
+// tree, like "src" or "node_modules", are held in memory once.
+func internName(name string) string {
+	return unique.Make(name).Value()
+}
+
+// dirEntries holds the entries of a directory ordered by name, so listings don't
+// have to be sorted on every read and can start from any name.
+type dirEntries struct {
+	nodes   []*fsNode
+	removed int // entries removed since the entries were last compacted
+
+	// listings built for ReadDir, kept until the entries change, so that reading a
+	// stable directory again only copies them
+	listing []os.DirEntry
+}
+
+func newDirEntries() *dirEntries {
+	return &dirEntries{}
+}
+
+// search returns the index of name, or where it would be inserted.
+func (d *dirEntries) search(name string) (int, bool) {
+	i := sort.Search(len(d.nodes), func(i int) bool { return d.nodes[i].name >= name })
+	return i, i < len(d.nodes) && d.nodes[i].name == name
+}
+
+func (d *dirEntries) len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.nodes)
+}
+
+func (d *dirEntries) get(name string) (*fsNode, bool) {
+	if d == nil {
+		return nil, false
+	}
+	if i, found := d.search(name); found {
+		return d.nodes[i], true
+	}
+	return nil, false
+}
+
+// set adds the node under its name, replacing any entry with the same name, and returns
+// whether it replaced one.
+func (d *dirEntries) set(n *fsNode) bool {
+	d.listing = nil
+	i, found := d.search(n.name)
+	if found {
+		d.nodes[i] = n
+		return true
+	}
+	d.nodes = append(d.nodes, nil)
+	copy(d.nodes[i+1:], d.nodes[i:])
+	d.nodes[i] = n
+	return false
+}
+
+// replace puts the node at index i, in place of the entry there, whose name it must have.
+func (d *dirEntries) replace(i int, n *fsNode) {
+	d.listing = nil
+	d.nodes[i] = n
+}
+
+func (d *dirEntries) delete(name string) bool {
+	i, found := d.search(name)
+	if !found {
+		return false
+	}
+	d.listing = nil
+	copy(d.nodes[i:], d.nodes[i+1:])
+	d.nodes[len(d.nodes)-1] = nil
+	d.nodes = d.nodes[:len(d.nodes)-1]
+	return true
+}
+
+// list returns a copy of the entries in name order.
+func (d *dirEntries) list() []*fsNode {
+	if d == nil {
+		return nil
+	}
+	nodes := make([]*fsNode, len(d.nodes))
+	copy(nodes, d.nodes)
+	return nodes
+}
+
+// after returns a copy of up to n entries, all of them when n <= 0, whose names sort after name.
+func (d *dirEntries) after(name string, n int) []*fsNode {
+	if d == nil {
+		return nil
+	}
+	i, found := d.search(name)
+	if found {
+		i++
+	}
+	rest := d.nodes[i:]
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	nodes := make([]*fsNode, len(rest))
+	copy(nodes, rest)
+	return nodes
+}
+
+// compact releases the capacity left behind by removed entries.
+func (d *dirEntries) compact() {
+	nodes := make([]*fsNode, len(d.nodes))
+	copy(nodes, d.nodes)
+	d.nodes = nodes
+	d.removed = 0
+}
+
+// dirEntries returns a copy of the listing of the entries, in name order.
+func (d *dirEntries) dirEntries() []os.DirEntry {
+	if d == nil {
+		return []os.DirEntry{}
+	}
+	if d.listing == nil {
+		d.listing = toDirEntries(d.nodes)
+	}
+	return slices.Clone(d.listing)
+}
+
+func toDirEntries(nodes []*fsNode) []os.DirEntry {
+	dirEntries := make([]os.DirEntry, len(nodes), len(nodes))
+	for i := range nodes {
+		dirEntries[i] = DirEntry{
+			node: nodes[i],
+		}
+	}
+	return dirEntries
+}
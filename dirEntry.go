@@ -1,12 +1,12 @@
 package memfs
 
 import (
-	"io/fs"
 	"os"
 )
 
 type DirEntry struct {
 	node *fsNode
+	fs   *FS // the filesystem of the entry, for the Sys of Info
 }
 
 func (de DirEntry) Name() string {
@@ -17,13 +17,26 @@ func (de DirEntry) IsDir() bool {
 	return de.node.isDir()
 }
 
+// Type returns the type bits of the mode of the entry, like Info().Mode().Type().
 func (de DirEntry) Type() os.FileMode {
-	if de.IsDir() {
-		return fs.ModeDir
-	}
-	return 0
+	return FileInfo{node: de.node}.Mode().Type()
 }
 
 func (de DirEntry) Info() (os.FileInfo, error) {
-	return FileInfo{node: de.node}, nil
+	info := FileInfo{node: de.node, fs: de.fs}
+	if de.fs != nil {
+		info.times = de.fs.timesOf(de.node)
+	}
+	return info, nil
+}
+
+// withFS returns the entries of a listing with f as their filesystem, for the Sys of their Info.
+func (f *FS) withFS(entries []os.DirEntry) []os.DirEntry {
+	for i, e := range entries {
+		if d, ok := e.(DirEntry); ok {
+			d.fs = f
+			entries[i] = d
+		}
+	}
+	return entries
 }
@@ -0,0 +1,118 @@
+package memfs
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"testing"
+	"unsafe"
+)
+
+func entryNames(nodes []*fsNode) []string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.name
+	}
+	return names
+}
+
+func Test_DirEntries(t *testing.T) {
+	d := newDirEntries()
+	assert.Equal(t, 0, d.len())
+	for _, name := range []string{"c", "a", "d", "b"} {
+		d.set(&fsNode{name: name})
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, entryNames(d.list()))
+
+	b := &fsNode{name: "b"}
+	d.set(b)
+	assert.Equal(t, 4, d.len())
+	e, exists := d.get("b")
+	assert.True(t, exists)
+	assert.Same(t, b, e)
+	_, exists = d.get("bb")
+	assert.False(t, exists)
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, entryNames(d.after("", 0)))
+	assert.Equal(t, []string{"c", "d"}, entryNames(d.after("b", 0)))
+	assert.Equal(t, []string{"c"}, entryNames(d.after("bb", 1)))
+	assert.Empty(t, d.after("d", 0))
+
+	assert.True(t, d.delete("a"))
+	assert.False(t, d.delete("a"))
+	assert.Equal(t, []string{"b", "c", "d"}, entryNames(d.list()))
+
+	var files *dirEntries
+	assert.Equal(t, 0, files.len())
+	assert.Nil(t, files.list())
+}
+
+func Test_Interned_Names(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/a/node_modules", 0755))
+	assert.Nil(t, mfs.MkdirAll("/b/"+string([]byte("node_modules")), 0755))
+
+	_, a, _, err := mfs.getEntry("/a/node_modules")
+	assert.Nil(t, err)
+	_, b, _, err := mfs.getEntry("/b/node_modules")
+	assert.Nil(t, err)
+	assert.Equal(t, unsafe.StringData(a.name), unsafe.StringData(b.name))
+}
+
+func Test_ReadDir_Cache(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/dir/a": nil, "/dir/b": nil})
+	assert.Nil(t, err)
+	names := func() []string {
+		entries, err := mfs.ReadDir("/dir")
+		assert.Nil(t, err)
+		ioEntries, err := fs.ReadDir(mfs.IOFS(), "dir")
+		assert.Nil(t, err)
+		var names []string
+		for i, e := range entries {
+			assert.Equal(t, e.Name(), ioEntries[i].Name())
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	entries, err := mfs.ReadDir("/dir")
+	assert.Nil(t, err)
+	dir, _ := mfs.root.entries.get("dir")
+	assert.NotNil(t, dir.entries.listing)
+	// the listing returned is a copy, which can be modified
+	entries[0], entries[1] = entries[1], entries[0]
+	assert.Equal(t, []string{"a", "b"}, names())
+
+	f, err := mfs.Create("/dir/c")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, []string{"a", "b", "c"}, names())
+	assert.Nil(t, mfs.Rename("/dir/a", "/dir/d"))
+	assert.Equal(t, []string{"b", "c", "d"}, names())
+	assert.Nil(t, mfs.Remove("/dir/b"))
+	assert.Equal(t, []string{"c", "d"}, names())
+	assert.Nil(t, mfs.Rename("/dir/c", "/c"))
+	assert.Equal(t, []string{"d"}, names())
+}
+
+func Benchmark_ReadDir(b *testing.B) {
+	mfs := New()
+	_ = mfs.MkdirAll("/dir", 0755)
+	for i := 0; i < 1000; i++ {
+		f, _ := mfs.Create(fmt.Sprintf("/dir/%04d", i))
+		_ = f.Close()
+	}
+	b.Run("ReadDir", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = mfs.ReadDir("/dir")
+		}
+	})
+	b.Run("IOFS", func(b *testing.B) {
+		fsys := mfs.IOFS()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = fs.ReadDir(fsys, "dir")
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package memfs
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// WithErrnos makes the errors of the operations mirroring those of the os package wrap
+// the syscall.Errno the OS reports along the fs.Err sentinels, syscall.ENOENT with
+// fs.ErrNotExist, syscall.EEXIST with fs.ErrExist, syscall.EACCES with fs.ErrPermission,
+// syscall.EINVAL with fs.ErrInvalid, syscall.EBADF with fs.ErrClosed and syscall.EIO with
+// ErrCorrupted, so code under test checking errnos can be tested. The errors wrapping an
+// errno already, like syscall.ENOTDIR or syscall.EISDIR, are the same with or without it.
+func WithErrnos() Option {
+	return func(f *FS) {
+		f.errnos = true
+	}
+}
+
+// errnos are the errnos wrapped WithErrnos by the errors wrapping the sentinels.
+var errnos = []struct {
+	sentinel error
+	errno    syscall.Errno
+}{
+	{fs.ErrNotExist, syscall.ENOENT},
+	{fs.ErrExist, syscall.EEXIST},
+	{fs.ErrPermission, syscall.EACCES},
+	{fs.ErrInvalid, syscall.EINVAL},
+	{fs.ErrClosed, syscall.EBADF},
+	{ErrCorrupted, syscall.EIO},
+}
+
+// errnoError is an error wrapping the errno of the sentinel it wraps.
+type errnoError struct {
+	err   error
+	errno syscall.Errno
+}
+
+func (e *errnoError) Error() string   { return e.err.Error() }
+func (e *errnoError) Unwrap() []error { return []error{e.err, e.errno} }
+
+// mapErrno makes *err wrap its errno when the filesystem was created WithErrnos, it is
+// deferred by the operations returning err.
+func (f *FS) mapErrno(err *error) {
+	if *err == nil || f == nil || !f.baseFS().errnos {
+		return
+	}
+	var errno syscall.Errno
+	if errors.As(*err, &errno) {
+		return
+	}
+	for _, e := range errnos {
+		if errors.Is(*err, e.sentinel) {
+			*err = &errnoError{err: *err, errno: e.errno}
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func Test_Errnos(t *testing.T) {
+	mfs := New(WithErrnos())
+	assert.Nil(t, mfs.MkdirAll("/dir", 0755))
+	assert.Nil(t, mfs.WriteFile("/dir/file", []byte("x"), 0644))
+
+	errno := func(err error) syscall.Errno {
+		var errno syscall.Errno
+		assert.True(t, errors.As(err, &errno), "%v", err)
+		return errno
+	}
+	_, err := mfs.Stat("/missing")
+	assert.Equal(t, syscall.ENOENT, errno(err))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.Equal(t, "path does not exist: /missing: file does not exist", err.Error())
+	err = mfs.Mkdir("/dir", 0755)
+	assert.Equal(t, syscall.EEXIST, errno(err))
+	assert.ErrorIs(t, err, fs.ErrExist)
+	_, err = mfs.OpenFile("/dir/file", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	assert.Equal(t, syscall.EEXIST, errno(err))
+	err = mfs.Rename("/missing", "/other")
+	assert.Equal(t, syscall.ENOENT, errno(err))
+	_, err = mfs.ReadFile("/dir/missing")
+	assert.Equal(t, syscall.ENOENT, errno(err))
+
+	// the errors wrapping an errno already are unchanged
+	_, err = mfs.Stat("/dir/file/x")
+	assert.Equal(t, syscall.ENOTDIR, errno(err))
+	err = mfs.Remove("/dir")
+	assert.Equal(t, syscall.ENOTEMPTY, errno(err))
+
+	f, err := mfs.Open("/dir/file")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("y"))
+	assert.Equal(t, syscall.EINVAL, errno(err))
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+	assert.Nil(t, f.Close())
+	err = f.Close()
+	assert.Equal(t, syscall.EBADF, errno(err))
+	assert.ErrorIs(t, err, fs.ErrClosed)
+
+	err = mfs.As(1000, 1000).WriteFile("/dir/other", nil, 0644)
+	assert.Equal(t, syscall.EACCES, errno(err))
+	assert.ErrorIs(t, err, fs.ErrPermission)
+
+	// without the option the errors only wrap the sentinels
+	_, err = New().Stat("/missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	var errnoErr syscall.Errno
+	assert.False(t, errors.As(err, &errnoErr))
+}
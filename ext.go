@@ -0,0 +1,92 @@
+package memfs
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+// nodeExt holds the attributes of a node that most nodes don't have, or only have with
+// some options, so they don't make every node of a tree bigger. It is created by the
+// first attribute set and copied along with the node, so checkpoints, namespaces and
+// forks keep them. Its mutex is taken last, no other lock is taken while holding it.
+type nodeExt struct {
+	mutex    sync.Mutex
+	uid, gid int32
+	ino      uint64         // the inode number given out by Sys, 0 until then
+	times    Times          // kept WithTimes
+	device   device         // the behavior of a special file, in place of its content
+	sparse   *sparseContent // the content of a file with holes, guarded by the node lock
+	ranges   *rangeTracker  // written to the file, WithRangeTracking
+	sum      [sha256.Size]byte
+	summed   bool          // sum is the checksum of the content, WithVerifyOnRead
+	locks    *advisoryLock // of the files which locked the node
+	acl      []ACLEntry    // the extended access control list set by SetACL
+	link     *fsNode       // the node the entry is a hard link to, set before it is added
+	names    uint32        // the entries naming a node with hard links, 0 before any
+}
+
+// getExt returns the attributes of the node, nil when it has none.
+func (f *fsNode) getExt() *nodeExt {
+	return f.ext.Load()
+}
+
+// extension returns the attributes of the node, created when it has none yet.
+func (f *fsNode) extension() *nodeExt {
+	if e := f.ext.Load(); e != nil {
+		return e
+	}
+	f.ext.CompareAndSwap(nil, new(nodeExt))
+	return f.ext.Load()
+}
+
+// owner returns the user and the group owning the node.
+func (f *fsNode) owner() (uid, gid int) {
+	e := f.getExt()
+	if e == nil {
+		return 0, 0
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return int(e.uid), int(e.gid)
+}
+
+// setOwner sets the user and the group owning the node.
+func (f *fsNode) setOwner(uid, gid int) {
+	if uid == 0 && gid == 0 && f.getExt() == nil {
+		return
+	}
+	e := f.extension()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.uid, e.gid = int32(uid), int32(gid)
+}
+
+// clone returns a copy of the attributes for a copy of the node, the caller must hold the
+// node lock. The locks held on the node are not copied, they are held on the node the
+// files opened.
+func (e *nodeExt) clone() *nodeExt {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return &nodeExt{
+		uid:    e.uid,
+		gid:    e.gid,
+		ino:    e.ino,
+		times:  e.times,
+		device: e.device,
+		sparse: e.sparse.clone(),
+		ranges: e.ranges.clone(),
+		sum:    e.sum,
+		summed: e.summed,
+		acl:    e.acl,
+		names:  e.names,
+	}
+}
+
+// clear drops the attributes of a removed node which can hold memory. The owner, the
+// inode number and the times stay for the FileInfo still describing it, and the locks
+// for the files still holding them.
+func (e *nodeExt) clear() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.device, e.ranges, e.summed, e.acl = nil, nil, false, nil
+}
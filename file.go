@@ -5,16 +5,19 @@ import (
 	"io"
 	"io/fs"
 	"os"
-	"sort"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 type contentOwner interface {
 	lockContent()
 	unlockContent()
-	getContent() []byte
-	setContent(c []byte)
+	size() int
+	readAt(p []byte, off int) int
+	writeAt(p []byte, off int, holes bool)
+	seekHole(off int64, hole bool) (int64, error)
 }
 
 type contentReadWriteSeeker interface {
@@ -26,16 +29,17 @@ type contentReadWriteSeeker interface {
 }
 
 type contentReadWriteSeekerImpl struct {
-	owner contentOwner
-	pos   int
+	owner  contentOwner
+	pos    int
+	ranges *rangeTracker // the ranges written, when created WithRangeTracking
+	holes  bool          // the holes are kept, when created WithSparseFiles
 }
 
 func (crws *contentReadWriteSeekerImpl) read(p []byte) (n int, err error) {
-	content := crws.owner.getContent()
-	if crws.pos >= len(p) {
+	if crws.pos >= crws.owner.size() {
 		return 0, io.EOF
 	}
-	n = copy(p, content[crws.pos:])
+	n = crws.owner.readAt(p, crws.pos)
 	crws.pos += n
 	return n, nil
 }
@@ -52,16 +56,21 @@ func (crws *contentReadWriteSeekerImpl) ReadAt(p []byte, off int64) (n int, err
 	}
 	crws.owner.lockContent()
 	defer crws.owner.unlockContent()
+	// reading at an offset does not move the position used by Read
+	pos := crws.pos
 	crws.pos = int(off)
-	return crws.read(p)
+	n, err = crws.read(p)
+	crws.pos = pos
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
 }
 
 func (crws *contentReadWriteSeekerImpl) Seek(offset int64, whence int) (int64, error) {
 	crws.owner.lockContent()
 	defer crws.owner.unlockContent()
 
-	content := crws.owner.getContent()
-
 	newPos, offs := 0, int(offset)
 	switch whence {
 	case io.SeekStart:
@@ -69,7 +78,7 @@ func (crws *contentReadWriteSeekerImpl) Seek(offset int64, whence int) (int64, e
 	case io.SeekCurrent:
 		newPos = crws.pos + offs
 	case io.SeekEnd:
-		newPos = len(content) + offs
+		newPos = crws.owner.size() + offs
 	}
 	if newPos < 0 {
 		return 0, os.ErrInvalid
@@ -79,27 +88,21 @@ func (crws *contentReadWriteSeekerImpl) Seek(offset int64, whence int) (int64, e
 	return int64(newPos), nil
 }
 
-func (crws *contentReadWriteSeekerImpl) write(p []byte) (n int, err error) {
-	content := crws.owner.getContent()
-
-	var newContent []byte
-
-	if crws.pos >= len(content) {
-		l := crws.pos + len(p)
-		newContent = make([]byte, l, l)
-		copy(newContent, content)
-	} else if crws.pos+len(p) > len(content) {
-		l := crws.pos + len(p)
-		newContent = make([]byte, l, l)
-		copy(newContent, content)
-	} else {
-		newContent = content
+// seekHole moves the position to the data, or the hole when hole is set, at or after offset.
+func (crws *contentReadWriteSeekerImpl) seekHole(offset int64, hole bool) (int64, error) {
+	crws.owner.lockContent()
+	defer crws.owner.unlockContent()
+	pos, err := crws.owner.seekHole(offset, hole)
+	if err != nil {
+		return 0, err
 	}
+	crws.pos = int(pos)
+	return pos, nil
+}
 
-	copy(newContent[crws.pos:], p)
-
-	crws.owner.setContent(newContent)
-
+func (crws *contentReadWriteSeekerImpl) write(p []byte) (n int, err error) {
+	crws.owner.writeAt(p, crws.pos, crws.holes)
+	crws.ranges.add(int64(crws.pos), int64(len(p)))
 	crws.pos += len(p)
 	return len(p), nil
 }
@@ -110,42 +113,363 @@ func (crws *contentReadWriteSeekerImpl) Write(p []byte) (n int, err error) {
 	return crws.write(p)
 }
 
+// append writes at the end of the content, wherever the position was moved to.
+func (crws *contentReadWriteSeekerImpl) append(p []byte) (n int, err error) {
+	crws.owner.lockContent()
+	defer crws.owner.unlockContent()
+	crws.pos = crws.owner.size()
+	return crws.write(p)
+}
+
 func (crws *contentReadWriteSeekerImpl) WriteAt(p []byte, off int64) (n int, err error) {
 	if off < 0 {
 		return 0, os.ErrInvalid
 	}
 	crws.owner.lockContent()
 	defer crws.owner.unlockContent()
+	// writing at an offset does not move the position used by Write
+	pos := crws.pos
 	crws.pos = int(off)
-	return crws.write(p)
+	n, err = crws.write(p)
+	crws.pos = pos
+	return n, err
 }
 
+// fsNode is a file or a directory. Trees can hold millions of nodes, the fields are
+// ordered and sized to keep the node small.
 type fsNode struct {
 	name     string
-	perm     os.FileMode
-	modified time.Time
 	content  []byte
+	modified time.Time
+	entries  *dirEntries
+	blob     *blob // when set, content is shared with other nodes and must not be modified in place
+	ext      atomic.Pointer[nodeExt]
 	mutex    sync.Mutex
-	entries  map[string]*fsNode
+	perm     os.FileMode
 	unlinked bool
+	noLock   bool   // the filesystem was created WithoutLocking
+	mapped   uint16 // number of writable mappings of the content, which must then stay in place
+}
+
+// newNode returns a node owned by the identity of f, modified now. It is a directory
+// when dir is set, otherwise an empty file. Nodes are allocated one at a time, so the
+// memory of a removed node is freed with it, whatever other nodes stay.
+func (f *FS) newNode(name string, perm os.FileMode, dir bool) *fsNode {
+	n := &fsNode{
+		name:     internName(name),
+		perm:     perm,
+		modified: f.now(),
+		noLock:   f.baseFS().noLock,
+	}
+	n.setOwner(f.uid, f.gid)
+	f.born(n, n.modified)
+	if dir {
+		n.entries = newDirEntries()
+	}
+	return n
+}
+
+func (f *fsNode) lock() {
+	if !f.noLock {
+		f.mutex.Lock()
+	}
+}
+
+func (f *fsNode) unlock() {
+	if !f.noLock {
+		f.mutex.Unlock()
+	}
 }
 
 func (f *fsNode) lockContent() {
-	f.mutex.Lock()
+	f.lock()
 }
 
 func (f *fsNode) unlockContent() {
-	f.mutex.Unlock()
+	f.unlock()
 }
 
+// getContent returns the content, a copy with its holes filled when the file has some.
 func (f *fsNode) getContent() []byte {
+	if s := f.sparseContent(); s != nil {
+		return s.dense()
+	}
+	return f.content
+}
+
+// size returns the size of the content.
+func (f *fsNode) size() int {
+	if s := f.sparseContent(); s != nil {
+		return int(s.size)
+	}
+	return len(f.content)
+}
+
+// allocated returns the number of bytes the content takes, its holes not counted.
+func (f *fsNode) allocated() int64 {
+	if s := f.sparseContent(); s != nil {
+		return s.allocated()
+	}
+	return int64(len(f.content))
+}
+
+func (f *fsNode) readAt(p []byte, off int) int {
+	if s := f.sparseContent(); s != nil {
+		return s.read(p, int64(off))
+	}
+	if off >= len(f.content) {
+		return 0
+	}
+	return copy(p, f.content[off:])
+}
+
+// writeAt writes p at off, growing the content past its end. With holes, the bytes
+// skipped past the end are a hole, unless the content is mapped.
+func (f *fsNode) writeAt(p []byte, off int, holes bool) {
+	if f.sparseContent() != nil || holes && off > len(f.content) && f.mapped == 0 {
+		f.makeSparse().write(p, int64(off))
+		return
+	}
+	var content []byte
+	if end := off + len(p); end > len(f.content) {
+		content = f.resizeContent(end)
+	} else {
+		content = f.getMutableContent()
+	}
+	copy(content[off:], p)
+}
+
+func (f *fsNode) seekHole(off int64, hole bool) (int64, error) {
+	if s := f.sparseContent(); s != nil {
+		return s.seekHole(off, hole)
+	}
+	return seekHole(off, int64(len(f.content)), hole)
+}
+
+// getMutableContent returns the content to be modified in place, which then has no holes.
+func (f *fsNode) getMutableContent() []byte {
+	if s := f.sparseContent(); s != nil {
+		f.setContent(s.dense())
+	}
+	if f.blob != nil {
+		c := make([]byte, len(f.content))
+		copy(c, f.content)
+		f.setContent(c)
+	}
 	return f.content
 }
 
+// resizeContent grows the content to size bytes, zero filled past the current end. Spare
+// capacity is used when the content isn't shared, otherwise the capacity of content that
+// is grown again is at least doubled, so that repeated small writes don't copy the whole
+// content every time. The first write to an empty file allocates exactly what it needs.
+func (f *fsNode) resizeContent(size int) []byte {
+	if s := f.sparseContent(); s != nil {
+		f.setContent(s.dense())
+	}
+	if f.blob == nil && size <= cap(f.content) {
+		n := len(f.content)
+		f.content = f.content[:size]
+		clear(f.content[n:])
+		return f.content
+	}
+	var c []byte
+	if len(f.content) == 0 {
+		c = make([]byte, size)
+	} else {
+		c = getBuffer(max(size, 2*cap(f.content)))[:size]
+	}
+	n := copy(c, f.content)
+	clear(c[n:])
+	if f.blob == nil && f.mapped == 0 {
+		putBuffer(f.content)
+	}
+	f.setContent(c)
+	return f.content
+}
+
+// growContent makes room for n more bytes past the end of the content without changing its length.
+func (f *fsNode) growContent(n int) {
+	if f.sparseContent() != nil {
+		return
+	}
+	if f.blob == nil && cap(f.content)-len(f.content) >= n {
+		return
+	}
+	c := getBuffer(len(f.content) + n)[:len(f.content)]
+	copy(c, f.content)
+	if f.blob == nil && f.mapped == 0 {
+		putBuffer(f.content)
+	}
+	f.setContent(c)
+}
+
+// truncateContent changes the length of the content to size, zero filled past the
+// current end. Mapped content is truncated in place, and the holes are kept but for a
+// size of 0.
+func (f *fsNode) truncateContent(size int) {
+	if s := f.sparseContent(); s != nil && size > 0 {
+		s.truncate(int64(size))
+		return
+	}
+	switch {
+	case size > len(f.content):
+		f.resizeContent(size)
+	case f.mapped > 0:
+		f.content = f.content[:size]
+	case size == 0:
+		f.setContent([]byte{})
+	case f.blob != nil:
+		c := make([]byte, size)
+		copy(c, f.content)
+		f.setContent(c)
+	default:
+		f.content = f.content[:size]
+	}
+}
+
+// sharedContent returns the content as a blob, with a reference held for the caller, the
+// caller must hold the node lock. Mapped content is modified in place, it is copied.
+func (f *fsNode) sharedContent() *blob {
+	if s := f.sparseContent(); s != nil {
+		return newBlob(s.dense())
+	}
+	if f.mapped > 0 {
+		c := make([]byte, len(f.content))
+		copy(c, f.content)
+		return newBlob(c)
+	}
+	if f.blob == nil {
+		f.blob = newBlob(f.content)
+	}
+	f.blob.retain()
+	return f.blob
+}
+
+// setContent replaces the content, which then has no holes.
 func (f *fsNode) setContent(c []byte) {
+	if f.blob != nil {
+		f.blob.release()
+		f.blob = nil
+	}
+	if e := f.getExt(); e != nil {
+		e.sparse = nil
+	}
 	f.content = c
 }
 
+// share moves the node content into the content store, so identical content is only held once.
+func (f *fsNode) share(store *contentStore) {
+	f.lockContent()
+	defer f.unlockContent()
+	if (f.blob != nil && f.blob.store == store) || f.unlinked || f.isDir() || len(f.content) == 0 || f.mapped > 0 {
+		return
+	}
+	b := store.intern(f.content)
+	f.setContent(b.data)
+	f.blob = b
+}
+
+// release drops the content of the node once removed, the buffer of content it doesn't
+// share nor map is reused, and the attributes holding memory.
+func (f *fsNode) release() {
+	f.lockContent()
+	if f.mapped == 0 {
+		if f.blob == nil {
+			putBuffer(f.content)
+		}
+		f.setContent(nil)
+	}
+	f.unlockContent()
+	if e := f.getExt(); e != nil {
+		e.clear()
+	}
+}
+
+// unlinkAll marks the node and all the nodes beneath it as unlinked and releases their
+// content, the content of a file with hard links once its last name is unlinked.
+func (f *fsNode) unlinkAll() {
+	if !f.isDir() {
+		f.unlinkName()
+		return
+	}
+	f.lock()
+	f.unlinked = true
+	children := f.entries.list()
+	f.unlock()
+	for _, e := range children {
+		e.unlinkAll()
+	}
+	f.release()
+}
+
+// clone returns a copy of the node and all the nodes beneath it, with their attributes.
+// File content is shared between the copies until either of them is modified, the hard
+// links of the copy name the copies of their files.
+func (f *fsNode) clone() *fsNode {
+	return f.cloneLinked(make(map[*fsNode]*fsNode))
+}
+
+// cloneLinked is clone, linked mapping the files with hard links copied to their copies.
+func (f *fsNode) cloneLinked(linked map[*fsNode]*fsNode) *fsNode {
+	if c, exists := linked[f]; exists {
+		return c
+	}
+	f.lock()
+	c := &fsNode{
+		name:     f.name,
+		perm:     f.perm,
+		modified: f.modified,
+		noLock:   f.noLock,
+	}
+	if e := f.getExt(); e != nil {
+		c.ext.Store(e.clone())
+	}
+	if !f.isDir() {
+		if f.blob != nil || len(f.content) > 0 {
+			c.blob = f.sharedContent()
+			c.content = c.blob.data
+		}
+		f.unlock()
+		if e := c.getExt(); e != nil && e.names > 0 {
+			linked[f] = c
+		}
+		if t := f.target(); t != f {
+			c.getExt().link = t.cloneLinked(linked)
+		}
+		return c
+	}
+	children := f.entries.list()
+	f.unlock()
+	// children are cloned in name order, so appending keeps the copy ordered
+	c.entries = &dirEntries{nodes: make([]*fsNode, 0, len(children))}
+	for _, e := range children {
+		c.entries.nodes = append(c.entries.nodes, e.cloneLinked(linked))
+	}
+	return c
+}
+
+// contains returns whether n is beneath the directory f.
+func (f *fsNode) contains(n *fsNode) bool {
+	stack := []*fsNode{f}
+	for len(stack) > 0 {
+		d := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d.lock()
+		children := d.entries.list()
+		d.unlock()
+		for _, c := range children {
+			if c == n {
+				return true
+			}
+			if c.isDir() {
+				stack = append(stack, c)
+			}
+		}
+	}
+	return false
+}
+
 func (f *fsNode) isDir() bool {
 	if f.entries != nil {
 		return true
@@ -153,107 +477,216 @@ func (f *fsNode) isDir() bool {
 	return false
 }
 
+// removeEntry deletes a directory entry, the caller must hold the node lock.
+func (f *fsNode) removeEntry(name string) {
+	if f.entries.delete(name) {
+		f.entries.removed++
+	}
+}
+
 func (f *fsNode) getEntryNames() []string {
 	if f.isDir() {
-		f.mutex.Lock()
-		defer f.mutex.Unlock()
-		names := make([]string, 0, len(f.entries))
-		for n := range f.entries {
-			names = append(names, n)
+		f.lock()
+		defer f.unlock()
+		names := make([]string, 0, f.entries.len())
+		for _, e := range f.entries.nodes {
+			names = append(names, e.name)
 		}
-		sort.Strings(names)
 		return names
 	}
 	return []string{}
 }
 
 type File struct {
-	node              *fsNode
-	flag              fileFlags
-	fd                int64
-	crws              *contentReadWriteSeekerImpl
-	closed            bool
-	indexReadDir      int
-	indexReaddir      int
-	indexReaddirnames int
+	fs        *FS
+	node      *fsNode
+	name      string // the path the file was opened with
+	flag      fileFlags
+	fd        int64
+	crws      *contentReadWriteSeekerImpl
+	closed    bool
+	dirCursor string         // name of the last entry read from a directory, shared by ReadDir, Readdir and Readdirnames
+	mapping   []byte         // the content returned by Map, until Unmap
+	mapShared bool           // mapping aliases the content, rather than a copy of it
+	device    device         // reads and writes go to the device rather than to the content
+	staged    *stagedContent // the content written, until synced, WithStagedWrites
+
+	readDeadline  atomic.Int64 // unix nanoseconds, 0 when not set
+	writeDeadline atomic.Int64
 }
 
 func (f *File) isDir() bool {
 	return f.node.isDir()
 }
 
+// Name returns the path the file was opened with, like os.File.Name, it doesn't follow
+// renames.
 func (f *File) Name() string {
-	return f.node.name
+	return f.name
+}
+
+// Fd returns the descriptor of the file in the table of the filesystem, for FileByFd, or
+// ^uintptr(0) once the file is closed, like os.File.Fd. Descriptors start at 100 and are
+// not reused. They are not OS descriptors.
+func (f *File) Fd() uintptr {
+	if f.closed {
+		return ^uintptr(0)
+	}
+	return uintptr(f.fd)
 }
 
-func (f *File) Stat() (os.FileInfo, error) {
+func (f *File) Stat() (_ os.FileInfo, err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
 		return FileInfo{}, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
 	}
-	return FileInfo{node: f.node}, nil
+	return FileInfo{node: f.node, fs: f.fs, times: f.fs.timesOf(f.node)}, nil
 }
 
-func (f *File) Close() error {
+func (f *File) Close() (err error) {
+	defer f.fs.mapErrno(&err)
 	if f.closed {
 		return fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
+	if f.mapping != nil {
+		_ = f.Unmap()
+	}
 	f.closed = true
+	if f.staged != nil {
+		f.staged.commit()
+	}
+	if f.fs != nil {
+		f.releaseLocks()
+		f.fs.removeOpenFile(f)
+		if f.fs.store != nil && f.flag.canWrite() {
+			f.node.share(f.fs.store)
+		}
+		if f.flag.canWrite() {
+			f.fs.storeChecksum(f.node)
+		}
+	}
 	return nil
 }
 
 func (f *File) Read(p []byte) (n int, err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
 		return 0, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
 	}
+	if f.isDir() {
+		return 0, fmt.Errorf("is a directory: %s: %w", f.Name(), syscall.EISDIR)
+	}
 	if !f.flag.canRead() {
 		return 0, fmt.Errorf("cannot read: %s: %w", f.Name(), fs.ErrInvalid)
 	}
 	if f.closed {
 		return 0, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
+	if err = f.checkDeadline(f.readDeadline.Load()); err != nil {
+		return 0, err
+	}
+	if f.device != nil {
+		return f.device.read(p)
+	}
+	f.fs.accessed(f.node)
 	return f.crws.Read(p)
 }
 
 func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
 		return 0, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
 	}
+	if f.isDir() {
+		return 0, fmt.Errorf("is a directory: %s: %w", f.Name(), syscall.EISDIR)
+	}
 	if !f.flag.canRead() {
 		return 0, fmt.Errorf("cannot read: %s: %w", f.Name(), fs.ErrInvalid)
 	}
 	if f.closed {
 		return 0, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
+	if err = f.checkDeadline(f.readDeadline.Load()); err != nil {
+		return 0, err
+	}
+	if f.device != nil {
+		if r, ok := f.device.(io.ReaderAt); ok {
+			return r.ReadAt(p, off)
+		}
+		return f.device.read(p)
+	}
+	f.fs.accessed(f.node)
 	return f.crws.ReadAt(p, off)
 }
 
 func (f *File) Seek(offset int64, whence int) (n int64, err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
 		return 0, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
 	}
 	if f.closed {
 		return 0, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
+	if f.isDir() {
+		// only rewinding the directory reads is supported
+		if offset != 0 || whence != io.SeekStart {
+			return 0, fmt.Errorf("cannot seek in directory: %s: %w", f.Name(), fs.ErrInvalid)
+		}
+		f.dirCursor = ""
+		return 0, nil
+	}
+	if f.device != nil {
+		if s, ok := f.device.(io.Seeker); ok {
+			return s.Seek(offset, whence)
+		}
+		return 0, nil
+	}
+	if whence == SeekData || whence == SeekHole {
+		return f.crws.seekHole(offset, whence == SeekHole)
+	}
 	return f.crws.Seek(offset, whence)
 }
 
 func (f *File) Write(p []byte) (n int, err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
 		return 0, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
 	}
+	if f.isDir() {
+		return 0, fmt.Errorf("is a directory: %s: %w", f.Name(), syscall.EISDIR)
+	}
 	if !f.flag.canWrite() {
 		return 0, fmt.Errorf("cannot write: %s: %w", f.Name(), fs.ErrInvalid)
 	}
 	if f.closed {
 		return 0, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
+	if err = f.checkDeadline(f.writeDeadline.Load()); err != nil {
+		return 0, err
+	}
+	if f.device != nil {
+		return f.device.write(p)
+	}
+	f.fs.written(f.node)
+	if f.flag.isAppend() {
+		return f.crws.append(p)
+	}
 	return f.crws.Write(p)
 }
 
+// WriteString is Write with the content of s, like os.File.WriteString.
+func (f *File) WriteString(s string) (n int, err error) {
+	return f.Write([]byte(s))
+}
+
 func (f *File) WriteAt(p []byte, off int64) (n int, err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
 		return 0, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
 	}
+	if f.isDir() {
+		return 0, fmt.Errorf("is a directory: %s: %w", f.Name(), syscall.EISDIR)
+	}
 	if !f.flag.canWrite() {
 		return 0, fmt.Errorf("cannot write: %s: %w", f.Name(), fs.ErrInvalid)
 	}
@@ -263,71 +696,86 @@ func (f *File) WriteAt(p []byte, off int64) (n int, err error) {
 	if f.closed {
 		return 0, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
+	if err = f.checkDeadline(f.writeDeadline.Load()); err != nil {
+		return 0, err
+	}
+	if f.device != nil {
+		return f.device.write(p)
+	}
+	f.fs.written(f.node)
 	return f.crws.WriteAt(p, off)
 }
 
-func (f *File) ReadDir(n int) ([]os.DirEntry, error) {
+// Grow is a hint that n more bytes are about to be written past the end of the file, so
+// the capacity is reserved at once rather than grown as the writes come. The size of the
+// file is not changed.
+func (f *File) Grow(n int) (err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
-		return nil, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
+		return fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
 	}
-	if f.closed {
-		return nil, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
+	if f.isDir() {
+		return fmt.Errorf("is a directory: %s: %w", f.Name(), syscall.EISDIR)
 	}
-	if !f.node.isDir() {
-		return nil, fmt.Errorf("not a directoru: %s: %w", f.node.name, fs.ErrInvalid)
-	}
-	names := f.node.getEntryNames()
-	f.node.mutex.Lock()
-	defer f.node.mutex.Unlock()
-	dirEntries := make([]os.DirEntry, len(names), len(names))
-	for i := range names {
-		dirEntries[i] = DirEntry{
-			node: f.node.entries[names[i]],
-		}
+	if !f.flag.canWrite() {
+		return fmt.Errorf("cannot write: %s: %w", f.Name(), fs.ErrInvalid)
 	}
-	if n < 0 || n >= len(names) {
-		return dirEntries, nil
+	if f.closed {
+		return fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
-	dirEntries = dirEntries[f.indexReadDir:]
-	if n < len(dirEntries) {
-		f.indexReadDir += n
-		return dirEntries[0:n], nil
+	if n < 0 {
+		return fmt.Errorf("negative count: %d: %w", n, fs.ErrInvalid)
 	}
-	f.indexReadDir = 0
-	return dirEntries, nil
+	f.node.lockContent()
+	defer f.node.unlockContent()
+	f.node.growContent(n)
+	return nil
 }
 
-func (f *File) Readdir(n int) ([]os.FileInfo, error) {
+// Truncate changes the size of the file, zero filling it past its current end, like
+// os.File.Truncate. The offset of the file is not changed, writes of files opened with
+// O_APPEND go to the new end.
+func (f *File) Truncate(size int64) (err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
-		return nil, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
+		return fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+	if f.isDir() {
+		return fmt.Errorf("is a directory: %s: %w", f.Name(), syscall.EISDIR)
+	}
+	if !f.flag.canWrite() {
+		return fmt.Errorf("cannot write: %s: %w", f.Name(), fs.ErrInvalid)
 	}
 	if f.closed {
-		return nil, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
+		return fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
-	if !f.node.isDir() {
-		return nil, fmt.Errorf("not a directoru: %s: %w", f.node.name, fs.ErrInvalid)
+	if size < 0 {
+		return fmt.Errorf("negative size: %d: %w", size, fs.ErrInvalid)
 	}
-	names := f.node.getEntryNames()
-	f.node.mutex.Lock()
-	defer f.node.mutex.Unlock()
-	fileInfos := make([]os.FileInfo, len(names), len(names))
-	for i := range names {
-		fileInfos[i] = FileInfo{
-			node: f.node.entries[names[i]],
-		}
+	if f.device != nil {
+		return fmt.Errorf("cannot truncate a device: %s: %w", f.Name(), fs.ErrInvalid)
 	}
-	if n < 0 || n >= len(names) {
-		return fileInfos, nil
+	f.fs.written(f.node)
+	if f.staged != nil {
+		f.staged.truncate(int(size))
+		f.crws.ranges.truncate(size)
+		return nil
 	}
-	fileInfos = fileInfos[f.indexReaddir:]
-	if n < len(fileInfos) {
-		f.indexReaddir += n
-		return fileInfos[0:n], nil
+	f.node.lockContent()
+	defer f.node.unlockContent()
+	if f.crws.holes && int(size) > f.node.size() && f.node.mapped == 0 {
+		f.node.makeSparse()
 	}
-	f.indexReaddir = 0
-	return fileInfos, nil
+	f.node.truncateContent(int(size))
+	f.crws.ranges.truncate(size)
+	return nil
 }
-func (f *File) Readdirnames(n int) ([]string, error) {
+
+// readDir returns the next n entries of a directory, or all the remaining ones when n <= 0.
+// The position is kept as the name of the last entry returned, so entries created or
+// removed in between reads are neither skipped nor returned twice.
+func (f *File) readDir(n int) (_ []*fsNode, err error) {
+	defer f.fs.mapErrno(&err)
 	if f.node.unlinked {
 		return nil, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
 	}
@@ -335,19 +783,56 @@ func (f *File) Readdirnames(n int) ([]string, error) {
 		return nil, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
 	}
 	if !f.node.isDir() {
-		return nil, fmt.Errorf("not a directoru: %s: %w", f.node.name, fs.ErrInvalid)
+		return nil, fmt.Errorf("not a directory: %s: %w", f.node.name, syscall.ENOTDIR)
+	}
+	f.node.lock()
+	nodes := f.node.entries.after(f.dirCursor, n)
+	f.node.unlock()
+	f.fs.accessed(f.node)
+	if len(nodes) == 0 {
+		if n > 0 {
+			return nil, io.EOF
+		}
+		return nodes, nil
+	}
+	f.dirCursor = nodes[len(nodes)-1].name
+	return nodes, nil
+}
+
+// ReadDir reads the directory like os.File.ReadDir, it returns io.EOF once all the
+// entries have been read when n > 0.
+func (f *File) ReadDir(n int) ([]os.DirEntry, error) {
+	nodes, err := f.readDir(n)
+	if err != nil {
+		return nil, err
 	}
-	names := f.node.getEntryNames()
-	f.node.mutex.Lock()
-	defer f.node.mutex.Unlock()
-	if n < 0 || n >= len(names) {
-		return names, nil
+	return f.fs.withFS(toDirEntries(nodes)), nil
+}
+
+func (f *File) Readdir(n int) ([]os.FileInfo, error) {
+	nodes, err := f.readDir(n)
+	if err != nil {
+		return nil, err
+	}
+	fileInfos := make([]os.FileInfo, len(nodes), len(nodes))
+	for i := range nodes {
+		fileInfos[i] = FileInfo{
+			node:  nodes[i],
+			fs:    f.fs,
+			times: f.fs.timesOf(nodes[i]),
+		}
+	}
+	return fileInfos, nil
+}
+
+func (f *File) Readdirnames(n int) ([]string, error) {
+	nodes, err := f.readDir(n)
+	if err != nil {
+		return nil, err
 	}
-	names = names[f.indexReaddirnames:]
-	if n < len(names) {
-		f.indexReaddirnames += n
-		return names[0:n], nil
+	names := make([]string, len(nodes))
+	for i := range nodes {
+		names[i] = nodes[i].name
 	}
-	f.indexReaddirnames = 0
 	return names, nil
 }
@@ -1,41 +1,141 @@
 package memfs
 
 import (
+	"io/fs"
 	"os"
 	"time"
 )
 
 type FileInfo struct {
-	node *fsNode
+	node  *fsNode
+	fs    *FS    // the filesystem of the entry, for its inode number
+	times *Times // kept WithTimes
 }
 
 func (fi FileInfo) Name() string {
 	return fi.node.name
 }
 
+// inode returns the node the entry names, the file of a hard link.
+func (fi FileInfo) inode() *fsNode {
+	return fi.node.target()
+}
+
 func (fi FileInfo) Size() int64 {
 	if !fi.node.unlinked {
-		fi.node.mutex.Lock()
-		defer fi.node.mutex.Unlock()
-		if !fi.node.isDir() {
-			return int64(len(fi.node.content))
+		n := fi.inode()
+		n.lock()
+		defer n.unlock()
+		if !n.isDir() {
+			return int64(n.size())
 		}
 	}
 	return 0
 }
 
+// Mode returns the permission bits of the entry with its type bits, fs.ModeDir for a directory.
 func (fi FileInfo) Mode() os.FileMode {
-	return fi.node.perm
+	n := fi.inode()
+	if n.isDir() {
+		return n.perm | fs.ModeDir
+	}
+	return n.perm
+}
+
+// UID returns the user owning the entry.
+func (fi FileInfo) UID() int {
+	uid, _ := fi.inode().owner()
+	return uid
+}
+
+// GID returns the group owning the entry.
+func (fi FileInfo) GID() int {
+	_, gid := fi.inode().owner()
+	return gid
 }
 
 func (fi FileInfo) ModTime() time.Time {
-	return fi.node.modified
+	n := fi.inode()
+	n.lock()
+	defer n.unlock()
+	return n.modified
 }
 
 func (fi FileInfo) IsDir() bool {
 	return fi.node.isDir()
 }
 
+// SysInfo is what the Sys method of a FileInfo returns, like the *syscall.Stat_t of the
+// FileInfo of the OS.
+type SysInfo struct {
+	Ino    uint64 // unique among the entries of the filesystem, and stable for the life of the entry
+	Nlink  uint32 // 0 once removed, the names of a file, 2 and one per subdirectory for a directory
+	UID    int
+	GID    int
+	Blocks int64  // 512 byte blocks of the data of a file, allocated for its data only WithSparseFiles
+	Times  *Times // kept WithTimes, nil otherwise
+}
+
+// Sys returns the *SysInfo of the entry.
 func (fi FileInfo) Sys() any {
+	n := fi.inode()
+	n.lock()
+	sys := &SysInfo{Nlink: n.nlink(), Times: fi.times}
+	sys.UID, sys.GID = n.owner()
+	size := n.allocated()
+	n.unlock()
+	if fi.fs != nil {
+		sys.Ino = fi.fs.inode(n)
+	}
+	if !n.isDir() {
+		sys.Blocks = (size + 511) / 512
+	}
+	return sys
+}
+
+// nlink returns the number of links to the node, the caller must hold the node lock.
+func (f *fsNode) nlink() uint32 {
+	if f.unlinked {
+		return 0
+	}
+	if !f.isDir() {
+		return f.names()
+	}
+	n := uint32(2)
+	for _, e := range f.entries.list() {
+		if e.isDir() {
+			n++
+		}
+	}
+	return n
+}
+
+// inode returns the inode number of n, numbered from 1 in the order they are first asked
+// for. Copies of the node keep its number.
+func (f *FS) inode(n *fsNode) uint64 {
+	e := n.extension()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.ino == 0 {
+		e.ino = f.baseFS().lastInode.Add(1)
+	}
+	return e.ino
+}
+
+// SameFile reports whether fi1 and fi2 describe the same file, like os.SameFile does for
+// the FileInfo of the OS: the same file, whatever its name or path now, so a file renamed
+// since, stat'ed through an open handle or through a hard link, is still the same. FileInfo
+// not returned by memfs describe no file of it.
+func SameFile(fi1, fi2 os.FileInfo) bool {
+	n1, n2 := infoNode(fi1), infoNode(fi2)
+	return n1 != nil && n1 == n2
+}
+
+// infoNode returns the node fi describes, nil when fi is not from memfs.
+func infoNode(fi os.FileInfo) *fsNode {
+	switch fi := fi.(type) {
+	case FileInfo:
+		return fi.inode()
+	}
 	return nil
 }
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
 )
 
@@ -13,9 +15,18 @@ func Test_Filename(t *testing.T) {
 	f := new(fsNode)
 	f.name = "test_file"
 
-	tf := &File{node: f}
+	tf := &File{node: f, name: "/dir/test_file"}
 
-	assert.Equal(t, "test_file", tf.Name())
+	assert.Equal(t, "/dir/test_file", tf.Name())
+
+	// the path the file was opened with, not following renames
+	mfs := New()
+	assert.Nil(t, mfs.WriteFile("/file", nil, 0644))
+	opened, err := mfs.Open("/file")
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.Rename("/file", "/renamed"))
+	assert.Equal(t, "/file", opened.Name())
+	assert.Nil(t, opened.Close())
 }
 
 func Test_IsDir(t *testing.T) {
@@ -24,7 +35,7 @@ func Test_IsDir(t *testing.T) {
 
 	d := new(fsNode)
 	d.name = "test_dir"
-	d.entries = make(map[string]*fsNode)
+	d.entries = newDirEntries()
 
 	assert.True(t, d.isDir())
 	assert.False(t, f.isDir())
@@ -35,7 +46,7 @@ func Test_IsDir(t *testing.T) {
 func Test_GetEntryNames(t *testing.T) {
 
 	d := new(fsNode)
-	d.entries = make(map[string]*fsNode)
+	d.entries = newDirEntries()
 
 	emptyNames := d.getEntryNames()
 	assert.Len(t, emptyNames, 0)
@@ -52,7 +63,7 @@ func Test_GetEntryNames(t *testing.T) {
 	}
 
 	for _, e := range entries {
-		d.entries[e.name] = e
+		d.entries.set(e)
 	}
 
 	names := d.getEntryNames()
@@ -339,12 +350,19 @@ func Test_ReadDirFuncs(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, s)
 	assert.Equal(t, 0, int(s.Size()))
-	assert.Nil(t, s.Sys())
+	assert.Nil(t, s.Sys().(*SysInfo).Times)
 
 	names, err := dir.Readdirnames(-1)
 	assert.Nil(t, err)
 	assert.Equal(t, 10, len(names))
 
+	// the reads share one position, rewound by seeking to the start
+	names, err = dir.Readdirnames(5)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, len(names))
+	_, err = dir.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+
 	names, err = dir.Readdirnames(5)
 	assert.Nil(t, err)
 	assert.Equal(t, 5, len(names))
@@ -353,6 +371,12 @@ func Test_ReadDirFuncs(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 5, len(names))
 
+	names, err = dir.Readdirnames(5)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, len(names))
+	_, err = dir.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+
 	entries, err := dir.ReadDir(-1)
 	assert.Nil(t, err)
 	assert.Equal(t, 10, len(entries))
@@ -363,6 +387,8 @@ func Test_ReadDirFuncs(t *testing.T) {
 	di, err := entries[0].Info()
 	assert.NotNil(t, di)
 	assert.Nil(t, err)
+	_, err = dir.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
 
 	entries, err = dir.ReadDir(5)
 	assert.Nil(t, err)
@@ -372,9 +398,14 @@ func Test_ReadDirFuncs(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 5, len(entries))
 
+	_, err = dir.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+
 	infos, err := dir.Readdir(-1)
 	assert.Nil(t, err)
 	assert.Equal(t, 10, len(infos))
+	_, err = dir.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
 
 	infos, err = dir.Readdir(5)
 	assert.Nil(t, err)
@@ -409,7 +440,7 @@ func Test_ReadDirFuncs(t *testing.T) {
 
 	err = inMemFS.Remove(tmpDirName)
 	assert.NotNil(t, err)
-	assert.True(t, errors.Is(err, os.ErrInvalid))
+	assert.True(t, errors.Is(err, syscall.ENOTEMPTY))
 
 	err = inMemFS.RemoveAll(tmpDirName)
 	assert.Nil(t, err)
@@ -439,3 +470,191 @@ func Test_ReadDirFuncs(t *testing.T) {
 	assert.True(t, errors.Is(err, os.ErrNotExist))
 
 }
+
+func Test_ReadDir_Cursor(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/d/b": nil, "/d/c": nil, "/d/e": nil})
+	assert.Nil(t, err)
+	dir, err := mfs.Open("/d")
+	assert.Nil(t, err)
+
+	names, err := dir.Readdirnames(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c"}, names)
+
+	// entries removed or created behind or ahead of the position
+	assert.Nil(t, mfs.Remove("/d/c"))
+	_, err = mfs.Create("/d/a")
+	assert.Nil(t, err)
+	_, err = mfs.Create("/d/d")
+	assert.Nil(t, err)
+
+	entries, err := dir.ReadDir(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "d", entries[0].Name())
+	assert.Equal(t, "e", entries[1].Name())
+	entries, err = dir.ReadDir(2)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, len(entries))
+	entries, err = dir.ReadDir(-1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	_, err = dir.Seek(1, io.SeekStart)
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+	_, err = dir.Read(make([]byte, 1))
+	assert.True(t, errors.Is(err, syscall.EISDIR))
+	_, err = dir.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	infos, err := dir.Readdir(-1)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(infos))
+	assert.Equal(t, "a", infos[0].Name())
+}
+
+func Test_Max_Open_Files(t *testing.T) {
+	mfs := New(WithMaxOpenFiles(2))
+	f1, err := mfs.Create("/one")
+	assert.Nil(t, err)
+	f2, err := mfs.Open("/")
+	assert.Nil(t, err)
+
+	_, err = mfs.Create("/two")
+	assert.True(t, errors.Is(err, syscall.EMFILE))
+	// nothing is created or truncated by the failed opens
+	_, err = mfs.Stat("/two")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = f1.Write([]byte("data"))
+	assert.Nil(t, err)
+	_, err = mfs.OpenFile("/one", os.O_RDWR|os.O_TRUNC, 0)
+	assert.True(t, errors.Is(err, syscall.EMFILE))
+	fi, err := mfs.Stat("/one")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), fi.Size())
+
+	assert.Nil(t, f2.Close())
+	f3, err := mfs.Open("/one")
+	assert.Nil(t, err)
+	assert.Nil(t, f3.Close())
+	// failed opens don't hold a slot
+	_, err = mfs.Open("/missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	f3, err = mfs.Open("/one")
+	assert.Nil(t, err)
+	assert.Nil(t, f3.Close())
+	assert.Nil(t, f1.Close())
+}
+
+func Test_Fd(t *testing.T) {
+	mfs := New()
+	f1, err := mfs.Create("/one")
+	assert.Nil(t, err)
+	f2, err := mfs.As(1000, 1000).Open("/one")
+	assert.Nil(t, err)
+	assert.NotEqual(t, f1.Fd(), f2.Fd())
+
+	// the views share the table
+	found, open := mfs.FileByFd(f2.Fd())
+	assert.True(t, open)
+	assert.Same(t, f2, found)
+
+	fd := f1.Fd()
+	assert.Nil(t, f1.Close())
+	assert.Equal(t, ^uintptr(0), f1.Fd())
+	_, open = mfs.FileByFd(fd)
+	assert.False(t, open)
+	assert.Nil(t, f2.Close())
+}
+
+func Test_WriteString(t *testing.T) {
+	mfs := New()
+	f, err := mfs.OpenFile("/file", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	var w io.StringWriter = f
+	n, err := w.WriteString("hello, ")
+	assert.Nil(t, err)
+	assert.Equal(t, 7, n)
+	_, err = io.WriteString(f, "world")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, "hello, world", readAll(t, mfs, "/file"))
+
+	r, err := mfs.Open("/file")
+	assert.Nil(t, err)
+	_, err = r.WriteString("read only")
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+	assert.Nil(t, r.Close())
+	_, err = r.WriteString("closed")
+	assert.NotNil(t, err)
+}
+
+func Test_SysInfo(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/dir/sub1", 0755))
+	assert.Nil(t, mfs.MkdirAll("/dir/sub2", 0755))
+	assert.Nil(t, mfs.WriteFile("/dir/file", []byte("x"), 0644))
+	assert.Nil(t, mfs.Chown("/dir/file", 1000, 100))
+
+	sys := func(path string) *SysInfo {
+		fi, err := mfs.Stat(path)
+		assert.Nil(t, err)
+		return fi.Sys().(*SysInfo)
+	}
+	assert.Equal(t, uint64(1), sys("/").Ino)
+	file := sys("/dir/file")
+	assert.Equal(t, SysInfo{Ino: file.Ino, Nlink: 1, UID: 1000, GID: 100, Blocks: 1}, *file)
+	assert.Equal(t, uint32(4), sys("/dir").Nlink)
+	assert.NotEqual(t, file.Ino, sys("/dir").Ino)
+
+	// the number is kept across renames, and the same through handles and listings
+	assert.Nil(t, mfs.Rename("/dir/file", "/dir/renamed"))
+	assert.Equal(t, file.Ino, sys("/dir/renamed").Ino)
+	f, err := mfs.Open("/dir/renamed")
+	assert.Nil(t, err)
+	fi, err := f.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, file.Ino, fi.Sys().(*SysInfo).Ino)
+	entries, err := mfs.ReadDir("/dir")
+	assert.Nil(t, err)
+	info, err := entries[0].Info()
+	assert.Nil(t, err)
+	assert.Equal(t, "renamed", info.Name())
+	assert.Equal(t, file.Ino, info.Sys().(*SysInfo).Ino)
+
+	assert.Nil(t, mfs.Remove("/dir/renamed"))
+	fi, err = f.Stat()
+	assert.NotNil(t, err)
+	assert.Equal(t, uint32(0), info.Sys().(*SysInfo).Nlink)
+	assert.Nil(t, f.Close())
+}
+
+func Test_Mode_Type_Bits(t *testing.T) {
+	mfs := New(WithDevices())
+	assert.Nil(t, mfs.MkdirAll("/dir", 0750))
+	assert.Nil(t, mfs.WriteFile("/dir/file", []byte("x"), 0640))
+
+	fi, err := mfs.Stat("/dir")
+	assert.Nil(t, err)
+	assert.True(t, fi.Mode().IsDir())
+	assert.Equal(t, fs.FileMode(0750), fi.Mode().Perm())
+	fi, err = mfs.Stat("/dir/file")
+	assert.Nil(t, err)
+	assert.True(t, fi.Mode().IsRegular())
+	assert.Equal(t, fs.FileMode(0640), fi.Mode())
+
+	entries, err := mfs.ReadDir("/")
+	assert.Nil(t, err)
+	types := make(map[string]fs.FileMode)
+	for _, e := range entries {
+		types[e.Name()] = e.Type()
+	}
+	assert.Equal(t, fs.ModeDir, types["dir"])
+	assert.Equal(t, fs.ModeDir, types["dev"])
+	entries, err = mfs.ReadDir("/dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "null", entries[0].Name())
+	assert.Equal(t, fs.ModeDevice|fs.ModeCharDevice, entries[0].Type())
+	entries, err = fs.ReadDir(mfs.DirFS("/dir"), ".")
+	assert.Nil(t, err)
+	assert.Equal(t, fs.FileMode(0), entries[0].Type())
+}
@@ -0,0 +1,111 @@
+package memfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// Entry describes a file or directory created by NewFromEntries.
+type Entry struct {
+	Data    []byte
+	Mode    os.FileMode // permission bits, with fs.ModeDir set for a directory, 0666 or 0777 when zero
+	ModTime time.Time   // the clock's time when zero
+}
+
+// NewFromMap returns a filesystem holding the files of the map, keyed by path. Missing
+// parent directories are created.
+//
+//	mfs, err := memfs.NewFromMap(map[string][]byte{"/app/config.json": []byte("{}")})
+func NewFromMap(files map[string][]byte, opts ...Option) (*FS, error) {
+	entries := make(map[string]Entry, len(files))
+	for p, data := range files {
+		entries[p] = Entry{Data: data}
+	}
+	return NewFromEntries(entries, opts...)
+}
+
+// NewFromEntries returns a filesystem holding the files and directories of the map, keyed
+// by path. Missing parent directories are created.
+func NewFromEntries(entries map[string]Entry, opts ...Option) (*FS, error) {
+	f := New(opts...)
+
+	paths := make([]string, 0, len(entries))
+	for p := range entries {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if err := f.createEntry(p, entries[p]); err != nil {
+			return nil, err
+		}
+	}
+	// the times are set last, as creating entries in a directory changes its time
+	for _, p := range paths {
+		if mtime := entries[p].ModTime; !mtime.IsZero() {
+			n, err := f.nodeAt(p)
+			if err != nil {
+				return nil, err
+			}
+			n.lock()
+			n.modified = mtime
+			n.unlock()
+		}
+	}
+	return f, nil
+}
+
+func (f *FS) createEntry(path string, e Entry) error {
+	if path == "" || !f.ValidPath(path) {
+		return fmt.Errorf("invalid path: %s: %w", path, os.ErrInvalid)
+	}
+	path = f.getAbsolutePath(path)
+	if err := f.MkdirAll(parentPath(path), fs.ModePerm); err != nil {
+		return err
+	}
+
+	if e.Mode.IsDir() {
+		perm := e.Mode.Perm()
+		if perm == 0 {
+			perm = fs.ModePerm
+		}
+		if err := f.MkdirAll(path, perm); err != nil {
+			return err
+		}
+	} else {
+		perm := e.Mode.Perm()
+		if perm == 0 {
+			perm = 0666
+		}
+		file, err := f.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+		if err != nil {
+			return err
+		}
+		if _, err = file.Write(e.Data); err != nil {
+			_ = file.Close()
+			return err
+		}
+		if err = file.Close(); err != nil {
+			return err
+		}
+	}
+
+	parentNode, entryNode, _, err := f.getEntry(path)
+	if err != nil {
+		return err
+	}
+	if entryNode == nil {
+		// the root dir
+		entryNode = parentNode
+	}
+	entryNode.lock()
+	if e.Mode.IsDir() && e.Mode.Perm() != 0 {
+		// the directory may have been created earlier as the parent of another entry
+		entryNode.perm = e.Mode.Perm()
+	}
+	entryNode.unlock()
+	return nil
+}
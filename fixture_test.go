@@ -0,0 +1,69 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_NewFromMap(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/app/config.json":   []byte("{}"),
+		"/app/static/a.css":  []byte("body{}"),
+		"/app/static/empty":  nil,
+		"/data/nested/x.bin": {1, 2, 3},
+	})
+	assert.Nil(t, err)
+
+	f, err := mfs.Open("/app/static/a.css")
+	assert.Nil(t, err)
+	data, err := io.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, "body{}", string(data))
+	assert.Nil(t, f.Close())
+
+	fi, err := mfs.Stat("/data/nested")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	fi, err = mfs.Stat("/app/static/empty")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), fi.Size())
+	assert.Equal(t, os.FileMode(0666), fi.Mode())
+
+	_, err = NewFromMap(map[string][]byte{"/a": nil, "/a/b": nil})
+	assert.NotNil(t, err)
+}
+
+func Test_NewFromEntries(t *testing.T) {
+	mtime := time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC)
+	mfs, err := NewFromEntries(map[string]Entry{
+		"/etc":           {Mode: fs.ModeDir | 0700, ModTime: mtime},
+		"/etc/passwd":    {Data: []byte("root"), Mode: 0600, ModTime: mtime},
+		"/var/empty":     {Mode: fs.ModeDir},
+		"/var/log/a.log": {Data: []byte("log")},
+	}, WithClock(NewStepClock(mtime.Add(time.Hour), 0)))
+	assert.Nil(t, err)
+
+	fi, err := mfs.Stat("/etc")
+	assert.Nil(t, err)
+	assert.Equal(t, fs.ModeDir|0700, fi.Mode())
+	assert.Equal(t, mtime, fi.ModTime())
+	fi, err = mfs.Stat("/etc/passwd")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+	assert.Equal(t, mtime, fi.ModTime())
+	fi, err = mfs.Stat("/var/empty")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	assert.Equal(t, fs.ModeDir|fs.ModePerm, fi.Mode())
+	fi, err = mfs.Stat("/var/log/a.log")
+	assert.Nil(t, err)
+	assert.Equal(t, mtime.Add(time.Hour), fi.ModTime())
+
+	_, err = NewFromEntries(map[string]Entry{"": {}})
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package memfs
+
+// Open flags beyond those of the os package, the OS has no equivalent for them.
+const (
+	O_DIRECTORY = 0x10000 // fail unless the path is a directory
+	O_NOFOLLOW  = 0x20000 // fail if the last element of the path is a symbolic link
+)
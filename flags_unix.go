@@ -0,0 +1,12 @@
+//go:build unix
+
+package memfs
+
+import "syscall"
+
+// Open flags beyond those of the os package, with the values of the OS so that flags
+// built for os.OpenFile can be given to OpenFile as they are.
+const (
+	O_DIRECTORY = syscall.O_DIRECTORY // fail unless the path is a directory
+	O_NOFOLLOW  = syscall.O_NOFOLLOW  // fail if the last element of the path is a symbolic link
+)
@@ -0,0 +1,214 @@
+package memfs
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// advisoryLock holds the locks of a node, the flock lock and the byte-range locks held
+// by the files which locked it.
+type advisoryLock struct {
+	mutex     sync.Mutex
+	cond      sync.Cond // broadcast when the flock lock is released
+	exclusive *File
+	shared    map[*File]bool
+	records   []recordLock
+}
+
+// recordLock is a byte-range lock of a file.
+type recordLock struct {
+	file      *File
+	off, end  int64
+	exclusive bool
+}
+
+// advisoryLock returns the lock of the node, created by the first file locking it.
+func (f *fsNode) advisoryLock() *advisoryLock {
+	e := f.extension()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.locks == nil {
+		e.locks = &advisoryLock{shared: make(map[*File]bool)}
+		e.locks.cond.L = &e.locks.mutex
+	}
+	return e.locks
+}
+
+// Lock places an exclusive advisory lock on the file, like flock with LOCK_EX, waiting
+// while other files hold a lock on it. Locks are held by the File, not by the goroutine
+// nor the path, so the files opened again to lock the same file wait as well. Closing the
+// File releases its lock. The locks are advisory, reads and writes ignore them.
+func (f *File) Lock() error {
+	_, err := f.flock(true, true)
+	return err
+}
+
+// TryLock places an exclusive advisory lock on the file like Lock, and returns false
+// rather than waiting when another file holds a lock on it, like flock with LOCK_NB.
+func (f *File) TryLock() (bool, error) {
+	return f.flock(true, false)
+}
+
+// RLock places a shared advisory lock on the file, like flock with LOCK_SH, waiting while
+// another file holds an exclusive lock on it.
+func (f *File) RLock() error {
+	_, err := f.flock(false, true)
+	return err
+}
+
+// Unlock releases the lock placed by Lock, TryLock or RLock, like flock with LOCK_UN, the
+// byte-range locks of the file are kept. Unlocking a file which holds no lock does nothing.
+func (f *File) Unlock() error {
+	if f.closed {
+		return fmt.Errorf("file closed: %s: %w", f.Name(), os.ErrClosed)
+	}
+	if l := f.lockState(); l != nil {
+		l.mutex.Lock()
+		l.release(f)
+		l.mutex.Unlock()
+	}
+	return nil
+}
+
+// flock locks the file, exclusive or shared, and returns false when it would have to
+// wait without wait. Like flock, converting the lock the file holds releases it first.
+func (f *File) flock(exclusive, wait bool) (bool, error) {
+	if f.closed {
+		return false, fmt.Errorf("file closed: %s: %w", f.Name(), os.ErrClosed)
+	}
+	l := f.node.advisoryLock()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.exclusive == f && exclusive || l.shared[f] && !exclusive {
+		return true, nil
+	}
+	if l.exclusive == f || l.shared[f] {
+		l.release(f)
+	}
+	for l.exclusive != nil || exclusive && len(l.shared) > 0 {
+		if !wait {
+			return false, nil
+		}
+		l.cond.Wait()
+	}
+	if exclusive {
+		l.exclusive = f
+	} else {
+		l.shared[f] = true
+	}
+	return true, nil
+}
+
+// lockState returns the locks of the node of the file, nil when it was never locked.
+func (f *File) lockState() *advisoryLock {
+	e := f.node.getExt()
+	if e == nil {
+		return nil
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.locks
+}
+
+// releaseLocks releases the flock lock and the byte-range locks the file holds, once closed.
+func (f *File) releaseLocks() {
+	l := f.lockState()
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.release(f)
+	l.unlockRange(f, 0, math.MaxInt64)
+}
+
+// release removes the lock of file and wakes the files waiting, the caller must hold the mutex.
+func (l *advisoryLock) release(file *File) {
+	if l.exclusive == file {
+		l.exclusive = nil
+	} else if l.shared[file] {
+		delete(l.shared, file)
+	} else {
+		return
+	}
+	l.cond.Broadcast()
+}
+
+// LockRange places a byte-range lock on the n bytes of the file at off, up to any end of
+// the file when n is 0, like fcntl with F_SETLK, exclusive for writing or shared for
+// reading. The lock replaces the locks the File holds in the range, and fails with an
+// error wrapping syscall.EAGAIN when another File holds a lock in the range which
+// conflicts, both being shared or not. An exclusive lock needs a file open for writing,
+// a shared one a file open for reading. Closing the File releases its locks, which are
+// advisory like those of Lock.
+func (f *File) LockRange(off, n int64, exclusive bool) error {
+	end, err := f.lockedRange(off, n)
+	if err != nil {
+		return err
+	}
+	if exclusive && !f.flag.canWrite() || !exclusive && !f.flag.canRead() {
+		return fmt.Errorf("cannot lock for the access mode: %s: %w", f.Name(), syscall.EBADF)
+	}
+	l := f.node.advisoryLock()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, r := range l.records {
+		if r.file != f && r.off < end && off < r.end && (exclusive || r.exclusive) {
+			return fmt.Errorf("range locked: %s [%d, %d): %w", f.Name(), r.off, r.end, syscall.EAGAIN)
+		}
+	}
+	l.unlockRange(f, off, end)
+	l.records = append(l.records, recordLock{file: f, off: off, end: end, exclusive: exclusive})
+	return nil
+}
+
+// UnlockRange releases the byte-range locks the file holds on the n bytes at off, up to
+// any end of the file when n is 0, like fcntl with F_UNLCK.
+func (f *File) UnlockRange(off, n int64) error {
+	end, err := f.lockedRange(off, n)
+	if err != nil {
+		return err
+	}
+	if l := f.lockState(); l != nil {
+		l.mutex.Lock()
+		l.unlockRange(f, off, end)
+		l.mutex.Unlock()
+	}
+	return nil
+}
+
+// lockedRange returns the end of the range of n bytes at off of a byte-range lock.
+func (f *File) lockedRange(off, n int64) (int64, error) {
+	if f.closed {
+		return 0, fmt.Errorf("file closed: %s: %w", f.Name(), os.ErrClosed)
+	}
+	if off < 0 || n < 0 || n > math.MaxInt64-off {
+		return 0, fmt.Errorf("invalid range %d+%d: %s: %w", off, n, f.Name(), syscall.EINVAL)
+	}
+	if n == 0 {
+		return math.MaxInt64, nil
+	}
+	return off + n, nil
+}
+
+// unlockRange removes the range [off, end) from the byte-range locks of file, the caller
+// must hold the mutex.
+func (l *advisoryLock) unlockRange(file *File, off, end int64) {
+	kept := make([]recordLock, 0, len(l.records)+1)
+	for _, r := range l.records {
+		if r.file != file || r.end <= off || r.off >= end {
+			kept = append(kept, r)
+			continue
+		}
+		if r.off < off {
+			kept = append(kept, recordLock{file: file, off: r.off, end: off, exclusive: r.exclusive})
+		}
+		if r.end > end {
+			kept = append(kept, recordLock{file: file, off: end, end: r.end, exclusive: r.exclusive})
+		}
+	}
+	l.records = kept
+}
@@ -0,0 +1,136 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_File_Lock(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.WriteFile("/app.lock", nil, 0644))
+	open := func() *File {
+		f, err := mfs.Open("/app.lock")
+		assert.Nil(t, err)
+		return f
+	}
+	a, b, c := open(), open(), open()
+
+	// shared locks are compatible, an exclusive one is not
+	assert.Nil(t, a.RLock())
+	assert.Nil(t, b.RLock())
+	ok, err := c.TryLock()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, a.Unlock())
+	assert.Nil(t, b.Unlock())
+	ok, err = c.TryLock()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = c.TryLock()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// Lock waits for the holder to unlock, or to be closed
+	locked := make(chan struct{})
+	go func() {
+		assert.Nil(t, a.Lock())
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("locked while held")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Nil(t, c.Close())
+	<-locked
+	ok, err = b.TryLock()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// converting a lock releases it first, like flock
+	assert.Nil(t, a.RLock())
+	assert.Nil(t, b.RLock())
+	ok, err = a.TryLock()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, b.Unlock())
+	assert.Nil(t, a.Lock())
+
+	// files of other paths are not locked
+	assert.Nil(t, mfs.WriteFile("/other.lock", nil, 0644))
+	other, err := mfs.Open("/other.lock")
+	assert.Nil(t, err)
+	ok, err = other.TryLock()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	assert.Nil(t, a.Close())
+	assert.True(t, errors.Is(a.Lock(), os.ErrClosed))
+	assert.True(t, errors.Is(a.Unlock(), os.ErrClosed))
+	assert.Nil(t, b.Lock())
+	assert.Nil(t, b.Close())
+	assert.Nil(t, other.Close())
+}
+
+func Test_File_LockRange(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.WriteFile("/db", make([]byte, 100), 0644))
+	open := func(flag int) *File {
+		f, err := mfs.OpenFile("/db", flag, 0)
+		assert.Nil(t, err)
+		return f
+	}
+	a, b := open(os.O_RDWR), open(os.O_RDWR)
+	locked := func(err error) bool {
+		if err != nil {
+			assert.True(t, errors.Is(err, syscall.EAGAIN), "%v", err)
+		}
+		return err != nil
+	}
+
+	assert.Nil(t, a.LockRange(10, 10, true))
+	assert.True(t, locked(b.LockRange(15, 10, false)))
+	assert.True(t, locked(b.LockRange(0, 0, true)))
+	assert.False(t, locked(b.LockRange(20, 10, true)))
+	assert.False(t, locked(b.LockRange(0, 10, false)))
+
+	// shared locks are compatible, and a file can change its own locks
+	assert.False(t, locked(a.LockRange(0, 5, false)))
+	assert.True(t, locked(a.LockRange(0, 5, true)))
+	assert.False(t, locked(a.LockRange(12, 2, false)))
+	assert.False(t, locked(b.LockRange(12, 2, false)))
+	assert.True(t, locked(b.LockRange(10, 2, false)))
+	assert.True(t, locked(b.LockRange(14, 1, false)))
+
+	// unlocking the middle of a range keeps its ends locked
+	assert.Nil(t, b.UnlockRange(0, 0))
+	assert.Nil(t, a.UnlockRange(11, 8))
+	assert.False(t, locked(b.LockRange(11, 8, true)))
+	assert.True(t, locked(b.LockRange(10, 1, false)))
+	assert.True(t, locked(b.LockRange(19, 1, false)))
+
+	// flock locks are independent of them
+	ok, err := b.TryLock()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, b.Unlock())
+	assert.True(t, locked(b.LockRange(10, 1, false)))
+
+	// closing the file releases its locks
+	assert.Nil(t, a.Close())
+	assert.False(t, locked(b.LockRange(0, 0, true)))
+
+	r := open(os.O_RDONLY)
+	assert.True(t, errors.Is(r.LockRange(200, 1, true), syscall.EBADF))
+	assert.True(t, locked(r.LockRange(200, 1, false)))
+	assert.True(t, errors.Is(r.LockRange(-1, 1, false), syscall.EINVAL))
+	assert.True(t, errors.Is(r.UnlockRange(0, -1), syscall.EINVAL))
+	assert.Nil(t, b.Close())
+	assert.Nil(t, r.LockRange(200, 1, false))
+	assert.Nil(t, r.Close())
+	assert.True(t, errors.Is(r.LockRange(0, 1, false), os.ErrClosed))
+}
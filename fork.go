@@ -0,0 +1,50 @@
+package memfs
+
+// TemplateFS is a tree frozen for Fork to create filesystems from, so an expensive setup
+// is made once and every parallel test, or shard, gets a filesystem of its own.
+type TemplateFS struct {
+	root      *fsNode
+	lastInode uint64 // the inode numbers of the tree are at most this one
+}
+
+// Template returns a template of the tree of f as it is now, changes made to f afterwards
+// don't affect it. Like a Snapshot, it shares file content with f until either modifies it.
+func Template(f *FS) *TemplateFS {
+	root := f.root.clone()
+	// forks copy the template concurrently
+	root.setNoLock(false)
+	return &TemplateFS{root: root, lastInode: f.baseFS().lastInode.Load()}
+}
+
+// Fork returns a new filesystem created with opts whose tree is a copy of the template.
+// The directories and entries are copied, file content is shared with the template and
+// the other forks until it is modified, so forking is cheap and the forks are isolated.
+// Forks can be made concurrently.
+func (t *TemplateFS) Fork(opts ...Option) *FS {
+	f := newFS(t.root.clone(), opts...)
+	// the entries keep the inode numbers of the template, the new entries follow them
+	f.lastInode.Store(max(f.lastInode.Load(), t.lastInode))
+	return f
+}
+
+// Release drops the references the template holds on shared content, it cannot be
+// forked afterwards.
+func (t *TemplateFS) Release() {
+	t.root.unlinkAll()
+}
+
+// setNoLock sets whether the node and the nodes beneath it lock.
+func (f *fsNode) setNoLock(noLock bool) {
+	stack := []*fsNode{f}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n.noLock = noLock
+		if t := n.target(); t != n {
+			t.noLock = noLock
+		}
+		if n.isDir() {
+			stack = append(stack, n.entries.list()...)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package memfs
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sync"
+	"testing"
+)
+
+func Test_Template_Fork(t *testing.T) {
+	setup, err := NewFromMap(map[string][]byte{
+		"/data/a.txt": []byte("a"),
+		"/data/b.txt": []byte("b"),
+	})
+	assert.Nil(t, err)
+	fi, err := setup.Stat("/data/b.txt")
+	assert.Nil(t, err)
+	ino := fi.Sys().(*SysInfo).Ino
+	template := Template(setup)
+	defer template.Release()
+
+	// the template doesn't see the changes made to the filesystem it was made from
+	assert.Nil(t, setup.Remove("/data/a.txt"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f := template.Fork()
+			assert.Equal(t, "a", readAll(t, f, "/data/a.txt"))
+
+			w, err := f.OpenFile("/data/a.txt", os.O_WRONLY|os.O_APPEND, 0)
+			assert.Nil(t, err)
+			_, err = fmt.Fprintf(w, "%d", i)
+			assert.Nil(t, err)
+			assert.Nil(t, w.Close())
+			assert.Equal(t, fmt.Sprintf("a%d", i), readAll(t, f, "/data/a.txt"))
+			assert.Nil(t, f.RemoveAll("/data"))
+		}()
+	}
+	wg.Wait()
+
+	// the forks changed copies
+	f := template.Fork(WithoutLocking())
+	assert.Equal(t, "a", readAll(t, f, "/data/a.txt"))
+	_, err = f.Stat("/data/b.txt")
+	assert.Nil(t, err)
+	_, err = f.Stat(f.TempDir())
+	assert.Nil(t, err)
+
+	// the entries keep their inode numbers, the entries created get new ones
+	assert.Nil(t, f.WriteFile("/data/c.txt", nil, 0644))
+	fi, err = f.Stat("/data/c.txt")
+	assert.Nil(t, err)
+	assert.Greater(t, fi.Sys().(*SysInfo).Ino, ino)
+	fi, err = f.Stat("/data/b.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, ino, fi.Sys().(*SysInfo).Ino)
+}
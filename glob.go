@@ -0,0 +1,156 @@
+package memfs
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Glob returns the paths of the entries matching pattern, or nil when there is none, like
+// filepath.Glob does, so code discovering files by pattern can run against the tree. The
+// pattern uses the separator of the filesystem, and the syntax of filepath.Match, the only
+// error is filepath.ErrBadPattern. Errors reading directories are ignored.
+func (f *FS) Glob(pattern string) ([]string, error) {
+	matches, err := f.glob(f.toSlash(pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range matches {
+		matches[i] = f.fromSlash(m)
+	}
+	return matches, nil
+}
+
+// GlobRecursive is Glob with "**" standing, as a whole element of the pattern, for any
+// number of directories, none included, like in "/src/**/*.go". A pattern ending with
+// "**" matches the directory and everything beneath it. The paths are returned sorted,
+// each once.
+func (f *FS) GlobRecursive(pattern string) ([]string, error) {
+	p := path.Clean(f.toSlash(pattern))
+	if !hasMeta(p) {
+		return f.Glob(pattern)
+	}
+	dir, elems := ".", strings.Split(p, "/")
+	if strings.HasPrefix(p, "/") {
+		dir, elems = "/", elems[1:]
+	}
+	for _, e := range elems {
+		if _, err := path.Match(e, ""); err != nil {
+			return nil, filepath.ErrBadPattern
+		}
+	}
+	// the directories without special characters are not searched
+	for len(elems) > 1 && !hasMeta(elems[0]) {
+		dir, elems = joinPath(dir, elems[0]), elems[1:]
+	}
+	if _, err := f.Stat(dir); err != nil {
+		return nil, nil
+	}
+
+	found := make(map[string]bool)
+	f.globRecursive(dir, elems, found)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	matches := make([]string, 0, len(found))
+	for m := range found {
+		matches = append(matches, f.fromSlash(m))
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// globRecursive adds to found the paths, with "/" separators, beneath the directory dir
+// matching the elements of a pattern.
+func (f *FS) globRecursive(dir string, elems []string, found map[string]bool) {
+	if len(elems) == 0 {
+		found[dir] = true
+		return
+	}
+	entries, err := f.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	if elems[0] == "**" {
+		// no directory, then one more for each of the subdirectories
+		f.globRecursive(dir, elems[1:], found)
+		for _, e := range entries {
+			if e.IsDir() {
+				f.globRecursive(joinPath(dir, e.Name()), elems, found)
+			} else if len(elems) == 1 {
+				found[joinPath(dir, e.Name())] = true
+			}
+		}
+		return
+	}
+	for _, e := range entries {
+		if ok, _ := path.Match(elems[0], e.Name()); !ok {
+			continue
+		}
+		if len(elems) == 1 {
+			found[joinPath(dir, e.Name())] = true
+		} else if e.IsDir() {
+			f.globRecursive(joinPath(dir, e.Name()), elems[1:], found)
+		}
+	}
+}
+
+// glob returns the paths, with "/" separators, matching pattern, a pattern with "/" separators.
+func (f *FS) glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, filepath.ErrBadPattern
+	}
+	if !hasMeta(pattern) {
+		if _, err := f.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := path.Split(pattern)
+	switch dir {
+	case "":
+		dir = "."
+	case "/":
+	default:
+		dir = dir[:len(dir)-1]
+	}
+	if !hasMeta(dir) {
+		return f.globDir(dir, file, nil), nil
+	}
+	if dir == pattern {
+		// a pattern like "[" which cannot be split further
+		return nil, filepath.ErrBadPattern
+	}
+	dirs, err := f.glob(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches = f.globDir(d, file, matches)
+	}
+	return matches, nil
+}
+
+// globDir appends to matches the paths of the entries of dir whose names match pattern,
+// in lexical order.
+func (f *FS) globDir(dir, pattern string, matches []string) []string {
+	entries, err := f.ReadDir(dir)
+	if err != nil {
+		return matches
+	}
+	for _, e := range entries {
+		if ok, _ := path.Match(pattern, e.Name()); ok {
+			matches = append(matches, joinPath(dir, e.Name()))
+		}
+	}
+	return matches
+}
+
+// hasMeta reports whether p, a pattern with "/" separators, contains any of the special
+// characters of path.Match.
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
@@ -0,0 +1,96 @@
+package memfs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Glob(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/etc/conf.d/a.conf":      []byte("a"),
+		"/etc/conf.d/b.conf":      []byte("b"),
+		"/etc/conf.d/c.bak":       []byte("c"),
+		"/etc/other.d/d.conf":     []byte("d"),
+		"/etc/other.d/sub/e.conf": []byte("e"),
+	}, WithSeparator('/'))
+	assert.Nil(t, err)
+
+	matches, err := mfs.Glob("/etc/conf.d/*.conf")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/etc/conf.d/a.conf", "/etc/conf.d/b.conf"}, matches)
+
+	matches, err = mfs.Glob("/etc/*.d/*.conf")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/etc/conf.d/a.conf", "/etc/conf.d/b.conf", "/etc/other.d/d.conf"}, matches)
+
+	matches, err = mfs.Glob("/etc/conf.d/[ab].conf")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/etc/conf.d/a.conf", "/etc/conf.d/b.conf"}, matches)
+
+	// patterns without special characters match an existing entry
+	matches, err = mfs.Glob("/etc/conf.d/c.bak")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/etc/conf.d/c.bak"}, matches)
+	matches, err = mfs.Glob("/etc/missing")
+	assert.Nil(t, err)
+	assert.Nil(t, matches)
+
+	// files have no entries to match
+	matches, err = mfs.Glob("/etc/conf.d/a.conf/*")
+	assert.Nil(t, err)
+	assert.Nil(t, matches)
+
+	_, err = mfs.Glob("/etc/[")
+	assert.Equal(t, filepath.ErrBadPattern, err)
+	_, err = mfs.Glob("/etc/[/*")
+	assert.Equal(t, filepath.ErrBadPattern, err)
+
+	// with the separator of the filesystem
+	mfs, err = NewFromMap(map[string][]byte{"/etc/conf.d/a.conf": []byte("a")}, WithSeparator('\\'))
+	assert.Nil(t, err)
+	matches, err = mfs.Glob(`\etc\*\*.conf`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`\etc\conf.d\a.conf`}, matches)
+}
+
+func Test_GlobRecursive(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/src/main.go":             []byte("a"),
+		"/src/README":              []byte("b"),
+		"/src/pkg/a/a.go":          []byte("c"),
+		"/src/pkg/a/a_test.go":     []byte("d"),
+		"/src/pkg/b/deep/b.go":     []byte("e"),
+		"/src/pkg/b/deep/notes.md": []byte("f"),
+		"/other/c.go":              []byte("g"),
+	}, WithSeparator('/'))
+	assert.Nil(t, err)
+
+	matches, err := mfs.GlobRecursive("/src/**/*.go")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/src/main.go", "/src/pkg/a/a.go", "/src/pkg/a/a_test.go", "/src/pkg/b/deep/b.go"}, matches)
+
+	matches, err = mfs.GlobRecursive("/**/*_test.go")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/src/pkg/a/a_test.go"}, matches)
+
+	matches, err = mfs.GlobRecursive("/src/pkg/**/deep/*")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/src/pkg/b/deep/b.go", "/src/pkg/b/deep/notes.md"}, matches)
+
+	// a trailing ** matches everything beneath the directory, each path once
+	matches, err = mfs.GlobRecursive("/src/pkg/b/**/**")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/src/pkg/b", "/src/pkg/b/deep", "/src/pkg/b/deep/b.go", "/src/pkg/b/deep/notes.md"}, matches)
+
+	// without ** it matches like Glob
+	matches, err = mfs.GlobRecursive("/src/*/a/*.go")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/src/pkg/a/a.go", "/src/pkg/a/a_test.go"}, matches)
+	matches, err = mfs.GlobRecursive("/missing/**/*.go")
+	assert.Nil(t, err)
+	assert.Nil(t, matches)
+
+	_, err = mfs.GlobRecursive("/src/**/[")
+	assert.Equal(t, filepath.ErrBadPattern, err)
+}
@@ -1,8 +1,13 @@
 module github.com/mbordner/memfs
 
-go 1.19
+go 1.23.0
 
-require github.com/stretchr/testify v1.8.1
+require (
+	github.com/stretchr/testify v1.8.1
+	github.com/tetratelabs/wazero v1.9.0
+	golang.org/x/text v0.28.0
+	golang.org/x/tools v0.36.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,96 @@
+package memfs
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// Op is an operation checked by the guard set WithGuard.
+type Op string
+
+const (
+	OpOpen    Op = "open"    // opening a file or a directory to read it
+	OpWrite   Op = "write"   // opening a file to write it, create it or truncate it
+	OpStat    Op = "stat"    // Stat, Access and GetACL
+	OpReadDir Op = "readdir" // ReadDir and ReadDirAfter
+	OpMkdir   Op = "mkdir"   // Mkdir and MkdirAll
+	OpRemove  Op = "remove"  // Remove and RemoveAll
+	OpRename  Op = "rename"  // Rename, checked for the old and the new path
+	OpLink    Op = "link"    // Link, checked for the old and the new path
+	OpChmod   Op = "chmod"   // Chmod and SetACL
+	OpChown   Op = "chown"   // Chown and Lchown
+	OpChtimes Op = "chtimes" // Chtimes
+)
+
+// Identity is the user and group an operation is made as, those given to As, 0 when the
+// filesystem is used directly.
+type Identity struct {
+	UID int
+	GID int
+}
+
+// Guard decides whether an operation is allowed, it is given the absolute path, with "/"
+// separators, in the whole tree, including through the views of OpenRoot and the *At
+// functions. Returning an error denies the operation, which fails with it.
+type Guard func(op Op, path string, id Identity) error
+
+// WithGuard makes the filesystem call guard before every operation, so restrictions like
+// "this component only writes under /out" can be enforced, and tested, in memory. The
+// entries New creates, like the temp directory, are not checked.
+func WithGuard(guard Guard) Option {
+	return func(f *FS) {
+		f.guard = guard
+	}
+}
+
+// checkGuard calls the guard of the filesystem for op on path.
+func (f *FS) checkGuard(op Op, p string) error {
+	base := f.baseFS()
+	if base.guard == nil {
+		return nil
+	}
+	p = f.getAbsolutePath(p)
+	if f.root != base.root {
+		dir, found := base.pathOf(f.root)
+		if !found {
+			return fmt.Errorf("directory removed: %s: %w", p, os.ErrNotExist)
+		}
+		p = path.Join(dir, p)
+	}
+	return base.guard(op, p, Identity{UID: f.uid, GID: f.gid})
+}
+
+// guardOpen calls the guard of the filesystem for opening path with flag.
+func (f *FS) guardOpen(path string, flag int) error {
+	op := OpOpen
+	if fileFlags(flag).canWrite() || flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		op = OpWrite
+	}
+	return f.checkGuard(op, path)
+}
+
+// pathOf returns the path of the directory n in the tree of f, when it is in it.
+func (f *FS) pathOf(n *fsNode) (string, bool) {
+	type dir struct {
+		node *fsNode
+		path string
+	}
+	stack := []dir{{node: f.root, path: "/"}}
+	for len(stack) > 0 {
+		d := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if d.node == n {
+			return d.path, true
+		}
+		d.node.lock()
+		children := d.node.entries.list()
+		d.node.unlock()
+		for _, c := range children {
+			if c.isDir() {
+				stack = append(stack, dir{node: c, path: path.Join(d.path, c.name)})
+			}
+		}
+	}
+	return "", false
+}
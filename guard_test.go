@@ -0,0 +1,60 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Guard(t *testing.T) {
+	var checked []string
+	// the component running as 1000 only writes under /out
+	mfs := New(WithGuard(func(op Op, path string, id Identity) error {
+		checked = append(checked, string(op)+" "+path)
+		if id.UID != 1000 || op == OpOpen || op == OpStat || op == OpReadDir {
+			return nil
+		}
+		if path != "/out" && !strings.HasPrefix(path, "/out/") {
+			return os.ErrPermission
+		}
+		return nil
+	}))
+	assert.Empty(t, checked)
+	assert.Nil(t, mfs.MkdirAll("/out", 0777))
+	assert.Nil(t, mfs.MkdirAll("/in", 0777))
+	assert.Equal(t, []string{"mkdir /out", "mkdir /in"}, checked)
+
+	component := mfs.As(1000, 1000)
+	f, err := component.Create("/out/result")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	_, err = component.Create("/in/result")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	f, err = component.OpenFile("/in", os.O_RDONLY, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.True(t, errors.Is(component.Mkdir("/tmp/dir", 0777), os.ErrPermission))
+	assert.True(t, errors.Is(component.Rename("/out/result", "/in/result"), os.ErrPermission))
+	assert.True(t, errors.Is(component.RemoveAll("/in"), os.ErrPermission))
+	_, err = mfs.Stat("/in")
+	assert.Nil(t, err)
+
+	// the paths are those in the whole tree through views
+	root, err := component.OpenRoot("/out")
+	assert.Nil(t, err)
+	checked = nil
+	assert.Nil(t, root.Mkdir("dir", 0777))
+	assert.Equal(t, []string{"mkdir /out/dir"}, checked)
+	assert.Nil(t, root.Remove("result"))
+
+	in, err := component.Open("/in")
+	assert.Nil(t, err)
+	_, err = component.OpenAt(in, "file", os.O_WRONLY|os.O_CREATE, 0666)
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.Nil(t, in.Close())
+
+	// the filesystem itself is not restricted
+	assert.Nil(t, mfs.Rename("/out/dir", "/in/dir"))
+}
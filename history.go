@@ -0,0 +1,259 @@
+package memfs
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrNoCheckpoint is returned by Undo and Redo when there is no state to step to.
+var ErrNoCheckpoint = errors.New("no checkpoint")
+
+// history holds the states of trees recorded by Checkpoint, Undo and Redo. A state is a
+// copy of the tree sharing file content with it, so recording one is cheap.
+type history struct {
+	mutex sync.Mutex
+	undo  []checkpoint
+	redo  []checkpoint
+}
+
+type checkpoint struct {
+	root *fsNode // the root the state was recorded from, and is restored to
+	tree *fsNode
+}
+
+func (f *FS) history() *history {
+	base := f.baseFS()
+	base.lock()
+	defer base.unlock()
+	if base.checkpoints == nil {
+		base.checkpoints = new(history)
+	}
+	return base.checkpoints
+}
+
+// Checkpoint records the state of the tree for Undo to return to, and discards the
+// states Undo stepped back from.
+func (f *FS) Checkpoint() {
+	h := f.history()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.undo = append(h.undo, checkpoint{root: f.root, tree: f.root.clone()})
+	for _, c := range h.redo {
+		c.tree.unlinkAll()
+	}
+	h.redo = nil
+}
+
+// Undo returns the tree to the state recorded by the last Checkpoint, dropping the changes
+// made since, which Redo brings back. Handles open on entries changed or removed by Undo
+// can no longer be used, like handles to removed files. It returns ErrNoCheckpoint when
+// there is no checkpoint left.
+func (f *FS) Undo() error {
+	h := f.history()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.undo) == 0 {
+		return ErrNoCheckpoint
+	}
+	c := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, checkpoint{root: c.root, tree: c.root.clone()})
+	f.restoreTree(c.root, c.tree)
+	return nil
+}
+
+// Redo returns the tree to the state Undo last stepped back from. It returns
+// ErrNoCheckpoint when Undo was not called since the last Checkpoint or Redo.
+func (f *FS) Redo() error {
+	h := f.history()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.redo) == 0 {
+		return ErrNoCheckpoint
+	}
+	c := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, checkpoint{root: c.root, tree: c.root.clone()})
+	f.restoreTree(c.root, c.tree)
+	return nil
+}
+
+// restoreTree replaces the entries and attributes of root with those of tree, a copy
+// of root taken earlier. The nodes of root the same as the nodes of tree at their path
+// are kept in their place, so the handles open on them stay usable, the other nodes of
+// root are unlinked and their tags move to the nodes replacing them.
+func (f *FS) restoreTree(root, tree *fsNode) {
+	base := f.baseFS()
+	base.lockRename()
+	defer base.unlockRename()
+	r := &restore{
+		kept:     make(map[*fsNode]*fsNode),
+		keeping:  make(map[*fsNode]bool),
+		replaced: make(map[*fsNode]*fsNode),
+	}
+	r.match(root, tree)
+	r.install(tree)
+
+	root.lock()
+	root.entries = tree.entries
+	f.invalidateLookups()
+	root.perm, root.modified = tree.perm, tree.modified
+	// the attributes of the root are restored too, the locks of the files are kept
+	ext := tree.getExt()
+	if e := root.getExt(); e != nil {
+		e.mutex.Lock()
+		locks := e.locks
+		e.mutex.Unlock()
+		if ext == nil {
+			ext = new(nodeExt)
+		}
+		ext.locks = locks
+	}
+	root.ext.Store(ext)
+	root.unlock()
+	r.keep()
+	r.unlink()
+
+	base.lock()
+	t := base.tags
+	base.unlock()
+	if t != nil {
+		t.mutex.Lock()
+		for n, old := range r.replaced {
+			if old != nil && r.kept[old] == nil {
+				t.move(n, old)
+			} else {
+				t.drop(n)
+			}
+		}
+		t.mutex.Unlock()
+	}
+}
+
+// restore holds the nodes of a tree matched by restoreTree with those of the copy
+// restored.
+type restore struct {
+	kept     map[*fsNode]*fsNode // the nodes of the copy, mapped to the nodes kept in their place
+	keeping  map[*fsNode]bool    // the nodes kept
+	replaced map[*fsNode]*fsNode // the other nodes, mapped to the nodes of the copy at their path
+	entries  []*fsNode           // the entries of the tree beneath the root
+}
+
+// match matches the entries beneath the directory cur with those of old, its copy.
+func (r *restore) match(cur, old *fsNode) {
+	cur.lock()
+	children := cur.entries.list()
+	cur.unlock()
+	for _, c := range children {
+		r.entries = append(r.entries, c)
+		o, exists := old.entries.get(c.name)
+		if !exists || c.isDir() != o.isDir() {
+			r.replace(c.target(), nil)
+			if c.isDir() {
+				r.match(c, &fsNode{entries: newDirEntries()})
+			}
+			continue
+		}
+		ct, ot := c.target(), o.target()
+		switch {
+		case r.kept[ot] == ct:
+		case r.kept[ot] == nil && !r.keeping[ct] && sameNode(ct, ot):
+			r.kept[ot], r.keeping[ct] = ct, true
+			delete(r.replaced, ct)
+		default:
+			r.replace(ct, ot)
+		}
+		if c.isDir() {
+			r.match(c, o)
+		}
+	}
+}
+
+// replace records the node n as replaced by old, the node at its path in the copy, or
+// dropped when old is nil.
+func (r *restore) replace(n, old *fsNode) {
+	if r.keeping[n] {
+		return
+	}
+	if prev, exists := r.replaced[n]; !exists || prev == nil {
+		r.replaced[n] = old
+	}
+}
+
+// install puts the nodes kept in the place of their copies beneath the directory dir of
+// the copy, which is not reachable yet, and points the hard links of the copy at them.
+func (r *restore) install(dir *fsNode) {
+	for i, n := range dir.entries.list() {
+		if t := n.target(); t != n {
+			if k := r.kept[t]; k != nil {
+				n.getExt().link = k
+			}
+			continue
+		}
+		if n.isDir() {
+			r.install(n)
+		}
+		k := r.kept[n]
+		if k == nil {
+			continue
+		}
+		dir.entries.replace(i, k)
+		if n.isDir() {
+			k.lock()
+			k.entries = n.entries
+			k.unlock()
+		}
+	}
+}
+
+// keep gives the files kept the names of their copies, whose content is released.
+func (r *restore) keep() {
+	for old, k := range r.kept {
+		if old.isDir() {
+			continue
+		}
+		var names uint32
+		if e := old.getExt(); e != nil {
+			names = e.names
+		}
+		if e := k.getExt(); e != nil || names > 0 {
+			e = k.extension()
+			k.lock()
+			e.mutex.Lock()
+			e.names = names
+			e.mutex.Unlock()
+			k.unlock()
+		}
+		old.release()
+	}
+}
+
+// unlink unlinks the entries of the tree which were not kept, the files kept stay named
+// by the hard links of the copy.
+func (r *restore) unlink() {
+	for _, n := range r.entries {
+		switch {
+		case r.keeping[n]:
+		case n.isDir():
+			n.lock()
+			n.unlinked = true
+			n.unlock()
+			n.release()
+		case r.keeping[n.target()]:
+			n.lock()
+			n.unlinked = true
+			n.unlock()
+		default:
+			n.unlinkName()
+		}
+	}
+}
+
+// sameNode returns whether the node n is unchanged since old, its earlier copy.
+func sameNode(n, old *fsNode) bool {
+	if n.isDir() != old.isDir() || changedSince(n, old) {
+		return false
+	}
+	return (n.device() == nil) == (old.device() == nil) && slices.Equal(n.extendedACL(), old.extendedACL())
+}
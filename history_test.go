@@ -0,0 +1,166 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func Test_Undo_Redo(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/doc.txt": []byte("v1")})
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(mfs.Undo(), ErrNoCheckpoint))
+	assert.True(t, errors.Is(mfs.Redo(), ErrNoCheckpoint))
+
+	write := func(path, data string) {
+		f, err := mfs.Create(path)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(data))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+	}
+
+	mfs.Checkpoint()
+	write("/doc.txt", "v2")
+	assert.Nil(t, mfs.Mkdir("/new", 0755))
+	mfs.Checkpoint()
+	write("/doc.txt", "v3")
+	assert.Nil(t, mfs.Remove("/new"))
+
+	open, err := mfs.Open("/doc.txt")
+	assert.Nil(t, err)
+	defer open.Close()
+
+	assert.Nil(t, mfs.Undo())
+	assert.Equal(t, "v2", readAll(t, mfs, "/doc.txt"))
+	_, err = mfs.Stat("/new")
+	assert.Nil(t, err)
+	_, err = open.Stat()
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	assert.Nil(t, mfs.Undo())
+	assert.Equal(t, "v1", readAll(t, mfs, "/doc.txt"))
+	_, err = mfs.Stat("/new")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	assert.True(t, errors.Is(mfs.Undo(), ErrNoCheckpoint))
+
+	assert.Nil(t, mfs.Redo())
+	assert.Equal(t, "v2", readAll(t, mfs, "/doc.txt"))
+	assert.Nil(t, mfs.Redo())
+	assert.Equal(t, "v3", readAll(t, mfs, "/doc.txt"))
+	assert.True(t, errors.Is(mfs.Redo(), ErrNoCheckpoint))
+
+	// a checkpoint drops the states undone
+	assert.Nil(t, mfs.Undo())
+	mfs.Checkpoint()
+	assert.True(t, errors.Is(mfs.Redo(), ErrNoCheckpoint))
+	write("/doc.txt", "v4")
+	assert.Nil(t, mfs.Undo())
+	assert.Equal(t, "v2", readAll(t, mfs, "/doc.txt"))
+}
+
+func Test_Undo_Keeps_Attributes(t *testing.T) {
+	mfs := New(WithTimes(), WithRangeTracking(), WithSparseFiles(), WithVerifyOnRead())
+	f, err := mfs.Create("/file")
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte("data"), 1000)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Nil(t, mfs.Chown("/file", 1000, 1000))
+	fi, err := mfs.Stat("/file")
+	assert.Nil(t, err)
+	before := *fi.Sys().(*SysInfo)
+
+	mfs.Checkpoint()
+	_, removed, _, err := mfs.getEntry("/file")
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.Remove("/file"))
+	// the removed node drops what holds memory
+	assert.Nil(t, removed.getExt().ranges)
+	assert.Nil(t, removed.getExt().sparse)
+	assert.Nil(t, mfs.Undo())
+
+	fi, err = mfs.Stat("/file")
+	assert.Nil(t, err)
+	after := fi.Sys().(*SysInfo)
+	assert.Equal(t, before.Ino, after.Ino)
+	assert.Equal(t, 1000, after.UID)
+	assert.Equal(t, 1000, after.GID)
+	assert.Equal(t, before.Times.Birth, after.Times.Birth)
+	assert.Equal(t, before.Blocks, after.Blocks)
+
+	f, err = mfs.Open("/file")
+	assert.Nil(t, err)
+	defer f.Close()
+	assert.Equal(t, []ByteRange{{Offset: 1000, Length: 4}}, f.WrittenRanges())
+	off, err := f.Seek(0, SeekData)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), off)
+
+	// the entries created afterwards get new inode numbers
+	assert.Nil(t, mfs.WriteFile("/other", nil, 0644))
+	fi, err = mfs.Stat("/other")
+	assert.Nil(t, err)
+	assert.Greater(t, fi.Sys().(*SysInfo).Ino, before.Ino)
+}
+
+func Test_Undo_Keeps_Unchanged_Files(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/keep": []byte("keep"), "/dir/changed": []byte("v1")})
+	assert.Nil(t, err)
+	keep, err := mfs.OpenFile("/keep", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	defer keep.Close()
+	changed, err := mfs.Open("/dir/changed")
+	assert.Nil(t, err)
+	defer changed.Close()
+
+	mfs.Checkpoint()
+	assert.Nil(t, mfs.WriteFile("/other", []byte("other"), 0644))
+	assert.Nil(t, mfs.WriteFile("/dir/changed", []byte("v2"), 0644))
+	assert.Nil(t, mfs.Undo())
+
+	// the handles open on files the undo doesn't change stay usable
+	_, err = keep.Stat()
+	assert.Nil(t, err)
+	_, err = changed.Stat()
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+	assert.Equal(t, "v1", readAll(t, mfs, "/dir/changed"))
+	_, err = mfs.Stat("/other")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	assert.Nil(t, mfs.Redo())
+	_, err = keep.Write([]byte("KEEP"))
+	assert.Nil(t, err)
+	assert.Equal(t, "KEEP", readAll(t, mfs, "/keep"))
+	assert.Equal(t, "other", readAll(t, mfs, "/other"))
+}
+
+func Test_Undo_Keeps_Tags(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/keep": []byte("keep"), "/changed": []byte("v1")})
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.SetTag("/keep", "state", "kept"))
+	assert.Nil(t, mfs.SetTag("/changed", "state", "changed"))
+
+	mfs.Checkpoint()
+	assert.Nil(t, mfs.WriteFile("/changed", []byte("v2"), 0644))
+	assert.Nil(t, mfs.WriteFile("/added", []byte("added"), 0644))
+	assert.Nil(t, mfs.SetTag("/added", "state", "added"))
+	assert.Nil(t, mfs.Undo())
+
+	tags, err := mfs.Tags("/keep")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"state": "kept"}, tags)
+	tags, err = mfs.Tags("/changed")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"state": "changed"}, tags)
+	paths, err := mfs.Query("state")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/changed", "/keep"}, paths)
+
+	assert.Nil(t, mfs.Redo())
+	tags, err = mfs.Tags("/changed")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"state": "changed"}, tags)
+}
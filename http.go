@@ -0,0 +1,196 @@
+package memfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"syscall"
+	"time"
+)
+
+// HTTPEntry describes a file or directory in the listings served by the HTTP handler.
+type HTTPEntry struct {
+	Name    string      `json:"name"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	IsDir   bool        `json:"isDir"`
+}
+
+type httpHandler struct {
+	fs *FS
+}
+
+// Handler returns an http.Handler exposing the tree over HTTP, with request paths
+// mapping directly to filesystem paths (use http.StripPrefix to mount it elsewhere).
+//
+//	GET, HEAD  serve file content, including range requests, or a JSON listing of a directory
+//	PROPFIND   returns a JSON listing, of the entry itself with "Depth: 0", of a directory's entries otherwise
+//	PUT        creates or replaces a file with the request body
+//	MKCOL      creates a directory
+//	DELETE     removes a file or empty directory, or a whole tree with "?recursive=true"
+func (f *FS) Handler() http.Handler {
+	return &httpHandler{fs: f}
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, name)
+	case "PROPFIND":
+		h.propfind(w, r, name)
+	case http.MethodPut:
+		h.put(w, r, name)
+	case "MKCOL":
+		if err := h.fs.Mkdir(name, fs.ModePerm); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		var err error
+		if r.URL.Query().Get("recursive") == "true" {
+			err = h.fs.RemoveAll(name)
+		} else {
+			err = h.fs.Remove(name)
+		}
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PROPFIND, PUT, MKCOL, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *httpHandler) get(w http.ResponseWriter, r *http.Request, name string) {
+	h.fs.ServeFile(w, r, name)
+}
+
+// ServeFile replies to the request with the content of the file at name, or a JSON listing
+// when it is a directory. The Last-Modified header comes from the modification time of the
+// file and a strong ETag from the hash of its content, so conditional and range requests
+// are answered like http.ServeContent does for os files. With WithPrecompression, the
+// file is served compressed when the request accepts one of the encodings.
+func (f *FS) ServeFile(w http.ResponseWriter, r *http.Request, name string) {
+	file, err := f.Open(name)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if file.isDir() {
+		(&httpHandler{fs: f}).list(w, name, false)
+		return
+	}
+
+	if f.serveCompressed(w, r, file, name) {
+		return
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, file.node.contentHash()))
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), file)
+}
+
+func (h *httpHandler) propfind(w http.ResponseWriter, r *http.Request, name string) {
+	h.list(w, name, r.Header.Get("Depth") == "0")
+}
+
+func (h *httpHandler) list(w http.ResponseWriter, name string, self bool) {
+	fi, err := h.fs.Stat(name)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	var listing []HTTPEntry
+	if self || !fi.IsDir() {
+		listing = []HTTPEntry{newHTTPEntry(fi)}
+	} else {
+		entries, err := h.fs.ReadDir(name)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		listing = make([]HTTPEntry, 0, len(entries))
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				writeHTTPError(w, err)
+				return
+			}
+			listing = append(listing, newHTTPEntry(info))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(listing)
+}
+
+func (h *httpHandler) put(w http.ResponseWriter, r *http.Request, name string) {
+	_, err := h.fs.Stat(name)
+	created := errors.Is(err, os.ErrNotExist)
+
+	file, err := h.fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	_, err = io.Copy(file, r.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newHTTPEntry(fi os.FileInfo) HTTPEntry {
+	return HTTPEntry{
+		Name:    fi.Name(),
+		Size:    fi.Size(),
+		Mode:    fi.Mode(),
+		ModTime: fi.ModTime(),
+		IsDir:   fi.IsDir(),
+	}
+}
+
+func writeHTTPError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		code = http.StatusNotFound
+	case errors.Is(err, os.ErrPermission):
+		code = http.StatusForbidden
+	case errors.Is(err, os.ErrExist):
+		code = http.StatusConflict
+	case errors.Is(err, os.ErrInvalid), errors.Is(err, syscall.EISDIR), errors.Is(err, syscall.ENOTDIR):
+		code = http.StatusBadRequest
+	case errors.Is(err, syscall.EMFILE):
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), code)
+}
@@ -0,0 +1,148 @@
+package memfs
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func doRequest(t *testing.T, h http.Handler, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func Test_Handler_CRUD(t *testing.T) {
+	mfs := New()
+	h := mfs.Handler()
+
+	w := doRequest(t, h, "MKCOL", "/data", nil, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = doRequest(t, h, http.MethodPut, "/data/file.txt", strings.NewReader("hello world"), nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = doRequest(t, h, http.MethodPut, "/data/file.txt", strings.NewReader("hello memfs"), nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doRequest(t, h, http.MethodPut, "/missing/file.txt", strings.NewReader("x"), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(t, h, http.MethodGet, "/data/file.txt", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello memfs", w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+
+	w = doRequest(t, h, http.MethodGet, "/data/file.txt", nil, map[string]string{"Range": "bytes=6-"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "memfs", w.Body.String())
+
+	w = doRequest(t, h, http.MethodGet, "/data/missing.txt", nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(t, h, http.MethodDelete, "/data", nil, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doRequest(t, h, http.MethodDelete, "/data?recursive=true", nil, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err := mfs.Stat("/data")
+	assert.NotNil(t, err)
+
+	w = doRequest(t, h, http.MethodPost, "/data", nil, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func Test_Handler_Listings(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/dir/sub", 0755))
+	f, err := mfs.Create("/dir/file")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(`12345`))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	h := mfs.Handler()
+
+	var listing []HTTPEntry
+	w := doRequest(t, h, "PROPFIND", "/dir", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	assert.Len(t, listing, 2)
+	assert.Equal(t, "file", listing[0].Name)
+	assert.Equal(t, int64(5), listing[0].Size)
+	assert.False(t, listing[0].IsDir)
+	assert.Equal(t, "sub", listing[1].Name)
+	assert.True(t, listing[1].IsDir)
+
+	w = doRequest(t, h, "PROPFIND", "/dir", nil, map[string]string{"Depth": "0"})
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	assert.Len(t, listing, 1)
+	assert.Equal(t, "dir", listing[0].Name)
+
+	w = doRequest(t, h, http.MethodGet, "/dir/", nil, nil)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	assert.Len(t, listing, 2)
+
+	w = doRequest(t, h, "PROPFIND", "/nothing", nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func Test_ServeFile(t *testing.T) {
+	mtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	mfs, err := NewFromEntries(map[string]Entry{"/site/index.html": {Data: []byte("<p>hello</p>"), ModTime: mtime}})
+	assert.Nil(t, err)
+	sum := sha256.Sum256([]byte("<p>hello</p>"))
+	etag := fmt.Sprintf(`"%x"`, sum)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mfs.ServeFile(w, r, "/site"+r.URL.Path)
+	})
+
+	w := doRequest(t, h, http.MethodGet, "/index.html", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, mtime.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<p>hello</p>", w.Body.String())
+
+	w = doRequest(t, h, http.MethodGet, "/index.html", nil, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = doRequest(t, h, http.MethodGet, "/index.html", nil, map[string]string{"If-Modified-Since": mtime.Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = doRequest(t, h, http.MethodGet, "/index.html", nil, map[string]string{"If-Match": `"other"`})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = doRequest(t, h, http.MethodGet, "/index.html", nil, map[string]string{"Range": "bytes=3-7", "If-Range": etag})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+	w = doRequest(t, h, http.MethodGet, "/index.html", nil, map[string]string{"Range": "bytes=3-7", "If-Range": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<p>hello</p>", w.Body.String())
+
+	// writing the file changes its modification time
+	f, err := mfs.OpenFile("/site/index.html", os.O_WRONLY, 0)
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte("<P>"), 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	w = doRequest(t, h, http.MethodGet, "/index.html", nil, map[string]string{"If-Modified-Since": mtime.Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<P>hello</p>", w.Body.String())
+	assert.NotEqual(t, mtime.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+
+	w = doRequest(t, h, http.MethodGet, "/missing.html", nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
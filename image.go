@@ -0,0 +1,311 @@
+package memfs
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// imageMagic starts every image, followed by the format version.
+const (
+	imageMagic   = "memfsimg"
+	imageVersion = 1
+
+	imageCompressed = 1 // entry flag, the stored content is deflated
+)
+
+// An image is a packed read-only tree: the magic, the version and the number of entries
+// as little endian uint32s, the table of entries, then the content of the files, each
+// aligned to 8 bytes. The root comes first in the table, every other entry comes after
+// its parent:
+//
+//	parent   uint32 index of the parent directory
+//	mode     uint32 fs.FileMode, permission bits and fs.ModeDir
+//	uid, gid int32
+//	modified int64  Unix time in nanoseconds
+//	offset   uint64 of the content from the start of the image
+//	size     uint64 of the stored content
+//	length   uint64 of the content once inflated
+//	flags    uint8
+//	name     uint16 length followed by the name
+type imageEntry struct {
+	parent   uint32
+	mode     fs.FileMode
+	uid, gid int32
+	modified int64
+	offset   uint64
+	size     uint64
+	length   uint64
+	flags    uint8
+	name     string
+
+	content []byte // the stored content, while writing
+}
+
+// Image is a read-only tree opened from a packed image, it serves the content of its
+// files from the image without copying it.
+type Image struct {
+	fs.FS
+	close func() error
+}
+
+// Close releases the image. Files must not be read once it is closed, which includes files
+// copied out of a mapped image with CopyFS, as they share its content until modified.
+func (i *Image) Close() error {
+	if i.close == nil {
+		return nil
+	}
+	err := i.close()
+	i.close = nil
+	return err
+}
+
+// WriteImage writes root and everything beneath it to w as a packed image, for OpenImage
+// and OpenImageFile to open. Compressed images are smaller, but their files are inflated
+// when the image is opened, instead of being read from the image.
+func (f *FS) WriteImage(w io.Writer, root string, compress bool) error {
+	parentNode, entryNode, missingPath, err := f.getEntry(root)
+	if err != nil {
+		return err
+	}
+	if missingPath != "" {
+		return fmt.Errorf("path does not exist: %s: %w", root, os.ErrNotExist)
+	}
+	if entryNode == nil {
+		entryNode = parentNode
+	}
+	if !entryNode.isDir() {
+		return fmt.Errorf("not a directory: %s: %w", root, syscall.ENOTDIR)
+	}
+
+	// a copy of the tree keeps the content from changing while it is written, without copying it
+	tree := entryNode.clone()
+	defer tree.unlinkAll()
+	// entries come after their parent, the stack holds the nodes waiting to be added
+	type pending struct {
+		node   *fsNode
+		parent uint32
+		depth  int
+	}
+	var entries []*imageEntry
+	stack := []pending{{node: tree}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		// a hard link is written as a copy of its file
+		n := p.node.target()
+		if err = f.checkDepth(p.node.name, p.depth); err != nil {
+			return err
+		}
+		e := &imageEntry{
+			parent:   p.parent,
+			mode:     n.perm.Perm(),
+			modified: n.modified.UnixNano(),
+			name:     p.node.name,
+		}
+		uid, gid := n.owner()
+		e.uid, e.gid = int32(uid), int32(gid)
+		if len(entries) == 0 {
+			e.name = ""
+		}
+		if n.isDir() {
+			e.mode |= fs.ModeDir
+		} else {
+			e.content = n.getContent()
+			e.length = uint64(len(e.content))
+			if compress && len(e.content) > 0 {
+				var buf bytes.Buffer
+				zw, _ := flate.NewWriter(&buf, flate.BestCompression)
+				_, _ = zw.Write(e.content)
+				if err := zw.Close(); err != nil {
+					return err
+				}
+				e.content, e.flags = buf.Bytes(), imageCompressed
+			}
+			e.size = uint64(len(e.content))
+		}
+		if len(e.name) > 0xffff {
+			return fmt.Errorf("name too long: %s: %w", e.name, os.ErrInvalid)
+		}
+		index := uint32(len(entries))
+		entries = append(entries, e)
+		children := n.entries.list()
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, pending{node: children[i], parent: index, depth: p.depth + 1})
+		}
+	}
+
+	offset := uint64(len(imageMagic) + 8)
+	for _, e := range entries {
+		offset += 4 + 4 + 4 + 4 + 8 + 8 + 8 + 8 + 1 + 2 + uint64(len(e.name))
+	}
+	for _, e := range entries {
+		if e.size > 0 {
+			offset = (offset + 7) &^ 7
+			e.offset, offset = offset, offset+e.size
+		}
+	}
+
+	buf := bytes.NewBufferString(imageMagic)
+	buf.Write(binary.LittleEndian.AppendUint32(nil, imageVersion))
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(entries))))
+	for _, e := range entries {
+		b := binary.LittleEndian.AppendUint32(nil, e.parent)
+		b = binary.LittleEndian.AppendUint32(b, uint32(e.mode))
+		b = binary.LittleEndian.AppendUint32(b, uint32(e.uid))
+		b = binary.LittleEndian.AppendUint32(b, uint32(e.gid))
+		b = binary.LittleEndian.AppendUint64(b, uint64(e.modified))
+		b = binary.LittleEndian.AppendUint64(b, e.offset)
+		b = binary.LittleEndian.AppendUint64(b, e.size)
+		b = binary.LittleEndian.AppendUint64(b, e.length)
+		b = append(b, e.flags)
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(e.name)))
+		buf.Write(append(b, e.name...))
+	}
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	written := uint64(buf.Len())
+	var padding [8]byte
+	for _, e := range entries {
+		if e.size == 0 {
+			continue
+		}
+		if _, err = w.Write(padding[:e.offset-written]); err != nil {
+			return err
+		}
+		if _, err = w.Write(e.content); err != nil {
+			return err
+		}
+		written = e.offset + e.size
+	}
+	return nil
+}
+
+// OpenImage opens the image held by data, which must not be modified while the image is in use.
+func OpenImage(data []byte) (*Image, error) {
+	f := New()
+	root, err := f.readImage(data)
+	if err != nil {
+		return nil, err
+	}
+	f.root = root
+	return &Image{FS: f.IOFS()}, nil
+}
+
+// OpenImageFile opens the image in the file at name of the OS filesystem. The file is
+// mapped in memory where the OS allows it, so that processes opening the same image share
+// its pages, and only the pages of the files read are loaded.
+func OpenImageFile(name string) (*Image, error) {
+	data, unmap, err := mapFile(name)
+	if err != nil {
+		return nil, err
+	}
+	image, err := OpenImage(data)
+	if err != nil {
+		_ = unmap()
+		return nil, err
+	}
+	image.close = unmap
+	return image, nil
+}
+
+var errInvalidImage = fmt.Errorf("invalid image: %w", os.ErrInvalid)
+
+// imageReader reads the little endian values of an image, it stops at the first error.
+type imageReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *imageReader) next(n int) []byte {
+	if r.err != nil || len(r.data)-r.pos < n {
+		r.err = errInvalidImage
+		return make([]byte, n)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *imageReader) uint16() uint16 { return binary.LittleEndian.Uint16(r.next(2)) }
+func (r *imageReader) uint32() uint32 { return binary.LittleEndian.Uint32(r.next(4)) }
+func (r *imageReader) uint64() uint64 { return binary.LittleEndian.Uint64(r.next(8)) }
+
+// readImage returns the root of the tree held by data.
+func (f *FS) readImage(data []byte) (*fsNode, error) {
+	r := &imageReader{data: data}
+	if string(r.next(len(imageMagic))) != imageMagic || r.err != nil {
+		return nil, fmt.Errorf("not a memfs image: %w", os.ErrInvalid)
+	}
+	if version := r.uint32(); version != imageVersion {
+		return nil, fmt.Errorf("unsupported image version %d: %w", version, os.ErrInvalid)
+	}
+	count := r.uint32()
+	if count == 0 || uint64(count) > uint64(len(data)) {
+		return nil, errInvalidImage
+	}
+
+	nodes := make([]*fsNode, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var e imageEntry
+		e.parent = r.uint32()
+		e.mode = fs.FileMode(r.uint32())
+		e.uid, e.gid = int32(r.uint32()), int32(r.uint32())
+		e.modified = int64(r.uint64())
+		e.offset, e.size, e.length = r.uint64(), r.uint64(), r.uint64()
+		e.flags = r.next(1)[0]
+		e.name = string(r.next(int(r.uint16())))
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		n := f.newNode(e.name, e.mode.Perm(), e.mode.IsDir())
+		n.setOwner(int(e.uid), int(e.gid))
+		n.modified = time.Unix(0, e.modified)
+		if !n.isDir() {
+			if e.offset > uint64(len(data)) || e.size > uint64(len(data))-e.offset {
+				return nil, fmt.Errorf("content out of image: %s: %w", e.name, errInvalidImage)
+			}
+			content := data[e.offset : e.offset+e.size : e.offset+e.size]
+			if e.flags&imageCompressed != 0 {
+				inflated, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(content)), int64(e.length)))
+				if err != nil {
+					return nil, fmt.Errorf("corrupt content: %s: %w", e.name, errors.Join(errInvalidImage, err))
+				}
+				n.content = inflated
+			} else if len(content) > 0 {
+				// content read from the image is copied before it is modified
+				n.content, n.blob = content, newBlob(content)
+			}
+			if uint64(len(n.content)) != e.length {
+				return nil, fmt.Errorf("corrupt content: %s: %w", e.name, errInvalidImage)
+			}
+		}
+
+		if i > 0 {
+			if e.parent >= i || !nodes[e.parent].isDir() || e.name == "" || e.name == "." || e.name == ".." ||
+				strings.Contains(e.name, "/") {
+				return nil, fmt.Errorf("invalid entry: %s: %w", e.name, errInvalidImage)
+			}
+			parent := nodes[e.parent]
+			if _, exists := parent.entries.get(e.name); exists {
+				return nil, fmt.Errorf("duplicate entry: %s: %w", e.name, errInvalidImage)
+			}
+			parent.entries.set(n)
+		} else if !n.isDir() {
+			return nil, fmt.Errorf("root is not a directory: %w", errInvalidImage)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes[0], nil
+}
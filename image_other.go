@@ -0,0 +1,14 @@
+//go:build !unix
+
+package memfs
+
+import "os"
+
+// mapFile reads the file at name in memory, the OS has no mapping available to the syscall package.
+func mapFile(name string) (data []byte, unmap func() error, err error) {
+	data, err = os.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
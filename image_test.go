@@ -0,0 +1,102 @@
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+	"unsafe"
+)
+
+func Test_Image(t *testing.T) {
+	mtime := time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)
+	src, err := NewFromEntries(map[string]Entry{
+		"/fixtures/a.txt":         {Data: []byte(strings.Repeat("a", 1000)), ModTime: mtime},
+		"/fixtures/empty":         {},
+		"/fixtures/sub/b.bin":     {Data: []byte{1, 2, 3}, Mode: 0600},
+		"/fixtures/sub/deep/dir/": {Mode: fs.ModeDir | 0700},
+	})
+	assert.Nil(t, err)
+
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		assert.Nil(t, src.WriteImage(&buf, "/fixtures", compress))
+		data := buf.Bytes()
+		img, err := OpenImage(data)
+		assert.Nil(t, err)
+		assert.Nil(t, fstest.TestFS(img, "a.txt", "empty", "sub/b.bin", "sub/deep/dir"))
+
+		content, err := fs.ReadFile(img, "a.txt")
+		assert.Nil(t, err)
+		assert.Equal(t, strings.Repeat("a", 1000), string(content))
+		info, err := fs.Stat(img, "a.txt")
+		assert.Nil(t, err)
+		assert.True(t, mtime.Equal(info.ModTime()))
+		info, err = fs.Stat(img, "sub/b.bin")
+		assert.Nil(t, err)
+		assert.Equal(t, fs.FileMode(0600), info.Mode())
+		info, err = fs.Stat(img, "sub/deep/dir")
+		assert.Nil(t, err)
+		assert.Equal(t, fs.ModeDir|0700, info.Mode())
+
+		// uncompressed content is read from the image itself
+		n := img.FS.(*ioFS).fs.root
+		a, _ := n.entries.get("a.txt")
+		inImage := uintptr(unsafe.Pointer(&a.content[0])) >= uintptr(unsafe.Pointer(&data[0])) &&
+			uintptr(unsafe.Pointer(&a.content[0])) < uintptr(unsafe.Pointer(&data[0]))+uintptr(len(data))
+		assert.Equal(t, !compress, inImage)
+		if compress {
+			assert.Less(t, len(data), 1000)
+		}
+
+		// copies are modified without modifying the image
+		dst := New()
+		assert.Nil(t, dst.CopyFS("/copy", img))
+		f, err := dst.OpenFile("/copy/a.txt", os.O_WRONLY, 0)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte("b"))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+		content, err = fs.ReadFile(img, "a.txt")
+		assert.Nil(t, err)
+		assert.Equal(t, strings.Repeat("a", 1000), string(content))
+		assert.Nil(t, img.Close())
+
+		for _, corrupt := range [][]byte{nil, []byte("memfsimg"), data[:len(data)-1], append([]byte("other"), data[5:]...)} {
+			_, err = OpenImage(corrupt)
+			assert.True(t, errors.Is(err, os.ErrInvalid))
+		}
+	}
+
+	err = src.WriteImage(&bytes.Buffer{}, "/fixtures/a.txt", false)
+	assert.True(t, errors.Is(err, syscall.ENOTDIR))
+	err = src.WriteImage(&bytes.Buffer{}, "/missing", false)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func Test_OpenImageFile(t *testing.T) {
+	src, err := NewFromMap(map[string][]byte{"/data/file": []byte("content")})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.Nil(t, src.WriteImage(&buf, "/data", false))
+	name := filepath.Join(t.TempDir(), "fixtures.img")
+	assert.Nil(t, os.WriteFile(name, buf.Bytes(), 0644))
+
+	img, err := OpenImageFile(name)
+	assert.Nil(t, err)
+	content, err := fs.ReadFile(img, "file")
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(content))
+	assert.Nil(t, img.Close())
+	assert.Nil(t, img.Close())
+
+	_, err = OpenImageFile(filepath.Join(t.TempDir(), "missing.img"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
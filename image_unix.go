@@ -0,0 +1,29 @@
+//go:build unix
+
+package memfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file at name in memory, read-only and shared with other processes.
+func mapFile(name string) (data []byte, unmap func() error, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	data, err = syscall.Mmap(int(file.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: name, Err: err}
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
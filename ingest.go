@@ -0,0 +1,188 @@
+package memfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const ingestBatchSize = 256
+
+// IngestEntry is a file or directory added by IngestParallel.
+type IngestEntry struct {
+	Path string
+	Mode os.FileMode // permission bits, with fs.ModeDir set for a directory
+	Data []byte      // content of a file, it is copied
+}
+
+type ingestBatch struct {
+	dir     string
+	entries []IngestEntry
+}
+
+// IngestParallel adds many files and directories using workers goroutines. Consecutive
+// entries of the same directory are batched, so the directory is looked up and locked once
+// per batch. Missing parent directories are created, the entries created take the mode
+// given less the umask, existing files are replaced and existing directories take the
+// mode given. Ingesting stops at the first failing batch,
+// the errors of all the failed batches are returned.
+//
+// Entries can come from a channel with:
+//
+//	func(yield func(memfs.IngestEntry) bool) {
+//		for e := range ch {
+//			if !yield(e) {
+//				return
+//			}
+//		}
+//	}
+func (f *FS) IngestParallel(entries iter.Seq[IngestEntry], workers int) error {
+	if workers < 1 || f.baseFS().noLock {
+		workers = 1
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		errs   []error
+		failed atomic.Bool
+	)
+	fail := func(err error) {
+		mutex.Lock()
+		errs = append(errs, err)
+		mutex.Unlock()
+		failed.Store(true)
+	}
+
+	batches := make(chan ingestBatch, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				if err := f.ingestBatch(b); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	var batch ingestBatch
+	for e := range entries {
+		if failed.Load() {
+			break
+		}
+		if e.Path == "" || !f.ValidPath(e.Path) {
+			fail(fmt.Errorf("invalid path: %s: %w", e.Path, os.ErrInvalid))
+			break
+		}
+		e.Path = f.getAbsolutePath(e.Path)
+		if e.Path == "/" {
+			fail(fmt.Errorf("cannot ingest root: %s: %w", e.Path, os.ErrInvalid))
+			break
+		}
+		dir := parentPath(e.Path)
+		if dir != batch.dir || len(batch.entries) == ingestBatchSize {
+			if len(batch.entries) > 0 {
+				batches <- batch
+			}
+			batch = ingestBatch{dir: dir, entries: make([]IngestEntry, 0, ingestBatchSize)}
+		}
+		batch.entries = append(batch.entries, e)
+	}
+	if len(batch.entries) > 0 && !failed.Load() {
+		batches <- batch
+	}
+	close(batches)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (f *FS) ingestBatch(b ingestBatch) error {
+	if err := f.MkdirAll(b.dir, fs.ModePerm); err != nil {
+		return err
+	}
+	parentNode, dirNode, _, err := f.getEntry(b.dir)
+	if err != nil {
+		return err
+	}
+	if dirNode == nil {
+		// the root dir
+		dirNode = parentNode
+	}
+	if err = f.checkAccess(b.dir, dirNode, accessWrite|accessExecute); err != nil {
+		return err
+	}
+
+	var errs []error
+	entries := make([]IngestEntry, 0, len(b.entries))
+	for _, e := range b.entries {
+		op := OpWrite
+		if e.Mode.IsDir() {
+			op = OpMkdir
+		}
+		if err := f.checkGuard(op, e.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	dirNode.lock()
+	defer dirNode.unlock()
+	for _, e := range entries {
+		name := f.normalize(baseName(e.Path))
+		entryNode, exists := dirNode.entries.get(name)
+		if !exists {
+			if err := f.validateNewPath(e.Path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		if e.Mode.IsDir() {
+			if !exists {
+				dirNode.entries.set(f.newNode(name, f.masked(e.Mode.Perm()), true))
+				f.touch(dirNode)
+			} else if entryNode.isDir() {
+				entryNode.lock()
+				entryNode.perm = e.Mode.Perm()
+				entryNode.unlock()
+			} else {
+				errs = append(errs, fmt.Errorf("path exists: %s: %w", e.Path, os.ErrExist))
+			}
+			continue
+		}
+
+		content := make([]byte, len(e.Data))
+		copy(content, e.Data)
+		if exists {
+			if entryNode.isDir() {
+				errs = append(errs, fmt.Errorf("is a directory: %s: %w", e.Path, syscall.EISDIR))
+				continue
+			}
+			entryNode.lockContent()
+			entryNode.setContent(content)
+			entryNode.perm = e.Mode.Perm()
+			entryNode.modified = f.now()
+			f.changed(entryNode)
+			entryNode.unlockContent()
+		} else {
+			entryNode = f.newNode(name, f.masked(e.Mode.Perm()), false)
+			entryNode.content = content
+			dirNode.entries.set(entryNode)
+			f.touch(dirNode)
+		}
+		if f.store != nil {
+			entryNode.share(f.store)
+		}
+		f.storeChecksum(entryNode)
+	}
+	return errors.Join(errs...)
+}
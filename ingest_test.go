@@ -0,0 +1,136 @@
+package memfs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func fixtureEntries(dirs, files int) func(yield func(IngestEntry) bool) {
+	return func(yield func(IngestEntry) bool) {
+		for d := 0; d < dirs; d++ {
+			for i := 0; i < files; i++ {
+				p := fmt.Sprintf("/fixtures/d%03d/f%04d.txt", d, i)
+				if !yield(IngestEntry{Path: p, Mode: 0644, Data: []byte(p)}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func Test_IngestParallel(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.IngestParallel(fixtureEntries(20, 500), 8))
+
+	dirs, err := mfs.ReadDir("/fixtures")
+	assert.Nil(t, err)
+	assert.Len(t, dirs, 20)
+	for _, d := range dirs {
+		entries, err := mfs.ReadDir("/fixtures/" + d.Name())
+		assert.Nil(t, err)
+		assert.Len(t, entries, 500)
+	}
+
+	p := "/fixtures/d007/f0123.txt"
+	f, err := mfs.Open(p)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, p, string(data))
+	assert.Nil(t, f.Close())
+	fi, err := mfs.Stat(p)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0644), fi.Mode())
+}
+
+func Test_IngestParallel_Dirs_And_Replace(t *testing.T) {
+	mfs := New()
+	entries := []IngestEntry{
+		{Path: "/a/b/c.txt", Mode: 0600, Data: []byte("one")},
+		{Path: "/a/b", Mode: fs.ModeDir | 0700},
+		{Path: "/a/empty", Mode: fs.ModeDir | 0755},
+		{Path: "/a/b/c.txt", Mode: 0644, Data: []byte("two")},
+	}
+	seq := func(yield func(IngestEntry) bool) {
+		for _, e := range entries {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+	assert.Nil(t, mfs.IngestParallel(seq, 1))
+
+	fi, err := mfs.Stat("/a/b")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	assert.Equal(t, fs.ModeDir|0700, fi.Mode())
+	fi, err = mfs.Stat("/a/empty")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	fi, err = mfs.Stat("/a/b/c.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+	assert.Equal(t, os.FileMode(0644), fi.Mode())
+
+	entries = []IngestEntry{{Path: "/a/b", Mode: 0644, Data: []byte("x")}}
+	err = mfs.IngestParallel(seq, 2)
+	assert.True(t, errors.Is(err, syscall.EISDIR))
+
+	entries = []IngestEntry{{Path: "/a/b/c.txt/d", Mode: 0644}}
+	err = mfs.IngestParallel(seq, 2)
+	assert.NotNil(t, err)
+
+	entries = []IngestEntry{{Path: "/", Mode: fs.ModeDir}}
+	err = mfs.IngestParallel(seq, 2)
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+}
+
+func Test_IngestParallel_Umask_And_Times(t *testing.T) {
+	mfs := New(WithClock(NewStepClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Second)))
+	mfs.SetUmask(022)
+	assert.Nil(t, mfs.MkdirAll("/dir", 0755))
+	fi, err := mfs.Stat("/dir")
+	assert.Nil(t, err)
+	modified := fi.ModTime()
+
+	seq := func(yield func(IngestEntry) bool) {
+		_ = yield(IngestEntry{Path: "/dir/file", Mode: 0666, Data: []byte("data")}) &&
+			yield(IngestEntry{Path: "/dir/sub", Mode: fs.ModeDir | 0777})
+	}
+	assert.Nil(t, mfs.IngestParallel(seq, 1))
+
+	fi, err = mfs.Stat("/dir/file")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0644), fi.Mode())
+	fi, err = mfs.Stat("/dir/sub")
+	assert.Nil(t, err)
+	assert.Equal(t, fs.ModeDir|0755, fi.Mode())
+	fi, err = mfs.Stat("/dir")
+	assert.Nil(t, err)
+	assert.True(t, fi.ModTime().After(modified))
+}
+
+func Test_IngestParallel_Shared_Content(t *testing.T) {
+	m := NewManager()
+	a := m.Namespace("a")
+	b := m.Namespace("b")
+	assert.Nil(t, a.IngestParallel(fixtureEntries(2, 10), 4))
+	assert.Nil(t, b.IngestParallel(fixtureEntries(2, 10), 4))
+	stats := m.Stats()
+	assert.Equal(t, 20, stats.Blobs)
+	assert.Equal(t, 40, stats.References)
+}
+
+func Benchmark_IngestParallel(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		mfs := New()
+		_ = mfs.IngestParallel(fixtureEntries(100, 100), 8)
+	}
+}
@@ -0,0 +1,187 @@
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// ioFS exposes the filesystem through the io/fs interfaces.
+type ioFS struct {
+	fs  *FS
+	dir string
+}
+
+// IOFS returns the tree as an fs.FS, for use with fs.WalkDir, fs.Glob, http.FS, template
+// parsing and the like. It implements fs.StatFS, fs.ReadFileFS and fs.ReadDirFS, so
+// helpers take their direct paths, and the ReadLink and Lstat methods of fs.ReadLinkFS.
+// memfs has no symbolic links, Lstat is the same as Stat and ReadLink always fails.
+func (f *FS) IOFS() fs.FS {
+	return f.DirFS("/")
+}
+
+// DirFS returns the tree rooted at the directory dir as an fs.FS, like os.DirFS does for
+// a directory on disk. As with os.DirFS, dir is looked up at every call, it doesn't have
+// to exist yet and a relative dir is resolved against the working directory.
+func (f *FS) DirFS(dir string) fs.FS {
+	return &ioFS{fs: f, dir: dir}
+}
+
+// CopyFS copies the tree of fsys into the directory dir, creating dir if necessary. It is
+// the counterpart of os.CopyFS, with the same semantics: files are created with mode 0666
+// plus the execute bits of the source, directories with mode 0777, existing files are not
+// overwritten and entries other than files and directories are rejected. Files of another
+// memfs tree are copied by sharing their content.
+//
+// Copying to disk is done by os.CopyFS(dir, mfs.IOFS()).
+func (f *FS) CopyFS(dir string, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		newPath := f.join(dir, name)
+
+		switch d.Type() {
+		case fs.ModeDir:
+			return f.MkdirAll(newPath, 0777)
+		case 0:
+			r, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			info, err := r.Stat()
+			if err != nil {
+				return err
+			}
+			w, err := f.OpenFile(newPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666|info.Mode()&0777)
+			if err != nil {
+				return err
+			}
+			if file, ok := r.(*ioFile); ok {
+				r = file.File
+			}
+			if _, err = w.ReadFrom(r); err != nil {
+				_ = w.Close()
+				return &fs.PathError{Op: "Copy", Path: newPath, Err: err}
+			}
+			return w.Close()
+		default:
+			return &fs.PathError{Op: "CopyFS", Path: name, Err: fs.ErrInvalid}
+		}
+	})
+}
+
+// path returns the memfs path of a valid io/fs name.
+func (i *ioFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return i.fs.join(i.dir, name), nil
+}
+
+// ioError returns a *fs.PathError for an error of memfs, with the error class it wraps.
+func ioError(op, name string, err error) error {
+	// errnos come first, as ENOTEMPTY is also fs.ErrExist
+	for _, target := range []error{syscall.EISDIR, syscall.ENOTDIR, syscall.ENOTEMPTY, syscall.EMFILE, fs.ErrNotExist, fs.ErrExist, fs.ErrPermission, fs.ErrClosed, fs.ErrInvalid} {
+		if errors.Is(err, target) {
+			return &fs.PathError{Op: op, Path: name, Err: target}
+		}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (i *ioFS) Open(name string) (fs.File, error) {
+	p, err := i.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := i.fs.Open(p)
+	if err != nil {
+		return nil, ioError("open", name, err)
+	}
+	if f.isDir() {
+		return &ioDir{File: f}, nil
+	}
+	return &ioFile{File: f}, nil
+}
+
+func (i *ioFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := i.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := i.fs.Stat(p)
+	if err != nil {
+		return nil, ioError("stat", name, err)
+	}
+	return fi, nil
+}
+
+func (i *ioFS) Lstat(name string) (fs.FileInfo, error) {
+	return i.Stat(name)
+}
+
+func (i *ioFS) ReadLink(name string) (string, error) {
+	if _, err := i.Stat(name); err != nil {
+		return "", ioError("readlink", name, err)
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
+
+func (i *ioFS) ReadFile(name string) ([]byte, error) {
+	p, err := i.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := i.fs.Open(p)
+	if err != nil {
+		return nil, ioError("readfile", name, err)
+	}
+	defer f.Close()
+	if f.isDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	if f.device != nil {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, ioError("readfile", name, err)
+		}
+		return data, nil
+	}
+	f.node.lockContent()
+	defer f.node.unlockContent()
+	content := f.node.getContent()
+	data := make([]byte, len(content))
+	copy(data, content)
+	return data, nil
+}
+
+func (i *ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := i.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := i.fs.readDirNode(p)
+	if err != nil {
+		return nil, ioError("readdir", name, err)
+	}
+	dir.lock()
+	defer dir.unlock()
+	return i.fs.withFS(dir.entries.dirEntries()), nil
+}
+
+type ioFile struct {
+	*File
+}
+
+// ioDir is an open directory reading its entries as fs.ReadDirFile expects.
+type ioDir struct {
+	*File
+}
+
+func (d *ioDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.Name(), Err: fs.ErrInvalid}
+}
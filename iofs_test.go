@@ -0,0 +1,133 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_IOFS(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/a.txt":         []byte("a"),
+		"/dir/b.txt":     []byte("bb"),
+		"/dir/sub/c.txt": []byte("ccc"),
+	})
+	assert.Nil(t, err)
+	fsys := mfs.IOFS()
+
+	// the root dir is not created again as an entry with an empty name
+	assert.Nil(t, mfs.MkdirAll("/", 0755))
+	assert.Nil(t, fstest.TestFS(fsys, "a.txt", "dir/b.txt", "dir/sub/c.txt"))
+
+	_, ok := fsys.(fs.StatFS)
+	assert.True(t, ok)
+	_, ok = fsys.(fs.ReadFileFS)
+	assert.True(t, ok)
+	_, ok = fsys.(fs.ReadDirFS)
+	assert.True(t, ok)
+
+	data, err := fs.ReadFile(fsys, "dir/sub/c.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "ccc", string(data))
+
+	matches, err := fs.Glob(fsys, "dir/*.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dir/b.txt"}, matches)
+
+	_, err = fs.Stat(fsys, "missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	var pathErr *fs.PathError
+	assert.True(t, errors.As(err, &pathErr))
+	assert.Equal(t, "missing", pathErr.Path)
+	_, err = fsys.Open("/a.txt")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	links := fsys.(interface {
+		ReadLink(name string) (string, error)
+		Lstat(name string) (fs.FileInfo, error)
+	})
+	_, err = links.ReadLink("a.txt")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+	_, err = links.ReadLink("missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	fi, err := links.Lstat("dir")
+	assert.Nil(t, err)
+	assert.True(t, fi.Mode().IsDir())
+}
+
+func Test_CopyFS(t *testing.T) {
+	src, err := NewFromEntries(map[string]Entry{
+		"/app/run.sh":          {Data: []byte("#!/bin/sh"), Mode: 0755},
+		"/app/conf/app.json":   {Data: []byte("{}"), Mode: 0600},
+		"/app/empty":           {Mode: fs.ModeDir | 0755},
+		"/app/conf/extra.json": {Data: []byte("[]")},
+	})
+	assert.Nil(t, err)
+	app, err := fs.Sub(src.IOFS(), "app")
+	assert.Nil(t, err)
+
+	// memfs to disk
+	dir := t.TempDir()
+	assert.Nil(t, os.CopyFS(dir, app))
+	data, err := os.ReadFile(filepath.Join(dir, "conf", "app.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", string(data))
+	fi, err := os.Stat(filepath.Join(dir, "run.sh"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0100), fi.Mode()&0100)
+	fi, err = os.Stat(filepath.Join(dir, "empty"))
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+
+	// disk to memfs
+	dst := New()
+	assert.Nil(t, dst.CopyFS("/copy", os.DirFS(dir)))
+	assert.Nil(t, fstest.TestFS(dst.IOFS(), "copy/run.sh", "copy/conf/app.json", "copy/conf/extra.json", "copy/empty"))
+	fi, err = dst.Stat("/copy/run.sh")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0777), fi.Mode())
+	fi, err = dst.Stat("/copy/conf/app.json")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0666), fi.Mode())
+
+	// existing files are not overwritten
+	assert.True(t, errors.Is(dst.CopyFS("/copy", os.DirFS(dir)), fs.ErrExist))
+
+	// memfs to memfs shares the content
+	assert.Nil(t, dst.CopyFS("/shared", app))
+	srcFile, err := src.Open("/app/run.sh")
+	assert.Nil(t, err)
+	dstFile, err := dst.Open("/shared/run.sh")
+	assert.Nil(t, err)
+	assert.Same(t, &srcFile.node.content[0], &dstFile.node.content[0])
+}
+
+func Test_DirFS(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/srv/www/index.html":   []byte("<html>"),
+		"/srv/www/css/site.css": []byte("body{}"),
+		"/srv/secret":           []byte("secret"),
+	})
+	assert.Nil(t, err)
+
+	www := mfs.DirFS("/srv/www")
+	assert.Nil(t, fstest.TestFS(www, "index.html", "css/site.css"))
+	data, err := fs.ReadFile(www, "css/site.css")
+	assert.Nil(t, err)
+	assert.Equal(t, "body{}", string(data))
+	_, err = fs.Stat(www, "../secret")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	// the directory is looked up on every call
+	later := mfs.DirFS("/later")
+	_, err = fs.Stat(later, ".")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	assert.Nil(t, mfs.MkdirAll("/later/dir", 0755))
+	fi, err := fs.Stat(later, "dir")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+}
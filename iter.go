@@ -0,0 +1,77 @@
+package memfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+)
+
+// Lines returns an iterator over the lines of the file at path, without their line endings.
+// The file is read as the iteration goes, an error ends it and is yielded with an empty line.
+//
+//	for line, err := range mfs.Lines("/var/log/app.log") {
+//		...
+//	}
+func (f *FS) Lines(path string) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		file, err := f.Open(path)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		defer file.Close()
+		r := bufio.NewReader(file)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil && err != io.EOF {
+				yield("", err)
+				return
+			}
+			if line == "" && err == io.EOF {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			if !yield(line, nil) {
+				return
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}
+}
+
+// Chunks returns an iterator over the content of the file at path in chunks of size bytes,
+// the last one can be shorter. Every chunk is a new slice the caller can keep.
+func (f *FS) Chunks(path string, size int) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		if size <= 0 {
+			yield(nil, fmt.Errorf("invalid chunk size: %d: %w", size, os.ErrInvalid))
+			return
+		}
+		file, err := f.Open(path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer file.Close()
+		for {
+			chunk := make([]byte, size)
+			n, err := io.ReadFull(file, chunk)
+			if n > 0 && !yield(chunk[:n], nil) {
+				return
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func Test_Lines(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/log":   []byte("one\ntwo\r\n\nfour"),
+		"/empty": nil,
+		"/nl":    []byte("a\n"),
+	})
+	assert.Nil(t, err)
+
+	var lines []string
+	for line, err := range mfs.Lines("/log") {
+		assert.Nil(t, err)
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []string{"one", "two", "", "four"}, lines)
+
+	lines = nil
+	for line := range mfs.Lines("/log") {
+		lines = append(lines, line)
+		break
+	}
+	assert.Equal(t, []string{"one"}, lines)
+
+	for range mfs.Lines("/empty") {
+		t.Fatal("no lines expected")
+	}
+	lines = nil
+	for line := range mfs.Lines("/nl") {
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []string{"a"}, lines)
+
+	for _, err := range mfs.Lines("/missing") {
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+	}
+	for _, err := range mfs.Lines("/tmp") {
+		assert.True(t, errors.Is(err, syscall.EISDIR))
+	}
+}
+
+func Test_Chunks(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/data": []byte("abcdefgh")})
+	assert.Nil(t, err)
+
+	var chunks []string
+	for chunk, err := range mfs.Chunks("/data", 3) {
+		assert.Nil(t, err)
+		chunks = append(chunks, string(chunk))
+	}
+	assert.Equal(t, []string{"abc", "def", "gh"}, chunks)
+
+	chunks = nil
+	for chunk := range mfs.Chunks("/data", 4) {
+		chunks = append(chunks, string(chunk))
+	}
+	assert.Equal(t, []string{"abcd", "efgh"}, chunks)
+
+	for _, err := range mfs.Chunks("/data", 0) {
+		assert.True(t, errors.Is(err, os.ErrInvalid))
+	}
+	for _, err := range mfs.Chunks("/missing", 1) {
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+	}
+}
@@ -0,0 +1,126 @@
+package memfs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Link creates newname as a hard link to the file at oldname, like os.Link. Both names
+// then refer to the same file: its content, permissions, owner and times are the same
+// through either, and files opened through one see the writes made through the other.
+// Removing a name, or renaming a file over it, leaves the file to its other names, its
+// content is released with the last one. Directories can't be linked, and Link fails
+// with an error wrapping fs.ErrExist when newname exists. Checkpoints, namespaces, forks
+// and tar exports keep the links, copies and images hold a file per name.
+func (f *FS) Link(oldname, newname string) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpLink, oldname); err != nil {
+		return err
+	}
+	if err := f.checkGuard(OpLink, newname); err != nil {
+		return err
+	}
+	_, oldNode, oldMissing, err := f.getEntry(oldname)
+	if err != nil {
+		return err
+	}
+	if oldMissing != "" {
+		return fmt.Errorf("path does not exist: %s: %w", oldname, os.ErrNotExist)
+	}
+	if oldNode == nil || oldNode.isDir() {
+		return fmt.Errorf("cannot link a directory: %s: %w", oldname, os.ErrPermission)
+	}
+	newParent, newNode, newMissing, err := f.getEntry(newname)
+	if err != nil {
+		return err
+	}
+	if newNode != nil || newMissing == "" {
+		return fmt.Errorf("path exists: %s: %w", newname, os.ErrExist)
+	}
+	if strings.Contains(newMissing, "/") {
+		return fmt.Errorf("path does not exist: %s: %w", newname, os.ErrNotExist)
+	}
+	if err = f.checkAccess(newname, newParent, accessWrite|accessExecute); err != nil {
+		return err
+	}
+	if err = f.validateNewPath(newname); err != nil {
+		return err
+	}
+
+	target := oldNode.target()
+	link := f.newNode(newMissing, 0, false)
+	link.extension().link = target
+
+	newParent.lock()
+	defer newParent.unlock()
+	if _, exists := newParent.entries.get(newMissing); exists {
+		return fmt.Errorf("path exists: %s: %w", newname, os.ErrExist)
+	}
+	target.lock()
+	unlinked := target.unlinked
+	if !unlinked {
+		e := target.extension()
+		e.mutex.Lock()
+		e.names = max(e.names, 1) + 1
+		e.mutex.Unlock()
+	}
+	target.unlock()
+	if unlinked {
+		return fmt.Errorf("path does not exist: %s: %w", oldname, os.ErrNotExist)
+	}
+	newParent.entries.set(link)
+	f.touch(newParent)
+	f.changed(target)
+	return nil
+}
+
+// target returns the node the entry names, the node it is a hard link to, or itself.
+func (f *fsNode) target() *fsNode {
+	if e := f.getExt(); e != nil && e.link != nil {
+		return e.link
+	}
+	return f
+}
+
+// names returns the number of entries naming the node, 1 when it has no hard links.
+func (f *fsNode) names() uint32 {
+	if e := f.getExt(); e != nil {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		if e.names > 0 {
+			return e.names
+		}
+	}
+	return 1
+}
+
+// unlinkName marks the entry as unlinked once removed from its directory, and the node it
+// names when it was its last name, whose content is then released. It returns whether the
+// node was unlinked.
+func (f *fsNode) unlinkName() bool {
+	t := f.target()
+	if t != f {
+		f.lock()
+		f.unlinked = true
+		f.unlock()
+	}
+	t.lock()
+	last := true
+	if e := t.getExt(); e != nil {
+		e.mutex.Lock()
+		if e.names > 0 {
+			e.names--
+		}
+		last = e.names == 0
+		e.mutex.Unlock()
+	}
+	if last {
+		t.unlinked = true
+	}
+	t.unlock()
+	if last {
+		t.release()
+	}
+	return last
+}
@@ -0,0 +1,115 @@
+package memfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func Test_Link(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/data/links", 0755))
+	assert.Nil(t, mfs.WriteFile("/data/file", []byte("one"), 0644))
+	assert.Nil(t, mfs.Link("/data/file", "/data/links/file"))
+	assert.Equal(t, "one", readAll(t, mfs, "/data/links/file"))
+
+	// both names are the same file
+	assert.Nil(t, mfs.WriteFile("/data/links/file", []byte("two"), 0644))
+	assert.Equal(t, "two", readAll(t, mfs, "/data/file"))
+	assert.Nil(t, mfs.Chmod("/data/links/file", 0600))
+	fi, err := mfs.Stat("/data/file")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+	linked, err := mfs.Stat("/data/links/file")
+	assert.Nil(t, err)
+	assert.Equal(t, "file", linked.Name())
+	assert.True(t, SameFile(fi, linked))
+	assert.Equal(t, uint32(2), linked.Sys().(*SysInfo).Nlink)
+	assert.Equal(t, fi.Sys().(*SysInfo).Ino, linked.Sys().(*SysInfo).Ino)
+
+	// a file opened through one name sees the writes made through the other
+	f, err := mfs.OpenFile("/data/file", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.WriteFile("/data/links/file", []byte("three"), 0644))
+	data := make([]byte, 5)
+	_, err = f.ReadAt(data, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "three", string(data))
+
+	// removing a name leaves the file to the other one
+	assert.Nil(t, mfs.Remove("/data/file"))
+	_, err = f.WriteAt([]byte("four!"), 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, "four!", readAll(t, mfs, "/data/links/file"))
+	linked, err = mfs.Stat("/data/links/file")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), linked.Sys().(*SysInfo).Nlink)
+	_, n, _, err := mfs.getEntry("/data/links/file")
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.Link("/data/links/file", "/data/file"))
+	assert.Nil(t, mfs.RemoveAll("/data/links"))
+	assert.False(t, n.target().unlinked)
+	assert.Equal(t, "four!", readAll(t, mfs, "/data/file"))
+	assert.Nil(t, mfs.Remove("/data/file"))
+	assert.True(t, n.target().unlinked)
+	assert.Nil(t, n.target().content)
+
+	// renaming over a link to the same file does nothing, over another link keeps the file
+	assert.Nil(t, mfs.WriteFile("/a", []byte("a"), 0644))
+	assert.Nil(t, mfs.Link("/a", "/b"))
+	assert.Nil(t, mfs.Rename("/a", "/b"))
+	assert.Equal(t, "a", readAll(t, mfs, "/a"))
+	assert.Nil(t, mfs.WriteFile("/c", []byte("c"), 0644))
+	assert.Nil(t, mfs.Rename("/c", "/b"))
+	assert.Equal(t, "a", readAll(t, mfs, "/a"))
+	fi, err = mfs.Stat("/a")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), fi.Sys().(*SysInfo).Nlink)
+
+	assert.True(t, errors.Is(mfs.Link("/a", "/b"), fs.ErrExist))
+	assert.True(t, errors.Is(mfs.Link("/missing", "/d"), fs.ErrNotExist))
+	assert.True(t, errors.Is(mfs.Link("/a", "/missing/d"), fs.ErrNotExist))
+	assert.True(t, errors.Is(mfs.Link("/data", "/d"), fs.ErrPermission))
+}
+
+func Test_Link_Copies(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.Mkdir("/data", 0755))
+	assert.Nil(t, mfs.WriteFile("/data/file", []byte("one"), 0644))
+	assert.Nil(t, mfs.Link("/data/file", "/data/link"))
+	assert.Nil(t, mfs.Link("/data/file", "/data/other"))
+	assert.Nil(t, mfs.Remove("/data/file"))
+
+	// checkpoints, namespaces and forks keep the links between their own files
+	mfs.Checkpoint()
+	assert.Nil(t, mfs.WriteFile("/data/link", []byte("two"), 0644))
+	assert.Equal(t, "two", readAll(t, mfs, "/data/other"))
+	assert.Nil(t, mfs.Undo())
+	for _, fsys := range []*FS{mfs, mfs.TestNamespace(t), Template(mfs).Fork()} {
+		assert.Equal(t, "one", readAll(t, fsys, "/data/other"))
+		assert.Nil(t, fsys.WriteFile("/data/link", []byte("three"), 0644))
+		assert.Equal(t, "three", readAll(t, fsys, "/data/other"))
+		assert.Nil(t, fsys.WriteFile("/data/link", []byte("one"), 0644))
+	}
+	ns := mfs.TestNamespace(t)
+	assert.Nil(t, ns.WriteFile("/data/link", []byte("four"), 0644))
+	assert.Equal(t, "one", readAll(t, mfs, "/data/other"))
+
+	// tar exports write the links again
+	var archive bytes.Buffer
+	assert.Nil(t, mfs.ExportTar(&archive, "/data"))
+	tr := tar.NewReader(&archive)
+	var headers []*tar.Header
+	for hdr, err := tr.Next(); err == nil; hdr, err = tr.Next() {
+		headers = append(headers, hdr)
+	}
+	assert.Len(t, headers, 2)
+	assert.Equal(t, "other", headers[1].Name)
+	assert.Equal(t, byte(tar.TypeLink), headers[1].Typeflag)
+	assert.Equal(t, "link", headers[1].Linkname)
+}
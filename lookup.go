@@ -0,0 +1,88 @@
+package memfs
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// maxLookups bounds the number of paths a lookup cache holds, it is emptied when full.
+const maxLookups = 4096
+
+// lookupCache keeps the nodes that paths resolved to, so resolving a deep path again
+// doesn't walk and lock every directory on the way.
+type lookupCache struct {
+	mutex   sync.RWMutex
+	entries map[lookupKey]lookup
+	// generation changes whenever an entry is removed from a directory or replaced, which
+	// invalidates all the paths cached, as any of them may go through the entry. Adding
+	// entries leaves the paths cached valid, so creating files doesn't invalidate them.
+	generation atomic.Uint64
+}
+
+type lookupKey struct {
+	root *fsNode // the root the path was resolved from, views have their own
+	path string  // absolute, with "/" separators
+}
+
+type lookup struct {
+	parent, entry *fsNode
+}
+
+// invalidateLookups empties the lookup cache of the filesystem, once an entry was removed
+// from a directory or replaced, the caller may hold node locks.
+func (f *FS) invalidateLookups() {
+	c := &f.baseFS().lookups
+	c.generation.Add(1)
+	if !f.baseFS().noLock {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+	c.entries = nil
+}
+
+// lookupGeneration returns the generation of the lookup cache, read before resolving a
+// path to cache.
+func (f *FS) lookupGeneration() uint64 {
+	return f.baseFS().lookups.generation.Load()
+}
+
+// getLookup returns the parent and the node path resolved to in the tree of f, when it
+// was cached since the last removal.
+func (f *FS) getLookup(path string) (parent, entry *fsNode, ok bool) {
+	base := f.baseFS()
+	if f.checked {
+		// the permissions of the directories on the way must be checked every time
+		return nil, nil, false
+	}
+	c := &base.lookups
+	if !base.noLock {
+		c.mutex.RLock()
+		defer c.mutex.RUnlock()
+	}
+	l, exists := c.entries[lookupKey{root: f.root, path: path}]
+	if !exists {
+		return nil, nil, false
+	}
+	return l.parent, l.entry, true
+}
+
+// putLookup caches the nodes path resolved to, generation being the generation read
+// before resolving it, so a removal made meanwhile keeps it from being cached.
+func (f *FS) putLookup(path string, parent, entry *fsNode, generation uint64) {
+	base := f.baseFS()
+	if f.checked {
+		return
+	}
+	c := &base.lookups
+	if !base.noLock {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+	if generation != c.generation.Load() {
+		return
+	}
+	if c.entries == nil || len(c.entries) >= maxLookups {
+		c.entries = make(map[lookupKey]lookup)
+	}
+	c.entries[lookupKey{root: f.root, path: path}] = lookup{parent: parent, entry: entry}
+}
@@ -0,0 +1,67 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func Test_Lookup_Cache(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/a/b/c/d/e/f": []byte("first")})
+	assert.Nil(t, err)
+	assert.Equal(t, "first", readAll(t, mfs, "/a/b/c/d/e/f"))
+	_, cached, ok := mfs.getLookup("/a/b/c/d/e/f")
+	assert.True(t, ok)
+
+	// removing or renaming anything on the way invalidates the path
+	assert.Nil(t, mfs.Rename("/a/b", "/a/moved"))
+	_, _, ok = mfs.getLookup("/a/b/c/d/e/f")
+	assert.False(t, ok)
+	assert.Nil(t, mfs.baseFS().lookups.entries)
+	_, err = mfs.Stat("/a/b/c/d/e/f")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	assert.Equal(t, "first", readAll(t, mfs, "/a/moved/c/d/e/f"))
+
+	assert.Nil(t, mfs.MkdirAll("/a/b/c/d/e", 0755))
+	f, err := mfs.Create("/a/b/c/d/e/f")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("second"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, "second", readAll(t, mfs, "/a/b/c/d/e/f"))
+	_, entry, ok := mfs.getLookup("/a/b/c/d/e/f")
+	assert.True(t, ok)
+	assert.NotSame(t, cached, entry)
+
+	// removing entries of another filesystem leaves the paths cached
+	other, err := NewFromMap(map[string][]byte{"/x": []byte("x")})
+	assert.Nil(t, err)
+	assert.Equal(t, "second", readAll(t, mfs, "/a/b/c/d/e/f"))
+	assert.Nil(t, other.Remove("/x"))
+	_, _, ok = mfs.getLookup("/a/b/c/d/e/f")
+	assert.True(t, ok)
+
+	assert.Nil(t, mfs.RemoveAll("/a/b"))
+	_, err = mfs.Stat("/a/b/c/d/e/f")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	// views checking permissions resolve paths every time
+	assert.Nil(t, mfs.MkdirAll("/private/dir", 0700))
+	f, err = mfs.Create("/private/dir/file")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	_, err = mfs.Stat("/private/dir/file")
+	assert.Nil(t, err)
+	_, err = mfs.As(1000, 1000).Stat("/private/dir/file")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+}
+
+func Benchmark_Lookup(b *testing.B) {
+	mfs, _ := NewFromMap(map[string][]byte{"/a/b/c/d/e/f": []byte("data")})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = mfs.Stat("/a/b/c/d/e/f")
+	}
+}
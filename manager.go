@@ -0,0 +1,66 @@
+package memfs
+
+import (
+	"sort"
+	"sync"
+)
+
+// Manager creates isolated filesystem namespaces, one per tenant, which share a
+// deduplicated content store. Every namespace behaves like a private FS, while
+// identical file content written into several namespaces is only held once.
+type Manager struct {
+	store      *contentStore
+	mutex      sync.Mutex
+	namespaces map[string]*FS
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		store:      newContentStore(),
+		namespaces: make(map[string]*FS),
+	}
+}
+
+// Namespace returns the FS for name, creating an empty one on first use.
+func (m *Manager) Namespace(name string) *FS {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if f, exists := m.namespaces[name]; exists {
+		return f
+	}
+	f := New()
+	f.store = m.store
+	m.namespaces[name] = f
+	return f
+}
+
+// Names returns the names of the existing namespaces in sorted order.
+func (m *Manager) Names() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	names := make([]string, 0, len(m.namespaces))
+	for n := range m.namespaces {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Drop removes the namespace and releases the content it references. It
+// returns false if the namespace does not exist.
+func (m *Manager) Drop(name string) bool {
+	m.mutex.Lock()
+	f, exists := m.namespaces[name]
+	delete(m.namespaces, name)
+	m.mutex.Unlock()
+	if !exists {
+		return false
+	}
+	f.root.unlinkAll()
+	return true
+}
+
+// Stats reports how much memory the shared content store holds.
+func (m *Manager) Stats() StoreStats {
+	return m.store.stats()
+}
@@ -0,0 +1,72 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+)
+
+func Test_Manager_Namespaces(t *testing.T) {
+	m := NewManager()
+
+	a := m.Namespace("a")
+	b := m.Namespace("b")
+	assert.NotNil(t, a)
+	assert.NotNil(t, b)
+	assert.True(t, a == m.Namespace("a"))
+	assert.Equal(t, []string{"a", "b"}, m.Names())
+
+	f, err := a.Create("/only_in_a")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	_, err = b.Stat("/only_in_a")
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	assert.True(t, m.Drop("a"))
+	assert.False(t, m.Drop("a"))
+	assert.Equal(t, []string{"b"}, m.Names())
+}
+
+func Test_Manager_Deduplicates_Content(t *testing.T) {
+	m := NewManager()
+	data := []byte(`shared fixture content`)
+
+	for _, name := range []string{"a", "b", "c"} {
+		f, err := m.Namespace(name).Create("/fixture")
+		assert.Nil(t, err)
+		_, err = f.Write(data)
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+	}
+
+	stats := m.Stats()
+	assert.Equal(t, 1, stats.Blobs)
+	assert.Equal(t, 3, stats.References)
+	assert.Equal(t, int64(len(data)), stats.StoredBytes)
+	assert.Equal(t, int64(3*len(data)), stats.LogicalBytes)
+
+	// modifying the content in one namespace must not leak into the others
+	f, err := m.Namespace("a").OpenFile("/fixture", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte(`S`), 0)
+	assert.Nil(t, err)
+
+	f2, err := m.Namespace("b").Open("/fixture")
+	assert.Nil(t, err)
+	readData, err := io.ReadAll(io.LimitReader(f2, int64(len(data))))
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), string(readData))
+
+	assert.Equal(t, 2, m.Stats().References)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, 2, m.Stats().Blobs)
+
+	assert.Nil(t, m.Namespace("b").Remove("/fixture"))
+	assert.True(t, m.Drop("c"))
+	assert.Equal(t, 1, m.Stats().Blobs)
+	assert.Equal(t, 1, m.Stats().References)
+}
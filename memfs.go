@@ -1,82 +1,283 @@
 package memfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"math/rand"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
-	tempDir = "tmp"
+	defaultTempDir = "/tmp"
+	tempAttempts   = 10000 // names CreateTemp and MkdirTemp try before giving up, as os does
 )
 
+// ErrPatternHasSeparator is wrapped by the errors of CreateTemp and MkdirTemp for a
+// pattern holding a path separator.
+var ErrPatternHasSeparator = errors.New("pattern contains path separator")
+
 type FS struct {
 	root   *fsNode
 	nextFD int64
+	files  map[int64]*File
 	mutex  sync.Mutex
+	store  *contentStore
+	clock  Clock
+	rand   *rand.Rand
+
+	dumpOnFailure bool
+
+	renameMutex sync.Mutex
+
+	base    *FS // the filesystem a view was created from
+	uid     int
+	gid     int
+	checked bool // operations are permission checked as uid and gid
+
+	strictFlags   bool
+	pathPolicy    PathPolicy
+	normalization *norm.Form // of the names, when created WithNameNormalization
+	guard         Guard
+
+	separator byte
+
+	precompressor *precompressor
+	noLock        bool
+
+	tempDir string // the directory of temporary files, defaultTempDir when empty
+
+	trash       *trash   // removed entries, when created WithTrash
+	checkpoints *history // states recorded by Checkpoint, created by the first call
+	tags        *tagIndex
+	lookups     lookupCache
+	maxDepth    int // of the trees walked, removed and exported, unlimited when 0
+
+	maxOpenFiles int // unlimited when 0
+	opening      int // files being opened, counted against maxOpenFiles
+
+	special []func(f *FS) // create the special files, like the devices created WithDevices
+
+	trackRanges  bool // the ranges written to files are recorded, WithRangeTracking
+	verifyOnRead bool // the checksums of files are recorded, WithVerifyOnRead
+
+	staged   bool // writes are staged by the handles until synced, WithStagedWrites
+	syncHook func(f *File) error
+
+	keepTimes bool          // the times of the entries are kept, WithTimes
+	noAtime   bool          // reads leave the access times unchanged, WithoutAtime
+	lastInode atomic.Uint64 // the last inode number given out by Sys
+	keepHoles bool          // the holes of files are kept, WithSparseFiles
+	errnos    bool          // errors wrap the errnos of their sentinels, WithErrnos
+	umask     atomic.Uint32 // bits cleared from the perm of the entries created, SetUmask
+}
+
+func New(opts ...Option) *FS {
+	return newFS(nil, opts...)
 }
 
-func New() *FS {
+// newFS returns a filesystem created with opts whose tree is root, or a new tree when nil.
+func newFS(root *fsNode, opts ...Option) *FS {
 	f := new(FS)
 	f.nextFD = 100
+	f.files = make(map[int64]*File)
+	for _, opt := range opts {
+		opt(f)
+	}
+	// the entries created here are not guarded
+	guard := f.guard
+	f.guard = nil
+	if f.rand == nil {
+		f.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
 
-	f.root = &fsNode{
-		name:     "",
-		modified: time.Now(),
-		perm:     fs.ModePerm,
-		entries:  make(map[string]*fsNode),
+	if root == nil {
+		root = f.newNode("", fs.ModePerm, true)
+	} else {
+		root.setNoLock(f.noLock)
 	}
-	f.root.entries[tempDir] = &fsNode{
-		name:     tempDir,
-		perm:     fs.ModePerm,
-		modified: time.Now(),
-		entries:  make(map[string]*fsNode),
+	f.root = root
+	// numbered first, like the root of the file systems of the OS
+	f.inode(root)
+	_ = f.MkdirAll(f.TempDir(), fs.ModePerm)
+
+	_ = f.MkdirAll(workingDir(), fs.ModePerm)
+	for _, create := range f.special {
+		create(f)
 	}
+	f.guard = guard
 
-	cwd, _ := os.Getwd()
-	_ = f.MkdirAll(cwd, fs.ModePerm)
+	return f
+}
+
+// view returns a filesystem sharing the state of f, with its tree rooted at root.
+func (f *FS) view(root *fsNode) *FS {
+	return &FS{
+		root:    root,
+		store:   f.store,
+		base:    f.baseFS(),
+		uid:     f.uid,
+		gid:     f.gid,
+		checked: f.checked,
+		tempDir: f.tempDir,
+	}
+}
 
+func (f *FS) baseFS() *FS {
+	if f.base != nil {
+		return f.base
+	}
 	return f
 }
 
-func (f *FS) getNextFileDescriptor() int64 {
-	f.mutex.Lock()
-	defer f.mutex.Unlock()
-	fd := f.nextFD
-	f.nextFD++
-	return fd
+// addOpenFile assigns the next file descriptor to file and tracks it as open until it is closed.
+func (f *FS) addOpenFile(file *File) *File {
+	base := f.baseFS()
+	base.lock()
+	defer base.unlock()
+	file.fd = base.nextFD
+	base.nextFD++
+	base.files[file.fd] = file
+	return file
+}
+
+// reserveOpenFile counts a file being opened against the limit set WithMaxOpenFiles, until
+// releaseOpenFile is called, once the file is open or failed to open. f must be the base filesystem.
+func (f *FS) reserveOpenFile(path string) error {
+	f.lock()
+	defer f.unlock()
+	if len(f.files)+f.opening >= f.maxOpenFiles {
+		return fmt.Errorf("too many open files: %s: %w", path, syscall.EMFILE)
+	}
+	f.opening++
+	return nil
+}
+
+func (f *FS) releaseOpenFile() {
+	f.lock()
+	defer f.unlock()
+	f.opening--
+}
+
+func (f *FS) removeOpenFile(file *File) {
+	base := f.baseFS()
+	base.lock()
+	defer base.unlock()
+	delete(base.files, file.fd)
+}
+
+// FileByFd returns the open file whose descriptor is fd, as returned by File.Fd.
+func (f *FS) FileByFd(fd uintptr) (*File, bool) {
+	base := f.baseFS()
+	base.lock()
+	defer base.unlock()
+	file, open := base.files[int64(fd)]
+	return file, open
+}
+
+// openFiles returns the files currently open, ordered by file descriptor.
+func (f *FS) openFiles() []*File {
+	base := f.baseFS()
+	base.lock()
+	defer base.unlock()
+	files := make([]*File, 0, len(base.files))
+	for _, file := range base.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].fd < files[j].fd })
+	return files
+}
+
+// lock takes the lock of the file table and the random source, f must be the base filesystem.
+func (f *FS) lock() {
+	if !f.noLock {
+		f.mutex.Lock()
+	}
 }
 
-func (f *FS) getAbsolutePath(path string) string {
-	if !filepath.IsAbs(path) {
-		path, _ = filepath.Abs(path)
+func (f *FS) unlock() {
+	if !f.noLock {
+		f.mutex.Unlock()
 	}
-	return filepath.Clean(path)
 }
 
-func (f *FS) randomString(n int) string {
-	rand.Seed(time.Now().UnixNano())
-	letters := []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+func (f *FS) lockRename() {
+	if !f.noLock {
+		f.renameMutex.Lock()
 	}
-	return string(b)
 }
 
-func (f *FS) createRandomPathPart(pattern string) string {
-	if !strings.Contains(pattern, "*") {
-		pattern = pattern + "*"
+func (f *FS) unlockRename() {
+	if !f.noLock {
+		f.renameMutex.Unlock()
 	}
-	return strings.Replace(pattern, "*", f.randomString(8), -1)
+}
+
+func (f *FS) now() time.Time {
+	base := f.baseFS()
+	if base.clock != nil {
+		return base.clock.Now()
+	}
+	return time.Now()
+}
+
+// peekNow returns the current time like now, without advancing a clock which advances
+// every time it is read, such as a StepClock, so checking a time doesn't change the times
+// recorded.
+func (f *FS) peekNow() time.Time {
+	base := f.baseFS()
+	if c, ok := base.clock.(interface{ Peek() time.Time }); ok {
+		return c.Peek()
+	}
+	return f.now()
+}
+
+// touch sets the modification time of the directory n to now, as an entry was added to
+// or removed from it. The caller must hold the node lock.
+func (f *FS) touch(n *fsNode) {
+	n.modified = f.now()
+	f.changed(n)
+}
+
+// written sets the modification time of the file n to now, as its content is written or
+// truncated. The caller must not hold the node lock.
+func (f *FS) written(n *fsNode) {
+	now := f.now()
+	n.lock()
+	n.modified = now
+	n.unlock()
+	f.changed(n)
+}
+
+// nextRandom returns the random part of a temporary name, a decimal number as os uses.
+func (f *FS) nextRandom() string {
+	base := f.baseFS()
+	base.lock()
+	defer base.unlock()
+	return strconv.FormatUint(uint64(base.rand.Uint32()), 10)
+}
+
+// tempPattern splits a pattern of CreateTemp or MkdirTemp at its last "*".
+func (f *FS) tempPattern(op, pattern string) (prefix, suffix string, err error) {
+	if strings.ContainsRune(pattern, '/') || strings.ContainsRune(pattern, rune(f.pathSeparator())) {
+		return "", "", &fs.PathError{Op: op, Path: pattern, Err: ErrPatternHasSeparator}
+	}
+	if i := strings.LastIndex(pattern, "*"); i != -1 {
+		return pattern[:i], pattern[i+1:], nil
+	}
+	return pattern, "", nil
 }
 
 func (f *FS) ValidPath(path string) bool {
@@ -86,6 +287,21 @@ func (f *FS) ValidPath(path string) bool {
 	return true
 }
 
+// nodeAt returns the node at path, the root included, the file a hard link names for one.
+func (f *FS) nodeAt(path string) (*fsNode, error) {
+	parentNode, entryNode, missingPath, err := f.getEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	if missingPath != "" {
+		return nil, fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
+	}
+	if entryNode == nil {
+		return parentNode, nil
+	}
+	return entryNode.target(), nil
+}
+
 func (f *FS) getEntry(path string) (parent *fsNode, entry *fsNode, missingPath string, err error) {
 	if !f.ValidPath(path) {
 		return nil, nil, "", fmt.Errorf("invalid path: %s: %w", path, os.ErrInvalid)
@@ -93,72 +309,103 @@ func (f *FS) getEntry(path string) (parent *fsNode, entry *fsNode, missingPath s
 
 	path = f.getAbsolutePath(path)
 
-	parentDir, lastEntry := filepath.Split(path)
+	i := strings.LastIndexByte(path, '/')
+	parentDir, lastEntry := path[:i+1], path[i+1:]
 	if parentDir == "/" && lastEntry == "" {
 		// was requesting entry for root dir
 		return f.root, nil, "", nil
 	}
+	if parent, entry, ok := f.getLookup(path); ok {
+		return parent, entry, "", nil
+	}
+	generation := f.lookupGeneration()
 
 	var parts []string
 	if parentDir == "/" {
 		// handle root dir
 		parts = []string{""}
 	} else {
-		parts = strings.Split(filepath.Clean(parentDir), string(filepath.Separator))
+		parts = strings.Split(strings.TrimSuffix(parentDir, "/"), "/")
 	}
 
 	current := f.root
 	parts = parts[1:]
 	for i, part := range parts {
-		current.mutex.Lock()
-		if e, exists := current.entries[part]; exists {
+		if err := f.checkAccess(path, current, accessExecute); err != nil {
+			return nil, nil, "", err
+		}
+		current.lock()
+		if e, exists := current.entries.get(part); exists {
 			if !e.isDir() {
-				current.mutex.Unlock()
-				return nil, nil, "", fmt.Errorf("not a directory: %s: %w", part, os.ErrInvalid)
+				current.unlock()
+				return nil, nil, "", fmt.Errorf("not a directory: %s: %w", part, syscall.ENOTDIR)
 			}
-			current.mutex.Unlock()
+			current.unlock()
 			current = e
 		} else {
-			current.mutex.Unlock()
-			return current, nil, strings.Join(parts[i:], string(filepath.Separator)), nil
+			current.unlock()
+			return current, nil, strings.Join(append(parts[i:], lastEntry), "/"), nil
 		}
 	}
 
-	if e, exists := current.entries[lastEntry]; exists {
+	if err := f.checkAccess(path, current, accessExecute); err != nil {
+		return nil, nil, "", err
+	}
+
+	current.lock()
+	e, exists := current.entries.get(lastEntry)
+	current.unlock()
+	if exists {
+		f.putLookup(path, current, e, generation)
 		return current, e, "", nil
 	}
 
 	return current, nil, lastEntry, nil
 }
 
-func (f *FS) MkdirAll(path string, perm os.FileMode) error {
+func (f *FS) MkdirAll(path string, perm os.FileMode) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpMkdir, path); err != nil {
+		return err
+	}
 	if path == "" || !f.ValidPath(path) {
 		return fmt.Errorf("invalid path: %s: %w", path, os.ErrInvalid)
 	}
 
 	path = f.getAbsolutePath(path)
+	if err := f.validateNewPath(path); err != nil {
+		return err
+	}
 
-	parts := strings.Split(path, string(filepath.Separator))
+	parts := strings.Split(path, "/")
+	perm = f.masked(perm)
 
 	current := f.root
 	for _, part := range parts[1:] {
-		current.mutex.Lock()
-		if entry, exists := current.entries[part]; exists {
+		if part == "" {
+			// the root dir
+			continue
+		}
+		if err := f.checkAccess(path, current, accessExecute); err != nil {
+			return err
+		}
+		current.lock()
+		if entry, exists := current.entries.get(part); exists {
 			if !entry.isDir() {
-				current.mutex.Unlock()
-				return fmt.Errorf("not a directory: %s: %w", part, os.ErrInvalid)
+				current.unlock()
+				return fmt.Errorf("not a directory: %s: %w", part, syscall.ENOTDIR)
 			}
-			current.mutex.Unlock()
+			current.unlock()
 			current = entry
 		} else {
-			entry := &fsNode{
-				name:     part,
-				perm:     perm,
-				modified: time.Now(),
-				entries:  make(map[string]*fsNode),
+			if !f.hasAccess(current, accessWrite) {
+				current.unlock()
+				return fmt.Errorf("permission denied: %s: %w", path, os.ErrPermission)
 			}
-			current.entries[part] = entry
-			current.mutex.Unlock()
+			entry := f.newNode(part, perm, true)
+			current.entries.set(entry)
+			f.touch(current)
+			current.unlock()
 			current = entry
 		}
 	}
@@ -173,14 +420,17 @@ func (f fileFlags) isSet(mask int) bool {
 	}
 	return false
 }
+func (f fileFlags) accessMode() int {
+	return int(f) & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+}
 func (f fileFlags) isReadOnly() bool {
-	return int(f) == os.O_RDONLY
+	return f.accessMode() == os.O_RDONLY
 }
 func (f fileFlags) isWriteOnly() bool {
-	return f.isSet(os.O_WRONLY)
+	return f.accessMode() == os.O_WRONLY
 }
 func (f fileFlags) isReadWrite() bool {
-	return f.isSet(os.O_RDWR)
+	return f.accessMode() == os.O_RDWR
 }
 func (f fileFlags) canRead() bool {
 	return f.isReadOnly() || f.isReadWrite()
@@ -200,6 +450,26 @@ func (f fileFlags) isCreateMustNotExist() bool {
 func (f fileFlags) isTruncating() bool {
 	return f.isSet(os.O_TRUNC)
 }
+func (f fileFlags) isDirectory() bool {
+	return f.isSet(O_DIRECTORY)
+}
+
+// validate rejects the flag combinations the OS rejects, or whose result it leaves unspecified.
+// O_SYNC is accepted, content is always up to date, even WithStagedWrites.
+func (f fileFlags) validate(path string) error {
+	known := os.O_RDONLY | os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_EXCL | os.O_SYNC | os.O_TRUNC | O_DIRECTORY | O_NOFOLLOW
+	switch {
+	case int(f)&^known != 0:
+		return fmt.Errorf("unsupported open flags %#x: %s: %w", int(f)&^known, path, syscall.EINVAL)
+	case f.accessMode() == os.O_WRONLY|os.O_RDWR:
+		return fmt.Errorf("invalid access mode: %s: %w", path, syscall.EINVAL)
+	case f.isReadOnly() && f.isTruncating():
+		return fmt.Errorf("cannot truncate a file opened read only: %s: %w", path, syscall.EINVAL)
+	case f.isCreateMustNotExist() && !f.isCreate():
+		return fmt.Errorf("O_EXCL without O_CREATE: %s: %w", path, syscall.EINVAL)
+	}
+	return nil
+}
 
 func (f *FS) Open(path string) (*File, error) {
 	return f.OpenFile(path, os.O_RDONLY, 0)
@@ -207,109 +477,284 @@ func (f *FS) Open(path string) (*File, error) {
 func (f *FS) Create(path string) (*File, error) {
 	return f.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 }
-func (f *FS) OpenFile(path string, flag int, perm os.FileMode) (*File, error) {
+
+// OpenFile opens the file at path like os.OpenFile. Besides the flags of the os package,
+// it supports O_DIRECTORY and O_NOFOLLOW, which never fails as there are no symbolic links.
+func (f *FS) OpenFile(path string, flag int, perm os.FileMode) (_ *File, err error) {
+	defer f.mapErrno(&err)
+	if err := f.guardOpen(path, flag); err != nil {
+		return nil, err
+	}
+	base := f.baseFS()
+	if base.maxOpenFiles > 0 {
+		if err := base.reserveOpenFile(path); err != nil {
+			return nil, err
+		}
+		defer base.releaseOpenFile()
+	}
+	return f.openFile(path, flag, f.masked(perm))
+}
+
+func (f *FS) openFile(path string, flag int, perm os.FileMode) (*File, error) {
 	fileFlag := fileFlags(flag)
+	strict := f.baseFS().strictFlags
+	if strict {
+		if err := fileFlag.validate(path); err != nil {
+			return nil, err
+		}
+	}
 
 	parentNode, entryNode, missingPath, err := f.getEntry(path)
 	if err != nil {
 		return nil, err
 	}
+	if entryNode == nil && missingPath == "" {
+		// the root dir
+		entryNode = parentNode
+	}
+	if entryNode != nil {
+		// a hard link opens the file it names
+		entryNode = entryNode.target()
+	}
 
 	// the path yet to create would point to a further nesting directory, the full path to the parent
 	// directory does not exist and should be an error
-	if missingPath != "" && len(strings.Split(missingPath, string(filepath.Separator))) > 1 {
+	if missingPath != "" && len(strings.Split(missingPath, "/")) > 1 {
 		return nil, fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
 	}
 
 	crws := &contentReadWriteSeekerImpl{owner: entryNode}
 
 	if entryNode != nil {
+		if fileFlag.isCreate() && fileFlag.isCreateMustNotExist() {
+			return nil, fmt.Errorf("path exists: %s: %w", path, os.ErrExist)
+		}
+		if fileFlag.isDirectory() && !entryNode.isDir() {
+			return nil, fmt.Errorf("not a directory: %s: %w", path, syscall.ENOTDIR)
+		}
+		if strict && entryNode.isDir() && (fileFlag.canWrite() || fileFlag.isTruncating()) {
+			return nil, fmt.Errorf("is a directory: %s: %w", path, syscall.EISDIR)
+		}
+		if fileFlag.canRead() {
+			if err := f.checkAccess(path, entryNode, accessRead); err != nil {
+				return nil, err
+			}
+		}
+		if fileFlag.canWrite() && !entryNode.isDir() {
+			if err := f.checkAccess(path, entryNode, accessWrite); err != nil {
+				return nil, err
+			}
+		}
 		if entryNode.isDir() {
-			return &File{
+			return f.addOpenFile(&File{
+				fs:   f,
 				node: entryNode,
+				name: path,
 				flag: fileFlag,
-				fd:   f.getNextFileDescriptor(),
-			}, nil
+			}), nil
+		}
+		if err := f.verifyOpen(path, entryNode, fileFlag); err != nil {
+			return nil, err
 		}
 		if fileFlag.canWrite() {
-			if fileFlag.isCreate() && fileFlag.isCreateMustNotExist() {
-				return nil, fmt.Errorf("path exists: %s: %w", path, os.ErrExist)
-			}
 			if fileFlag.isTruncating() {
 				entryNode.lockContent()
-				entryNode.setContent([]byte{})
+				entryNode.truncateContent(0)
 				entryNode.unlockContent()
+				f.rangeTracker(entryNode).truncate(0)
+				f.written(entryNode)
 			} else if fileFlag.isAppend() {
 				_, _ = crws.Seek(0, io.SeekEnd)
 			}
 		}
 	} else {
-		if fileFlag.isReadOnly() {
+		if strict && !fileFlag.isCreate() {
+			return nil, fmt.Errorf("path does not exist: %s: %w", path, syscall.ENOENT)
+		} else if fileFlag.isReadOnly() && !fileFlag.isCreate() {
 			return nil, fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
 		} else {
 			if fileFlag.isCreate() {
-				parentNode.mutex.Lock()
-				defer parentNode.mutex.Unlock()
-				entryNode = &fsNode{
-					name:     missingPath,
-					perm:     perm,
-					modified: time.Now(),
-					content:  []byte{},
+				if fileFlag.isDirectory() {
+					return nil, fmt.Errorf("cannot create a directory with OpenFile: %s: %w", path, syscall.EINVAL)
+				}
+				if err := f.checkAccess(path, parentNode, accessWrite|accessExecute); err != nil {
+					return nil, err
+				}
+				if err := f.validateNewPath(path); err != nil {
+					return nil, err
 				}
+				parentNode.lock()
+				defer parentNode.unlock()
+				entryNode = f.newNode(missingPath, perm, false)
 				crws.owner = entryNode
-				parentNode.entries[missingPath] = entryNode
+				parentNode.entries.set(entryNode)
+				f.touch(parentNode)
 			} else {
 				return nil, fmt.Errorf("path does not exist and cannot create: %s: %w", path, os.ErrInvalid)
 			}
 		}
 	}
 
-	return &File{
-		node: entryNode,
-		flag: fileFlag,
-		crws: crws,
-		fd:   f.getNextFileDescriptor(),
-	}, nil
+	dev, err := f.openDevice(entryNode, fileFlag)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open: %s: %w", path, err)
+	}
+	crws.ranges = f.rangeTracker(entryNode)
+	crws.holes = f.baseFS().keepHoles && !entryNode.isDir()
+	file := &File{
+		fs:     f,
+		node:   entryNode,
+		name:   path,
+		flag:   fileFlag,
+		crws:   crws,
+		device: dev,
+	}
+	if f.baseFS().staged && fileFlag.canWrite() && !fileFlag.isSet(os.O_SYNC) && file.device == nil {
+		file.staged = newStagedContent(entryNode)
+		crws.owner = file.staged
+	}
+	return f.addOpenFile(file), nil
+}
+
+// ReadFile returns the content of the file at path, like os.ReadFile.
+func (f *FS) ReadFile(path string) (_ []byte, err error) {
+	defer f.mapErrno(&err)
+	file, err := f.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if file.isDir() {
+		return nil, fmt.Errorf("is a directory: %s: %w", path, syscall.EISDIR)
+	}
+	if file.device != nil {
+		return io.ReadAll(file)
+	}
+	f.accessed(file.node)
+	file.node.lockContent()
+	defer file.node.unlockContent()
+	return append([]byte{}, file.node.getContent()...), nil
+}
+
+// WriteFile writes data to the file at path, like os.WriteFile: the file is created with
+// perm when it doesn't exist, and truncated otherwise.
+func (f *FS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return f.writeFile(path, data, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+// AppendFile writes data at the end of the file at path, which is created with perm when
+// it doesn't exist.
+func (f *FS) AppendFile(path string, data []byte, perm os.FileMode) error {
+	return f.writeFile(path, data, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
+}
+
+func (f *FS) writeFile(path string, data []byte, flag int, perm os.FileMode) error {
+	file, err := f.OpenFile(path, flag, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
-func (f *FS) Stat(path string) (FileInfo, error) {
-	_, entryNode, missingPath, err := f.getEntry(path)
+func (f *FS) Stat(path string) (_ FileInfo, err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpStat, path); err != nil {
+		return FileInfo{}, err
+	}
+	parentNode, entryNode, missingPath, err := f.getEntry(path)
 	if err != nil {
 		return FileInfo{}, err
 	}
+	if entryNode == nil && missingPath == "" {
+		// the root dir
+		entryNode = parentNode
+	}
 	if missingPath != "" {
 		return FileInfo{}, fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
 	}
-	return FileInfo{node: entryNode}, nil
+	return FileInfo{node: entryNode, fs: f, times: f.timesOf(entryNode)}, nil
 }
 
-func (f *FS) Remove(path string) error {
+func (f *FS) Remove(path string) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpRemove, path); err != nil {
+		return err
+	}
 	parentNode, entryNode, missingPath, err := f.getEntry(path)
 	if err != nil {
 		return err
 	}
+	if entryNode == nil && missingPath == "" {
+		return fmt.Errorf("cannot remove root: %s: %w", path, syscall.EBUSY)
+	}
 	if missingPath != "" {
 		return fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
 	}
-	if entryNode.isDir() {
-		if len(entryNode.entries) == 0 {
-			parentNode.mutex.Lock()
-			defer parentNode.mutex.Unlock()
-			entryNode.unlinked = true
-			delete(parentNode.entries, entryNode.name)
-		} else {
-			return fmt.Errorf("directory not empty: %s: %w", path, os.ErrInvalid)
-		}
-	} else {
-		parentNode.mutex.Lock()
-		defer parentNode.mutex.Unlock()
-		entryNode.unlinked = true
-		delete(parentNode.entries, entryNode.name)
+	if err = f.checkAccess(path, parentNode, accessWrite|accessExecute); err != nil {
+		return err
+	}
+	if entryNode.isDir() && entryNode.entries.len() > 0 {
+		return fmt.Errorf("directory not empty: %s: %w", path, syscall.ENOTEMPTY)
+	}
+	parentNode.lock()
+	defer parentNode.unlock()
+	if f.baseFS().trash != nil {
+		f.moveToTrash(parentNode, entryNode, path)
+		return nil
+	}
+	parentNode.removeEntry(entryNode.name)
+	f.invalidateLookups()
+	f.touch(parentNode)
+	if entryNode.unlinkName() {
+		f.dropTags(entryNode.target())
 	}
 	return nil
 }
 
 func (f *FS) RemoveAll(path string) error {
+	return f.RemoveAllContext(context.Background(), path, nil)
+}
+
+// RemoveProgress reports the progress of RemoveAllContext.
+type RemoveProgress struct {
+	Path       string // the entry just removed
+	Removed    int    // entries removed so far
+	BytesFreed int64  // content bytes of the files removed so far
+}
+
+// RemoveAllContext is RemoveAll stopping with the context error when ctx is done, leaving
+// the entries not yet removed in place. onProgress, when not nil, is called after every
+// entry removed.
+func (f *FS) RemoveAllContext(ctx context.Context, path string, onProgress func(RemoveProgress)) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpRemove, path); err != nil {
+		return err
+	}
+	var progress RemoveProgress
+	return f.removeAll(ctx, path, &progress, onProgress)
+}
+
+// removeFrame is an entry RemoveAllContext is removing.
+type removeFrame struct {
+	path     string
+	parent   *fsNode
+	node     *fsNode
+	depth    int
+	expanded bool // the entries of the directory were queued for removal
+}
+
+// removeAll removes the entry at path and everything beneath it, depth first with an
+// explicit stack so the depth of the tree doesn't matter. The entries which can't be
+// removed are left in place, and so are the directories holding them, only the error of
+// the entry at path is returned.
+func (f *FS) removeAll(ctx context.Context, path string, progress *RemoveProgress, onProgress func(RemoveProgress)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	parentNode, entryNode, missingPath, err := f.getEntry(path)
 	if err != nil {
 		return err
@@ -317,43 +762,173 @@ func (f *FS) RemoveAll(path string) error {
 	if missingPath != "" {
 		return fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
 	}
-	if entryNode.isDir() {
-		for part := range entryNode.entries {
-			_ = f.RemoveAll(filepath.Join(path, part))
+	if entryNode == nil {
+		return fmt.Errorf("cannot remove root: %s: %w", path, syscall.EBUSY)
+	}
+	if f.baseFS().trash != nil {
+		if err = f.checkAccess(path, parentNode, accessWrite|accessExecute); err != nil {
+			return err
 		}
-		parentNode.mutex.Lock()
-		entryNode.unlinked = true
-		delete(parentNode.entries, entryNode.name)
-		parentNode.mutex.Unlock()
-	} else {
-		parentNode.mutex.Lock()
-		entryNode.unlinked = true
-		delete(parentNode.entries, entryNode.name)
-		parentNode.mutex.Unlock()
+		// the entry goes to the trash with everything beneath it
+		parentNode.lock()
+		f.moveToTrash(parentNode, entryNode, path)
+		parentNode.unlock()
+		reportRemoved(path, 0, progress, onProgress)
+		return nil
+	}
+
+	stack := []removeFrame{{path: path, parent: parentNode, node: entryNode}}
+	for len(stack) > 0 {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		fr := &stack[len(stack)-1]
+		if !fr.expanded {
+			err = f.checkDepth(fr.path, fr.depth)
+			if err != nil {
+				return err
+			}
+			if err = f.checkAccess(fr.path, fr.parent, accessWrite|accessExecute); err == nil && fr.node.isDir() {
+				err = f.checkAccess(fr.path, fr.node, accessRead|accessWrite|accessExecute)
+			}
+			if err == nil && fr.node.isDir() {
+				fr.expanded = true
+				fr.node.lock()
+				children := fr.node.entries.list()
+				fr.node.unlock()
+				parent, childPath, depth := fr.node, fr.path, fr.depth+1
+				// pushed in reverse order, so they are removed in name order
+				for i := len(children) - 1; i >= 0; i-- {
+					stack = append(stack, removeFrame{path: f.join(childPath, children[i].name), parent: parent, node: children[i], depth: depth})
+				}
+				continue
+			}
+		} else if fr.node.entries.len() > 0 {
+			err = fmt.Errorf("directory not empty: %s: %w", fr.path, syscall.ENOTEMPTY)
+		}
+
+		done := *fr
+		stack = stack[:len(stack)-1]
+		if err != nil {
+			if len(stack) == 0 {
+				return err
+			}
+			// the directory holding the entry can't be removed either
+			continue
+		}
+		done.parent.lock()
+		removed := false
+		if e, _ := done.parent.entries.get(done.node.name); e == done.node {
+			done.parent.removeEntry(done.node.name)
+			f.invalidateLookups()
+			f.touch(done.parent)
+			removed = true
+		}
+		done.parent.unlock()
+		if !removed {
+			if len(stack) == 0 {
+				return fmt.Errorf("path does not exist: %s: %w", done.path, os.ErrNotExist)
+			}
+			continue
+		}
+		var freed int64
+		if t := done.node.target(); !t.isDir() {
+			t.lockContent()
+			freed = int64(t.size())
+			t.unlockContent()
+		}
+		if done.node.unlinkName() {
+			f.dropTags(done.node.target())
+		} else {
+			// the content stays with the other hard links
+			freed = 0
+		}
+		reportRemoved(done.path, freed, progress, onProgress)
 	}
 	return nil
 }
-func (f *FS) ReadDir(path string) ([]os.DirEntry, error) {
-	_, entryNode, missingPath, err := f.getEntry(path)
+
+// reportRemoved counts an entry removed by RemoveAllContext.
+func reportRemoved(path string, freed int64, progress *RemoveProgress, onProgress func(RemoveProgress)) {
+	progress.Path = path
+	progress.Removed++
+	progress.BytesFreed += freed
+	if onProgress != nil {
+		onProgress(*progress)
+	}
+}
+
+func (f *FS) ReadDir(path string) (_ []os.DirEntry, err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpReadDir, path); err != nil {
+		return nil, err
+	}
+	dir, err := f.readDirNode(path)
+	if err != nil {
+		return nil, err
+	}
+	f.accessed(dir)
+	dir.lock()
+	defer dir.unlock()
+	return f.withFS(dir.entries.dirEntries()), nil
+}
+
+// readDirNode returns the node of the directory at path, to be listed.
+func (f *FS) readDirNode(path string) (*fsNode, error) {
+	parentNode, entryNode, missingPath, err := f.getEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	if entryNode == nil && missingPath == "" {
+		// the root dir
+		entryNode = parentNode
+	}
+	if missingPath != "" {
+		return nil, fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
+	}
+	if err = f.checkAccess(path, entryNode, accessRead); err != nil {
+		return nil, err
+	}
+	return entryNode, nil
+}
+
+// ReadDirAfter returns up to n entries of the directory, all of them when n <= 0, whose names
+// sort after the name given. Large directories can be listed page by page by passing the
+// name of the last entry of the previous page.
+func (f *FS) ReadDirAfter(path, after string, n int) (_ []os.DirEntry, err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpReadDir, path); err != nil {
+		return nil, err
+	}
+	parentNode, entryNode, missingPath, err := f.getEntry(path)
 	if err != nil {
 		return nil, err
 	}
+	if entryNode == nil && missingPath == "" {
+		// the root dir
+		entryNode = parentNode
+	}
 	if missingPath != "" {
 		return nil, fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
 	}
-	names := entryNode.getEntryNames()
-	entryNode.mutex.Lock()
-	defer entryNode.mutex.Unlock()
-	dirEntries := make([]os.DirEntry, len(names), len(names))
-	for i := range names {
-		dirEntries[i] = DirEntry{
-			node: entryNode.entries[names[i]],
-		}
+	if !entryNode.isDir() {
+		return nil, fmt.Errorf("not a directory: %s: %w", path, syscall.ENOTDIR)
+	}
+	if err = f.checkAccess(path, entryNode, accessRead); err != nil {
+		return nil, err
 	}
-	return dirEntries, nil
+	entryNode.lock()
+	nodes := entryNode.entries.after(after, n)
+	entryNode.unlock()
+	f.accessed(entryNode)
+	return f.withFS(toDirEntries(nodes)), nil
 }
 
-func (f *FS) Mkdir(path string, perm os.FileMode) error {
+func (f *FS) Mkdir(path string, perm os.FileMode) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpMkdir, path); err != nil {
+		return err
+	}
 	parentNode, entryNode, missingPath, err := f.getEntry(path)
 	if err != nil {
 		return err
@@ -365,65 +940,346 @@ func (f *FS) Mkdir(path string, perm os.FileMode) error {
 	if entryNode != nil {
 		return fmt.Errorf("path exists: %s: %w", path, os.ErrExist)
 	}
-	if missingPath != "" && len(strings.Split(missingPath, string(filepath.Separator))) > 1 {
+	if missingPath != "" && len(strings.Split(missingPath, "/")) > 1 {
 		return fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
 	}
-	parentNode.mutex.Lock()
-	defer parentNode.mutex.Unlock()
-	entryNode = &fsNode{
-		name:     missingPath,
-		perm:     perm,
-		modified: time.Now(),
-		entries:  make(map[string]*fsNode),
+	if err = f.checkAccess(path, parentNode, accessWrite|accessExecute); err != nil {
+		return err
+	}
+	if err = f.validateNewPath(path); err != nil {
+		return err
 	}
-	parentNode.entries[missingPath] = entryNode
+	parentNode.lock()
+	defer parentNode.unlock()
+	entryNode = f.newNode(missingPath, f.masked(perm), true)
+	parentNode.entries.set(entryNode)
+	f.touch(parentNode)
 	return nil
 }
 
-func (f *FS) CreateTemp(dir, pattern string) (*File, error) {
-	if dir == "" {
-		dir = f.TempDir()
+// Rename moves the file or directory at oldpath to newpath like os.Rename, directories
+// with everything beneath them. An existing file at newpath is replaced, as is an existing
+// empty directory when oldpath is a directory.
+func (f *FS) Rename(oldpath, newpath string) error {
+	return rename(f, oldpath, f, newpath)
+}
+
+// rename moves the entry at oldpath in the tree of oldfs to newpath in the tree of newfs,
+// two views of the same filesystem, rooted at different directories for RenameAt.
+func rename(oldfs *FS, oldpath string, newfs *FS, newpath string) (err error) {
+	defer oldfs.mapErrno(&err)
+	if err := oldfs.checkGuard(OpRename, oldpath); err != nil {
+		return err
+	}
+	if err := newfs.checkGuard(OpRename, newpath); err != nil {
+		return err
+	}
+	oldParent, oldNode, oldMissing, err := oldfs.getEntry(oldpath)
+	if err != nil {
+		return err
+	}
+	if oldNode == nil {
+		if oldMissing == "" {
+			return fmt.Errorf("cannot rename root: %s: %w", oldpath, syscall.EBUSY)
+		}
+		return fmt.Errorf("path does not exist: %s: %w", oldpath, os.ErrNotExist)
 	}
 
-	_, entryNode, _, err := f.getEntry(dir)
+	newParent, newNode, newMissing, err := newfs.getEntry(newpath)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if newNode == nil {
+		if newMissing == "" {
+			return fmt.Errorf("cannot rename over root: %s: %w", newpath, syscall.EBUSY)
+		}
+		if len(strings.Split(newMissing, "/")) > 1 {
+			return fmt.Errorf("path does not exist: %s: %w", newpath, os.ErrNotExist)
+		}
+	}
+	if oldNode == newNode || newNode != nil && oldNode.target() == newNode.target() {
+		// like rename(2), nothing is done for two hard links to the same file
+		return nil
+	}
+
+	if err = oldfs.checkAccess(oldpath, oldParent, accessWrite|accessExecute); err != nil {
+		return err
+	}
+	if err = newfs.checkAccess(newpath, newParent, accessWrite|accessExecute); err != nil {
+		return err
+	}
+	if err = newfs.validateNewPath(newpath); err != nil {
+		return err
+	}
+
+	sameTree := oldfs.root == newfs.root
+	newAbs := newfs.getAbsolutePath(newpath)
+	if oldNode.isDir() && ((sameTree && strings.HasPrefix(newAbs, oldfs.getAbsolutePath(oldpath)+"/")) ||
+		(!sameTree && oldNode.contains(newParent))) {
+		return fmt.Errorf("cannot move directory into itself: %s: %w", newpath, syscall.EINVAL)
+	}
+	if newNode != nil {
+		if newNode.isDir() && !oldNode.isDir() {
+			return fmt.Errorf("is a directory: %s: %w", newpath, syscall.EISDIR)
+		}
+		if !newNode.isDir() && oldNode.isDir() {
+			return fmt.Errorf("not a directory: %s: %w", newpath, syscall.ENOTDIR)
+		}
+	}
+
+	// renames lock two directories, they are serialized so that two renames can never
+	// lock the same pair of directories in opposite order
+	base := oldfs.baseFS()
+	base.lockRename()
+	defer base.unlockRename()
+
+	oldParent.lock()
+	defer oldParent.unlock()
+	if newParent != oldParent {
+		newParent.lock()
+		defer newParent.unlock()
+	}
+
+	newName := baseName(newAbs)
+	if e, _ := oldParent.entries.get(oldNode.name); e != oldNode {
+		return fmt.Errorf("path changed during rename: %s: %w", oldpath, os.ErrNotExist)
+	}
+	if e, _ := newParent.entries.get(newName); e != newNode {
+		return fmt.Errorf("path changed during rename: %s: %w", oldpath, os.ErrNotExist)
 	}
-	if entryNode == nil || !entryNode.isDir() {
-		return nil, fmt.Errorf("dir does not exist: %s: %w", dir, os.ErrNotExist)
+
+	if newNode != nil && newNode.isDir() {
+		newNode.lock()
+		empty := newNode.entries.len() == 0
+		if empty {
+			newNode.unlinked = true
+		}
+		newNode.unlock()
+		if !empty {
+			return fmt.Errorf("directory not empty: %s: %w", newpath, syscall.ENOTEMPTY)
+		}
 	}
 
-	var file *File
-	err = errors.New("tmp")
-	for err != nil {
-		file, err = f.Create(filepath.Join(dir, f.createRandomPathPart(pattern)))
+	oldParent.removeEntry(oldNode.name)
+	oldNode.name = internName(newName)
+	newParent.entries.set(oldNode)
+	oldfs.invalidateLookups()
+	oldfs.touch(oldParent)
+	newfs.touch(newParent)
+	oldfs.changed(oldNode)
+
+	if newNode != nil && newNode.unlinkName() {
+		oldfs.dropTags(newNode.target())
+	}
+	if base.tags != nil && sameTree {
+		base.tags.rename(oldfs.root, oldfs.getAbsolutePath(oldpath), newAbs)
 	}
-	return file, nil
+
+	return nil
 }
 
-func (f *FS) MkdirTemp(dir, pattern string) (name string, err error) {
+// RenameExchange atomically swaps the entries at a and b, files or directories, like
+// renameat2 with RENAME_EXCHANGE, so no one can see either path missing. Both must exist,
+// and neither can be a directory containing the other.
+func (f *FS) RenameExchange(a, b string) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpRename, a); err != nil {
+		return err
+	}
+	if err := f.checkGuard(OpRename, b); err != nil {
+		return err
+	}
+	aParent, aNode, err := f.exchangedEntry(a)
+	if err != nil {
+		return err
+	}
+	bParent, bNode, err := f.exchangedEntry(b)
+	if err != nil {
+		return err
+	}
+	if aNode == bNode {
+		return nil
+	}
+	if err = f.checkAccess(a, aParent, accessWrite|accessExecute); err != nil {
+		return err
+	}
+	if err = f.checkAccess(b, bParent, accessWrite|accessExecute); err != nil {
+		return err
+	}
+	if (aNode.isDir() && aNode.contains(bNode)) || (bNode.isDir() && bNode.contains(aNode)) {
+		return fmt.Errorf("cannot move directory into itself: %s: %w", b, syscall.EINVAL)
+	}
+
+	base := f.baseFS()
+	base.lockRename()
+	defer base.unlockRename()
+
+	aParent.lock()
+	defer aParent.unlock()
+	if bParent != aParent {
+		bParent.lock()
+		defer bParent.unlock()
+	}
+
+	if e, _ := aParent.entries.get(aNode.name); e != aNode {
+		return fmt.Errorf("path changed during rename: %s: %w", a, os.ErrNotExist)
+	}
+	if e, _ := bParent.entries.get(bNode.name); e != bNode {
+		return fmt.Errorf("path changed during rename: %s: %w", b, os.ErrNotExist)
+	}
+
+	// the entries are found by name, their places are taken before the names are swapped
+	ai, _ := aParent.entries.search(aNode.name)
+	bi, _ := bParent.entries.search(bNode.name)
+	aNode.name, bNode.name = bNode.name, aNode.name
+	aParent.entries.replace(ai, bNode)
+	bParent.entries.replace(bi, aNode)
+	f.invalidateLookups()
+	f.touch(aParent)
+	f.touch(bParent)
+	f.changed(aNode)
+	f.changed(bNode)
+
+	if base.tags != nil {
+		base.tags.exchange(f.root, f.getAbsolutePath(a), f.getAbsolutePath(b))
+	}
+	return nil
+}
+
+// exchangedEntry returns the entry at path, and its parent, to be swapped by RenameExchange.
+func (f *FS) exchangedEntry(path string) (*fsNode, *fsNode, error) {
+	parent, n, missing, err := f.getEntry(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n == nil {
+		if missing == "" {
+			return nil, nil, fmt.Errorf("cannot rename root: %s: %w", path, syscall.EBUSY)
+		}
+		return nil, nil, fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
+	}
+	return parent, n, nil
+}
+
+// Chtimes changes the access and modification times of the entry at path, like os.Chtimes.
+// atime is ignored unless the filesystem was created WithTimes. A zero time is left unchanged.
+// Through a view created by As, only the owner of the entry and the superuser can.
+func (f *FS) Chtimes(path string, atime, mtime time.Time) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpChtimes, path); err != nil {
+		return err
+	}
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return err
+	}
+	return f.chtimes(path, n, atime, mtime)
+}
+
+// Chtimes changes the modification time of the file like FS.Chtimes, as futimens does.
+func (f *File) Chtimes(atime, mtime time.Time) (err error) {
+	defer f.fs.mapErrno(&err)
+	if err := f.checkAttributes(); err != nil {
+		return err
+	}
+	return f.fs.chtimes(f.Name(), f.node, atime, mtime)
+}
+
+func (f *FS) chtimes(name string, n *fsNode, atime, mtime time.Time) error {
+	n.lock()
+	defer n.unlock()
+	if !f.owns(n) {
+		return fmt.Errorf("not the owner: %s: %w", name, os.ErrPermission)
+	}
+	if !mtime.IsZero() {
+		n.modified = mtime
+	}
+	if !atime.IsZero() {
+		f.setAccessTime(n, atime)
+	}
+	f.changed(n)
+	return nil
+}
+
+// Truncate changes the size of the file at path like os.Truncate, zero filling it past its
+// current end.
+func (f *FS) Truncate(path string, size int64) (err error) {
+	defer f.mapErrno(&err)
+	if _, err := f.Stat(path); err != nil {
+		return err
+	}
+	file, err := f.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = file.Truncate(size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// CreateTemp creates a new file in dir, or in TempDir when dir is empty, like os.CreateTemp:
+// the name is pattern with its last "*" replaced by a random string, which is appended when
+// there is no "*", and the file is opened O_RDWR|O_CREATE|O_EXCL with mode 0600. An error
+// wrapping fs.ErrExist is returned when no free name is found.
+func (f *FS) CreateTemp(dir, pattern string) (_ *File, err error) {
+	defer f.mapErrno(&err)
 	if dir == "" {
 		dir = f.TempDir()
 	}
+	prefix, suffix, err := f.tempPattern("createtemp", pattern)
+	if err != nil {
+		return nil, err
+	}
+	for try := 0; try < tempAttempts; try++ {
+		file, err := f.OpenFile(f.join(dir, prefix+f.nextRandom()+suffix), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if !errors.Is(err, os.ErrExist) {
+			return file, err
+		}
+	}
+	return nil, &fs.PathError{Op: "createtemp", Path: f.join(dir, prefix+"*"+suffix), Err: fs.ErrExist}
+}
 
-	_, entryNode, _, err := f.getEntry(dir)
+// MkdirTemp creates a new directory in dir, or in TempDir when dir is empty, like
+// os.MkdirTemp: the name is pattern with its last "*" replaced by a random string, which
+// is appended when there is no "*", and the directory is created with mode 0700.
+func (f *FS) MkdirTemp(dir, pattern string) (name string, err error) {
+	defer f.mapErrno(&err)
+	if dir == "" {
+		dir = f.TempDir()
+	}
+	prefix, suffix, err := f.tempPattern("mkdirtemp", pattern)
 	if err != nil {
 		return "", err
 	}
-	if entryNode == nil || !entryNode.isDir() {
-		return "", fmt.Errorf("dir does not exist: %s: %w", dir, os.ErrNotExist)
+	for try := 0; try < tempAttempts; try++ {
+		name = f.join(dir, prefix+f.nextRandom()+suffix)
+		err = f.Mkdir(name, 0700)
+		if err == nil {
+			return name, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
 	}
+	return "", &fs.PathError{Op: "mkdirtemp", Path: f.join(dir, prefix+"*"+suffix), Err: fs.ErrExist}
+}
 
-	var tDir string
-	err = errors.New("tmp")
-	for err != nil {
-		tDir = filepath.Join(dir, f.createRandomPathPart(pattern))
-		err = f.Mkdir(tDir, fs.ModePerm)
+// TempDir returns the directory CreateTemp and MkdirTemp use when they are given none,
+// "/tmp" unless the filesystem was created WithTempDir or f is a view made by InTempDir.
+func (f *FS) TempDir() string {
+	if f.tempDir == "" {
+		return f.fromSlash(defaultTempDir)
 	}
-
-	return tDir, nil
+	return f.fromSlash(f.getAbsolutePath(f.tempDir))
 }
 
-func (f *FS) TempDir() string {
-	return string(filepath.Separator) + tempDir
+// InTempDir returns a view of the filesystem whose TempDir is dir, as TMPDIR does for a
+// process, so that temporary files of a session can be kept apart. The view shares the
+// tree and the identity of f, dir isn't created.
+func (f *FS) InTempDir(dir string) *FS {
+	v := f.view(f.root)
+	v.tempDir = dir
+	return v
 }
@@ -1,11 +1,16 @@
 package memfs
 
 import (
+	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"io/fs"
 	"os"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func Test_MkdirAll(t *testing.T) {
@@ -57,7 +62,7 @@ func Test_Try_Path_With_File_Part(t *testing.T) {
 
 	err = mfs.MkdirAll("/testDir/file1/testDir3", 0777)
 	assert.NotNil(t, err)
-	assert.True(t, errors.Is(err, os.ErrInvalid))
+	assert.True(t, errors.Is(err, syscall.ENOTDIR))
 
 	f2, err := mfs.Create("/testDir/testDir4/testDir5/file2")
 	assert.NotNil(t, err)
@@ -93,6 +98,13 @@ func Test_Missing_Dir_Paths(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, "", name)
 
+	f, err := mfs.Create("/test/file1")
+	assert.Nil(t, f)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	err = mfs.Mkdir("/test/test1", 0777)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
 }
 
 func Test_Non_UTF8_File_Path(t *testing.T) {
@@ -157,3 +169,582 @@ func Test_Open_Mode_Issues(t *testing.T) {
 	assert.Nil(t, f5)
 	assert.True(t, errors.Is(err, os.ErrNotExist))
 }
+
+func Test_Rename(t *testing.T) {
+	mfs := New()
+
+	assert.Nil(t, mfs.MkdirAll("/a/b", 0777))
+	f, err := mfs.Create("/a/b/file1")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(`file1`))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	assert.Nil(t, mfs.Rename("/a/b/file1", "/a/file2"))
+	_, err = mfs.Stat("/a/b/file1")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	fi, err := mfs.Stat("/a/file2")
+	assert.Nil(t, err)
+	assert.Equal(t, "file2", fi.Name())
+	assert.Equal(t, int64(5), fi.Size())
+
+	// whole subtrees move with their directory
+	assert.Nil(t, mfs.Rename("/a", "/c"))
+	_, err = mfs.Stat("/c/b")
+	assert.Nil(t, err)
+	_, err = mfs.Stat("/c/file2")
+	assert.Nil(t, err)
+
+	// renaming onto itself is a no-op
+	assert.Nil(t, mfs.Rename("/c/file2", "/c/file2"))
+
+	err = mfs.Rename("/missing", "/c/file3")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	err = mfs.Rename("/c/file2", "/missing/file3")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	err = mfs.Rename("/c", "/c/b/c")
+	assert.True(t, errors.Is(err, syscall.EINVAL))
+
+	err = mfs.Rename("/", "/d")
+	assert.True(t, errors.Is(err, syscall.EBUSY))
+}
+
+func Test_Rename_Over_Existing(t *testing.T) {
+	mfs := New()
+
+	assert.Nil(t, mfs.MkdirAll("/dir/full/child", 0777))
+	assert.Nil(t, mfs.MkdirAll("/dir/empty", 0777))
+	assert.Nil(t, mfs.MkdirAll("/dir/src", 0777))
+	for _, name := range []string{"/dir/file1", "/dir/file2"} {
+		f, err := mfs.Create(name)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(name))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+	}
+
+	replaced, err := mfs.Open("/dir/file2")
+	assert.Nil(t, err)
+
+	// file over file replaces the destination
+	assert.Nil(t, mfs.Rename("/dir/file1", "/dir/file2"))
+	fi, err := mfs.Stat("/dir/file2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len("/dir/file1")), fi.Size())
+	_, err = replaced.Stat()
+	assert.NotNil(t, err)
+
+	// file over directory
+	err = mfs.Rename("/dir/file2", "/dir/empty")
+	assert.True(t, errors.Is(err, syscall.EISDIR))
+
+	// directory over file
+	err = mfs.Rename("/dir/src", "/dir/file2")
+	assert.True(t, errors.Is(err, syscall.ENOTDIR))
+
+	// directory over non-empty directory
+	err = mfs.Rename("/dir/src", "/dir/full")
+	assert.True(t, errors.Is(err, syscall.ENOTEMPTY))
+
+	// directory over empty directory
+	assert.Nil(t, mfs.Rename("/dir/src", "/dir/empty"))
+	_, err = mfs.Stat("/dir/src")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	_, err = mfs.Stat("/dir/empty")
+	assert.Nil(t, err)
+}
+
+func Test_RenameExchange(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/srv/blue/config":  []byte("blue"),
+		"/srv/green/config": []byte("green"),
+		"/srv/green/extra":  []byte("extra"),
+		"/srv/current":      []byte("blue"),
+		"/srv/next":         []byte("green"),
+	}, WithSeparator('/'))
+	assert.Nil(t, err)
+
+	// open handles follow their file
+	f, err := mfs.Open("/srv/current")
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.RenameExchange("/srv/current", "/srv/next"))
+	assert.Equal(t, "green", readAll(t, mfs, "/srv/current"))
+	assert.Equal(t, "blue", readAll(t, mfs, "/srv/next"))
+	data, err := io.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, "blue", string(data))
+	assert.Nil(t, f.Close())
+
+	// directories with everything beneath them, and their tags
+	assert.Nil(t, mfs.SetTag("/srv/green/extra", "kind", "extra"))
+	assert.Nil(t, mfs.RenameExchange("/srv/blue", "/srv/green"))
+	assert.Equal(t, "green", readAll(t, mfs, "/srv/blue/config"))
+	assert.Equal(t, "extra", readAll(t, mfs, "/srv/blue/extra"))
+	assert.Equal(t, "blue", readAll(t, mfs, "/srv/green/config"))
+	_, err = mfs.Stat("/srv/green/extra")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	paths, err := mfs.Query("kind")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/srv/blue/extra"}, paths)
+
+	// a file and a directory in different directories
+	assert.Nil(t, mfs.RenameExchange("/srv/green/config", "/srv/blue"))
+	fi, err := mfs.Stat("/srv/green/config")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	assert.Equal(t, "blue", readAll(t, mfs, "/srv/blue"))
+
+	assert.Nil(t, mfs.RenameExchange("/srv/next", "/srv/next"))
+	assert.True(t, errors.Is(mfs.RenameExchange("/srv/next", "/srv/missing"), os.ErrNotExist))
+	assert.True(t, errors.Is(mfs.RenameExchange("/srv", "/srv/green/config/extra"), syscall.EINVAL))
+	assert.True(t, errors.Is(mfs.RenameExchange("/srv/next", "/"), syscall.EBUSY))
+}
+
+func Test_ReadDirAfter(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/big", fs.ModePerm))
+	for _, name := range []string{"e", "b", "d", "a", "c"} {
+		f, err := mfs.Create("/big/" + name)
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+	}
+
+	var names []string
+	after := ""
+	for {
+		page, err := mfs.ReadDirAfter("/big", after, 2)
+		assert.Nil(t, err)
+		if len(page) == 0 {
+			break
+		}
+		assert.LessOrEqual(t, len(page), 2)
+		for _, e := range page {
+			names = append(names, e.Name())
+		}
+		after = page[len(page)-1].Name()
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+
+	all, err := mfs.ReadDirAfter("/big", "bb", 0)
+	assert.Nil(t, err)
+	assert.Len(t, all, 3)
+
+	root, err := mfs.ReadDir("/")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, root)
+
+	_, err = mfs.ReadDirAfter("/big/a", "", 0)
+	assert.True(t, errors.Is(err, syscall.ENOTDIR))
+	_, err = mfs.ReadDirAfter("/missing", "", 0)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func Test_Root_Stat_Open(t *testing.T) {
+	mfs := New()
+	fi, err := mfs.Stat("/")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+
+	f, err := mfs.Open("/")
+	assert.Nil(t, err)
+	names, err := f.Readdirnames(-1)
+	assert.Nil(t, err)
+	assert.Contains(t, names, "tmp")
+	assert.Nil(t, f.Close())
+}
+
+func Test_Open_Flags(t *testing.T) {
+	mfs := New()
+	f, err := mfs.OpenFile("/created", os.O_RDONLY|os.O_CREATE, 0644)
+	assert.Nil(t, err)
+	_, err = f.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, f.Close())
+
+	_, err = mfs.OpenFile("/created", os.O_RDONLY|os.O_CREATE|os.O_EXCL, 0644)
+	assert.True(t, errors.Is(err, os.ErrExist))
+
+	// appending writes always go to the end, even after seeking
+	a, err := mfs.OpenFile("/created", os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0)
+	assert.Nil(t, err)
+	_, err = a.Write([]byte("one"))
+	assert.Nil(t, err)
+	_, err = a.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	other, err := mfs.OpenFile("/created", os.O_WRONLY|os.O_APPEND, 0)
+	assert.Nil(t, err)
+	_, err = other.Write([]byte("two"))
+	assert.Nil(t, err)
+	_, err = a.Write([]byte("three"))
+	assert.Nil(t, err)
+	assert.Nil(t, a.Close())
+	assert.Nil(t, other.Close())
+
+	r, err := mfs.Open("/created")
+	assert.Nil(t, err)
+	data, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "onetwothree", string(data))
+	assert.Nil(t, r.Close())
+}
+
+func Test_Strict_Flags(t *testing.T) {
+	mfs := New(WithStrictFlags())
+	assert.Nil(t, mfs.Mkdir("/dir", 0777))
+	f, err := mfs.Create("/file")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	for _, flag := range []int{
+		os.O_RDONLY | os.O_TRUNC,
+		os.O_RDWR | os.O_EXCL,
+		os.O_WRONLY | os.O_RDWR,
+		os.O_RDONLY | 0x40000000,
+	} {
+		_, err = mfs.OpenFile("/file", flag, 0)
+		assert.True(t, errors.Is(err, syscall.EINVAL), "flag %#x", flag)
+	}
+
+	_, err = mfs.OpenFile("/dir", os.O_WRONLY, 0)
+	assert.True(t, errors.Is(err, syscall.EISDIR))
+	_, err = mfs.OpenFile("/missing", os.O_WRONLY, 0)
+	assert.True(t, errors.Is(err, syscall.ENOENT))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	f, err = mfs.OpenFile("/file", os.O_RDWR|os.O_SYNC|os.O_APPEND, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	d, err := mfs.Open("/dir")
+	assert.Nil(t, err)
+	assert.Nil(t, d.Close())
+
+	// views share the setting
+	_, err = mfs.As(0, 0).OpenFile("/file", os.O_RDONLY|os.O_TRUNC, 0)
+	assert.True(t, errors.Is(err, syscall.EINVAL))
+}
+
+func Test_RemoveAllContext(t *testing.T) {
+	files := map[string][]byte{}
+	for _, p := range []string{"/cache/a/1", "/cache/a/2", "/cache/b/1", "/cache/b/2", "/cache/c"} {
+		files[p] = []byte("0123456789")
+	}
+	mfs, err := NewFromMap(files)
+	assert.Nil(t, err)
+
+	var last RemoveProgress
+	var paths []string
+	err = mfs.RemoveAllContext(context.Background(), "/cache", func(p RemoveProgress) {
+		paths = append(paths, p.Path)
+		last = p
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/cache/a/1", "/cache/a/2", "/cache/a", "/cache/b/1", "/cache/b/2", "/cache/b", "/cache/c", "/cache"}, paths)
+	assert.Equal(t, RemoveProgress{Path: "/cache", Removed: 8, BytesFreed: 50}, last)
+	_, err = mfs.Stat("/cache")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	mfs, err = NewFromMap(files)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	err = mfs.RemoveAllContext(ctx, "/cache", func(p RemoveProgress) {
+		if p.Removed == 3 {
+			cancel()
+		}
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = mfs.Stat("/cache/a")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	entries, err := mfs.ReadDir("/cache")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.True(t, errors.Is(mfs.RemoveAll("/"), syscall.EBUSY))
+}
+
+func Test_Open_Directory_NoFollow(t *testing.T) {
+	for _, mfs := range []*FS{New(), New(WithStrictFlags())} {
+		assert.Nil(t, mfs.Mkdir("/dir", 0777))
+		f, err := mfs.Create("/file")
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+
+		d, err := mfs.OpenFile("/dir", os.O_RDONLY|O_DIRECTORY|O_NOFOLLOW, 0)
+		assert.Nil(t, err)
+		assert.True(t, d.isDir())
+		assert.Nil(t, d.Close())
+
+		_, err = mfs.OpenFile("/file", os.O_RDONLY|O_DIRECTORY, 0)
+		assert.True(t, errors.Is(err, syscall.ENOTDIR))
+		_, err = mfs.OpenFile("/new", os.O_RDWR|os.O_CREATE|O_DIRECTORY, 0777)
+		assert.True(t, errors.Is(err, syscall.EINVAL))
+		_, err = mfs.OpenFile("/missing", os.O_RDONLY|O_DIRECTORY, 0)
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+
+		f, err = mfs.OpenFile("/file", os.O_RDWR|O_NOFOLLOW, 0)
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+	}
+}
+
+func Test_Without_Locking(t *testing.T) {
+	mfs := New(WithoutLocking())
+	assert.True(t, mfs.root.noLock)
+	assert.Nil(t, mfs.MkdirAll("/a/b", 0755))
+	f, err := mfs.Create("/a/b/file")
+	assert.Nil(t, err)
+	assert.True(t, f.node.noLock)
+	_, err = f.Write([]byte("content"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Nil(t, mfs.Rename("/a/b/file", "/a/file"))
+	entries, err := mfs.ReadDir("/a")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	var visited []string
+	err = mfs.WalkParallel("/a", 8, func(path string, d fs.DirEntry, err error) error {
+		// a single worker calls fn, so appending needs no lock
+		visited = append(visited, path)
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/a", "/a/b", "/a/file"}, visited)
+	assert.Nil(t, mfs.RemoveAll("/a"))
+
+	assert.False(t, New().root.noLock)
+}
+
+func Benchmark_Without_Locking(b *testing.B) {
+	for _, opts := range [][]Option{nil, {WithoutLocking()}} {
+		name := "locking"
+		if opts != nil {
+			name = "without_locking"
+		}
+		b.Run(name, func(b *testing.B) {
+			mfs := New(opts...)
+			_ = mfs.MkdirAll("/bench/dir", 0755)
+			p := make([]byte, 64)
+			for i := 0; i < b.N; i++ {
+				f, _ := mfs.Create("/bench/dir/file")
+				_, _ = f.Write(p)
+				_, _ = f.Stat()
+				_ = f.Close()
+				_, _ = mfs.Stat("/bench/dir/file")
+			}
+		})
+	}
+}
+
+func Test_TempDir(t *testing.T) {
+	mfs := New(WithTempDir("/var/tmp"))
+	assert.Equal(t, "/var/tmp", mfs.TempDir())
+	fi, err := mfs.Stat("/var/tmp")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	f, err := mfs.CreateTemp("", "file*")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(f.Name(), "/var/tmp/file"))
+	assert.Nil(t, f.Close())
+	entries, err := mfs.ReadDir("/var/tmp")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	// sessions have temporary directories of their own
+	assert.Nil(t, mfs.MkdirAll("/sessions/a", 0777))
+	session := mfs.InTempDir("/sessions/a")
+	assert.Equal(t, "/sessions/a", session.TempDir())
+	assert.Equal(t, "/var/tmp", mfs.TempDir())
+	dir, err := session.MkdirTemp("", "build")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(dir, "/sessions/a/build"))
+	assert.Equal(t, "/sessions/a", session.As(1000, 1000).TempDir())
+
+	_, err = mfs.InTempDir("/sessions/missing").CreateTemp("", "file")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Equal(t, `\var\tmp`, New(WithTempDir("/var/tmp"), WithSeparator('\\')).TempDir())
+}
+
+type constSource int64
+
+func (s constSource) Int63() int64 { return int64(s) }
+func (constSource) Seed(int64)     {}
+
+func Test_CreateTemp_Pattern(t *testing.T) {
+	mfs := New()
+	f, err := mfs.CreateTemp("", "a*b*.txt")
+	assert.Nil(t, err)
+	assert.Regexp(t, `^/tmp/a\*b[0-9]+\.txt$`, f.Name())
+	fi, err := f.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+	// opened for reading and writing
+	_, err = f.Write([]byte("data"))
+	assert.Nil(t, err)
+	data := make([]byte, 4)
+	_, err = f.ReadAt(data, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	dir, err := mfs.MkdirTemp("", "build")
+	assert.Nil(t, err)
+	assert.Regexp(t, `^/tmp/build[0-9]+$`, dir)
+	fi, err = mfs.Stat(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, os.ModeDir|0700, fi.Mode())
+
+	_, err = mfs.CreateTemp("", "sub/file*")
+	assert.True(t, errors.Is(err, ErrPatternHasSeparator))
+	_, err = mfs.MkdirTemp("", "sub/dir*")
+	assert.True(t, errors.Is(err, ErrPatternHasSeparator))
+
+	// the names tried are bounded
+	constant := New(WithRandSource(constSource(42)))
+	f, err = constant.CreateTemp("", "file")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	_, err = constant.CreateTemp("", "file")
+	assert.True(t, errors.Is(err, fs.ErrExist))
+	_, err = constant.MkdirTemp("", "file")
+	assert.True(t, errors.Is(err, fs.ErrExist))
+}
+
+func Test_Chtimes(t *testing.T) {
+	mfs := New()
+	f, err := mfs.Create("/cache")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	expired := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, mfs.Chtimes("/cache", time.Time{}, expired))
+	fi, err := mfs.Stat("/cache")
+	assert.Nil(t, err)
+	assert.True(t, fi.ModTime().Equal(expired))
+
+	// a zero time leaves it unchanged
+	assert.Nil(t, mfs.Chtimes("/cache", expired, time.Time{}))
+	fi, err = mfs.Stat("/cache")
+	assert.Nil(t, err)
+	assert.True(t, fi.ModTime().Equal(expired))
+
+	assert.True(t, errors.Is(mfs.As(1000, 1000).Chtimes("/cache", time.Time{}, time.Now()), os.ErrPermission))
+	assert.True(t, errors.Is(mfs.Chtimes("/missing", time.Time{}, time.Now()), os.ErrNotExist))
+}
+
+func Test_FS_Truncate(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/file": []byte("0123456789")})
+	assert.Nil(t, err)
+
+	assert.Nil(t, mfs.Truncate("/file", 4))
+	assert.Equal(t, "0123", readAll(t, mfs, "/file"))
+	assert.Nil(t, mfs.Truncate("/file", 6))
+	assert.Equal(t, "0123\x00\x00", readAll(t, mfs, "/file"))
+
+	assert.True(t, errors.Is(mfs.Truncate("/file", -1), os.ErrInvalid))
+	assert.True(t, errors.Is(mfs.Truncate("/", 0), syscall.EISDIR))
+	assert.True(t, errors.Is(mfs.Truncate("/missing", 0), os.ErrNotExist))
+	assert.Nil(t, mfs.Chmod("/file", 0444))
+	assert.True(t, errors.Is(mfs.As(1000, 1000).Truncate("/file", 0), os.ErrPermission))
+}
+
+func Test_ReadFile_WriteFile(t *testing.T) {
+	mfs := New(WithDevices())
+	assert.Nil(t, mfs.WriteFile("/file", []byte("first version"), 0600))
+	assert.Nil(t, mfs.WriteFile("/file", []byte("second"), 0644))
+	data, err := mfs.ReadFile("/file")
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(data))
+	// the mode of an existing file is kept
+	fi, err := mfs.Stat("/file")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+
+	data, err = mfs.ReadFile("/dev/null")
+	assert.Nil(t, err)
+	assert.Empty(t, data)
+
+	_, err = mfs.ReadFile("/")
+	assert.True(t, errors.Is(err, syscall.EISDIR))
+	_, err = mfs.ReadFile("/missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.True(t, errors.Is(mfs.WriteFile("/missing/file", nil, 0644), os.ErrNotExist))
+}
+
+func Test_AppendFile(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.AppendFile("/log", []byte("one\n"), 0600))
+	assert.Nil(t, mfs.AppendFile("/log", []byte("two\n"), 0644))
+	data, err := mfs.ReadFile("/log")
+	assert.Nil(t, err)
+	assert.Equal(t, "one\ntwo\n", string(data))
+	fi, err := mfs.Stat("/log")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+	assert.True(t, errors.Is(mfs.AppendFile("/", nil, 0644), syscall.EISDIR))
+}
+
+func Test_SameFile(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.WriteFile("/a", []byte("a"), 0644))
+	assert.Nil(t, mfs.WriteFile("/b", []byte("a"), 0644))
+
+	a, err := mfs.Stat("/a")
+	assert.Nil(t, err)
+	b, err := mfs.Stat("/b")
+	assert.Nil(t, err)
+	assert.False(t, SameFile(a, b))
+
+	f, err := mfs.Open("/a")
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.Rename("/a", "/renamed"))
+	renamed, err := mfs.Stat("/renamed")
+	assert.Nil(t, err)
+	handle, err := f.Stat()
+	assert.Nil(t, err)
+	assert.True(t, SameFile(a, renamed))
+	assert.True(t, SameFile(handle, renamed))
+	assert.Nil(t, f.Close())
+
+	viaIOFS, err := fs.Stat(mfs.IOFS(), "renamed")
+	assert.Nil(t, err)
+	assert.True(t, SameFile(viaIOFS, a))
+
+	osInfo, err := os.Stat(os.TempDir())
+	assert.Nil(t, err)
+	assert.False(t, SameFile(osInfo, osInfo))
+	assert.False(t, SameFile(nil, nil))
+}
+
+func Test_Dir_ModTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mfs := New(WithClock(NewStepClock(start, time.Second)))
+	assert.Nil(t, mfs.MkdirAll("/a/b", 0755))
+	assert.Nil(t, mfs.Mkdir("/c", 0755))
+
+	modTime := func(path string) time.Time {
+		fi, err := mfs.Stat(path)
+		assert.Nil(t, err)
+		return fi.ModTime()
+	}
+	changed := func(path string, change func()) {
+		before := modTime(path)
+		change()
+		assert.True(t, modTime(path).After(before), path)
+	}
+
+	changed("/a", func() { assert.Nil(t, mfs.Mkdir("/a/dir", 0755)) })
+	changed("/a/b", func() { assert.Nil(t, mfs.WriteFile("/a/b/file", []byte("x"), 0644)) })
+	changed("/a/b", func() { assert.Nil(t, mfs.Rename("/a/b/file", "/a/b/renamed")) })
+	changed("/a/b", func() { assert.Nil(t, mfs.Rename("/a/b/renamed", "/c/file")) })
+	changed("/c", func() { assert.Nil(t, mfs.Rename("/c/file", "/a/b/file")) })
+	changed("/a/b", func() { assert.Nil(t, mfs.Remove("/a/b/file")) })
+	changed("/a", func() { assert.Nil(t, mfs.RemoveAll("/a/dir")) })
+	changed("/", func() { assert.Nil(t, mfs.MkdirAll("/d/e", 0755)) })
+
+	// writing a file leaves its directory unchanged
+	assert.Nil(t, mfs.WriteFile("/c/file", []byte("x"), 0644))
+	before := modTime("/c")
+	assert.Nil(t, mfs.WriteFile("/c/file", []byte("y"), 0644))
+	assert.Equal(t, before, modTime("/c"))
+}
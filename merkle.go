@@ -0,0 +1,133 @@
+package memfs
+
+import (
+	"crypto/sha256"
+	"path"
+	"sort"
+)
+
+// treeHasher computes the Merkle hashes of nodes, keeping those of the subtrees hashed.
+type treeHasher struct {
+	fs     *FS
+	hashes map[*fsNode][sha256.Size]byte
+}
+
+// hash returns the hash of the node: for a file, the hash of "f" followed by the hash of
+// its content, for a directory, the hash of "d" followed by the name and the hash of each
+// of its entries, in name order, so it changes with the content or the name of any entry
+// beneath it, but not with modes, owners or times.
+func (h *treeHasher) hash(p string, n *fsNode) ([sha256.Size]byte, error) {
+	n = n.target()
+	if sum, exists := h.hashes[n]; exists {
+		return sum, nil
+	}
+	if err := h.fs.checkAccess(p, n, accessRead); err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	d := sha256.New()
+	if n.isDir() {
+		n.lock()
+		children := n.entries.list()
+		n.unlock()
+		d.Write([]byte{'d'})
+		for _, c := range children {
+			sum, err := h.hash(h.fs.join(p, c.name), c)
+			if err != nil {
+				return [sha256.Size]byte{}, err
+			}
+			d.Write([]byte(c.name))
+			d.Write([]byte{0})
+			d.Write(sum[:])
+		}
+	} else {
+		sum := n.contentHash()
+		d.Write([]byte{'f'})
+		d.Write(sum[:])
+	}
+	var sum [sha256.Size]byte
+	d.Sum(sum[:0])
+	h.hashes[n] = sum
+	return sum, nil
+}
+
+// TreeHash returns the Merkle hash of the entry at path, computed from the content of the
+// files and the names of the entries beneath it, so that two trees holding the same files
+// have the same hash whatever their modes, owners and times.
+func (f *FS) TreeHash(path string) ([sha256.Size]byte, error) {
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	h := &treeHasher{fs: f, hashes: make(map[*fsNode][sha256.Size]byte)}
+	return h.hash(path, n)
+}
+
+// DiffTree compares the tree at path with the tree at otherPath of other, which can be f,
+// and returns the paths, relative to both and with "/" separators, of the entries which
+// differ, in path order. Entries present on one side only are listed without the entries
+// beneath them, and so are entries which are a file on one side and a directory on the
+// other. The comparison only descends into the directories whose hashes differ.
+func (f *FS) DiffTree(path string, other *FS, otherPath string) ([]string, error) {
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return nil, err
+	}
+	o, err := other.nodeAt(otherPath)
+	if err != nil {
+		return nil, err
+	}
+	h := &treeHasher{fs: f, hashes: make(map[*fsNode][sha256.Size]byte)}
+	oh := &treeHasher{fs: other, hashes: make(map[*fsNode][sha256.Size]byte)}
+	var diff []string
+	if err = diffTree(h, oh, path, otherPath, ".", n, o, &diff); err != nil {
+		return nil, err
+	}
+	sort.Strings(diff)
+	return diff, nil
+}
+
+// diffTree appends to diff the relative paths of the entries differing beneath n and o,
+// at p and op in their filesystems.
+func diffTree(h, oh *treeHasher, p, op, rel string, n, o *fsNode, diff *[]string) error {
+	sum, err := h.hash(p, n)
+	if err != nil {
+		return err
+	}
+	otherSum, err := oh.hash(op, o)
+	if err != nil {
+		return err
+	}
+	if sum == otherSum {
+		return nil
+	}
+	if !n.isDir() || !o.isDir() {
+		*diff = append(*diff, rel)
+		return nil
+	}
+
+	n.lock()
+	children := n.entries.list()
+	n.unlock()
+	o.lock()
+	otherChildren := o.entries.list()
+	o.unlock()
+	others := make(map[string]*fsNode, len(otherChildren))
+	for _, c := range otherChildren {
+		others[c.name] = c
+	}
+	for _, c := range children {
+		oc, exists := others[c.name]
+		delete(others, c.name)
+		if !exists {
+			*diff = append(*diff, path.Join(rel, c.name))
+			continue
+		}
+		if err = diffTree(h, oh, h.fs.join(p, c.name), oh.fs.join(op, c.name), path.Join(rel, c.name), c, oc, diff); err != nil {
+			return err
+		}
+	}
+	for name := range others {
+		*diff = append(*diff, path.Join(rel, name))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func Test_TreeHash(t *testing.T) {
+	files := map[string][]byte{
+		"/a/one":        []byte("1"),
+		"/a/sub/two":    []byte("2"),
+		"/a/sub/three":  []byte("3"),
+		"/a/other/four": []byte("4"),
+	}
+	mfs, err := NewFromMap(files)
+	assert.Nil(t, err)
+	copied, err := NewFromMap(files)
+	assert.Nil(t, err)
+
+	sum, err := mfs.TreeHash("/a")
+	assert.Nil(t, err)
+	copiedSum, err := copied.TreeHash("/a")
+	assert.Nil(t, err)
+	assert.Equal(t, sum, copiedSum)
+
+	diff, err := mfs.DiffTree("/a", copied, "/a")
+	assert.Nil(t, err)
+	assert.Empty(t, diff)
+
+	f, err := copied.OpenFile("/a/sub/two", os.O_WRONLY|os.O_TRUNC, 0)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("changed"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Nil(t, copied.Remove("/a/one"))
+	assert.Nil(t, copied.Mkdir("/a/new", 0755))
+	assert.Nil(t, copied.Remove("/a/other/four"))
+	assert.Nil(t, copied.Remove("/a/other"))
+	f, err = copied.Create("/a/other")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	copiedSum, err = copied.TreeHash("/a")
+	assert.Nil(t, err)
+	assert.NotEqual(t, sum, copiedSum)
+	subSum, err := mfs.TreeHash("/a/sub/three")
+	assert.Nil(t, err)
+	copiedSubSum, err := copied.TreeHash("/a/sub/three")
+	assert.Nil(t, err)
+	assert.Equal(t, subSum, copiedSubSum)
+
+	diff, err = mfs.DiffTree("/a", copied, "/a")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"new", "one", "other", "sub/two"}, diff)
+
+	diff, err = mfs.DiffTree("/a/sub/two", mfs, "/a/sub/three")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"."}, diff)
+
+	_, err = mfs.TreeHash("/missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
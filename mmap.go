@@ -0,0 +1,92 @@
+package memfs
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// Map returns the content of the file for code written around memory mapped files. The
+// mapping of a file opened for writing aliases the content: changes made through the
+// mapping are seen by reads of the file, and writes to the file within the mapped length
+// are seen through the mapping, like a shared mapping of an OS file. The mapping of a file
+// opened read-only is a private copy, the mapping must not be modified then.
+//
+// The mapping keeps the length of the file at the time of the call. Truncating the file
+// keeps its content in place: the mapped bytes past the new end are no longer part of the
+// file, and are zeroed when the file grows back over them. Growing the file past the
+// capacity of its content moves the content, the mapping is then only written back into
+// the file by Flush. A file can only be mapped once at a time, it is unmapped by Unmap or
+// Close.
+func (f *File) Map() ([]byte, error) {
+	if f.node.unlinked {
+		return nil, fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+	if f.isDir() {
+		return nil, fmt.Errorf("is a directory: %s: %w", f.Name(), syscall.EISDIR)
+	}
+	if !f.flag.canRead() {
+		return nil, fmt.Errorf("cannot read: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+	if f.closed {
+		return nil, fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
+	}
+	if f.mapping != nil {
+		return nil, fmt.Errorf("already mapped: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+	if f.staged != nil {
+		return nil, fmt.Errorf("cannot map staged writes: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+
+	f.node.lockContent()
+	defer f.node.unlockContent()
+	if !f.flag.canWrite() {
+		content := f.node.getContent()
+		f.mapping = make([]byte, len(content))
+		copy(f.mapping, content)
+		return f.mapping, nil
+	}
+	content := f.node.getMutableContent()
+	if content == nil {
+		content = []byte{}
+		f.node.content = content
+	}
+	f.node.mapped++
+	f.mapping, f.mapShared = content[:len(content):len(content)], true
+	return f.mapping, nil
+}
+
+// Flush writes the mapping back into the file when the content of the file moved since
+// it was mapped, as it does when the file grows past the capacity of its content. The
+// mapped bytes past the end of the file are dropped.
+func (f *File) Flush() error {
+	if f.mapping == nil {
+		return fmt.Errorf("not mapped: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+	if !f.mapShared || len(f.mapping) == 0 {
+		return nil
+	}
+	f.node.lockContent()
+	defer f.node.unlockContent()
+	content := f.node.getContent()
+	if len(content) > 0 && &content[0] == &f.mapping[0] {
+		return nil
+	}
+	content = f.node.getMutableContent()
+	copy(content, f.mapping)
+	return nil
+}
+
+// Unmap flushes the mapping and releases it, it must not be used afterwards.
+func (f *File) Unmap() error {
+	if err := f.Flush(); err != nil {
+		return err
+	}
+	if f.mapShared {
+		f.node.lockContent()
+		f.node.mapped--
+		f.node.unlockContent()
+	}
+	f.mapping, f.mapShared = nil, false
+	return nil
+}
@@ -0,0 +1,113 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func Test_Map(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/file": []byte("hello world")})
+	assert.Nil(t, err)
+	f, err := mfs.OpenFile("/file", os.O_RDWR, 0)
+	assert.Nil(t, err)
+
+	m, err := f.Map()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", string(m))
+	_, err = f.Map()
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	// changes are seen both ways
+	copy(m, "HELLO")
+	assert.Equal(t, "HELLO world", readAll(t, mfs, "/file"))
+	_, err = f.WriteAt([]byte("W"), 6)
+	assert.Nil(t, err)
+	assert.Equal(t, "HELLO World", string(m))
+
+	// content taken by a copy is not changed through the mapping
+	assert.Nil(t, mfs.Copy("/file", "/copy"))
+	m[0] = 'J'
+	assert.Equal(t, "HELLO World", readAll(t, mfs, "/copy"))
+	assert.Equal(t, "JELLO World", readAll(t, mfs, "/file"))
+
+	// truncating keeps the content in place, growing back zeroes the mapped bytes
+	assert.Nil(t, f.Truncate(5))
+	assert.Equal(t, "JELLO", readAll(t, mfs, "/file"))
+	assert.Nil(t, f.Truncate(8))
+	assert.Equal(t, "JELLO\x00\x00\x00rld", string(m))
+
+	// once the content moved, the mapping is written back by Flush
+	_, err = f.WriteAt(make([]byte, 1<<16), 8)
+	assert.Nil(t, err)
+	copy(m, "mapped")
+	content := readAll(t, mfs, "/file")
+	assert.Equal(t, "JELLO", content[:5])
+	assert.Nil(t, f.Flush())
+	content = readAll(t, mfs, "/file")
+	assert.Equal(t, "mapped\x00\x00", content[:8])
+	assert.Equal(t, 8+1<<16, len(content))
+
+	assert.Nil(t, f.Unmap())
+	assert.Equal(t, uint16(0), f.node.mapped)
+	assert.True(t, errors.Is(f.Unmap(), fs.ErrInvalid))
+	assert.True(t, errors.Is(f.Flush(), fs.ErrInvalid))
+
+	m, err = f.Map()
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(1), f.node.mapped)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, uint16(0), f.node.mapped)
+
+	// read-only files are mapped privately
+	r, err := mfs.Open("/copy")
+	assert.Nil(t, err)
+	m, err = r.Map()
+	assert.Nil(t, err)
+	m[0] = 'x'
+	assert.Equal(t, "HELLO World", readAll(t, mfs, "/copy"))
+	assert.Nil(t, r.Close())
+
+	w, err := mfs.OpenFile("/copy", os.O_WRONLY, 0)
+	assert.Nil(t, err)
+	_, err = w.Map()
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+	assert.True(t, errors.Is(w.Truncate(-1), fs.ErrInvalid))
+	assert.Nil(t, w.Close())
+}
+
+func Test_Truncate(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/file": []byte("0123456789")})
+	assert.Nil(t, err)
+	f, err := mfs.OpenFile("/file", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	_, err = f.Seek(4, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Truncate(2))
+	assert.Equal(t, "01", readAll(t, mfs, "/file"))
+	assert.Nil(t, f.Truncate(4))
+	assert.Equal(t, "01\x00\x00", readAll(t, mfs, "/file"))
+	_, err = f.Write([]byte("x"))
+	assert.Nil(t, err)
+	assert.Equal(t, "01\x00\x00x", readAll(t, mfs, "/file"))
+	assert.Nil(t, f.Close())
+	assert.True(t, errors.Is(f.Truncate(0), fs.ErrClosed))
+
+	// appending writes at the new end
+	a, err := mfs.OpenFile("/file", os.O_WRONLY|os.O_APPEND, 0)
+	assert.Nil(t, err)
+	_, err = a.Write([]byte("yz"))
+	assert.Nil(t, err)
+	assert.Nil(t, a.Truncate(3))
+	_, err = a.Write([]byte("!"))
+	assert.Nil(t, err)
+	assert.Equal(t, "01\x00!", readAll(t, mfs, "/file"))
+	assert.Nil(t, a.Close())
+
+	r, err := mfs.Open("/file")
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(r.Truncate(0), fs.ErrInvalid))
+	assert.Nil(t, r.Close())
+}
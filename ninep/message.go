@@ -0,0 +1,175 @@
+package ninep
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// message types of 9P2000
+const (
+	Tversion = 100
+	Rversion = 101
+	Tauth    = 102
+	Rauth    = 103
+	Tattach  = 104
+	Rattach  = 105
+	Rerror   = 107
+	Tflush   = 108
+	Rflush   = 109
+	Twalk    = 110
+	Rwalk    = 111
+	Topen    = 112
+	Ropen    = 113
+	Tcreate  = 114
+	Rcreate  = 115
+	Tread    = 116
+	Rread    = 117
+	Twrite   = 118
+	Rwrite   = 119
+	Tclunk   = 120
+	Rclunk   = 121
+	Tremove  = 122
+	Rremove  = 123
+	Tstat    = 124
+	Rstat    = 125
+	Twstat   = 126
+	Rwstat   = 127
+)
+
+// open modes, qid types and mode bits
+const (
+	OREAD   = 0
+	OWRITE  = 1
+	ORDWR   = 2
+	OEXEC   = 3
+	OTRUNC  = 0x10
+	ORCLOSE = 0x40
+
+	QTDIR  = 0x80
+	QTFILE = 0x00
+
+	DMDIR = 0x80000000
+)
+
+const (
+	// NOTAG is the tag of Tversion messages.
+	NOTAG = 0xffff
+	// NOFID is the afid of an attach without authentication.
+	NOFID = 0xffffffff
+
+	headerSize = 7 // size[4] type[1] tag[2]
+	qidSize    = 13
+)
+
+var errShortMessage = errors.New("short 9P message")
+
+// Qid is the server's unique identification of a file.
+type Qid struct {
+	Type    uint8
+	Version uint32
+	Path    uint64
+}
+
+// Stat is the machine independent directory entry of a file.
+type Stat struct {
+	Type   uint16
+	Dev    uint32
+	Qid    Qid
+	Mode   uint32
+	Atime  uint32
+	Mtime  uint32
+	Length uint64
+	Name   string
+	UID    string
+	GID    string
+	MUID   string
+}
+
+// decoder reads the fields of a message, a short message sets err and yields zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.b) < n {
+		d.err = errShortMessage
+		return make([]byte, n)
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) u8() uint8   { return d.next(1)[0] }
+func (d *decoder) u16() uint16 { return binary.LittleEndian.Uint16(d.next(2)) }
+func (d *decoder) u32() uint32 { return binary.LittleEndian.Uint32(d.next(4)) }
+func (d *decoder) u64() uint64 { return binary.LittleEndian.Uint64(d.next(8)) }
+
+func (d *decoder) str() string {
+	return string(d.next(int(d.u16())))
+}
+
+func (d *decoder) qid() Qid {
+	return Qid{Type: d.u8(), Version: d.u32(), Path: d.u64()}
+}
+
+func (d *decoder) stat() Stat {
+	sub := decoder{b: d.next(int(d.u16()))}
+	st := Stat{
+		Type:   sub.u16(),
+		Dev:    sub.u32(),
+		Qid:    sub.qid(),
+		Mode:   sub.u32(),
+		Atime:  sub.u32(),
+		Mtime:  sub.u32(),
+		Length: sub.u64(),
+		Name:   sub.str(),
+		UID:    sub.str(),
+		GID:    sub.str(),
+		MUID:   sub.str(),
+	}
+	if sub.err != nil {
+		d.err = sub.err
+	}
+	return st
+}
+
+func putU8(b []byte, v uint8) []byte   { return append(b, v) }
+func putU16(b []byte, v uint16) []byte { return binary.LittleEndian.AppendUint16(b, v) }
+func putU32(b []byte, v uint32) []byte { return binary.LittleEndian.AppendUint32(b, v) }
+func putU64(b []byte, v uint64) []byte { return binary.LittleEndian.AppendUint64(b, v) }
+
+func putStr(b []byte, s string) []byte {
+	return append(putU16(b, uint16(len(s))), s...)
+}
+
+func putQid(b []byte, q Qid) []byte {
+	return putU64(putU32(putU8(b, q.Type), q.Version), q.Path)
+}
+
+func putStat(b []byte, st Stat) []byte {
+	size := 2 + 4 + qidSize + 4 + 4 + 4 + 8 + 2 + len(st.Name) + 2 + len(st.UID) + 2 + len(st.GID) + 2 + len(st.MUID)
+	b = putU16(b, uint16(size))
+	b = putU16(b, st.Type)
+	b = putU32(b, st.Dev)
+	b = putQid(b, st.Qid)
+	b = putU32(b, st.Mode)
+	b = putU32(b, st.Atime)
+	b = putU32(b, st.Mtime)
+	b = putU64(b, st.Length)
+	b = putStr(b, st.Name)
+	b = putStr(b, st.UID)
+	b = putStr(b, st.GID)
+	return putStr(b, st.MUID)
+}
+
+// newMessage starts a message, its size is set by finishMessage.
+func newMessage(typ uint8, tag uint16) []byte {
+	return putU16(putU8(make([]byte, 4, 64), typ), tag)
+}
+
+func finishMessage(b []byte) []byte {
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
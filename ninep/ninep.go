@@ -0,0 +1,604 @@
+// Package ninep serves a memfs.FS with the 9P2000 protocol, so the tree can be mounted
+// by QEMU guests, WSL, the Linux v9fs client or plan9port tools during integration tests:
+//
+//	l, _ := net.Listen("tcp", "127.0.0.1:5640")
+//	go ninep.NewServer(mfs, "/").Serve(l)
+//
+// Only the base 9P2000 dialect is spoken, clients asking for 9P2000.u or 9P2000.L are
+// answered with 9P2000. There is no authentication.
+package ninep
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/mbordner/memfs"
+)
+
+const (
+	version = "9P2000"
+	maxSize = 1 << 20 // largest msize accepted from clients
+
+	maxWalkNames = 16
+)
+
+// Server serves a directory of a memfs.FS to any number of connections.
+type Server struct {
+	fs   *memfs.FS
+	root string
+
+	mutex   sync.Mutex
+	qids    map[string]uint64 // qid paths handed out, by file path
+	nextQid uint64
+}
+
+// NewServer returns a server exposing dir of fsys as the root of the file tree.
+func NewServer(fsys *memfs.FS, dir string) *Server {
+	return &Server{
+		fs:   fsys,
+		root: path.Clean("/" + dir),
+		qids: map[string]uint64{"/": 0},
+	}
+}
+
+// Serve accepts connections on l and serves each of them until l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			_ = s.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn serves requests read from rw until it is closed. Requests of a connection
+// are handled one at a time.
+func (s *Server) ServeConn(rw io.ReadWriter) error {
+	c := &conn{s: s, rw: rw, msize: 8192, fids: make(map[uint32]*fid)}
+	defer c.clunkAll()
+	for {
+		typ, tag, body, err := c.readMessage()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		reply := c.handle(typ, tag, body)
+		if _, err = rw.Write(finishMessage(reply)); err != nil {
+			return err
+		}
+	}
+}
+
+// fsPath returns the memfs path of a path of the served tree.
+func (s *Server) fsPath(p string) string {
+	return path.Join(s.root, p)
+}
+
+func (s *Server) qid(p string, fi os.FileInfo) Qid {
+	s.mutex.Lock()
+	id, exists := s.qids[p]
+	if !exists {
+		s.nextQid++
+		id = s.nextQid
+		s.qids[p] = id
+	}
+	s.mutex.Unlock()
+	q := Qid{Type: QTFILE, Version: uint32(fi.ModTime().UnixNano()), Path: id}
+	if fi.IsDir() {
+		q.Type = QTDIR
+	}
+	return q
+}
+
+// renamed keeps the qid of a file that moved, and forgets the one of the file it replaced.
+func (s *Server) renamed(from, to string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if id, exists := s.qids[from]; exists {
+		delete(s.qids, from)
+		s.qids[to] = id
+	} else {
+		delete(s.qids, to)
+	}
+}
+
+func (s *Server) stat(p string, fi os.FileInfo) Stat {
+	st := Stat{
+		Qid:    s.qid(p, fi),
+		Mode:   uint32(fi.Mode().Perm()),
+		Atime:  uint32(fi.ModTime().Unix()),
+		Mtime:  uint32(fi.ModTime().Unix()),
+		Length: uint64(fi.Size()),
+		Name:   path.Base(p),
+		UID:    "memfs",
+		GID:    "memfs",
+		MUID:   "memfs",
+	}
+	if fi.IsDir() {
+		st.Mode |= DMDIR
+		st.Length = 0
+	}
+	return st
+}
+
+type fid struct {
+	path    string // path in the served tree
+	file    *memfs.File
+	mode    uint8
+	dir     bool
+	entries [][]byte // encoded stats of a directory being read
+	offset  uint64   // offset the next directory read continues from
+}
+
+type conn struct {
+	s     *Server
+	rw    io.ReadWriter
+	msize uint32
+	fids  map[uint32]*fid
+}
+
+func (c *conn) readMessage() (uint8, uint16, []byte, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(c.rw, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[:4])
+	if size < headerSize || size > maxSize {
+		return 0, 0, nil, fmt.Errorf("invalid message size %d: %w", size, os.ErrInvalid)
+	}
+	body := make([]byte, size-headerSize)
+	if _, err := io.ReadFull(c.rw, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return header[4], binary.LittleEndian.Uint16(header[5:]), body, nil
+}
+
+func (c *conn) clunkAll() {
+	for _, f := range c.fids {
+		if f.file != nil {
+			_ = f.file.Close()
+		}
+	}
+	c.fids = make(map[uint32]*fid)
+}
+
+func errorMessage(tag uint16, err error) []byte {
+	return putStr(newMessage(Rerror, tag), err.Error())
+}
+
+func (c *conn) handle(typ uint8, tag uint16, body []byte) []byte {
+	d := &decoder{b: body}
+	var reply []byte
+	var err error
+	switch typ {
+	case Tversion:
+		reply = c.version(tag, d)
+	case Tauth:
+		err = errors.New("authentication not required")
+	case Tattach:
+		reply, err = c.attach(tag, d)
+	case Tflush:
+		d.u16()
+		reply = newMessage(Rflush, tag)
+	case Twalk:
+		reply, err = c.walk(tag, d)
+	case Topen:
+		reply, err = c.open(tag, d)
+	case Tcreate:
+		reply, err = c.create(tag, d)
+	case Tread:
+		reply, err = c.read(tag, d)
+	case Twrite:
+		reply, err = c.write(tag, d)
+	case Tclunk:
+		reply, err = c.clunk(tag, d)
+	case Tremove:
+		reply, err = c.remove(tag, d)
+	case Tstat:
+		reply, err = c.statFid(tag, d)
+	case Twstat:
+		reply, err = c.wstat(tag, d)
+	default:
+		err = fmt.Errorf("unknown message type %d", typ)
+	}
+	if err == nil && d.err != nil {
+		err = d.err
+	}
+	if err != nil {
+		return errorMessage(tag, err)
+	}
+	return reply
+}
+
+func (c *conn) version(tag uint16, d *decoder) []byte {
+	msize, v := d.u32(), d.str()
+	c.clunkAll()
+	c.msize = min(msize, maxSize)
+	if !strings.HasPrefix(v, version) {
+		v = "unknown"
+	} else {
+		v = version
+	}
+	return putStr(putU32(newMessage(Rversion, tag), c.msize), v)
+}
+
+func (c *conn) getFid(d *decoder) (*fid, error) {
+	id := d.u32()
+	if f, exists := c.fids[id]; exists {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown fid %d", id)
+}
+
+func (c *conn) newFid(id uint32) error {
+	if _, exists := c.fids[id]; exists {
+		return fmt.Errorf("fid %d in use", id)
+	}
+	return nil
+}
+
+func (c *conn) attach(tag uint16, d *decoder) ([]byte, error) {
+	id, afid, _, _ := d.u32(), d.u32(), d.str(), d.str()
+	if afid != NOFID {
+		return nil, errors.New("authentication not required")
+	}
+	if err := c.newFid(id); err != nil {
+		return nil, err
+	}
+	fi, err := c.s.fs.Stat(c.s.root)
+	if err != nil {
+		return nil, err
+	}
+	c.fids[id] = &fid{path: "/"}
+	return putQid(newMessage(Rattach, tag), c.s.qid("/", fi)), nil
+}
+
+func (c *conn) walk(tag uint16, d *decoder) ([]byte, error) {
+	f, err := c.getFid(d)
+	if err != nil {
+		return nil, err
+	}
+	newID, n := d.u32(), int(d.u16())
+	if n > maxWalkNames {
+		return nil, fmt.Errorf("too many names to walk: %d: %w", n, os.ErrInvalid)
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = d.str()
+	}
+	if f.file != nil {
+		return nil, errors.New("fid is open")
+	}
+	if f != c.fids[newID] {
+		if err = c.newFid(newID); err != nil {
+			return nil, err
+		}
+	}
+
+	p := f.path
+	qids := make([]Qid, 0, n)
+	for _, name := range names {
+		next := path.Join(p, name)
+		if name == ".." {
+			next = path.Dir(p)
+		} else if strings.Contains(name, "/") || name == "." {
+			err = fmt.Errorf("invalid name: %s: %w", name, os.ErrInvalid)
+			break
+		}
+		fi, statErr := c.s.fs.Stat(c.s.fsPath(next))
+		if statErr != nil {
+			err = statErr
+			break
+		}
+		p = next
+		qids = append(qids, c.s.qid(p, fi))
+	}
+	if len(qids) == 0 && n > 0 {
+		return nil, err
+	}
+	if len(qids) == n {
+		c.fids[newID] = &fid{path: p}
+	}
+
+	reply := putU16(newMessage(Rwalk, tag), uint16(len(qids)))
+	for _, q := range qids {
+		reply = putQid(reply, q)
+	}
+	return reply, nil
+}
+
+func openFlag(mode uint8) int {
+	var flag int
+	switch mode & 3 {
+	case OWRITE:
+		flag = os.O_WRONLY
+	case ORDWR:
+		flag = os.O_RDWR
+	default:
+		flag = os.O_RDONLY
+	}
+	if mode&OTRUNC != 0 {
+		flag |= os.O_TRUNC
+	}
+	return flag
+}
+
+func (c *conn) iounit() uint32 {
+	return c.msize - 24 // size[4] Rread tag[2] count[4] with room to spare
+}
+
+func (c *conn) open(tag uint16, d *decoder) ([]byte, error) {
+	f, err := c.getFid(d)
+	if err != nil {
+		return nil, err
+	}
+	mode := d.u8()
+	if f.file != nil {
+		return nil, errors.New("fid is already open")
+	}
+	p := c.s.fsPath(f.path)
+	fi, err := c.s.fs.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() && mode&3 != OREAD {
+		return nil, fmt.Errorf("is a directory: %s: %w", f.path, syscall.EISDIR)
+	}
+	file, err := c.s.fs.OpenFile(p, openFlag(mode), 0)
+	if err != nil {
+		return nil, err
+	}
+	f.file, f.mode, f.dir = file, mode, fi.IsDir()
+	return putU32(putQid(newMessage(Ropen, tag), c.s.qid(f.path, fi)), c.iounit()), nil
+}
+
+func (c *conn) create(tag uint16, d *decoder) ([]byte, error) {
+	f, err := c.getFid(d)
+	if err != nil {
+		return nil, err
+	}
+	name, perm, mode := d.str(), d.u32(), d.u8()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if f.file != nil {
+		return nil, errors.New("fid is open")
+	}
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid name: %s: %w", name, os.ErrInvalid)
+	}
+	p := path.Join(f.path, name)
+	fsPath := c.s.fsPath(p)
+
+	var file *memfs.File
+	if perm&DMDIR != 0 {
+		if mode&3 != OREAD {
+			return nil, fmt.Errorf("is a directory: %s: %w", p, syscall.EISDIR)
+		}
+		if err = c.s.fs.Mkdir(fsPath, fs.FileMode(perm&0777)); err != nil {
+			return nil, err
+		}
+		file, err = c.s.fs.Open(fsPath)
+	} else {
+		file, err = c.s.fs.OpenFile(fsPath, openFlag(mode)|os.O_CREATE|os.O_EXCL, fs.FileMode(perm&0777))
+	}
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	f.path, f.file, f.mode, f.dir = p, file, mode, fi.IsDir()
+	return putU32(putQid(newMessage(Rcreate, tag), c.s.qid(p, fi)), c.iounit()), nil
+}
+
+func (c *conn) read(tag uint16, d *decoder) ([]byte, error) {
+	f, err := c.getFid(d)
+	if err != nil {
+		return nil, err
+	}
+	offset, count := d.u64(), d.u32()
+	if f.file == nil {
+		return nil, errors.New("fid is not open")
+	}
+	count = min(count, c.iounit())
+
+	reply := putU32(newMessage(Rread, tag), 0)
+	if f.dir {
+		if offset == 0 {
+			if err = c.readDir(f); err != nil {
+				return nil, err
+			}
+		} else if offset != f.offset {
+			return nil, errors.New("bad offset in directory read")
+		}
+		// only whole entries are returned
+		n := 0
+		for len(f.entries) > 0 && n+len(f.entries[0]) <= int(count) {
+			reply = append(reply, f.entries[0]...)
+			n += len(f.entries[0])
+			f.entries = f.entries[1:]
+		}
+		f.offset += uint64(n)
+	} else {
+		if f.mode&3 == OWRITE {
+			return nil, errors.New("fid is not open for reading")
+		}
+		buf := make([]byte, count)
+		n, err := f.file.ReadAt(buf, int64(offset))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		reply = append(reply, buf[:n]...)
+	}
+	binary.LittleEndian.PutUint32(reply[headerSize:], uint32(len(reply)-headerSize-4))
+	return reply, nil
+}
+
+func (c *conn) readDir(f *fid) error {
+	entries, err := c.s.fs.ReadDir(c.s.fsPath(f.path))
+	if err != nil {
+		return err
+	}
+	f.entries = make([][]byte, 0, len(entries))
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		f.entries = append(f.entries, putStat(nil, c.s.stat(path.Join(f.path, e.Name()), fi)))
+	}
+	f.offset = 0
+	return nil
+}
+
+func (c *conn) write(tag uint16, d *decoder) ([]byte, error) {
+	f, err := c.getFid(d)
+	if err != nil {
+		return nil, err
+	}
+	offset, count := d.u64(), d.u32()
+	data := d.next(int(count))
+	if d.err != nil {
+		return nil, d.err
+	}
+	if f.file == nil || f.mode&3 == OREAD || f.mode&3 == OEXEC {
+		return nil, errors.New("fid is not open for writing")
+	}
+	n, err := f.file.WriteAt(data, int64(offset))
+	if err != nil {
+		return nil, err
+	}
+	return putU32(newMessage(Rwrite, tag), uint32(n)), nil
+}
+
+func (c *conn) clunk(tag uint16, d *decoder) ([]byte, error) {
+	id := d.u32()
+	f, exists := c.fids[id]
+	if !exists {
+		return nil, fmt.Errorf("unknown fid %d", id)
+	}
+	delete(c.fids, id)
+	if f.file != nil {
+		_ = f.file.Close()
+		if f.mode&ORCLOSE != 0 {
+			if err := c.s.fs.Remove(c.s.fsPath(f.path)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return newMessage(Rclunk, tag), nil
+}
+
+func (c *conn) remove(tag uint16, d *decoder) ([]byte, error) {
+	id := d.u32()
+	f, exists := c.fids[id]
+	if !exists {
+		return nil, fmt.Errorf("unknown fid %d", id)
+	}
+	// the fid is clunked even when the remove fails
+	delete(c.fids, id)
+	if f.file != nil {
+		_ = f.file.Close()
+	}
+	if f.path == "/" {
+		return nil, fmt.Errorf("cannot remove root: %w", os.ErrPermission)
+	}
+	if err := c.s.fs.Remove(c.s.fsPath(f.path)); err != nil {
+		return nil, err
+	}
+	return newMessage(Rremove, tag), nil
+}
+
+func (c *conn) statFid(tag uint16, d *decoder) ([]byte, error) {
+	f, err := c.getFid(d)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := c.s.fs.Stat(c.s.fsPath(f.path))
+	if err != nil {
+		return nil, err
+	}
+	st := putStat(nil, c.s.stat(f.path, fi))
+	return append(putU16(newMessage(Rstat, tag), uint16(len(st))), st...), nil
+}
+
+// wstat supports renaming within a directory and truncating a file to zero, other
+// changes report an error unless they leave the value as it is.
+func (c *conn) wstat(tag uint16, d *decoder) ([]byte, error) {
+	f, err := c.getFid(d)
+	if err != nil {
+		return nil, err
+	}
+	d.u16()
+	st := d.stat()
+	if d.err != nil {
+		return nil, d.err
+	}
+	p := c.s.fsPath(f.path)
+	fi, err := c.s.fs.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	current := c.s.stat(f.path, fi)
+
+	if st.Mode != ^uint32(0) && st.Mode != current.Mode {
+		return nil, errors.New("changing the mode is not supported")
+	}
+	if st.Mtime != ^uint32(0) && st.Mtime != current.Mtime {
+		return nil, errors.New("changing the modification time is not supported")
+	}
+	if st.GID != "" && st.GID != current.GID {
+		return nil, errors.New("changing the group is not supported")
+	}
+	if st.Length != ^uint64(0) && st.Length != current.Length {
+		if fi.IsDir() || st.Length != 0 {
+			return nil, errors.New("only truncating to zero is supported")
+		}
+	}
+	if st.Name != "" && st.Name != current.Name {
+		if f.path == "/" || strings.Contains(st.Name, "/") || st.Name == "." || st.Name == ".." {
+			return nil, fmt.Errorf("invalid name: %s: %w", st.Name, os.ErrInvalid)
+		}
+	}
+
+	if st.Length == 0 && current.Length != 0 {
+		file, err := c.s.fs.OpenFile(p, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			return nil, err
+		}
+		_ = file.Close()
+	}
+	if st.Name != "" && st.Name != current.Name {
+		newPath := path.Join(path.Dir(f.path), st.Name)
+		if err = c.s.fs.Rename(p, c.s.fsPath(newPath)); err != nil {
+			return nil, err
+		}
+		c.s.renamed(f.path, newPath)
+		for _, other := range c.fids {
+			if other.path == f.path {
+				other.path = newPath
+			} else if strings.HasPrefix(other.path, f.path+"/") {
+				other.path = newPath + strings.TrimPrefix(other.path, f.path)
+			}
+		}
+	}
+	return newMessage(Rwstat, tag), nil
+}
@@ -0,0 +1,207 @@
+package ninep
+
+import (
+	"encoding/binary"
+	"github.com/mbordner/memfs"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"os"
+	"testing"
+)
+
+// client is a minimal 9P2000 client sending one request at a time.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func newClient(t *testing.T, mfs *memfs.FS, dir string) *client {
+	serverConn, clientConn := net.Pipe()
+	go func() {
+		_ = NewServer(mfs, dir).ServeConn(serverConn)
+		serverConn.Close()
+	}()
+	t.Cleanup(func() { clientConn.Close() })
+	return &client{t: t, conn: clientConn}
+}
+
+// rpc sends a request and returns the reply type and body.
+func (c *client) rpc(msg []byte) (uint8, *decoder) {
+	_, err := c.conn.Write(finishMessage(msg))
+	assert.Nil(c.t, err)
+	var header [headerSize]byte
+	_, err = io.ReadFull(c.conn, header[:])
+	assert.Nil(c.t, err)
+	body := make([]byte, binary.LittleEndian.Uint32(header[:4])-headerSize)
+	_, err = io.ReadFull(c.conn, body)
+	assert.Nil(c.t, err)
+	return header[4], &decoder{b: body}
+}
+
+// ok sends a request that must succeed with the reply type given.
+func (c *client) ok(msg []byte, want uint8) *decoder {
+	typ, d := c.rpc(msg)
+	if typ == Rerror {
+		c.t.Fatalf("unexpected error: %s", d.str())
+	}
+	assert.Equal(c.t, want, typ)
+	return d
+}
+
+// fails sends a request that must fail, it returns the error string.
+func (c *client) fails(msg []byte) string {
+	typ, d := c.rpc(msg)
+	assert.Equal(c.t, uint8(Rerror), typ)
+	return d.str()
+}
+
+func (c *client) attach(fid uint32) Qid {
+	d := c.ok(putStr(putU32(newMessage(Tversion, NOTAG), 8192), "9P2000.u"), Rversion)
+	assert.Equal(c.t, uint32(8192), d.u32())
+	assert.Equal(c.t, "9P2000", d.str())
+	return c.ok(putStr(putStr(putU32(putU32(newMessage(Tattach, 1), fid), NOFID), "user"), ""), Rattach).qid()
+}
+
+func walkMessage(fid, newfid uint32, names ...string) []byte {
+	m := putU16(putU32(putU32(newMessage(Twalk, 1), fid), newfid), uint16(len(names)))
+	for _, n := range names {
+		m = putStr(m, n)
+	}
+	return m
+}
+
+func writeFile(t *testing.T, mfs *memfs.FS, name, data string) {
+	f, err := mfs.Create(name)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(data))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+}
+
+func Test_Walk_Read_Stat(t *testing.T) {
+	mfs := memfs.New()
+	assert.Nil(t, mfs.MkdirAll("/srv/dir", 0755))
+	writeFile(t, mfs, "/srv/dir/a.txt", "hello 9p")
+
+	c := newClient(t, mfs, "/srv")
+	root := c.attach(0)
+	assert.Equal(t, uint8(QTDIR), root.Type)
+
+	d := c.ok(walkMessage(0, 1, "dir", "a.txt"), Rwalk)
+	assert.Equal(t, uint16(2), d.u16())
+	assert.Equal(t, uint8(QTDIR), d.qid().Type)
+	file := d.qid()
+	assert.Equal(t, uint8(QTFILE), file.Type)
+
+	// a partial walk returns the qids walked and no fid
+	d = c.ok(walkMessage(0, 2, "dir", "missing"), Rwalk)
+	assert.Equal(t, uint16(1), d.u16())
+	c.fails(putU8(putU32(newMessage(Topen, 1), 2), OREAD))
+	c.fails(walkMessage(0, 2, "missing"))
+
+	d = c.ok(putU8(putU32(newMessage(Topen, 1), 1), OREAD), Ropen)
+	assert.Equal(t, file, d.qid())
+	d = c.ok(putU32(putU64(putU32(newMessage(Tread, 1), 1), 6), 100), Rread)
+	assert.Equal(t, "9p", string(d.next(int(d.u32()))))
+
+	d = c.ok(putU32(newMessage(Tstat, 1), 1), Rstat)
+	d.u16()
+	st := d.stat()
+	assert.Equal(t, "a.txt", st.Name)
+	assert.Equal(t, uint64(8), st.Length)
+	assert.Equal(t, uint32(0666), st.Mode)
+	assert.Equal(t, file, st.Qid)
+	c.ok(putU32(newMessage(Tclunk, 1), 1), Rclunk)
+	c.fails(putU32(newMessage(Tclunk, 1), 1))
+
+	// ".." does not leave the served directory
+	d = c.ok(walkMessage(0, 3, ".."), Rwalk)
+	assert.Equal(t, uint16(1), d.u16())
+	assert.Equal(t, root, d.qid())
+}
+
+func Test_Read_Dir(t *testing.T) {
+	mfs := memfs.New()
+	assert.Nil(t, mfs.MkdirAll("/srv/sub", 0755))
+	writeFile(t, mfs, "/srv/a", "a")
+	writeFile(t, mfs, "/srv/b", "bb")
+
+	c := newClient(t, mfs, "/srv")
+	c.attach(0)
+	c.ok(walkMessage(0, 1), Rwalk)
+	c.ok(putU8(putU32(newMessage(Topen, 1), 1), OREAD), Ropen)
+
+	var names []string
+	var offset uint64
+	for {
+		// a small count only fits one entry per read
+		d := c.ok(putU32(putU64(putU32(newMessage(Tread, 1), 1), offset), 80), Rread)
+		n := d.u32()
+		if n == 0 {
+			break
+		}
+		offset += uint64(n)
+		entries := &decoder{b: d.next(int(n))}
+		for len(entries.b) > 0 {
+			st := entries.stat()
+			names = append(names, st.Name)
+			if st.Name == "sub" {
+				assert.Equal(t, uint32(DMDIR|0755), st.Mode)
+			}
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "sub"}, names)
+	c.fails(putU32(putU64(putU32(newMessage(Tread, 1), 1), 1), 60))
+}
+
+func Test_Create_Write_Remove(t *testing.T) {
+	mfs := memfs.New()
+	assert.Nil(t, mfs.MkdirAll("/srv", 0755))
+
+	c := newClient(t, mfs, "/srv")
+	c.attach(0)
+
+	c.ok(walkMessage(0, 1), Rwalk)
+	c.ok(putU8(putU32(putStr(putU32(newMessage(Tcreate, 1), 1), "dir"), DMDIR|0700), OREAD), Rcreate)
+	c.ok(putU32(newMessage(Tclunk, 1), 1), Rclunk)
+	fi, err := mfs.Stat("/srv/dir")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	assert.Equal(t, os.ModeDir|0700, fi.Mode())
+
+	c.ok(walkMessage(0, 1, "dir"), Rwalk)
+	c.ok(putU8(putU32(putStr(putU32(newMessage(Tcreate, 1), 1), "f.txt"), 0600), ORDWR), Rcreate)
+	m := putU32(putU64(putU32(newMessage(Twrite, 1), 1), 0), 5)
+	d := c.ok(append(m, "hello"...), Rwrite)
+	assert.Equal(t, uint32(5), d.u32())
+
+	// rename through wstat, leaving everything else untouched
+	dontTouch := Stat{Type: ^uint16(0), Dev: ^uint32(0), Mode: ^uint32(0), Atime: ^uint32(0), Mtime: ^uint32(0), Length: ^uint64(0), Name: "g.txt"}
+	dontTouch.Qid = Qid{Type: ^uint8(0), Version: ^uint32(0), Path: ^uint64(0)}
+	st := putStat(nil, dontTouch)
+	c.ok(append(putU16(putU32(newMessage(Twstat, 1), 1), uint16(len(st))), st...), Rwstat)
+	dontTouch.Name, dontTouch.Mode = "", 0777
+	st = putStat(nil, dontTouch)
+	c.fails(append(putU16(putU32(newMessage(Twstat, 1), 1), uint16(len(st))), st...))
+	c.ok(putU32(newMessage(Tclunk, 1), 1), Rclunk)
+
+	f, err := mfs.Open("/srv/dir/g.txt")
+	assert.Nil(t, err)
+	data, err := io.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Nil(t, f.Close())
+
+	c.ok(walkMessage(0, 1, "dir"), Rwalk)
+	c.fails(putU32(newMessage(Tremove, 1), 1))
+	c.ok(walkMessage(0, 1, "dir", "g.txt"), Rwalk)
+	c.ok(putU32(newMessage(Tremove, 1), 1), Rremove)
+	_, err = mfs.Stat("/srv/dir/g.txt")
+	assert.NotNil(t, err)
+	c.ok(walkMessage(0, 1, "dir"), Rwalk)
+	c.ok(putU32(newMessage(Tremove, 1), 1), Rremove)
+
+	c.ok(walkMessage(0, 1), Rwalk)
+	c.fails(putU8(putU32(putStr(putU32(newMessage(Tcreate, 1), 1), "../x"), 0600), ORDWR))
+}
@@ -0,0 +1,49 @@
+package memfs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"unsafe"
+)
+
+func Test_Node_Size(t *testing.T) {
+	// every entry of a tree is a node, see the field order of fsNode
+	assert.LessOrEqual(t, int(unsafe.Sizeof(fsNode{})), 104)
+}
+
+func Test_New_Node(t *testing.T) {
+	mfs := New()
+	v := mfs.As(1000, 1000)
+	n := v.newNode("dir", 0750, true)
+	assert.Equal(t, "dir", n.name)
+	assert.Equal(t, os.FileMode(0750), n.perm)
+	uid, gid := n.owner()
+	assert.Equal(t, 1000, uid)
+	assert.Equal(t, 1000, gid)
+	assert.True(t, n.isDir())
+	assert.False(t, mfs.newNode("file", 0640, false).isDir())
+}
+
+func Test_Node_Release(t *testing.T) {
+	// the removed nodes still referenced keep no content
+	mfs := New()
+	nodes := make(map[string]*fsNode)
+	assert.Nil(t, mfs.Mkdir("/dir", 0755))
+	for _, name := range []string{"/removed", "/dir/all", "/replaced", "/new"} {
+		assert.Nil(t, mfs.WriteFile(name, make([]byte, 4096), 0644))
+		_, n, _, err := mfs.getEntry(name)
+		assert.Nil(t, err)
+		nodes[name] = n
+	}
+	assert.Nil(t, mfs.Remove("/removed"))
+	assert.Nil(t, mfs.RemoveAll("/dir"))
+	assert.Nil(t, mfs.Rename("/new", "/replaced"))
+	for _, name := range []string{"/removed", "/dir/all", "/replaced"} {
+		assert.True(t, nodes[name].unlinked, name)
+		assert.Nil(t, nodes[name].content, name)
+	}
+	data, err := mfs.ReadFile("/replaced")
+	assert.Nil(t, err)
+	assert.Len(t, data, 4096)
+}
@@ -0,0 +1,23 @@
+package memfs
+
+import "golang.org/x/text/unicode/norm"
+
+// WithNameNormalization makes the filesystem normalize the names of entries to form when
+// they are created and looked up, as macOS does, HFS+ storing names in NFD. Names which
+// only differ by their normalization, like "é" as one rune or as "e" and a combining
+// accent, then refer to the same entry, and ReadDir returns the normalized names, so the
+// bugs of code comparing the names it wrote to those it lists show up on any platform.
+func WithNameNormalization(form norm.Form) Option {
+	return func(f *FS) {
+		f.normalization = &form
+	}
+}
+
+// normalize returns p normalized to the form of the filesystem, unchanged without one.
+func (f *FS) normalize(p string) string {
+	form := f.baseFS().normalization
+	if form == nil {
+		return p
+	}
+	return form.String(p)
+}
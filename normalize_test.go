@@ -0,0 +1,42 @@
+package memfs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/unicode/norm"
+	"testing"
+)
+
+func Test_Name_Normalization(t *testing.T) {
+	nfc, nfd := "/caf\u00e9", "/cafe\u0301"
+
+	mfs := New(WithNameNormalization(norm.NFD))
+	f, err := mfs.Create(nfc)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	_, err = mfs.Stat(nfd)
+	assert.Nil(t, err)
+	entries, err := mfs.ReadDir("/")
+	assert.Nil(t, err)
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	// the name listed is not the one created
+	assert.Contains(t, names, nfd[1:])
+	assert.NotContains(t, names, nfc[1:])
+
+	assert.Nil(t, mfs.MkdirAll("/r\u00e9sum\u00e9s/2024", 0755))
+	_, err = mfs.Stat("/re\u0301sume\u0301s/2024")
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.Rename(nfd, "/r\u00e9sum\u00e9s/caf\u00e9"))
+	_, err = mfs.Stat("/re\u0301sume\u0301s/cafe\u0301")
+	assert.Nil(t, err)
+
+	// without normalization the names are different entries
+	mfs = New()
+	f, err = mfs.Create(nfc)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	_, err = mfs.Stat(nfd)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,110 @@
+package memfs
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Option configures a filesystem created by New.
+type Option func(f *FS)
+
+// Clock provides the current time to the filesystem, it is used for all the times recorded on entries.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock makes the filesystem read times from clock instead of the system time.
+func WithClock(clock Clock) Option {
+	return func(f *FS) {
+		f.clock = clock
+	}
+}
+
+// WithStrictFlags makes OpenFile reject the flag combinations the OS rejects or leaves
+// unspecified, such as O_RDONLY|O_TRUNC or O_EXCL without O_CREATE, and report the errors
+// the OS reports, syscall.EISDIR when opening a directory for writing and syscall.ENOENT
+// for a missing file opened without O_CREATE.
+func WithStrictFlags() Option {
+	return func(f *FS) {
+		f.strictFlags = true
+	}
+}
+
+// WithoutLocking makes the filesystem skip its internal locking, for benchmarks and
+// scripts using it from a single goroutine, where locking nodes on every operation
+// dominates profiles. The filesystem, its views and its files must then never be used
+// by several goroutines at the same time, IngestParallel and WalkParallel use a single
+// worker.
+func WithoutLocking() Option {
+	return func(f *FS) {
+		f.noLock = true
+	}
+}
+
+// WithTempDir makes the filesystem keep temporary files in dir, created with the
+// filesystem, rather than in "/tmp", which is then not created.
+func WithTempDir(dir string) Option {
+	return func(f *FS) {
+		f.tempDir = dir
+	}
+}
+
+// WithMaxDepth makes RemoveAll, WalkParallel and the exports of trees fail with an error
+// wrapping ErrTooDeep for entries more than depth levels beneath the path they start from,
+// rather than going through pathological trees. There is no limit when depth is 0.
+func WithMaxDepth(depth int) Option {
+	return func(f *FS) {
+		f.maxDepth = depth
+	}
+}
+
+// WithMaxOpenFiles makes OpenFile, and the functions opening files, fail with an error
+// wrapping syscall.EMFILE while n files are open, like the OS past the limit of open
+// files of a process, so the handling of "too many open files" can be tested.
+func WithMaxOpenFiles(n int) Option {
+	return func(f *FS) {
+		f.maxOpenFiles = n
+	}
+}
+
+// WithRandSource makes the filesystem use src for the random parts of temporary names.
+func WithRandSource(src rand.Source) Option {
+	return func(f *FS) {
+		f.rand = rand.New(src)
+	}
+}
+
+// StepClock is a deterministic Clock which starts at a fixed time and advances by a
+// fixed step every time it is read, so successive modifications get distinct times.
+type StepClock struct {
+	mutex sync.Mutex
+	now   time.Time
+	step  time.Duration
+}
+
+func NewStepClock(start time.Time, step time.Duration) *StepClock {
+	return &StepClock{now: start, step: step}
+}
+
+func (c *StepClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Peek returns the time the next Now returns, without advancing the clock.
+func (c *StepClock) Peek() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d without reading it.
+func (c *StepClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,214 @@
+package memfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+const (
+	accessExecute = 1 << iota
+	accessWrite
+	accessRead
+)
+
+// As returns a view of the filesystem where every operation is permission checked as
+// the given user and group. Entries created through the view are owned by that identity.
+// The view shares the tree with f, so changes made through either are visible to both.
+// A uid of 0 is treated as the superuser and bypasses the checks.
+func (f *FS) As(uid, gid int) *FS {
+	v := f.view(f.root)
+	v.uid, v.gid, v.checked = uid, gid, true
+	return v
+}
+
+// hasAccess returns whether the identity of f has all the access bits in want
+// (a combination of accessRead, accessWrite and accessExecute) on the node, granted by
+// its permission bits or its access control list.
+func (f *FS) hasAccess(n *fsNode, want os.FileMode) bool {
+	if !f.checked || f.uid == 0 {
+		return true
+	}
+	perm := n.perm.Perm()
+	uid, gid := n.owner()
+	if f.uid != uid {
+		// the group bits are the mask of an extended access control list
+		if extended := n.extendedACL(); extended != nil {
+			if granted, matched := f.aclAccess(n, extended, perm>>3&7, want); matched {
+				return granted
+			}
+			return perm&want == want
+		}
+	}
+	switch {
+	case f.uid == uid:
+		perm >>= 6
+	case f.gid == gid:
+		perm >>= 3
+	}
+	return perm&want == want
+}
+
+// owns returns whether the identity of f can change the attributes of n, as its owner or
+// the superuser.
+func (f *FS) owns(n *fsNode) bool {
+	if !f.checked || f.uid == 0 {
+		return true
+	}
+	uid, _ := n.owner()
+	return f.uid == uid
+}
+
+func (f *FS) checkAccess(path string, n *fsNode, want os.FileMode) error {
+	if !f.hasAccess(n, want) {
+		return fmt.Errorf("permission denied: %s: %w", path, os.ErrPermission)
+	}
+	return nil
+}
+
+// Access checks, without opening it, that the entry at path exists and can be accessed in
+// mode, a combination of the R_OK (4), W_OK (2) and X_OK (1) bits of access(2), by the
+// identity of the view, like unix.Access. A mode of 0, F_OK, only checks the entry exists.
+// The permission bits are evaluated even where operations aren't checked: a filesystem
+// not created by As is checked against the bits of the owner, and the superuser is only
+// granted X_OK on a file with an execute bit set, like by access(2).
+func (f *FS) Access(path string, mode uint32) (err error) {
+	defer f.mapErrno(&err)
+	if mode&^uint32(accessRead|accessWrite|accessExecute) != 0 {
+		return fmt.Errorf("invalid access mode: %o: %w", mode, os.ErrInvalid)
+	}
+	if err := f.checkGuard(OpStat, path); err != nil {
+		return err
+	}
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return err
+	}
+	want := os.FileMode(mode)
+	var granted bool
+	switch {
+	case !f.checked:
+		n.lock()
+		granted = n.perm.Perm()>>6&want == want
+		n.unlock()
+	case f.uid == 0:
+		n.lock()
+		granted = want&accessExecute == 0 || n.isDir() || n.perm&0111 != 0
+		n.unlock()
+	default:
+		granted = f.hasAccess(n, want)
+	}
+	if !granted {
+		return fmt.Errorf("permission denied: %s: %w", path, os.ErrPermission)
+	}
+	return nil
+}
+
+// SetUmask sets the permission bits cleared from the perm given to OpenFile, Mkdir and
+// MkdirAll, and the functions creating entries through them, like umask(2), so the entries
+// created get the modes the OS would give them. The mask is shared by the views of the
+// filesystem, it is 0 until set.
+func (f *FS) SetUmask(mask os.FileMode) {
+	f.baseFS().umask.Store(uint32(mask & fs.ModePerm))
+}
+
+// masked returns perm without the bits of the mask set by SetUmask.
+func (f *FS) masked(perm os.FileMode) os.FileMode {
+	return perm &^ os.FileMode(f.baseFS().umask.Load())
+}
+
+// chmodBits are the bits of a mode Chmod changes, like os.Chmod.
+const chmodBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// Chmod changes the permission bits of the entry at path to those of mode, like os.Chmod.
+// Through a view created by As, only the owner of the entry and the superuser can.
+func (f *FS) Chmod(path string, mode os.FileMode) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpChmod, path); err != nil {
+		return err
+	}
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return err
+	}
+	return f.chmod(path, n, mode)
+}
+
+// Chmod changes the permission bits of the file like FS.Chmod, like os.File.Chmod.
+func (f *File) Chmod(mode os.FileMode) (err error) {
+	defer f.fs.mapErrno(&err)
+	if err := f.checkAttributes(); err != nil {
+		return err
+	}
+	return f.fs.chmod(f.Name(), f.node, mode)
+}
+
+func (f *FS) chmod(name string, n *fsNode, mode os.FileMode) error {
+	n.lock()
+	defer n.unlock()
+	if !f.owns(n) {
+		return fmt.Errorf("not the owner: %s: %w", name, os.ErrPermission)
+	}
+	n.perm = n.perm&^chmodBits | mode&chmodBits
+	f.changed(n)
+	return nil
+}
+
+// Chown changes the user and group owning the entry at path, like os.Chown, a uid or gid
+// of -1 leaves it unchanged. Through a view created by As, only the superuser can change
+// the user, and the owner can only give the entry to its own group.
+func (f *FS) Chown(path string, uid, gid int) (err error) {
+	defer f.mapErrno(&err)
+	if err := f.checkGuard(OpChown, path); err != nil {
+		return err
+	}
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return err
+	}
+	return f.chown(path, n, uid, gid)
+}
+
+// Lchown is Chown, memfs has no symbolic links.
+func (f *FS) Lchown(path string, uid, gid int) (err error) {
+	defer f.mapErrno(&err)
+	return f.Chown(path, uid, gid)
+}
+
+// Chown changes the user and group owning the file like FS.Chown, like os.File.Chown.
+func (f *File) Chown(uid, gid int) (err error) {
+	defer f.fs.mapErrno(&err)
+	if err := f.checkAttributes(); err != nil {
+		return err
+	}
+	return f.fs.chown(f.Name(), f.node, uid, gid)
+}
+
+func (f *FS) chown(name string, n *fsNode, uid, gid int) error {
+	n.lock()
+	defer n.unlock()
+	owner, group := n.owner()
+	if uid == -1 {
+		uid = owner
+	}
+	if gid == -1 {
+		gid = group
+	}
+	if f.checked && f.uid != 0 && (uid != owner || f.uid != owner || (gid != group && gid != f.gid)) {
+		return fmt.Errorf("cannot change owner: %s: %w", name, os.ErrPermission)
+	}
+	n.setOwner(uid, gid)
+	f.changed(n)
+	return nil
+}
+
+// checkAttributes returns an error when the attributes of the file can no longer be changed.
+func (f *File) checkAttributes() error {
+	if f.node.unlinked {
+		return fmt.Errorf("file unlinked: %s: %w", f.Name(), fs.ErrInvalid)
+	}
+	if f.closed {
+		return fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_As_Permission_Checks(t *testing.T) {
+	mfs := New()
+
+	assert.Nil(t, mfs.MkdirAll("/etc/app", 0755))
+	f, err := mfs.OpenFile("/etc/app/config", os.O_RDWR|os.O_CREATE, 0644)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	reader := mfs.As(1000, 1000)
+
+	f, err = reader.Open("/etc/app/config")
+	assert.Nil(t, err)
+	assert.NotNil(t, f)
+
+	f, err = reader.OpenFile("/etc/app/config", os.O_RDWR, 0)
+	assert.Nil(t, f)
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	_, err = reader.Create("/etc/app/other")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	err = reader.Mkdir("/etc/app/dir", 0755)
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	err = reader.MkdirAll("/etc/app/dir/subdir", 0755)
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	err = reader.Remove("/etc/app/config")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	err = reader.RemoveAll("/etc/app")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	_, err = reader.CreateTemp("/etc/app", "tmp*")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	entries, err := reader.ReadDir("/etc/app")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	// the superuser is never denied
+	f, err = mfs.As(0, 0).OpenFile("/etc/app/config", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	assert.NotNil(t, f)
+}
+
+func Test_As_Ownership(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/home/user", 0777))
+	assert.Nil(t, mfs.MkdirAll("/secret", 0700))
+
+	user := mfs.As(1000, 1000)
+	other := mfs.As(1001, 1000)
+
+	assert.Nil(t, user.Mkdir("/home/user/private", 0700))
+	f, err := user.OpenFile("/home/user/shared", os.O_RDWR|os.O_CREATE, 0640)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	_, err = other.ReadDir("/home/user/private")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	// group members may read but not write
+	f, err = other.Open("/home/user/shared")
+	assert.Nil(t, err)
+	assert.NotNil(t, f)
+	_, err = other.OpenFile("/home/user/shared", os.O_WRONLY, 0)
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	_, err = mfs.As(2000, 2000).Open("/home/user/shared")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	// without search permission on a directory nothing beneath it can be reached
+	_, err = user.Stat("/secret/anything")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	// the view shares the tree with the filesystem it was created from
+	_, err = mfs.Stat("/home/user/private")
+	assert.Nil(t, err)
+}
+
+func Test_Chmod(t *testing.T) {
+	mfs := New()
+	user := mfs.As(1000, 1000)
+	assert.Nil(t, mfs.MkdirAll("/home/user", 0777))
+	f, err := user.Create("/home/user/file")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	// making the file read-only mid-test
+	assert.Nil(t, user.Chmod("/home/user/file", 0444|os.ModeSticky))
+	fi, err := mfs.Stat("/home/user/file")
+	assert.Nil(t, err)
+	assert.Equal(t, 0444|os.ModeSticky, fi.Mode())
+	_, err = user.OpenFile("/home/user/file", os.O_WRONLY, 0)
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	// only the owner can change the mode
+	assert.True(t, errors.Is(mfs.As(1001, 1000).Chmod("/home/user/file", 0666), os.ErrPermission))
+	assert.Nil(t, mfs.Chmod("/home/user/file", 0600))
+	fi, err = mfs.Stat("/home/user/file")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+
+	// the type bits are kept
+	assert.Nil(t, mfs.Chmod("/home/user", os.ModeDir|0700))
+	fi, err = mfs.Stat("/home/user")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+	_, err = user.Stat("/home/user/file")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	assert.True(t, errors.Is(mfs.Chmod("/missing", 0644), os.ErrNotExist))
+}
+
+func Test_Chown(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/srv", 0777))
+	f, err := mfs.Create("/srv/file")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	assert.Nil(t, mfs.Chown("/srv/file", 1000, 100))
+	fi, err := mfs.Stat("/srv/file")
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, fi.UID())
+	assert.Equal(t, 100, fi.GID())
+
+	// the owner can give the file to its group, but not to another user
+	user := mfs.As(1000, 1000)
+	assert.True(t, errors.Is(user.Chown("/srv/file", 1001, -1), os.ErrPermission))
+	assert.True(t, errors.Is(user.Chown("/srv/file", -1, 2000), os.ErrPermission))
+	assert.Nil(t, user.Lchown("/srv/file", -1, 1000))
+	fi, err = mfs.Stat("/srv/file")
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, fi.UID())
+	assert.Equal(t, 1000, fi.GID())
+	assert.True(t, errors.Is(mfs.As(1001, 1000).Chown("/srv/file", -1, 1000), os.ErrPermission))
+
+	// the owner decides who can access the file
+	assert.Nil(t, mfs.Chmod("/srv/file", 0600))
+	_, err = mfs.As(1001, 1000).Open("/srv/file")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	f, err = user.Open("/srv/file")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	assert.True(t, errors.Is(mfs.Chown("/missing", 0, 0), os.ErrNotExist))
+}
+
+func Test_File_Attributes(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/srv", 0777))
+	f, err := mfs.As(1000, 1000).Create("/srv/file")
+	assert.Nil(t, err)
+
+	assert.Nil(t, f.Chmod(0600))
+	assert.Nil(t, f.Chown(-1, 1000))
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, f.Chtimes(time.Time{}, mtime))
+	fi, err := f.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode())
+	assert.Equal(t, 1000, fi.(FileInfo).GID())
+	assert.True(t, fi.ModTime().Equal(mtime))
+	// the handle keeps the identity it was opened with
+	assert.True(t, errors.Is(f.Chown(0, -1), os.ErrPermission))
+
+	other, err := mfs.As(1001, 1001).Open("/srv")
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(other.Chmod(0700), os.ErrPermission))
+	assert.Nil(t, other.Close())
+	assert.True(t, errors.Is(other.Chtimes(time.Time{}, mtime), os.ErrClosed))
+
+	assert.Nil(t, mfs.Remove("/srv/file"))
+	assert.True(t, errors.Is(f.Chmod(0644), os.ErrInvalid))
+	assert.Nil(t, f.Close())
+}
+
+func Test_Access(t *testing.T) {
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll("/home/user", 0755))
+	assert.Nil(t, mfs.WriteFile("/home/user/script", []byte("#!/bin/sh"), 0750))
+	assert.Nil(t, mfs.Chown("/home/user/script", 1000, 100))
+	assert.Nil(t, mfs.WriteFile("/home/user/private", []byte("x"), 0600))
+
+	owner := mfs.As(1000, 100)
+	assert.Nil(t, owner.Access("/home/user/script", 4|2|1))
+	assert.True(t, errors.Is(owner.Access("/home/user/private", 4), os.ErrPermission))
+
+	group := mfs.As(1001, 100)
+	assert.Nil(t, group.Access("/home/user/script", 4|1))
+	assert.True(t, errors.Is(group.Access("/home/user/script", 2), os.ErrPermission))
+
+	other := mfs.As(1002, 200)
+	assert.Nil(t, other.Access("/home/user/script", 0))
+	assert.True(t, errors.Is(other.Access("/home/user/script", 4), os.ErrPermission))
+	assert.Nil(t, other.Access("/home/user", 4|1))
+
+	// the bits of the owner are evaluated on a filesystem not created by As, the
+	// superuser gets X_OK when an execute bit is set
+	assert.Nil(t, mfs.Access("/home/user/private", 4|2))
+	assert.True(t, errors.Is(mfs.Access("/home/user/private", 1), os.ErrPermission))
+	assert.Nil(t, mfs.Access("/home/user/script", 4|2|1))
+	root := mfs.As(0, 0)
+	assert.Nil(t, root.Access("/home/user/private", 4|2))
+	assert.True(t, errors.Is(root.Access("/home/user/private", 1), os.ErrPermission))
+	assert.Nil(t, root.Access("/home/user/script", 4|2|1))
+	assert.Nil(t, root.Access("/home/user", 1))
+	assert.True(t, errors.Is(mfs.Access("/missing", 0), os.ErrNotExist))
+	assert.True(t, errors.Is(mfs.Access("/home", 8), os.ErrInvalid))
+}
+
+func Test_SetUmask(t *testing.T) {
+	mfs := New()
+	mfs.SetUmask(0022)
+
+	assert.Nil(t, mfs.MkdirAll("/a/b", 0777))
+	assert.Nil(t, mfs.Mkdir("/a/c", 0775))
+	assert.Nil(t, mfs.WriteFile("/a/file", nil, 0666))
+	f, err := mfs.CreateTemp("/tmp", "x*")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	mode := func(path string) os.FileMode {
+		fi, err := mfs.Stat(path)
+		assert.Nil(t, err)
+		return fi.Mode().Perm()
+	}
+	assert.Equal(t, os.FileMode(0755), mode("/a"))
+	assert.Equal(t, os.FileMode(0755), mode("/a/b"))
+	assert.Equal(t, os.FileMode(0755), mode("/a/c"))
+	assert.Equal(t, os.FileMode(0644), mode("/a/file"))
+	assert.Equal(t, os.FileMode(0600), mode(f.Name()))
+
+	// Chmod and existing entries are not masked
+	assert.Nil(t, mfs.Chmod("/a/file", 0666))
+	assert.Equal(t, os.FileMode(0666), mode("/a/file"))
+	assert.Nil(t, mfs.WriteFile("/a/file", nil, 0600))
+	assert.Equal(t, os.FileMode(0666), mode("/a/file"))
+
+	mfs.SetUmask(0)
+	assert.Nil(t, mfs.WriteFile("/a/other", nil, 0666))
+	assert.Equal(t, os.FileMode(0666), mode("/a/other"))
+}
@@ -0,0 +1,97 @@
+package memfs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// PathPolicy decides which paths entries can be created at, it is given the absolute path,
+// with "/" separators, of every file or directory about to be created or renamed.
+type PathPolicy interface {
+	ValidatePath(path string) error
+}
+
+// WithPathPolicy makes the filesystem refuse to create entries at paths the policy rejects,
+// so paths which wouldn't be portable to the target OS fail in tests rather than in production.
+func WithPathPolicy(policy PathPolicy) Option {
+	return func(f *FS) {
+		f.pathPolicy = policy
+	}
+}
+
+// PathRules is a PathPolicy made of the common portability rules, the rules of the zero
+// fields are not checked.
+type PathRules struct {
+	MaxPathLength        int      // in bytes
+	MaxNameLength        int      // in bytes, of every name of the path
+	ForbiddenChars       string   // characters names cannot contain
+	ReservedNames        []string // names reserved by the OS, matched without case and extension
+	NoTrailingDotOrSpace bool     // names cannot end with a dot or a space
+}
+
+// PosixPathRules returns the limits of common Unix filesystems.
+func PosixPathRules() PathRules {
+	return PathRules{
+		MaxPathLength:  4095,
+		MaxNameLength:  255,
+		ForbiddenChars: "\x00",
+	}
+}
+
+// WindowsPathRules returns the rules of NTFS paths without the long path prefix.
+func WindowsPathRules() PathRules {
+	var control strings.Builder
+	for c := byte(0); c < 0x20; c++ {
+		control.WriteByte(c)
+	}
+	reserved := []string{"CON", "PRN", "AUX", "NUL"}
+	for i := '1'; i <= '9'; i++ {
+		reserved = append(reserved, "COM"+string(i), "LPT"+string(i))
+	}
+	return PathRules{
+		MaxPathLength:        259,
+		MaxNameLength:        255,
+		ForbiddenChars:       `<>:"|?*\` + control.String(),
+		ReservedNames:        reserved,
+		NoTrailingDotOrSpace: true,
+	}
+}
+
+func (r PathRules) ValidatePath(path string) error {
+	if r.MaxPathLength > 0 && len(path) > r.MaxPathLength {
+		return fmt.Errorf("path too long: %s: %w", path, syscall.ENAMETOOLONG)
+	}
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if r.MaxNameLength > 0 && len(name) > r.MaxNameLength {
+			return fmt.Errorf("file name too long: %s: %w", path, syscall.ENAMETOOLONG)
+		}
+		if i := strings.IndexAny(name, r.ForbiddenChars); i >= 0 {
+			return fmt.Errorf("invalid character %q in name: %s: %w", name[i], path, os.ErrInvalid)
+		}
+		if r.NoTrailingDotOrSpace && (strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ")) {
+			return fmt.Errorf("name ends with a dot or space: %s: %w", path, os.ErrInvalid)
+		}
+		stem, _, _ := strings.Cut(name, ".")
+		stem = strings.TrimRight(stem, " ")
+		for _, reserved := range r.ReservedNames {
+			if strings.EqualFold(stem, reserved) {
+				return fmt.Errorf("reserved name: %s: %w", path, os.ErrInvalid)
+			}
+		}
+	}
+	return nil
+}
+
+// validateNewPath checks the path of an entry about to be created against the path policy.
+func (f *FS) validateNewPath(path string) error {
+	policy := f.baseFS().pathPolicy
+	if policy == nil {
+		return nil
+	}
+	return policy.ValidatePath(f.getAbsolutePath(path))
+}
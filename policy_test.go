@@ -0,0 +1,56 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func Test_Windows_Path_Rules(t *testing.T) {
+	mfs := New(WithPathPolicy(WindowsPathRules()))
+
+	for _, name := range []string{"/con", "/Aux.txt", "/LPT1", "/a:b", "/q?", "/back\\slash", "/dot.", "/space ", "/tab\t"} {
+		_, err := mfs.Create(name)
+		assert.True(t, errors.Is(err, os.ErrInvalid), name)
+	}
+	assert.True(t, errors.Is(mfs.Mkdir("/nul", 0755), os.ErrInvalid))
+	assert.True(t, errors.Is(mfs.MkdirAll("/ok/com9/x", 0755), os.ErrInvalid))
+	_, err := mfs.Stat("/ok")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	long := "/" + strings.Repeat("a", 256)
+	_, err = mfs.Create(long)
+	assert.True(t, errors.Is(err, syscall.ENAMETOOLONG))
+	deep := strings.Repeat("/"+strings.Repeat("d", 100), 3)
+	assert.True(t, errors.Is(mfs.MkdirAll(deep, 0755), syscall.ENAMETOOLONG))
+
+	f, err := mfs.Create("/console.log")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.True(t, errors.Is(mfs.Rename("/console.log", "/prn.log"), os.ErrInvalid))
+	assert.Nil(t, mfs.Rename("/console.log", "/com10"))
+
+	// existing entries are still opened
+	f, err = mfs.OpenFile("/com10", os.O_RDWR|os.O_CREATE, 0666)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	err = mfs.IngestParallel(func(yield func(IngestEntry) bool) {
+		yield(IngestEntry{Path: "/in/CON", Mode: 0644})
+	}, 1)
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+}
+
+func Test_Posix_Path_Rules(t *testing.T) {
+	mfs := New(WithPathPolicy(PosixPathRules()))
+	f, err := mfs.Create("/con: a?b ")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	_, err = mfs.Create("/nul\x00")
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+	_, err = mfs.Create("/" + strings.Repeat("a", 256))
+	assert.True(t, errors.Is(err, syscall.ENAMETOOLONG))
+}
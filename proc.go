@@ -0,0 +1,200 @@
+package memfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// WithProc creates a /proc directory of files describing the filesystem, generated when
+// they are first read after being opened, like those of procfs:
+//
+//	/proc/fds    a line per open file: its descriptor, its access and its path
+//	/proc/stats  a "name value" line per statistic of the tree, its content and its files
+//
+// The files are read-only and have a size of 0, they are read to their end.
+func WithProc() Option {
+	return func(f *FS) {
+		f.special = append(f.special, (*FS).createProc)
+	}
+}
+
+// createProc creates the files of /proc, f must be the base filesystem.
+func (f *FS) createProc() {
+	f.createSpecial("/proc", "fds", 0444, procFile{generate: (*FS).procFDs})
+	f.createSpecial("/proc", "stats", 0444, procFile{generate: (*FS).procStats})
+}
+
+// procFile is a special file whose content is generated, for the filesystem it is opened
+// from, every time it is opened.
+type procFile struct {
+	generate func(f *FS) []byte
+}
+
+func (procFile) read([]byte) (int, error)  { return 0, io.EOF }
+func (procFile) write([]byte) (int, error) { return 0, os.ErrPermission }
+
+func (p procFile) open(f *FS, _ fileFlags) (device, error) {
+	return &procHandle{generate: func() []byte { return p.generate(f) }}, nil
+}
+
+// RegisterVirtualFile creates the read-only file at p, whose content is generated by gen
+// every time the file is opened, so files like /proc/meminfo, version files or dynamic
+// configurations can be simulated. Opening the file fails with the error gen returns, and
+// with an error wrapping os.ErrPermission for writing. The missing parent directories are
+// created, the file has a size of 0, like those created WithProc, and is read to its end.
+func (f *FS) RegisterVirtualFile(p string, gen func() ([]byte, error)) error {
+	if dir := path.Dir(f.toSlash(p)); dir != "." {
+		if err := f.MkdirAll(f.fromSlash(dir), 0755); err != nil {
+			return err
+		}
+	}
+	file, err := f.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0444)
+	if err != nil {
+		return err
+	}
+	file.node.setDevice(virtualFile{generate: gen})
+	return file.Close()
+}
+
+// virtualFile is a special file whose content is generated by the function registered
+// every time it is opened.
+type virtualFile struct {
+	generate func() ([]byte, error)
+}
+
+func (virtualFile) read([]byte) (int, error)  { return 0, io.EOF }
+func (virtualFile) write([]byte) (int, error) { return 0, os.ErrPermission }
+
+func (v virtualFile) open(_ *FS, flag fileFlags) (device, error) {
+	if flag.canWrite() {
+		return nil, os.ErrPermission
+	}
+	content, err := v.generate()
+	if err != nil {
+		return nil, err
+	}
+	return &procHandle{content: bytes.NewReader(content)}, nil
+}
+
+// procHandle reads the content generated for an open file, when it is first read.
+type procHandle struct {
+	generate func() []byte
+	content  *bytes.Reader
+}
+
+func (h *procHandle) reader() *bytes.Reader {
+	if h.content == nil {
+		h.content = bytes.NewReader(h.generate())
+	}
+	return h.content
+}
+
+func (h *procHandle) read(p []byte) (int, error) { return h.reader().Read(p) }
+func (h *procHandle) write([]byte) (int, error)  { return 0, os.ErrPermission }
+
+func (h *procHandle) ReadAt(p []byte, off int64) (int, error) {
+	return h.reader().ReadAt(p, off)
+}
+
+func (h *procHandle) Seek(offset int64, whence int) (int64, error) {
+	return h.reader().Seek(offset, whence)
+}
+
+// procFDs lists the open files, with the path of their entry in the tree, or its name
+// followed by "(deleted)" when the entry was removed.
+func (f *FS) procFDs() []byte {
+	files := f.openFiles()
+	paths := f.nodePaths()
+	var buf bytes.Buffer
+	for _, file := range files {
+		access := "r"
+		switch {
+		case file.flag.isReadWrite():
+			access = "rw"
+		case file.flag.isWriteOnly():
+			access = "w"
+		}
+		if file.flag.isAppend() {
+			access += "a"
+		}
+		p, exists := paths[file.node]
+		if !exists {
+			p = file.Name() + " (deleted)"
+		}
+		fmt.Fprintf(&buf, "%d %s %s\n", file.fd, access, p)
+	}
+	return buf.Bytes()
+}
+
+// nodePaths returns the paths of the nodes of the tree, with "/" separators.
+func (f *FS) nodePaths() map[*fsNode]string {
+	paths := map[*fsNode]string{f.root: "/"}
+	type dir struct {
+		node *fsNode
+		path string
+	}
+	stack := []dir{{node: f.root, path: ""}}
+	for len(stack) > 0 {
+		d := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d.node.lock()
+		children := d.node.entries.list()
+		d.node.unlock()
+		for _, c := range children {
+			p := d.path + "/" + c.name
+			paths[c] = p
+			if t := c.target(); t != c {
+				// the files of hard links have the path of one of them, unless named themselves
+				if _, named := paths[t]; !named {
+					paths[t] = p
+				}
+			}
+			if c.isDir() {
+				stack = append(stack, dir{node: c, path: p})
+			}
+		}
+	}
+	return paths
+}
+
+// procStats describes the tree, the memory Compact can reclaim and the content store.
+func (f *FS) procStats() []byte {
+	var files, dirs int
+	var size int64
+	for n := range f.nodePaths() {
+		if n.target() != n {
+			// counted with the file it names
+			continue
+		}
+		if n.isDir() {
+			dirs++
+			continue
+		}
+		files++
+		n.lockContent()
+		size += int64(n.size())
+		n.unlockContent()
+	}
+	reclaimable := f.Reclaimable()
+	lines := []string{
+		fmt.Sprintf("files %d", files),
+		fmt.Sprintf("directories %d", dirs),
+		fmt.Sprintf("bytes %d", size),
+		fmt.Sprintf("open %d", len(f.openFiles())),
+		fmt.Sprintf("reclaimable_bytes %d", reclaimable.Bytes()),
+	}
+	if f.store != nil {
+		stats := f.store.stats()
+		lines = append(lines,
+			fmt.Sprintf("store_blobs %d", stats.Blobs),
+			fmt.Sprintf("store_references %d", stats.References),
+			fmt.Sprintf("store_bytes %d", stats.StoredBytes),
+			fmt.Sprintf("store_logical_bytes %d", stats.LogicalBytes),
+		)
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
@@ -0,0 +1,113 @@
+package memfs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Proc(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/data/file": []byte("content")}, WithProc(), WithTempDir("/tmp"))
+	assert.Nil(t, err)
+
+	f, err := mfs.OpenFile("/data/file", os.O_RDWR|os.O_APPEND, 0)
+	assert.Nil(t, err)
+	defer f.Close()
+	removed, err := mfs.Create("/data/removed")
+	assert.Nil(t, err)
+	defer removed.Close()
+	assert.Nil(t, mfs.Remove("/data/removed"))
+
+	fds := readAll(t, mfs, "/proc/fds")
+	assert.Contains(t, fds, " rwa /data/file\n")
+	assert.Contains(t, fds, " rw /data/removed (deleted)\n")
+	assert.Contains(t, fds, " r /proc/fds\n")
+
+	stats := readAll(t, mfs, "/proc/stats")
+	assert.Contains(t, stats, "files 3\n") // /data/file and the files of /proc
+	assert.Contains(t, stats, "bytes 7\n")
+	assert.Contains(t, stats, "open 3\n")
+
+	// the content is generated at every open
+	assert.Nil(t, removed.Close())
+	assert.NotContains(t, readAll(t, mfs, "/proc/fds"), "removed")
+
+	fi, err := mfs.Stat("/proc/stats")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), fi.Size())
+	assert.Equal(t, fs.FileMode(0444), fi.Mode())
+
+	p, err := mfs.OpenFile("/proc/stats", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	_, err = p.Write([]byte("x"))
+	assert.True(t, errors.Is(err, fs.ErrPermission))
+	data := make([]byte, 5)
+	_, err = p.ReadAt(data, 6)
+	assert.Nil(t, err)
+	assert.Equal(t, "3\ndir", string(data))
+	_, err = p.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	all, err := io.ReadAll(p)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(all), "files 3\n"))
+	assert.Nil(t, p.Close())
+}
+
+func Test_RegisterVirtualFile(t *testing.T) {
+	mfs := New()
+	calls := 0
+	assert.Nil(t, mfs.RegisterVirtualFile("/proc/meminfo", func() ([]byte, error) {
+		calls++
+		return []byte(fmt.Sprintf("MemFree: %d kB\n", calls*1024)), nil
+	}))
+	assert.Equal(t, 0, calls)
+
+	// generated when opened, again for every open
+	f, err := mfs.Open("/proc/meminfo")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	data, err := io.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, "MemFree: 1024 kB\n", string(data))
+	assert.Nil(t, f.Close())
+	assert.Equal(t, "MemFree: 2048 kB\n", readAll(t, mfs, "/proc/meminfo"))
+	data, err = mfs.ReadFile("/proc/meminfo")
+	assert.Nil(t, err)
+	assert.Equal(t, "MemFree: 3072 kB\n", string(data))
+
+	fi, err := mfs.Stat("/proc/meminfo")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0444), fi.Mode())
+	_, err = mfs.OpenFile("/proc/meminfo", os.O_WRONLY, 0)
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	_, err = mfs.OpenFile("/proc/meminfo", os.O_RDWR|os.O_TRUNC, 0)
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.Equal(t, 3, calls)
+
+	// read through fs.ReadFile, and kept by checkpoints, namespaces and forks
+	data, err = fs.ReadFile(mfs.IOFS(), "proc/meminfo")
+	assert.Nil(t, err)
+	assert.Equal(t, "MemFree: 4096 kB\n", string(data))
+	mfs.Checkpoint()
+	assert.Nil(t, mfs.Undo())
+	assert.Equal(t, "MemFree: 5120 kB\n", readAll(t, mfs, "/proc/meminfo"))
+	assert.Equal(t, "MemFree: 6144 kB\n", readAll(t, mfs.TestNamespace(t), "/proc/meminfo"))
+	assert.Equal(t, "MemFree: 7168 kB\n", readAll(t, Template(mfs).Fork(), "/proc/meminfo"))
+
+	// the errors of the generator are returned by opens
+	broken := errors.New("broken")
+	assert.Nil(t, mfs.RegisterVirtualFile("/etc/version", func() ([]byte, error) {
+		return nil, broken
+	}))
+	_, err = mfs.Open("/etc/version")
+	assert.True(t, errors.Is(err, broken))
+
+	assert.True(t, errors.Is(mfs.RegisterVirtualFile("/proc/meminfo", nil), fs.ErrExist))
+	assert.Nil(t, mfs.WriteFile("/file", nil, 0644))
+	assert.NotNil(t, mfs.RegisterVirtualFile("/file/x", nil))
+}
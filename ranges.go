@@ -0,0 +1,145 @@
+package memfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// WithRangeTracking makes every file record the byte ranges written to it, for
+// WrittenRanges and WaitForRange, so code writing files out of order, as resumable
+// downloads do, can be tested. Writes through Map are not recorded.
+func WithRangeTracking() Option {
+	return func(f *FS) {
+		f.trackRanges = true
+	}
+}
+
+// ByteRange is a range of bytes of a file.
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
+func (r ByteRange) end() int64 {
+	return r.Offset + r.Length
+}
+
+// rangeTracker holds the ranges written to a file, sorted and merged.
+type rangeTracker struct {
+	mutex   sync.Mutex
+	ranges  []ByteRange
+	changed chan struct{} // closed, and replaced, when ranges are added
+}
+
+// rangeTracker returns the tracker of the node, nil when ranges are not tracked.
+func (f *FS) rangeTracker(n *fsNode) *rangeTracker {
+	if !f.baseFS().trackRanges || n.isDir() {
+		return nil
+	}
+	e := n.extension()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.ranges == nil {
+		e.ranges = &rangeTracker{changed: make(chan struct{})}
+	}
+	return e.ranges
+}
+
+// clone returns a copy of the tracker, for a copy of its file.
+func (t *rangeTracker) clone() *rangeTracker {
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return &rangeTracker{ranges: append([]ByteRange{}, t.ranges...), changed: make(chan struct{})}
+}
+
+// add records that n bytes were written at off.
+func (t *rangeTracker) add(off, n int64) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	r := ByteRange{Offset: off, Length: n}
+	// the ranges overlapping or touching r are merged into it
+	i := sort.Search(len(t.ranges), func(i int) bool { return t.ranges[i].end() >= r.Offset })
+	j := i
+	for ; j < len(t.ranges) && t.ranges[j].Offset <= r.end(); j++ {
+		start, end := min(r.Offset, t.ranges[j].Offset), max(r.end(), t.ranges[j].end())
+		r = ByteRange{Offset: start, Length: end - start}
+	}
+	t.ranges = append(t.ranges[:i], append([]ByteRange{r}, t.ranges[j:]...)...)
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// truncate forgets the ranges past size.
+func (t *rangeTracker) truncate(size int64) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	kept := t.ranges[:0]
+	for _, r := range t.ranges {
+		if r.Offset >= size {
+			break
+		}
+		r.Length = min(r.end(), size) - r.Offset
+		kept = append(kept, r)
+	}
+	t.ranges = kept
+}
+
+// covered returns whether [off, off+n) was written, with the channel closed at the next
+// write otherwise.
+func (t *rangeTracker) covered(off, n int64) (bool, <-chan struct{}) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	i := sort.Search(len(t.ranges), func(i int) bool { return t.ranges[i].end() > off })
+	if n == 0 || (i < len(t.ranges) && t.ranges[i].Offset <= off && t.ranges[i].end() >= off+n) {
+		return true, nil
+	}
+	return false, t.changed
+}
+
+// WrittenRanges returns the ranges of the file written since it was created or last
+// truncated, merged and in offset order. It returns nil when the filesystem was not
+// created WithRangeTracking.
+func (f *File) WrittenRanges() []ByteRange {
+	t := f.crws.ranges
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]ByteRange{}, t.ranges...)
+}
+
+// WaitForRange waits until the n bytes at off were written to the file, through any of
+// its handles, or until ctx is done, returning its error.
+func (f *File) WaitForRange(ctx context.Context, off, n int64) error {
+	t := f.crws.ranges
+	if t == nil {
+		return fmt.Errorf("ranges not tracked: %s: %w", f.Name(), os.ErrInvalid)
+	}
+	if off < 0 || n < 0 {
+		return fmt.Errorf("negative offset or length: %w", os.ErrInvalid)
+	}
+	for {
+		done, changed := t.covered(off, n)
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
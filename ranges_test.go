@@ -0,0 +1,78 @@
+package memfs
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_Range_Tracking(t *testing.T) {
+	mfs := New(WithRangeTracking())
+
+	w, err := mfs.Create("/download")
+	assert.Nil(t, err)
+	assert.Equal(t, []ByteRange{}, w.WrittenRanges())
+
+	_, err = w.WriteAt([]byte("world"), 6)
+	assert.Nil(t, err)
+	_, err = w.WriteAt([]byte("!"), 20)
+	assert.Nil(t, err)
+	assert.Equal(t, []ByteRange{{Offset: 6, Length: 5}, {Offset: 20, Length: 1}}, w.WrittenRanges())
+
+	// the ranges are shared by the handles of the file
+	r, err := mfs.Open("/download")
+	assert.Nil(t, err)
+	assert.Equal(t, w.WrittenRanges(), r.WrittenRanges())
+
+	done := make(chan error)
+	go func() {
+		done <- r.WaitForRange(context.Background(), 0, 11)
+	}()
+	select {
+	case <-done:
+		t.Fatal("range not written yet")
+	case <-time.After(10 * time.Millisecond):
+	}
+	_, err = w.Write([]byte("hello "))
+	assert.Nil(t, err)
+	assert.Nil(t, <-done)
+	assert.Equal(t, []ByteRange{{Offset: 0, Length: 11}, {Offset: 20, Length: 1}}, r.WrittenRanges())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, r.WaitForRange(ctx, 10, 5))
+
+	// truncating forgets the ranges past the size
+	assert.Nil(t, w.Truncate(8))
+	assert.Equal(t, []ByteRange{{Offset: 0, Length: 8}}, r.WrittenRanges())
+	assert.Nil(t, w.Close())
+
+	w, err = mfs.OpenFile("/download", os.O_WRONLY|os.O_TRUNC, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []ByteRange{}, r.WrittenRanges())
+	assert.Nil(t, w.Close())
+	assert.Nil(t, r.Close())
+
+	// copies sharing the content record it as written
+	src, err := mfs.Create("/src")
+	assert.Nil(t, err)
+	_, err = src.Write([]byte("content"))
+	assert.Nil(t, err)
+	_, err = src.Seek(0, 0)
+	assert.Nil(t, err)
+	dst, err := mfs.Create("/dst")
+	assert.Nil(t, err)
+	_, err = dst.ReadFrom(src)
+	assert.Nil(t, err)
+	assert.Equal(t, []ByteRange{{Offset: 0, Length: 7}}, dst.WrittenRanges())
+	assert.Nil(t, src.Close())
+	assert.Nil(t, dst.Close())
+
+	untracked, err := New().Create("/file")
+	assert.Nil(t, err)
+	assert.Nil(t, untracked.WrittenRanges())
+	assert.True(t, errors.Is(untracked.WaitForRange(context.Background(), 0, 1), os.ErrInvalid))
+}
@@ -0,0 +1,148 @@
+package memfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RetentionRule selects files of a directory and limits how long, or how many of them,
+// are kept. The limits of the zero fields are not enforced.
+type RetentionRule struct {
+	Dir       string        // the directory holding the files
+	Recursive bool          // the files beneath the subdirectories of Dir are selected as well
+	Pattern   string        // path.Match pattern the names of the files selected must match, any name when empty
+	MaxAge    time.Duration // files modified longer ago than MaxAge are removed
+	MaxFiles  int           // the files modified last are kept, the others removed
+}
+
+// Retention enforces retention rules on a filesystem, removing the files the rules don't
+// keep, with the ages measured with the clock of the filesystem. Files are removed with
+// Remove, so a filesystem created WithTrash moves them to its trash.
+type Retention struct {
+	fs    *FS
+	mutex sync.Mutex
+	rules []RetentionRule
+}
+
+// NewRetention returns a retention engine for f enforcing rules.
+func NewRetention(f *FS, rules ...RetentionRule) *Retention {
+	return &Retention{fs: f, rules: rules}
+}
+
+// AddRule registers another rule, enforced from the next call of Enforce.
+func (r *Retention) AddRule(rule RetentionRule) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.rules = append(r.rules, rule)
+}
+
+// retained is a file selected by a rule.
+type retained struct {
+	path     string
+	modified time.Time
+}
+
+// Enforce applies the rules once and returns the paths of the files removed, in path order.
+// A missing directory selects no file. Errors don't stop the enforcement, they are returned
+// joined after it.
+func (r *Retention) Enforce() (removed []string, err error) {
+	r.mutex.Lock()
+	rules := append([]RetentionRule(nil), r.rules...)
+	r.mutex.Unlock()
+
+	var errs []error
+	now := r.fs.now()
+	for _, rule := range rules {
+		files, err := r.selectFiles(rule.Dir, rule)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// newest first, so the files past MaxFiles are the oldest ones
+		sort.Slice(files, func(i, j int) bool {
+			if !files[i].modified.Equal(files[j].modified) {
+				return files[i].modified.After(files[j].modified)
+			}
+			return files[i].path < files[j].path
+		})
+		for i, file := range files {
+			expired := rule.MaxAge > 0 && now.Sub(file.modified) > rule.MaxAge
+			if !expired && (rule.MaxFiles <= 0 || i < rule.MaxFiles) {
+				continue
+			}
+			if err := r.fs.Remove(file.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+				continue
+			}
+			removed = append(removed, file.path)
+		}
+	}
+	sort.Strings(removed)
+	return removed, errors.Join(errs...)
+}
+
+// selectFiles returns the files of dir selected by the rule.
+func (r *Retention) selectFiles(dir string, rule RetentionRule) ([]retained, error) {
+	entries, err := r.fs.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []retained
+	for _, e := range entries {
+		p := r.fs.join(dir, e.Name())
+		if e.IsDir() {
+			if rule.Recursive {
+				sub, err := r.selectFiles(p, rule)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, sub...)
+			}
+			continue
+		}
+		if rule.Pattern != "" {
+			matched, err := path.Match(rule.Pattern, e.Name())
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern: %s: %w", rule.Pattern, err)
+			}
+			if !matched {
+				continue
+			}
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, retained{path: p, modified: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Run calls Enforce at every tick received until ctx is done or ticks is closed, ticks
+// being a time.Ticker channel, or one a test sends to as it advances the clock. onEnforce,
+// when not nil, is called with the result of every enforcement.
+func (r *Retention) Run(ctx context.Context, ticks <-chan time.Time, onEnforce func(removed []string, err error)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-ticks:
+			if !ok {
+				return nil
+			}
+			removed, err := r.Enforce()
+			if onEnforce != nil {
+				onEnforce(removed, err)
+			}
+		}
+	}
+}
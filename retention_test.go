@@ -0,0 +1,79 @@
+package memfs
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"sync"
+	"testing"
+	"time"
+)
+
+type manualClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *manualClock) advance(d time.Duration) time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+func Test_Retention(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &manualClock{now: start}
+	mfs, err := NewFromEntries(map[string]Entry{
+		"/cache/a.tmp":       {ModTime: start.Add(-30 * time.Hour)},
+		"/cache/deep/b.tmp":  {ModTime: start.Add(-25 * time.Hour)},
+		"/cache/deep/c.keep": {ModTime: start.Add(-25 * time.Hour)},
+		"/cache/d.tmp":       {ModTime: start.Add(-time.Hour)},
+		"/spool/1":           {ModTime: start.Add(-3 * time.Minute)},
+		"/spool/2":           {ModTime: start.Add(-2 * time.Minute)},
+		"/spool/3":           {ModTime: start.Add(-time.Minute)},
+	}, WithClock(clock))
+	assert.Nil(t, err)
+
+	r := NewRetention(mfs, RetentionRule{Dir: "/cache", Recursive: true, Pattern: "*.tmp", MaxAge: 24 * time.Hour})
+	r.AddRule(RetentionRule{Dir: "/spool", MaxFiles: 2})
+	r.AddRule(RetentionRule{Dir: "/missing", MaxFiles: 1})
+
+	removed, err := r.Enforce()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/cache/a.tmp", "/cache/deep/b.tmp", "/spool/1"}, removed)
+	_, err = mfs.Stat("/cache/deep/c.keep")
+	assert.Nil(t, err)
+
+	removed, err = r.Enforce()
+	assert.Nil(t, err)
+	assert.Empty(t, removed)
+
+	// enforced again at every tick
+	ticks := make(chan time.Time)
+	results := make(chan []string)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Run(ctx, ticks, func(removed []string, err error) {
+			assert.Nil(t, err)
+			results <- removed
+		})
+	}()
+	ticks <- clock.advance(24 * time.Hour)
+	assert.Equal(t, []string{"/cache/d.tmp"}, <-results)
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+
+	_, err = NewRetention(mfs, RetentionRule{Dir: "/spool", Pattern: "["}).Enforce()
+	assert.NotNil(t, err)
+	_, err = mfs.Stat("/cache/d.tmp")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
@@ -0,0 +1,175 @@
+package memfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+// Root gives access to the entries beneath a directory, like os.Root. Names are relative
+// to the directory and cannot refer to anything outside of it: absolute names and names
+// whose ".." elements climb above the directory are rejected. memfs has no symbolic
+// links, so these are the only ways out.
+type Root struct {
+	fs     *FS // a view with its tree rooted at the directory
+	name   string
+	closed bool
+}
+
+// OpenRoot opens the directory dir for access through a Root.
+func (f *FS) OpenRoot(dir string) (*Root, error) {
+	sub, err := f.Sub(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Root{fs: sub, name: dir}, nil
+}
+
+// Sub returns a view of the filesystem with its tree rooted at the directory dir, sharing
+// the entries, the open files and the options of f, so a component can be handed its own
+// directory while changes made through either are seen by the other. Unlike a Root, the
+// view resolves absolute paths, against dir, and ".." elements stop at dir.
+func (f *FS) Sub(dir string) (*FS, error) {
+	parentNode, entryNode, missingPath, err := f.getEntry(dir)
+	if err != nil {
+		return nil, err
+	}
+	if entryNode == nil && missingPath == "" {
+		// the root dir
+		entryNode = parentNode
+	}
+	if missingPath != "" {
+		return nil, fmt.Errorf("path does not exist: %s: %w", dir, os.ErrNotExist)
+	}
+	if !entryNode.isDir() {
+		return nil, fmt.Errorf("not a directory: %s: %w", dir, syscall.ENOTDIR)
+	}
+	if err = f.checkAccess(dir, entryNode, accessExecute); err != nil {
+		return nil, err
+	}
+	return f.view(entryNode), nil
+}
+
+// Name returns the name of the directory given to OpenRoot.
+func (r *Root) Name() string {
+	return r.name
+}
+
+// Close closes the root, further operations fail. Files opened through it stay open.
+func (r *Root) Close() error {
+	if r.closed {
+		return fmt.Errorf("root closed: %s: %w", r.name, os.ErrClosed)
+	}
+	r.closed = true
+	return nil
+}
+
+// path returns the path of name in the view of the root.
+func (r *Root) path(name string) (string, error) {
+	if r.closed {
+		return "", fmt.Errorf("root closed: %s: %w", r.name, os.ErrClosed)
+	}
+	if r.fs.root.unlinked {
+		return "", fmt.Errorf("root removed: %s: %w", r.name, os.ErrNotExist)
+	}
+	local := path.Clean(r.fs.toSlash(name))
+	if name == "" || strings.HasPrefix(local, "/") || !fs.ValidPath(local) {
+		return "", fmt.Errorf("path escapes from parent: %s: %w", name, os.ErrInvalid)
+	}
+	return "/" + local, nil
+}
+
+func (r *Root) Open(name string) (*File, error) {
+	return r.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (r *Root) Create(name string) (*File, error) {
+	return r.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (r *Root) OpenFile(name string, flag int, perm os.FileMode) (*File, error) {
+	p, err := r.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.fs.OpenFile(p, flag, perm)
+}
+
+// OpenRoot opens the directory name beneath the root as a Root.
+func (r *Root) OpenRoot(name string) (*Root, error) {
+	p, err := r.path(name)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := r.fs.OpenRoot(p)
+	if err != nil {
+		return nil, err
+	}
+	sub.name = r.fs.join(r.name, name)
+	return sub, nil
+}
+
+func (r *Root) Mkdir(name string, perm os.FileMode) error {
+	p, err := r.path(name)
+	if err != nil {
+		return err
+	}
+	return r.fs.Mkdir(p, perm)
+}
+
+func (r *Root) MkdirAll(name string, perm os.FileMode) error {
+	p, err := r.path(name)
+	if err != nil {
+		return err
+	}
+	return r.fs.MkdirAll(p, perm)
+}
+
+func (r *Root) Remove(name string) error {
+	p, err := r.path(name)
+	if err != nil {
+		return err
+	}
+	return r.fs.Remove(p)
+}
+
+func (r *Root) RemoveAll(name string) error {
+	p, err := r.path(name)
+	if err != nil {
+		return err
+	}
+	return r.fs.RemoveAll(p)
+}
+
+func (r *Root) Rename(oldname, newname string) error {
+	oldpath, err := r.path(oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := r.path(newname)
+	if err != nil {
+		return err
+	}
+	return r.fs.Rename(oldpath, newpath)
+}
+
+func (r *Root) Stat(name string) (FileInfo, error) {
+	p, err := r.path(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return r.fs.Stat(p)
+}
+
+// Lstat is the same as Stat, memfs has no symbolic links.
+func (r *Root) Lstat(name string) (FileInfo, error) {
+	return r.Stat(name)
+}
+
+// FS returns the tree beneath the root as an fs.FS.
+func (r *Root) FS() fs.FS {
+	return r.fs.IOFS()
+}
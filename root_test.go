@@ -0,0 +1,111 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func Test_OpenRoot(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/srv/app/config.json": []byte("{}"),
+		"/srv/secret":          []byte("secret"),
+	})
+	assert.Nil(t, err)
+
+	root, err := mfs.OpenRoot("/srv/app")
+	assert.Nil(t, err)
+	assert.Equal(t, "/srv/app", root.Name())
+
+	f, err := root.Open("config.json")
+	assert.Nil(t, err)
+	data, err := io.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", string(data))
+	assert.Nil(t, f.Close())
+
+	// names cannot leave the directory
+	for _, name := range []string{"../secret", "/srv/secret", "a/../../secret", ""} {
+		_, err = root.Open(name)
+		assert.True(t, errors.Is(err, os.ErrInvalid), name)
+	}
+	_, err = root.Create("../new")
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+	assert.True(t, errors.Is(root.Rename("config.json", "../config.json"), os.ErrInvalid))
+
+	// changes are made to the tree the root was opened from
+	assert.Nil(t, root.MkdirAll("data/cache", 0755))
+	f, err = root.Create("data/cache/x")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Nil(t, root.Rename("data/cache/x", "data/y"))
+	_, err = mfs.Stat("/srv/app/data/y")
+	assert.Nil(t, err)
+	fi, err := root.Stat("data/../data/y")
+	assert.Nil(t, err)
+	assert.Equal(t, "y", fi.Name())
+	assert.Nil(t, root.Mkdir("logs", 0700))
+	assert.Nil(t, root.Remove("logs"))
+	assert.True(t, errors.Is(root.Remove("."), syscall.EBUSY))
+
+	sub, err := root.OpenRoot("data")
+	assert.Nil(t, err)
+	assert.Equal(t, "/srv/app/data", sub.Name())
+	_, err = sub.Lstat("y")
+	assert.Nil(t, err)
+	_, err = sub.Stat("../config.json")
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+	data, err = fs.ReadFile(root.FS(), "config.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", string(data))
+
+	assert.Nil(t, root.RemoveAll("data"))
+	_, err = sub.Stat("y")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	assert.Nil(t, root.Close())
+	_, err = root.Open("config.json")
+	assert.True(t, errors.Is(err, os.ErrClosed))
+	assert.True(t, errors.Is(root.Close(), os.ErrClosed))
+
+	_, err = mfs.OpenRoot("/srv/secret")
+	assert.True(t, errors.Is(err, syscall.ENOTDIR))
+	_, err = mfs.OpenRoot("/missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func Test_Sub(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/srv/app/config.json": []byte("{}"),
+		"/srv/secret":          []byte("secret"),
+	})
+	assert.Nil(t, err)
+
+	sub, err := mfs.Sub("/srv/app")
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", readAll(t, sub, "/config.json"))
+
+	// paths resolve within the directory
+	_, err = sub.Stat("/../secret")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// changes are seen from both sides
+	assert.Nil(t, sub.WriteFile("/out", []byte("result"), 0644))
+	assert.Equal(t, "result", readAll(t, mfs, "/srv/app/out"))
+	assert.Nil(t, mfs.WriteFile("/srv/app/config.json", []byte(`{"a":1}`), 0644))
+	assert.Equal(t, `{"a":1}`, readAll(t, sub, "/config.json"))
+
+	nested, err := sub.Sub("/")
+	assert.Nil(t, err)
+	_, err = nested.Stat("/out")
+	assert.Nil(t, err)
+
+	_, err = mfs.Sub("/srv/secret")
+	assert.True(t, errors.Is(err, syscall.ENOTDIR))
+	_, err = mfs.Sub("/missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
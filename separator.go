@@ -0,0 +1,86 @@
+package memfs
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WithSeparator makes the filesystem use sep, '/' or '\\', as its path separator whatever
+// the OS the tests run on, so fixtures behave the same everywhere. As on Windows, '/' is
+// accepted as well when the separator is '\\'. The default is filepath.Separator.
+func WithSeparator(sep byte) Option {
+	return func(f *FS) {
+		f.separator = sep
+	}
+}
+
+// pathSeparator returns the separator of the paths given to and returned by the filesystem.
+func (f *FS) pathSeparator() byte {
+	if sep := f.baseFS().separator; sep != 0 {
+		return sep
+	}
+	return filepath.Separator
+}
+
+// toSlash returns p with its separators replaced by "/", the form paths are resolved in.
+func (f *FS) toSlash(p string) string {
+	if f.pathSeparator() == '\\' {
+		return strings.ReplaceAll(p, `\`, "/")
+	}
+	return p
+}
+
+// fromSlash returns p with the separator of the filesystem in place of "/".
+func (f *FS) fromSlash(p string) string {
+	if f.pathSeparator() == '\\' {
+		return strings.ReplaceAll(p, "/", `\`)
+	}
+	return p
+}
+
+// join returns the path of name in the directory dir, using the separator of the filesystem.
+func (f *FS) join(dir, name string) string {
+	return f.fromSlash(joinPath(f.toSlash(dir), f.toSlash(name)))
+}
+
+// getAbsolutePath returns the clean absolute form of p with "/" separators, relative
+// paths are resolved against the working directory of the process. The names are
+// normalized when the filesystem was created WithNameNormalization.
+func (f *FS) getAbsolutePath(p string) string {
+	p = f.normalize(f.toSlash(p))
+	if !strings.HasPrefix(p, "/") {
+		p = workingDir() + "/" + p
+	}
+	return path.Clean(p)
+}
+
+// workingDir returns the working directory of the process with "/" separators and
+// without a volume name.
+func workingDir() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "/"
+	}
+	wd = filepath.ToSlash(strings.TrimPrefix(wd, filepath.VolumeName(wd)))
+	if !strings.HasPrefix(wd, "/") {
+		wd = "/" + wd
+	}
+	return wd
+}
+
+// parentPath returns the directory of p, a path with "/" separators.
+func parentPath(p string) string {
+	return path.Dir(p)
+}
+
+// baseName returns the last element of p, a path with "/" separators.
+func baseName(p string) string {
+	return path.Base(p)
+}
+
+// joinPath returns the path of name in dir, a path with "/" separators.
+func joinPath(dir, name string) string {
+	return path.Join(dir, name)
+}
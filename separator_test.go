@@ -0,0 +1,52 @@
+package memfs
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_Windows_Separator(t *testing.T) {
+	mfs := New(WithSeparator('\\'))
+	assert.Nil(t, mfs.MkdirAll(`\app\conf`, 0755))
+	f, err := mfs.Create(`\app\conf\app.json`)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	// "/" is a separator as well
+	fi, err := mfs.Stat("/app/conf/app.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "app.json", fi.Name())
+	assert.Nil(t, mfs.Rename(`\app\conf\app.json`, `/app\app.json`))
+	_, err = mfs.Stat(`\app\app.json`)
+	assert.Nil(t, err)
+
+	assert.Equal(t, `\tmp`, mfs.TempDir())
+	dir, err := mfs.MkdirTemp("", "x")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(dir, `\tmp\x`))
+
+	var tree bytes.Buffer
+	assert.Nil(t, mfs.DumpTree(&tree, `\app`))
+	assert.Contains(t, tree.String(), ` \app\conf`+"\n")
+	assert.Contains(t, tree.String(), ` \app\app.json`+"\n")
+
+	var removed []string
+	assert.Nil(t, mfs.RemoveAllContext(context.Background(), `\app`, func(p RemoveProgress) {
+		removed = append(removed, p.Path)
+	}))
+	assert.Contains(t, removed, `\app\app.json`)
+}
+
+func Test_Slash_Separator(t *testing.T) {
+	mfs := New(WithSeparator('/'))
+	f, err := mfs.Create(`/a\b`)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, `/a\b`, f.Name())
+	_, err = mfs.Stat("/a/b")
+	assert.NotNil(t, err)
+	assert.Equal(t, "/tmp", mfs.TempDir())
+}
@@ -0,0 +1,133 @@
+package memfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"path"
+	"sort"
+)
+
+// Snapshot is a copy of the tree at the time it was taken. It shares file content with
+// the tree until either is modified, so taking one is cheap.
+type Snapshot struct {
+	root *fsNode
+}
+
+// Snapshot returns a copy of the tree as it is now, for ExportDelta to compare the tree with later.
+func (f *FS) Snapshot() *Snapshot {
+	return &Snapshot{root: f.root.clone()}
+}
+
+// Release drops the references the snapshot holds on shared content.
+func (s *Snapshot) Release() {
+	s.root.unlinkAll()
+}
+
+// ExportDelta writes the entries added or changed since the snapshot was taken to w as a
+// tar archive, named relative to the root like ExportTar names them, and returns the
+// names of the entries removed since, in name order. A removed directory is listed, but
+// not the entries beneath it. An entry changed when its content, mode, owner or
+// modification time did, or when a file was replaced by a directory or the other way
+// round, in which case it is listed as removed as well.
+func (f *FS) ExportDelta(w io.Writer, since *Snapshot) (removed []string, err error) {
+	tw := tar.NewWriter(w)
+	if err = f.exportDelta(tw, f.root, since.root, &removed); err != nil {
+		return nil, err
+	}
+	sort.Strings(removed)
+	return removed, tw.Close()
+}
+
+// deltaEntry is an entry of the tree, with its copy in the snapshot when it existed
+// then, waiting to be compared, or a directory whose entries are waiting to be.
+type deltaEntry struct {
+	cur, old *fsNode
+	name     string
+	depth    int
+	existed  bool
+	expand   bool
+}
+
+// exportDelta writes the entries beneath cur that differ from those beneath old, each
+// directory followed by its entries.
+func (f *FS) exportDelta(tw *tar.Writer, cur, old *fsNode, removed *[]string) error {
+	stack := []deltaEntry{{cur: cur, old: old, expand: true}}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if e.expand {
+			e.cur.lock()
+			children := e.cur.entries.list()
+			e.cur.unlock()
+			e.old.lock()
+			oldChildren := e.old.entries.list()
+			e.old.unlock()
+
+			previous := make(map[string]*fsNode, len(oldChildren))
+			for _, o := range oldChildren {
+				previous[o.name] = o
+			}
+			// pushed in reverse order, so they are compared in name order
+			for i := len(children) - 1; i >= 0; i-- {
+				o, existed := previous[children[i].name]
+				stack = append(stack, deltaEntry{cur: children[i], old: o, name: path.Join(e.name, children[i].name), depth: e.depth + 1, existed: existed})
+			}
+			for _, c := range children {
+				delete(previous, c.name)
+			}
+			for n := range previous {
+				*removed = append(*removed, path.Join(e.name, n))
+			}
+			continue
+		}
+
+		if err := f.checkDepth(e.name, e.depth); err != nil {
+			return err
+		}
+		if e.existed && e.old.isDir() != e.cur.isDir() {
+			*removed = append(*removed, e.name)
+			e.existed = false
+		}
+		if !e.existed || changedSince(e.cur.target(), e.old.target()) {
+			if err := writeTarNode(tw, e.cur, e.name, nil); err != nil {
+				return err
+			}
+		}
+		if e.cur.isDir() {
+			if !e.existed {
+				// everything beneath a new directory is new
+				e.old = &fsNode{entries: newDirEntries()}
+			}
+			e.expand = true
+			stack = append(stack, e)
+		}
+	}
+	return nil
+}
+
+// changedSince returns whether the node differs from its earlier copy old.
+func changedSince(n, old *fsNode) bool {
+	n.lockContent()
+	defer n.unlockContent()
+	old.lockContent()
+	defer old.unlockContent()
+	uid, gid := n.owner()
+	oldUID, oldGID := old.owner()
+	if n.perm != old.perm || uid != oldUID || gid != oldGID || !n.modified.Equal(old.modified) {
+		return true
+	}
+	if n.isDir() {
+		return false
+	}
+	content, oldContent := n.getContent(), old.getContent()
+	if len(content) != len(oldContent) {
+		return true
+	}
+	// content left untouched since the snapshot is still shared with it
+	if len(content) == 0 || &content[0] == &oldContent[0] {
+		return false
+	}
+	return !bytes.Equal(content, oldContent)
+}
@@ -0,0 +1,95 @@
+package memfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+)
+
+func tarNames(t *testing.T, archive []byte) map[string]string {
+	names := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return names
+		}
+		assert.Nil(t, err)
+		data, err := io.ReadAll(tr)
+		assert.Nil(t, err)
+		names[hdr.Name] = string(data)
+	}
+}
+
+func Test_ExportDelta(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/app/same":        []byte("same"),
+		"/app/changed":     []byte("before"),
+		"/app/rewritten":   []byte("identical"),
+		"/app/gone":        []byte("gone"),
+		"/app/old/a":       []byte("a"),
+		"/app/kind":        []byte("file"),
+		"/app/chmod":       []byte("chmod"),
+		"/app/sub/keep":    []byte("keep"),
+		"/app/sub/dropped": []byte("dropped"),
+	})
+	assert.Nil(t, err)
+	fi, err := mfs.Stat("/app/rewritten")
+	assert.Nil(t, err)
+	rewritten := fi.ModTime()
+	snap := mfs.Snapshot()
+	defer snap.Release()
+
+	write := func(name, data string) {
+		f, err := mfs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(data))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+	}
+	write("/app/changed", "after")
+	write("/app/rewritten", "identical")
+	// rewritten with the same content and modification time, it is unchanged
+	assert.Nil(t, mfs.Chtimes("/app/rewritten", rewritten, rewritten))
+	write("/app/added", "added")
+	assert.Nil(t, mfs.Remove("/app/gone"))
+	assert.Nil(t, mfs.RemoveAll("/app/old"))
+	assert.Nil(t, mfs.Remove("/app/kind"))
+	assert.Nil(t, mfs.MkdirAll("/app/kind/inner", 0755))
+	assert.Nil(t, mfs.MkdirAll("/app/new/deep", 0755))
+	write("/app/new/deep/file", "deep")
+	assert.Nil(t, mfs.Remove("/app/sub/dropped"))
+	f, err := mfs.Open("/app/chmod")
+	assert.Nil(t, err)
+	f.node.perm = 0600
+	assert.Nil(t, f.Close())
+
+	var archive bytes.Buffer
+	removed, err := mfs.ExportDelta(&archive, snap)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"app/gone", "app/kind", "app/old", "app/sub/dropped"}, removed)
+	// directories whose entries changed have a new modification time
+	assert.Equal(t, map[string]string{
+		"app/":              "",
+		"app/added":         "added",
+		"app/changed":       "after",
+		"app/chmod":         "chmod",
+		"app/kind/":         "",
+		"app/kind/inner/":   "",
+		"app/new/":          "",
+		"app/new/deep/":     "",
+		"app/new/deep/file": "deep",
+		"app/sub/":          "",
+	}, tarNames(t, archive.Bytes()))
+
+	// the snapshot itself is not changed by the export
+	archive.Reset()
+	removed, err = mfs.ExportDelta(&archive, mfs.Snapshot())
+	assert.Nil(t, err)
+	assert.Empty(t, removed)
+	assert.Empty(t, tarNames(t, archive.Bytes()))
+}
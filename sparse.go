@@ -0,0 +1,214 @@
+package memfs
+
+import (
+	"slices"
+	"sort"
+	"syscall"
+)
+
+// SeekData and SeekHole are the whences of Seek moving to the start of the data, or of the
+// hole, at or after the offset, like SEEK_DATA and SEEK_HOLE of lseek(2). Seeking fails
+// with syscall.ENXIO past the end of the file, which ends with an implicit hole.
+const (
+	SeekData = 3
+	SeekHole = 4
+)
+
+// WithSparseFiles makes the filesystem keep the holes of files, the ranges skipped by
+// writes past their end and added by truncates growing them, so Seek finds them with
+// SeekData and SeekHole and the SysInfo of files only counts their data in Blocks. The
+// holes take no memory, they read as zeros and writing to them allocates the bytes
+// written. Mapping a file, or writing to it through staged writes, fills its holes.
+// Without it files have no holes, like on filesystems which don't support them.
+func WithSparseFiles() Option {
+	return func(f *FS) {
+		f.keepHoles = true
+	}
+}
+
+// sparseContent is the content of a file with holes, the extents of its data sorted and
+// neither overlapping nor adjacent. The bytes between them, up to the size, are holes.
+type sparseContent struct {
+	size    int64
+	extents []extent
+}
+
+// extent is data of a sparse file, at its offset.
+type extent struct {
+	off  int64
+	data []byte
+}
+
+func (e extent) end() int64 {
+	return e.off + int64(len(e.data))
+}
+
+// sparseContent returns the content of the node when it has holes, nil otherwise, the
+// caller must hold the node lock.
+func (f *fsNode) sparseContent() *sparseContent {
+	if e := f.getExt(); e != nil {
+		return e.sparse
+	}
+	return nil
+}
+
+// makeSparse moves the content of the node into a single extent, when it has no holes
+// yet, and returns it, the caller must hold the node lock.
+func (f *fsNode) makeSparse() *sparseContent {
+	if s := f.sparseContent(); s != nil {
+		return s
+	}
+	data := f.content
+	if f.blob != nil {
+		data = append([]byte{}, f.content...)
+	}
+	s := &sparseContent{size: int64(len(data))}
+	if len(data) > 0 {
+		s.extents = []extent{{data: data}}
+	}
+	f.setContent(nil)
+	f.extension().sparse = s
+	return s
+}
+
+// clone returns a copy of the content, for a copy of its file.
+func (s *sparseContent) clone() *sparseContent {
+	if s == nil {
+		return nil
+	}
+	c := &sparseContent{size: s.size, extents: make([]extent, len(s.extents))}
+	for i, e := range s.extents {
+		c.extents[i] = extent{off: e.off, data: append([]byte{}, e.data...)}
+	}
+	return c
+}
+
+// read reads the content at off into p, the holes as zeros, and returns the number of
+// bytes read, fewer than p past the size.
+func (s *sparseContent) read(p []byte, off int64) int {
+	if off >= s.size {
+		return 0
+	}
+	p = p[:min(int64(len(p)), s.size-off)]
+	clear(p)
+	end := off + int64(len(p))
+	for i := s.find(off); i < len(s.extents) && s.extents[i].off < end; i++ {
+		e := s.extents[i]
+		if e.off >= off {
+			copy(p[e.off-off:], e.data)
+		} else {
+			copy(p, e.data[off-e.off:])
+		}
+	}
+	return len(p)
+}
+
+// find returns the index of the first extent ending after off.
+func (s *sparseContent) find(off int64) int {
+	return sort.Search(len(s.extents), func(i int) bool { return s.extents[i].end() > off })
+}
+
+// write writes p at off, the file growing past its size. The extents overlapping or
+// touching the bytes written are merged with them into one, grown in place when the
+// first has the capacity.
+func (s *sparseContent) write(p []byte, off int64) {
+	end := off + int64(len(p))
+	s.size = max(s.size, end)
+	if len(p) == 0 {
+		return
+	}
+	i := sort.Search(len(s.extents), func(i int) bool { return s.extents[i].end() >= off })
+	j := i
+	for j < len(s.extents) && s.extents[j].off <= end {
+		j++
+	}
+	merged := s.extents[i:j]
+	start, stop := off, end
+	if len(merged) > 0 {
+		start, stop = min(start, merged[0].off), max(stop, merged[len(merged)-1].end())
+	}
+
+	var data []byte
+	first := 0
+	if len(merged) > 0 && merged[0].off == start && int64(cap(merged[0].data)) >= stop-start {
+		data, first = merged[0].data[:stop-start], 1
+	} else {
+		capacity := stop - start
+		if len(merged) > 0 && merged[0].off == start {
+			// an extent written again past its end is grown like the content of files
+			capacity = max(capacity, 2*int64(cap(merged[0].data)))
+		}
+		data = make([]byte, stop-start, capacity)
+	}
+	for _, e := range merged[first:] {
+		copy(data[e.off-start:], e.data)
+	}
+	copy(data[off-start:], p)
+	s.extents = slices.Replace(s.extents, i, j, extent{off: start, data: data})
+}
+
+// truncate changes the size of the content, the bytes added are a hole.
+func (s *sparseContent) truncate(size int64) {
+	s.size = size
+	i := s.find(size)
+	if i < len(s.extents) && s.extents[i].off < size {
+		s.extents[i].data = s.extents[i].data[:size-s.extents[i].off]
+		i++
+	}
+	clear(s.extents[i:])
+	s.extents = s.extents[:i]
+}
+
+// dense returns a copy of the content, the holes filled with zeros.
+func (s *sparseContent) dense() []byte {
+	c := make([]byte, s.size)
+	for _, e := range s.extents {
+		copy(c[e.off:], e.data)
+	}
+	return c
+}
+
+// allocated returns the number of bytes of the data, the holes not counted.
+func (s *sparseContent) allocated() int64 {
+	var n int64
+	for _, e := range s.extents {
+		n += int64(len(e.data))
+	}
+	return n
+}
+
+// seekHole returns the offset of the data, or of the hole when hole is set, at or after
+// off in the content.
+func (s *sparseContent) seekHole(off int64, hole bool) (int64, error) {
+	if _, err := seekHole(off, s.size, hole); err != nil {
+		return 0, err
+	}
+	if i := s.find(off); i < len(s.extents) {
+		e := s.extents[i]
+		switch {
+		case e.off <= off && hole:
+			return e.end(), nil
+		case e.off <= off:
+			return off, nil
+		case !hole:
+			return e.off, nil
+		}
+	} else if !hole {
+		return 0, syscall.ENXIO
+	}
+	return off, nil
+}
+
+// seekHole returns the offset of the data, or of the hole when hole is set, at or after
+// off in content of size bytes without holes.
+func seekHole(off, size int64, hole bool) (int64, error) {
+	switch {
+	case off < 0:
+		return 0, syscall.EINVAL
+	case off >= size:
+		return 0, syscall.ENXIO
+	case hole:
+		return size, nil
+	}
+	return off, nil
+}
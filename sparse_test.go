@@ -0,0 +1,143 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func Test_Sparse_Files(t *testing.T) {
+	mfs := New(WithSparseFiles())
+	f, err := mfs.Create("/disk.img")
+	assert.Nil(t, err)
+
+	// data at 0, a hole, data at 4096, and a hole grown by truncating
+	_, err = f.Write([]byte("head"))
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte("tail"), 4096)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Truncate(8192))
+
+	seek := func(off int64, whence int) int64 {
+		pos, err := f.Seek(off, whence)
+		assert.Nil(t, err)
+		return pos
+	}
+	assert.Equal(t, int64(0), seek(0, SeekData))
+	assert.Equal(t, int64(4), seek(0, SeekHole))
+	assert.Equal(t, int64(4096), seek(4, SeekData))
+	assert.Equal(t, int64(4100), seek(4096, SeekHole))
+	assert.Equal(t, int64(5000), seek(5000, SeekHole))
+	_, err = f.Seek(5000, SeekData)
+	assert.True(t, errors.Is(err, syscall.ENXIO))
+	_, err = f.Seek(8192, SeekHole)
+	assert.True(t, errors.Is(err, syscall.ENXIO))
+
+	// the position moved to the data
+	seek(4, SeekData)
+	data := make([]byte, 4)
+	_, err = io.ReadFull(f, data)
+	assert.Nil(t, err)
+	assert.Equal(t, "tail", string(data))
+
+	fi, err := f.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8192), fi.Size())
+	assert.Equal(t, int64(1), fi.Sys().(*SysInfo).Blocks)
+
+	// writing fills the holes
+	_, err = f.WriteAt(make([]byte, 4092), 4)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4100), seek(0, SeekHole))
+	assert.Nil(t, f.Truncate(4098))
+	assert.Equal(t, int64(4098), seek(0, SeekHole))
+	assert.Nil(t, f.Close())
+
+	f, err = mfs.OpenFile("/disk.img", os.O_RDWR|os.O_TRUNC, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Truncate(100))
+	assert.Equal(t, int64(0), seek(0, SeekHole))
+	_, err = f.Seek(0, SeekData)
+	assert.True(t, errors.Is(err, syscall.ENXIO))
+	assert.Nil(t, f.Close())
+
+	// without holes, files are data up to their end
+	mfs = New()
+	f, err = mfs.Create("/file")
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte("x"), 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), seek(10, SeekData))
+	assert.Equal(t, int64(1001), seek(10, SeekHole))
+	fi, err = f.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), fi.Sys().(*SysInfo).Blocks)
+	assert.Nil(t, f.Close())
+}
+
+func Test_Sparse_Files_Extents(t *testing.T) {
+	mfs := New(WithSparseFiles())
+	f, err := mfs.Create("/disk.img")
+	assert.Nil(t, err)
+
+	// a 1GB file with 8 bytes of data takes the memory of its data only
+	_, err = f.WriteAt([]byte("head"), 0)
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte("tail"), 1<<30-4)
+	assert.Nil(t, err)
+	_, n, _, err := mfs.getEntry("/disk.img")
+	assert.Nil(t, err)
+	assert.Nil(t, n.content)
+	assert.Len(t, n.sparseContent().extents, 2)
+	fi, err := f.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1<<30), fi.Size())
+	assert.Equal(t, int64(1), fi.Sys().(*SysInfo).Blocks)
+
+	// the holes read as zeros
+	data := make([]byte, 12)
+	_, err = f.ReadAt(data, 1<<30-12)
+	assert.Nil(t, err)
+	assert.Equal(t, append(make([]byte, 8), "tail"...), data)
+	_, err = f.ReadAt(data, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, append([]byte("ad"), make([]byte, 10)...), data)
+
+	// sequential writes grow an extent, writes bridging extents merge them
+	for i := 0; i < 4; i++ {
+		_, err = f.WriteAt([]byte("abcd"), int64(4+4*i))
+		assert.Nil(t, err)
+	}
+	_, err = f.WriteAt([]byte("x"), 100)
+	assert.Nil(t, err)
+	assert.Len(t, n.sparseContent().extents, 3)
+	_, err = f.WriteAt(make([]byte, 90), 15)
+	assert.Nil(t, err)
+	assert.Len(t, n.sparseContent().extents, 2)
+	assert.Equal(t, int64(109), n.allocated())
+
+	// copies keep the holes
+	mfs.Checkpoint()
+	assert.Nil(t, mfs.Undo())
+	_, c, _, err := mfs.getEntry("/disk.img")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1<<30), int64(c.size()))
+	assert.Equal(t, int64(109), c.allocated())
+	assert.Nil(t, f.Close())
+
+	// truncating drops the data past the size, mapping fills the holes
+	f, err = mfs.OpenFile("/disk.img", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Truncate(50))
+	assert.Nil(t, f.Truncate(100))
+	assert.Equal(t, int64(50), c.allocated())
+	m, err := f.Map()
+	assert.Nil(t, err)
+	assert.Len(t, m, 100)
+	assert.Equal(t, "headabcd", string(m[:8]))
+	assert.Nil(t, c.sparseContent())
+	assert.Nil(t, f.Close())
+}
@@ -0,0 +1,119 @@
+package memfs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// contentStore is a content addressed store of file data that can be shared by
+// multiple filesystems, identical content is only held in memory once.
+type contentStore struct {
+	mutex sync.Mutex
+	blobs map[[sha256.Size]byte]*blob
+}
+
+// blob is file content shared by several nodes, it is copied before a node modifies it.
+type blob struct {
+	store *contentStore // nil when the content is shared by copies but not deduplicated
+	mutex *sync.Mutex   // guards refs, the store mutex for stored blobs
+	sum   [sha256.Size]byte
+	data  []byte
+	refs  int
+}
+
+// StoreStats describes the memory held by a shared content store.
+type StoreStats struct {
+	Blobs        int   // number of distinct contents held
+	References   int   // number of files referencing stored content
+	StoredBytes  int64 // bytes actually held in memory
+	LogicalBytes int64 // bytes the referencing files would hold without sharing
+}
+
+func newContentStore() *contentStore {
+	return &contentStore{blobs: make(map[[sha256.Size]byte]*blob)}
+}
+
+// intern returns the stored blob for data, adding it when it is not yet known.
+// The returned blob has a reference held for the caller.
+func (s *contentStore) intern(data []byte) *blob {
+	sum := sha256.Sum256(data)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if b, exists := s.blobs[sum]; exists {
+		b.refs++
+		return b
+	}
+
+	stored := make([]byte, len(data))
+	copy(stored, data)
+	b := &blob{store: s, mutex: &s.mutex, sum: sum, data: stored, refs: 1}
+	s.blobs[sum] = b
+	return b
+}
+
+// newBlob returns an unstored blob taking ownership of data, with a reference held for the caller.
+func newBlob(data []byte) *blob {
+	return &blob{mutex: new(sync.Mutex), data: data, refs: 1}
+}
+
+func (b *blob) retain() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refs++
+}
+
+func (b *blob) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.refs--
+	if b.refs <= 0 && b.store != nil {
+		delete(b.store.blobs, b.sum)
+	}
+}
+
+func (s *contentStore) stats() StoreStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var stats StoreStats
+	for _, b := range s.blobs {
+		stats.Blobs++
+		stats.References += b.refs
+		stats.StoredBytes += int64(len(b.data))
+		stats.LogicalBytes += int64(len(b.data)) * int64(b.refs)
+	}
+	return stats
+}
+
+// ContentHash returns the SHA-256 hash of the content of the file at path. The hash of
+// content held in a shared store is known already and is not computed again.
+func (f *FS) ContentHash(path string) ([sha256.Size]byte, error) {
+	_, entryNode, missingPath, err := f.getEntry(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	if missingPath != "" {
+		return [sha256.Size]byte{}, fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
+	}
+	if entryNode == nil || entryNode.isDir() {
+		return [sha256.Size]byte{}, fmt.Errorf("is a directory: %s: %w", path, syscall.EISDIR)
+	}
+	entryNode = entryNode.target()
+	if err = f.checkAccess(path, entryNode, accessRead); err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return entryNode.contentHash(), nil
+}
+
+func (f *fsNode) contentHash() [sha256.Size]byte {
+	f.lockContent()
+	defer f.unlockContent()
+	if f.blob != nil && f.blob.store != nil {
+		return f.blob.sum
+	}
+	return sha256.Sum256(f.getContent())
+}
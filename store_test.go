@@ -0,0 +1,42 @@
+package memfs
+
+import (
+	"crypto/sha256"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func Test_ContentHash(t *testing.T) {
+	m := NewManager()
+	mfs := m.Namespace("a")
+	plain, err := NewFromMap(map[string][]byte{"/f": []byte("content")})
+	assert.Nil(t, err)
+
+	f, err := mfs.Create("/f")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("content"))
+	assert.Nil(t, err)
+
+	expected := sha256.Sum256([]byte("content"))
+	sum, err := mfs.ContentHash("/f")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, sum)
+
+	// stored content reuses the hash of the store
+	assert.Nil(t, f.Close())
+	sum, err = mfs.ContentHash("/f")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, sum)
+
+	sum, err = plain.ContentHash("/f")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, sum)
+
+	_, err = plain.ContentHash("/tmp")
+	assert.True(t, errors.Is(err, syscall.EISDIR))
+	_, err = plain.ContentHash("/missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
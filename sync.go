@@ -0,0 +1,123 @@
+package memfs
+
+import (
+	"fmt"
+	"io/fs"
+	"sync"
+)
+
+// WithStagedWrites makes the writes to a file only visible to the other handles of the
+// file when the handle writing them is synced or closed, like writes held in a page cache
+// which a crash would lose, so code relying on Sync can be tested. Every handle opened for
+// writing works on a copy of the content, reads through it see its own writes. Stat and
+// the other handles report the content synced last. Handles opened with O_SYNC don't
+// stage their writes, and files with staged writes cannot be mapped.
+func WithStagedWrites() Option {
+	return func(f *FS) {
+		f.staged = true
+	}
+}
+
+// WithSyncHook makes Sync call hook once the writes of the file are synced, the error it
+// returns is returned by Sync, so failing syncs can be injected and synced content can be
+// persisted.
+func WithSyncHook(hook func(f *File) error) Option {
+	return func(f *FS) {
+		f.syncHook = hook
+	}
+}
+
+// Sync commits the writes to the file, which are only staged until then when the
+// filesystem was created WithStagedWrites, and calls the hook set WithSyncHook.
+func (f *File) Sync() (err error) {
+	defer f.fs.mapErrno(&err)
+	if f.closed {
+		return fmt.Errorf("file closed: %s: %w", f.Name(), fs.ErrClosed)
+	}
+	if f.staged != nil {
+		f.staged.commit()
+	}
+	if hook := f.fs.baseFS().syncHook; hook != nil {
+		return hook(f)
+	}
+	return nil
+}
+
+// stagedContent is the content a handle writes WithStagedWrites, until it is committed
+// to the node.
+type stagedContent struct {
+	node    *fsNode
+	mutex   sync.Mutex
+	content []byte
+	dirty   bool // written since last committed
+}
+
+// newStagedContent returns a copy of the content of the node, to be written by a handle.
+func newStagedContent(n *fsNode) *stagedContent {
+	n.lockContent()
+	defer n.unlockContent()
+	return &stagedContent{node: n, content: append([]byte{}, n.getContent()...)}
+}
+
+func (s *stagedContent) lockContent()   { s.mutex.Lock() }
+func (s *stagedContent) unlockContent() { s.mutex.Unlock() }
+func (s *stagedContent) size() int      { return len(s.content) }
+
+func (s *stagedContent) readAt(p []byte, off int) int {
+	if off >= len(s.content) {
+		return 0
+	}
+	return copy(p, s.content[off:])
+}
+
+// writeAt writes p at off, the staged content has no holes.
+func (s *stagedContent) writeAt(p []byte, off int, _ bool) {
+	if end := off + len(p); end > len(s.content) {
+		s.resizeContent(end)
+	}
+	s.dirty = true
+	copy(s.content[off:], p)
+}
+
+func (s *stagedContent) seekHole(off int64, hole bool) (int64, error) {
+	return seekHole(off, int64(len(s.content)), hole)
+}
+
+func (s *stagedContent) resizeContent(size int) {
+	s.dirty = true
+	if size <= cap(s.content) {
+		n := len(s.content)
+		s.content = s.content[:size]
+		clear(s.content[n:])
+		return
+	}
+	c := make([]byte, size, max(size, 2*cap(s.content)))
+	copy(c, s.content)
+	s.content = c
+}
+
+// truncate changes the length of the staged content to size, zero filled past the current end.
+func (s *stagedContent) truncate(size int) {
+	s.lockContent()
+	defer s.unlockContent()
+	if size > len(s.content) {
+		s.resizeContent(size)
+		return
+	}
+	s.dirty = true
+	s.content = s.content[:size]
+}
+
+// commit copies the staged content to the node, in place when the node is mapped.
+func (s *stagedContent) commit() {
+	s.lockContent()
+	defer s.unlockContent()
+	if !s.dirty {
+		return
+	}
+	s.node.lockContent()
+	s.node.truncateContent(len(s.content))
+	copy(s.node.getMutableContent(), s.content)
+	s.node.unlockContent()
+	s.dirty = false
+}
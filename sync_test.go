@@ -0,0 +1,89 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func Test_Staged_Writes(t *testing.T) {
+	var synced []string
+	mfs, err := NewFromMap(map[string][]byte{"/journal": []byte("old")}, WithStagedWrites(), WithSyncHook(func(f *File) error {
+		synced = append(synced, f.Name())
+		return nil
+	}))
+	assert.Nil(t, err)
+
+	w, err := mfs.OpenFile("/journal", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	_, err = w.WriteAt([]byte("new entry"), 0)
+	assert.Nil(t, err)
+
+	// the writer reads its writes, the others don't until synced
+	data := make([]byte, 9)
+	_, err = w.ReadAt(data, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "new entry", string(data))
+	assert.Equal(t, "old", readAll(t, mfs, "/journal"))
+	fi, err := mfs.Stat("/journal")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), fi.Size())
+
+	assert.Nil(t, w.Sync())
+	assert.Equal(t, []string{"/journal"}, synced)
+	assert.Equal(t, "new entry", readAll(t, mfs, "/journal"))
+
+	// truncating is staged as well, closing commits
+	assert.Nil(t, w.Truncate(3))
+	assert.Equal(t, "new entry", readAll(t, mfs, "/journal"))
+	_, err = w.Map()
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+	assert.Nil(t, w.Close())
+	assert.Equal(t, "new", readAll(t, mfs, "/journal"))
+	assert.Equal(t, []string{"/journal"}, synced)
+	assert.True(t, errors.Is(w.Sync(), os.ErrClosed))
+
+	// copies go through the staged content
+	src, err := mfs.OpenFile("/journal", os.O_RDWR, 0)
+	assert.Nil(t, err)
+	_, err = src.Write([]byte("NEW"))
+	assert.Nil(t, err)
+	_, err = src.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	dst, err := mfs.Create("/copy")
+	assert.Nil(t, err)
+	_, err = io.Copy(dst, src)
+	assert.Nil(t, err)
+	n, err := mfs.CopyRange(dst, 3, src, 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, "", readAll(t, mfs, "/copy"))
+	assert.Nil(t, dst.Close())
+	assert.Nil(t, src.Close())
+	assert.Equal(t, "NEWNE", readAll(t, mfs, "/copy"))
+
+	// files opened with O_SYNC don't stage their writes
+	w, err = mfs.OpenFile("/journal", os.O_WRONLY|os.O_SYNC, 0)
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("now"))
+	assert.Nil(t, err)
+	assert.Equal(t, "now", readAll(t, mfs, "/journal"))
+	assert.Nil(t, w.Close())
+}
+
+func Test_Sync_Hook_Error(t *testing.T) {
+	mfs := New(WithSyncHook(func(*File) error {
+		return syscall.EIO
+	}))
+	f, err := mfs.Create("/file")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("data"))
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(f.Sync(), syscall.EIO))
+	// without staged writes, the content is visible before it is synced
+	assert.Equal(t, "data", readAll(t, mfs, "/file"))
+	assert.Nil(t, f.Close())
+}
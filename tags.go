@@ -0,0 +1,334 @@
+package memfs
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tagIndex holds the tags of entries, indexed by key and value so that Query only looks
+// at the entries holding the tags selected. Entries are keyed by node, so their tags
+// follow them when they are renamed, and the path of every tagged entry is kept for
+// Query to return, updated by Rename. The paths are those of the view the entry was last
+// tagged or found through, views have their own roots, so Query looks the entries whose
+// path is not from its view up in its tree.
+type tagIndex struct {
+	mutex sync.Mutex
+	nodes map[*fsNode]*nodeTags
+	index map[string]map[string]map[*fsNode]struct{} // key, value, entries
+}
+
+type nodeTags struct {
+	root *fsNode // of the view path is from
+	path string  // absolute, with "/" separators
+	tags map[string]string
+}
+
+func (f *FS) tagIndex() *tagIndex {
+	base := f.baseFS()
+	base.lock()
+	defer base.unlock()
+	if base.tags == nil {
+		base.tags = &tagIndex{
+			nodes: make(map[*fsNode]*nodeTags),
+			index: make(map[string]map[string]map[*fsNode]struct{}),
+		}
+	}
+	return base.tags
+}
+
+// SetTag tags the entry at path with key and value, replacing the value it had for key.
+func (f *FS) SetTag(path, key, value string) error {
+	if key == "" || strings.ContainsAny(key, "=,") {
+		return fmt.Errorf("invalid tag key: %q: %w", key, os.ErrInvalid)
+	}
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return err
+	}
+	t := f.tagIndex()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	nt := t.nodes[n]
+	if nt == nil {
+		nt = &nodeTags{tags: make(map[string]string)}
+		t.nodes[n] = nt
+	}
+	nt.root, nt.path = f.root, f.getAbsolutePath(path)
+	if old, exists := nt.tags[key]; exists {
+		t.unindex(n, key, old)
+	}
+	nt.tags[key] = value
+	values := t.index[key]
+	if values == nil {
+		values = make(map[string]map[*fsNode]struct{})
+		t.index[key] = values
+	}
+	if values[value] == nil {
+		values[value] = make(map[*fsNode]struct{})
+	}
+	values[value][n] = struct{}{}
+	return nil
+}
+
+// RemoveTag removes the tag key from the entry at path, if it has one.
+func (f *FS) RemoveTag(path, key string) error {
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return err
+	}
+	t := f.tagIndex()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if nt := t.nodes[n]; nt != nil {
+		if value, exists := nt.tags[key]; exists {
+			delete(nt.tags, key)
+			t.unindex(n, key, value)
+			if len(nt.tags) == 0 {
+				delete(t.nodes, n)
+			}
+		}
+	}
+	return nil
+}
+
+// Tags returns a copy of the tags of the entry at path.
+func (f *FS) Tags(path string) (map[string]string, error) {
+	n, err := f.nodeAt(path)
+	if err != nil {
+		return nil, err
+	}
+	t := f.tagIndex()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tags := make(map[string]string)
+	if nt := t.nodes[n]; nt != nil {
+		maps.Copy(tags, nt.tags)
+	}
+	return tags, nil
+}
+
+// unindex drops the entry from the index of key and value, the caller must hold the index lock.
+func (t *tagIndex) unindex(n *fsNode, key, value string) {
+	entries := t.index[key][value]
+	delete(entries, n)
+	if len(entries) == 0 {
+		delete(t.index[key], value)
+		if len(t.index[key]) == 0 {
+			delete(t.index, key)
+		}
+	}
+}
+
+// drop removes all the tags of the entry, the caller must hold the index lock.
+func (t *tagIndex) drop(n *fsNode) {
+	if nt := t.nodes[n]; nt != nil {
+		for key, value := range nt.tags {
+			t.unindex(n, key, value)
+		}
+		delete(t.nodes, n)
+	}
+}
+
+// rename updates the paths of the tagged entries at oldpath and beneath it, both absolute
+// in the view of root.
+func (t *tagIndex) rename(root *fsNode, oldpath, newpath string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, nt := range t.nodes {
+		if nt.root != root {
+			continue
+		}
+		if nt.path == oldpath {
+			nt.path = newpath
+		} else if rest, ok := strings.CutPrefix(nt.path, oldpath+"/"); ok {
+			nt.path = newpath + "/" + rest
+		}
+	}
+}
+
+// exchange swaps the paths of the entries at or beneath a and b, neither containing the
+// other, both absolute in the view of root.
+func (t *tagIndex) exchange(root *fsNode, a, b string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, nt := range t.nodes {
+		if nt.root != root {
+			continue
+		}
+		if nt.path == a {
+			nt.path = b
+		} else if nt.path == b {
+			nt.path = a
+		} else if rest, ok := strings.CutPrefix(nt.path, a+"/"); ok {
+			nt.path = b + "/" + rest
+		} else if rest, ok := strings.CutPrefix(nt.path, b+"/"); ok {
+			nt.path = a + "/" + rest
+		}
+	}
+}
+
+// Query returns the paths of the entries with the tags the selector selects, in path
+// order. The selector is a comma separated list of "key=value", selecting the entries
+// tagged with key and value, and of "key", selecting the entries tagged with key, an
+// entry must match all of them. Removed entries are not returned, and lose their tags.
+func (f *FS) Query(selector string) ([]string, error) {
+	type term struct {
+		key, value string
+		any        bool
+	}
+	var terms []term
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		key, value, hasValue := strings.Cut(part, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid selector: %q: %w", selector, os.ErrInvalid)
+		}
+		terms = append(terms, term{key: key, value: value, any: !hasValue})
+	}
+
+	t := f.tagIndex()
+	t.mutex.Lock()
+	// the entries of the term selecting the fewest are checked against the other terms
+	var candidates map[*fsNode]struct{}
+	for i, tm := range terms {
+		var selected map[*fsNode]struct{}
+		if tm.any {
+			selected = make(map[*fsNode]struct{})
+			for _, entries := range t.index[tm.key] {
+				maps.Copy(selected, entries)
+			}
+		} else {
+			selected = t.index[tm.key][tm.value]
+		}
+		if i == 0 || len(selected) < len(candidates) {
+			candidates = selected
+		}
+	}
+	type match struct {
+		node *fsNode
+		root *fsNode
+		path string
+	}
+	var matches []match
+	for n := range candidates {
+		nt := t.nodes[n]
+		selected := true
+		for _, tm := range terms {
+			if value, exists := nt.tags[tm.key]; !exists || (!tm.any && value != tm.value) {
+				selected = false
+				break
+			}
+		}
+		if selected {
+			matches = append(matches, match{node: n, root: nt.root, path: nt.path})
+		}
+	}
+	t.mutex.Unlock()
+
+	var removed []*fsNode
+	var lost map[*fsNode]bool // the entries whose path is not from the view, or is stale
+	result := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if m.node.unlinked {
+			removed = append(removed, m.node)
+			continue
+		}
+		if m.root == f.root {
+			if n, err := f.nodeAt(m.path); err == nil && n == m.node {
+				result = append(result, f.fromSlash(m.path))
+				continue
+			}
+		}
+		if lost == nil {
+			lost = make(map[*fsNode]bool)
+		}
+		lost[m.node] = true
+	}
+	var found map[*fsNode]string
+	if len(lost) > 0 {
+		found = f.findPaths(lost)
+		for _, p := range found {
+			result = append(result, f.fromSlash(p))
+		}
+	}
+	if len(removed) > 0 || len(found) > 0 {
+		t.mutex.Lock()
+		for _, n := range removed {
+			t.drop(n)
+		}
+		for n, p := range found {
+			if nt := t.nodes[n]; nt != nil {
+				nt.root, nt.path = f.root, p
+			}
+		}
+		t.mutex.Unlock()
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// move moves the tags of the entry from to the entry to, they are dropped when to has
+// tags already, the caller must hold the index lock.
+func (t *tagIndex) move(from, to *fsNode) {
+	nt := t.nodes[from]
+	if nt == nil {
+		return
+	}
+	if t.nodes[to] != nil {
+		t.drop(from)
+		return
+	}
+	for key, value := range nt.tags {
+		entries := t.index[key][value]
+		delete(entries, from)
+		entries[to] = struct{}{}
+	}
+	delete(t.nodes, from)
+	t.nodes[to] = nt
+}
+
+// findPaths returns the paths of the nodes of nodes found in the tree of the view, files
+// with hard links at the path of one of their names.
+func (f *FS) findPaths(nodes map[*fsNode]bool) map[*fsNode]string {
+	found := make(map[*fsNode]string)
+	type dir struct {
+		node *fsNode
+		path string
+	}
+	stack := []dir{{node: f.root, path: ""}}
+	for len(stack) > 0 && len(found) < len(nodes) {
+		d := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d.node.lock()
+		children := d.node.entries.list()
+		d.node.unlock()
+		for _, c := range children {
+			p := d.path + "/" + c.name
+			if t := c.target(); nodes[t] && found[t] == "" {
+				found[t] = p
+			}
+			if c.isDir() {
+				stack = append(stack, dir{node: c, path: p})
+			}
+		}
+	}
+	return found
+}
+
+// dropTags drops the tags of the node, once it is unlinked.
+func (f *FS) dropTags(n *fsNode) {
+	base := f.baseFS()
+	base.lock()
+	t := base.tags
+	base.unlock()
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.drop(n)
+}
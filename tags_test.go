@@ -0,0 +1,124 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"testing"
+)
+
+func Test_Tags(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/assets/logo.png":  nil,
+		"/assets/icon.png":  nil,
+		"/assets/style.css": nil,
+	}, WithSeparator('/'))
+	assert.Nil(t, err)
+
+	assert.Nil(t, mfs.SetTag("/assets/logo.png", "kind", "image"))
+	assert.Nil(t, mfs.SetTag("/assets/logo.png", "stage", "built"))
+	assert.Nil(t, mfs.SetTag("/assets/icon.png", "kind", "image"))
+	assert.Nil(t, mfs.SetTag("/assets/style.css", "kind", "style"))
+	assert.Nil(t, mfs.SetTag("/assets", "owner", "web"))
+	assert.True(t, errors.Is(mfs.SetTag("/missing", "kind", "image"), fs.ErrNotExist))
+	assert.True(t, errors.Is(mfs.SetTag("/assets", "a=b", "c"), fs.ErrInvalid))
+
+	paths, err := mfs.Query("kind=image")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/assets/icon.png", "/assets/logo.png"}, paths)
+	paths, err = mfs.Query("kind=image,stage")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/assets/logo.png"}, paths)
+	paths, err = mfs.Query("kind")
+	assert.Nil(t, err)
+	assert.Len(t, paths, 3)
+	paths, err = mfs.Query("kind=missing,stage")
+	assert.Nil(t, err)
+	assert.Empty(t, paths)
+	_, err = mfs.Query("kind,")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	tags, err := mfs.Tags("/assets/logo.png")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"kind": "image", "stage": "built"}, tags)
+
+	// a new value replaces the old one in the index
+	assert.Nil(t, mfs.SetTag("/assets/icon.png", "kind", "icon"))
+	paths, err = mfs.Query("kind=image")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/assets/logo.png"}, paths)
+	assert.Nil(t, mfs.RemoveTag("/assets/logo.png", "stage"))
+	paths, err = mfs.Query("stage")
+	assert.Nil(t, err)
+	assert.Empty(t, paths)
+
+	// tags follow renamed entries, and are dropped with removed ones
+	assert.Nil(t, mfs.Rename("/assets", "/static"))
+	paths, err = mfs.Query("owner=web")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/static"}, paths)
+	paths, err = mfs.Query("kind=image")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/static/logo.png"}, paths)
+	assert.Nil(t, mfs.Remove("/static/logo.png"))
+	paths, err = mfs.Query("kind=image")
+	assert.Nil(t, err)
+	assert.Empty(t, paths)
+	assert.Empty(t, mfs.tags.index["kind"]["image"])
+}
+
+func Test_Tags_Views(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/site/assets/logo.png": nil, "/site/index.html": nil}, WithSeparator('/'))
+	assert.Nil(t, err)
+	site, err := mfs.Sub("/site")
+	assert.Nil(t, err)
+
+	// the entries tagged through a view are found through the others
+	assert.Nil(t, site.SetTag("/assets/logo.png", "kind", "image"))
+	assert.Nil(t, mfs.SetTag("/site/index.html", "kind", "page"))
+	paths, err := mfs.Query("kind")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/site/assets/logo.png", "/site/index.html"}, paths)
+	paths, err = site.Query("kind")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/assets/logo.png", "/index.html"}, paths)
+
+	// renames through a view move the paths of its entries only
+	assert.Nil(t, site.Rename("/assets", "/static"))
+	assert.Nil(t, mfs.Rename("/site/index.html", "/site/home.html"))
+	paths, err = site.Query("kind")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/home.html", "/static/logo.png"}, paths)
+	paths, err = mfs.Query("kind")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/site/home.html", "/site/static/logo.png"}, paths)
+	assert.Nil(t, mfs.RenameExchange("/site/home.html", "/site/static"))
+	paths, err = mfs.Query("kind=image")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/site/home.html/logo.png"}, paths)
+
+	// the entries outside of a view are not found through it
+	assert.Nil(t, mfs.WriteFile("/other", nil, 0644))
+	assert.Nil(t, mfs.SetTag("/other", "kind", "other"))
+	paths, err = site.Query("kind=other")
+	assert.Nil(t, err)
+	assert.Empty(t, paths)
+}
+
+func Test_Tags_Removed(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{"/a": nil, "/b": nil, "/dir/c": nil, "/d": nil}, WithSeparator('/'))
+	assert.Nil(t, err)
+	for _, p := range []string{"/a", "/b", "/dir/c", "/dir"} {
+		assert.Nil(t, mfs.SetTag(p, "kind", "x"))
+	}
+	assert.Nil(t, mfs.Link("/a", "/e"))
+
+	// the tags of removed entries are dropped without waiting for a query
+	assert.Nil(t, mfs.Remove("/b"))
+	assert.Nil(t, mfs.RemoveAll("/dir"))
+	assert.Nil(t, mfs.Rename("/d", "/a"))
+	assert.Len(t, mfs.tags.nodes, 1)
+	assert.Nil(t, mfs.Remove("/e"))
+	assert.Empty(t, mfs.tags.nodes)
+	assert.Empty(t, mfs.tags.index)
+}
@@ -0,0 +1,203 @@
+package memfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// ExportTar writes root and everything beneath it to w as a tar archive, with entry
+// names relative to root. The hard links to a file written before them, and the files
+// sharing their content with it while they also share its mode, owner and modification
+// time, are written as hard links to it.
+func (f *FS) ExportTar(w io.Writer, root string) error {
+	_, entryNode, missingPath, err := f.getEntry(root)
+	if err != nil {
+		return err
+	}
+	if missingPath != "" {
+		return fmt.Errorf("path does not exist: %s: %w", root, os.ErrNotExist)
+	}
+	if entryNode == nil {
+		entryNode = f.root
+	}
+
+	tw := tar.NewWriter(w)
+	links := make(map[any]*tar.Header)
+	if entryNode.isDir() {
+		if err = f.exportTarTree(tw, entryNode, links); err != nil {
+			return err
+		}
+	} else if err = writeTarNode(tw, entryNode, entryNode.name, links); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// tarEntry is an entry of a directory waiting to be exported.
+type tarEntry struct {
+	parent *fsNode
+	name   string
+	depth  int
+}
+
+// exportTarTree writes the entries beneath dir, each directory followed by its entries.
+func (f *FS) exportTarTree(tw *tar.Writer, dir *fsNode, links map[any]*tar.Header) error {
+	var stack []tarEntry
+	// entries are pushed in reverse order, so they are popped in name order
+	push := func(parent *fsNode, name string, depth int) {
+		names := parent.getEntryNames()
+		for i := len(names) - 1; i >= 0; i-- {
+			stack = append(stack, tarEntry{parent: parent, name: path.Join(name, names[i]), depth: depth})
+		}
+	}
+	push(dir, "", 1)
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		e.parent.lock()
+		n, exists := e.parent.entries.get(path.Base(e.name))
+		e.parent.unlock()
+		if !exists {
+			continue
+		}
+		if err := f.checkDepth(e.name, e.depth); err != nil {
+			return err
+		}
+		if err := writeTarNode(tw, n, e.name, links); err != nil {
+			return err
+		}
+		if n.isDir() {
+			push(n, e.name, e.depth+1)
+		}
+	}
+	return nil
+}
+
+// writeTarNode writes the entry of a node, the file it names for a hard link. With links
+// set, a hard link to a file written before it, or a file sharing its content, is written
+// as a hard link to it, links maps the files with hard links and the shared content to
+// the header of the first file.
+func writeTarNode(tw *tar.Writer, n *fsNode, name string, links map[any]*tar.Header) error {
+	n = n.target()
+	// the lock is held while the content is written, as content buffers are reused once a file outgrows them
+	n.lock()
+	defer n.unlock()
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(n.perm.Perm()),
+		ModTime: n.modified,
+	}
+	hdr.Uid, hdr.Gid = n.owner()
+	var content []byte
+	var key any
+	if n.names() > 1 {
+		key = n
+	} else if n.blob != nil {
+		key = n.blob
+	}
+	if n.isDir() {
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	} else if first, linked := links[key]; linked && key != nil && first.Mode == hdr.Mode &&
+		first.Uid == hdr.Uid && first.Gid == hdr.Gid && first.ModTime.Equal(hdr.ModTime) {
+		hdr.Typeflag = tar.TypeLink
+		hdr.Linkname = first.Name
+	} else {
+		hdr.Typeflag = tar.TypeReg
+		content = n.getContent()
+		hdr.Size = int64(len(content))
+		if links != nil && key != nil && !linked {
+			links[key] = hdr
+		}
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// ImportTar extracts the tar archive read from r into dir, creating dir if needed.
+// Directories, regular files and hard links are supported, entries keep the mode, owner
+// and modification time recorded in the archive. A hard link replaces the file at its
+// path, if any, with a link to its target, so both keep the mode, owner and modification
+// time of the target.
+func (f *FS) ImportTar(r io.Reader, dir string) error {
+	if err := f.MkdirAll(dir, fs.ModePerm); err != nil {
+		return err
+	}
+	dir = f.getAbsolutePath(dir)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// cleaning the name as an absolute path keeps entries from escaping dir
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		target := f.join(dir, name)
+		perm := fs.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = f.MkdirAll(target, perm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = f.MkdirAll(parentPath(f.getAbsolutePath(target)), fs.ModePerm); err != nil {
+				return err
+			}
+			file, err := f.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			if err = f.MkdirAll(parentPath(f.getAbsolutePath(target)), fs.ModePerm); err != nil {
+				return err
+			}
+			linkTarget := f.join(dir, path.Clean("/"+hdr.Linkname))
+			if fi, err := f.Stat(target); err == nil && !fi.IsDir() {
+				if err = f.Remove(target); err != nil {
+					return err
+				}
+			}
+			if err = f.Link(linkTarget, target); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("unsupported tar entry type %q: %s: %w", hdr.Typeflag, hdr.Name, os.ErrInvalid)
+		}
+
+		_, n, _, err := f.getEntry(target)
+		if err != nil {
+			return err
+		}
+		n.lock()
+		n.perm = perm
+		n.setOwner(hdr.Uid, hdr.Gid)
+		n.modified = hdr.ModTime
+		n.unlock()
+	}
+}
@@ -0,0 +1,118 @@
+package memfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Tar_Round_Trip(t *testing.T) {
+	src := New(WithClock(NewStepClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Second)))
+	assert.Nil(t, src.MkdirAll("/export/a/b", 0750))
+	f, err := src.OpenFile("/export/a/file", os.O_RDWR|os.O_CREATE, 0640)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(`file content`))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	var archive bytes.Buffer
+	assert.Nil(t, src.ExportTar(&archive, "/export"))
+
+	dst := New()
+	assert.Nil(t, dst.ImportTar(bytes.NewReader(archive.Bytes()), "/import"))
+
+	var srcTree, dstTree strings.Builder
+	assert.Nil(t, src.DumpTree(&srcTree, "/export/a"))
+	assert.Nil(t, dst.DumpTree(&dstTree, "/import/a"))
+	assert.Equal(t, strings.ReplaceAll(srcTree.String(), "/export", "/import"), dstTree.String())
+
+	srcInfo, err := src.Stat("/export/a/file")
+	assert.Nil(t, err)
+	dstInfo, err := dst.Stat("/import/a/file")
+	assert.Nil(t, err)
+	assert.True(t, srcInfo.ModTime().Equal(dstInfo.ModTime()))
+
+	err = dst.ExportTar(&archive, "/missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func Test_ImportTar_Stays_In_Dir(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	assert.Nil(t, tw.WriteHeader(&tar.Header{Name: "../../escaped", Typeflag: tar.TypeReg, Mode: 0644, Size: 1}))
+	_, err := tw.Write([]byte(`x`))
+	assert.Nil(t, err)
+	assert.Nil(t, tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "escaped"}))
+	assert.Nil(t, tw.Close())
+
+	mfs := New()
+	err = mfs.ImportTar(bytes.NewReader(archive.Bytes()), "/dir")
+	assert.True(t, errors.Is(err, os.ErrInvalid))
+
+	_, err = mfs.Stat("/dir/escaped")
+	assert.Nil(t, err)
+	_, err = mfs.Stat("/escaped")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func Test_Tar_Hard_Links(t *testing.T) {
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	assert.Nil(t, tw.WriteHeader(&tar.Header{Name: "bin/tool", Typeflag: tar.TypeReg, Mode: 0755, Uid: 1, ModTime: modified, Size: 4}))
+	_, err := tw.Write([]byte(`tool`))
+	assert.Nil(t, err)
+	assert.Nil(t, tw.WriteHeader(&tar.Header{Name: "bin/alias", Typeflag: tar.TypeLink, Linkname: "../../bin/tool"}))
+	assert.Nil(t, tw.Close())
+
+	mfs := New()
+	assert.Nil(t, mfs.ImportTar(bytes.NewReader(archive.Bytes()), "/img"))
+	assert.Equal(t, "tool", readAll(t, mfs, "/img/bin/alias"))
+	info, err := mfs.Stat("/img/bin/alias")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode())
+	assert.True(t, modified.Equal(info.ModTime()))
+
+	tool, err := mfs.Open("/img/bin/tool")
+	assert.Nil(t, err)
+	alias, err := mfs.Open("/img/bin/alias")
+	assert.Nil(t, err)
+	assert.Same(t, tool.node, alias.node)
+	assert.Nil(t, tool.Close())
+	assert.Nil(t, alias.Close())
+
+	// links are written again on export, to the file written first
+	archive.Reset()
+	assert.Nil(t, mfs.ExportTar(&archive, "/img"))
+	tr := tar.NewReader(&archive)
+	var headers []*tar.Header
+	for hdr, err := tr.Next(); err == nil; hdr, err = tr.Next() {
+		headers = append(headers, hdr)
+	}
+	assert.Len(t, headers, 3)
+	assert.Equal(t, "bin/alias", headers[1].Name)
+	assert.Equal(t, byte(tar.TypeReg), headers[1].Typeflag)
+	assert.Equal(t, "bin/tool", headers[2].Name)
+	assert.Equal(t, byte(tar.TypeLink), headers[2].Typeflag)
+	assert.Equal(t, "bin/alias", headers[2].Linkname)
+
+	// both names are the same file
+	f, err := mfs.OpenFile("/img/bin/alias", os.O_WRONLY|os.O_TRUNC, 0)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(`other`))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Equal(t, "other", readAll(t, mfs, "/img/bin/tool"))
+
+	archive.Reset()
+	tw = tar.NewWriter(&archive)
+	assert.Nil(t, tw.WriteHeader(&tar.Header{Name: "alias", Typeflag: tar.TypeLink, Linkname: "missing"}))
+	assert.Nil(t, tw.Close())
+	err = mfs.ImportTar(bytes.NewReader(archive.Bytes()), "/other")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,58 @@
+package memfs
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// NewTB returns a filesystem for use by the test t. The filesystem uses a deterministic
+// clock, starting at 2000-01-01 UTC and advancing a second on every read, and a fixed
+// random seed, so names and times are the same on every run. Options are applied after
+// these defaults and can override them. When the test finishes, it fails if any file
+// opened from the filesystem was left open.
+func NewTB(t testing.TB, opts ...Option) *FS {
+	t.Helper()
+
+	defaults := []Option{
+		WithClock(NewStepClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Second)),
+		WithRandSource(rand.NewSource(1)),
+	}
+	f := New(append(defaults, opts...)...)
+
+	t.Cleanup(func() {
+		if open := f.openFiles(); len(open) > 0 {
+			names := make([]string, len(open))
+			for i, file := range open {
+				names[i] = file.Name()
+			}
+			t.Errorf("memfs: %d file(s) left open: %s", len(open), strings.Join(names, ", "))
+		}
+		if f.dumpOnFailure && t.Failed() {
+			var tree strings.Builder
+			_ = f.DumpTree(&tree, "/")
+			t.Logf("memfs tree:\n%s", tree.String())
+		}
+	})
+
+	return f
+}
+
+// WithTreeDumpOnFailure makes a filesystem created by NewTB log its tree when the test fails.
+func WithTreeDumpOnFailure() Option {
+	return func(f *FS) {
+		f.dumpOnFailure = true
+	}
+}
+
+// TestNamespace returns an isolated view of the filesystem for the test t. The view starts
+// as a copy of the current tree, sharing file content until it is modified, so parallel
+// tests can start from the same preloaded fixtures without seeing each other's changes.
+// The copy is released when the test finishes.
+func (f *FS) TestNamespace(t testing.TB) *FS {
+	t.Helper()
+	ns := f.view(f.root.clone())
+	t.Cleanup(ns.root.unlinkAll)
+	return ns
+}
@@ -0,0 +1,156 @@
+package memfs
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingTB struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+	logs     []string
+	failed   bool
+}
+
+func (r *recordingTB) Helper()           {}
+func (r *recordingTB) Cleanup(fn func()) { r.cleanups = append(r.cleanups, fn) }
+func (r *recordingTB) Failed() bool      { return r.failed || len(r.errors) > 0 }
+func (r *recordingTB) Logf(format string, args ...any) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func Test_NewTB_Is_Deterministic(t *testing.T) {
+	mfs1, mfs2 := NewTB(t), NewTB(t)
+
+	name1, err := mfs1.MkdirTemp("", "test*")
+	assert.Nil(t, err)
+	name2, err := mfs2.MkdirTemp("", "test*")
+	assert.Nil(t, err)
+	assert.Equal(t, name1, name2)
+
+	fi, err := mfs1.Stat(name1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2000, fi.ModTime().Year())
+}
+
+func Test_NewTB_Reports_Open_Files(t *testing.T) {
+	tb := &recordingTB{}
+	mfs := NewTB(tb)
+
+	f, err := mfs.Create("/left_open")
+	assert.Nil(t, err)
+	assert.NotNil(t, f)
+	closed, err := mfs.Create("/closed")
+	assert.Nil(t, err)
+	assert.Nil(t, closed.Close())
+
+	tb.finish()
+	assert.Len(t, tb.errors, 1)
+	assert.Contains(t, tb.errors[0], "left_open")
+	assert.NotContains(t, tb.errors[0], "closed")
+	assert.Len(t, tb.logs, 0)
+}
+
+func Test_NewTB_Dumps_Tree_On_Failure(t *testing.T) {
+	tb := &recordingTB{}
+	mfs := NewTB(tb, WithTreeDumpOnFailure())
+	assert.Nil(t, mfs.MkdirAll("/dumped/dir", 0755))
+	tb.failed = true
+
+	tb.finish()
+	assert.Len(t, tb.logs, 1)
+	assert.Contains(t, tb.logs[0], "/dumped/dir")
+
+	tb = &recordingTB{}
+	NewTB(tb, WithTreeDumpOnFailure())
+	tb.finish()
+	assert.Len(t, tb.logs, 0)
+}
+
+func Test_DumpTree(t *testing.T) {
+	mfs := New(WithClock(NewStepClock(time.Now(), time.Millisecond)))
+	assert.Nil(t, mfs.MkdirAll("/a/b", 0755))
+	f, err := mfs.OpenFile("/a/file", os.O_RDWR|os.O_CREATE, 0644)
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(`12345`))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	var tree strings.Builder
+	assert.Nil(t, mfs.DumpTree(&tree, "/a"))
+	assert.Equal(t, "drwxr-xr-x        0 /a\n"+
+		"drwxr-xr-x        0 /a/b\n"+
+		"-rw-r--r--        5 /a/file\n", tree.String())
+}
+
+func Test_TestNamespace(t *testing.T) {
+	fixtures := New()
+	assert.Nil(t, fixtures.MkdirAll("/fixtures", 0755))
+	f, err := fixtures.Create("/fixtures/data")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(`fixture`))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	var first *FS
+	for i := 0; i < 4; i++ {
+		i := i
+		t.Run(fmt.Sprintf("parallel-%d", i), func(t *testing.T) {
+			t.Parallel()
+			ns := fixtures.TestNamespace(t)
+			if i == 0 {
+				first = ns
+			}
+
+			f, err := ns.OpenFile("/fixtures/data", os.O_RDWR, 0)
+			assert.Nil(t, err)
+			data := make([]byte, 7)
+			_, err = f.ReadAt(data, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, "fixture", string(data))
+
+			_, err = f.WriteAt([]byte(fmt.Sprintf("%d", i)), 0)
+			assert.Nil(t, err)
+			assert.Nil(t, f.Close())
+
+			own, err := ns.Create(fmt.Sprintf("/fixtures/own-%d", i))
+			assert.Nil(t, err)
+			assert.Nil(t, own.Close())
+
+			entries, err := ns.ReadDir("/fixtures")
+			assert.Nil(t, err)
+			assert.Len(t, entries, 2)
+		})
+	}
+
+	t.Cleanup(func() {
+		entries, err := fixtures.ReadDir("/fixtures")
+		assert.Nil(t, err)
+		assert.Len(t, entries, 1)
+
+		f, err := fixtures.Open("/fixtures/data")
+		assert.Nil(t, err)
+		data := make([]byte, 7)
+		_, err = f.ReadAt(data, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, "fixture", string(data))
+		assert.Nil(t, f.Close())
+
+		// namespaces are released once their test finishes
+		assert.True(t, first.root.unlinked)
+	})
+}
@@ -0,0 +1,75 @@
+package memfs
+
+import (
+	"crypto/sha256"
+	"io/fs"
+	"maps"
+	"sync"
+)
+
+// TemplateReloader keeps templates parsed from the files of a tree matching glob patterns,
+// parsing them again when files matching the patterns are added, removed or modified.
+// The files are checked at every call of Templates, there is no background watcher, so
+// a test modifying a template sees the change at its next call.
+type TemplateReloader[T any] struct {
+	fs       *FS
+	parse    func(fs.FS, ...string) (T, error)
+	patterns []string
+
+	mutex     sync.Mutex
+	sums      map[string][sha256.Size]byte
+	templates T
+	parsed    bool
+}
+
+// NewTemplateReloader returns a reloader parsing the files matching patterns with parse,
+// html/template.ParseFS or text/template.ParseFS, the patterns are relative to the root
+// like names of IOFS.
+func NewTemplateReloader[T any](f *FS, parse func(fs.FS, ...string) (T, error), patterns ...string) *TemplateReloader[T] {
+	return &TemplateReloader[T]{fs: f, parse: parse, patterns: patterns}
+}
+
+// Templates returns the templates, parsed again first when the files changed since they
+// were last parsed. When parsing fails, the error is returned with the templates parsed
+// last, and parsing is tried again at the next call.
+func (r *TemplateReloader[T]) Templates() (T, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	sums, err := r.checksums()
+	if err != nil {
+		return r.templates, err
+	}
+	if r.parsed && maps.Equal(sums, r.sums) {
+		return r.templates, nil
+	}
+	templates, err := r.parse(r.fs.IOFS(), r.patterns...)
+	if err != nil {
+		return r.templates, err
+	}
+	r.templates, r.sums, r.parsed = templates, sums, true
+	return r.templates, nil
+}
+
+// checksums returns the content hashes of the files matching the patterns, keyed by name.
+func (r *TemplateReloader[T]) checksums() (map[string][sha256.Size]byte, error) {
+	fsys := r.fs.IOFS()
+	sums := make(map[string][sha256.Size]byte)
+	for _, pattern := range r.patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if _, seen := sums[name]; seen {
+				continue
+			}
+			sum, err := r.fs.ContentHash(r.fs.join("/", name))
+			if err != nil {
+				return nil, err
+			}
+			sums[name] = sum
+		}
+	}
+	return sums, nil
+}
@@ -0,0 +1,64 @@
+package memfs
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"os"
+	"testing"
+	texttemplate "text/template"
+)
+
+func Test_TemplateReloader(t *testing.T) {
+	mfs, err := NewFromMap(map[string][]byte{
+		"/views/index.html": []byte(`{{define "index"}}<p>{{.}}</p>{{end}}`),
+	})
+	assert.Nil(t, err)
+	reloader := NewTemplateReloader(mfs, template.ParseFS, "views/*.html")
+
+	render := func(name string) string {
+		tmpl, err := reloader.Templates()
+		assert.Nil(t, err)
+		var out bytes.Buffer
+		assert.Nil(t, tmpl.ExecuteTemplate(&out, name, "<hi>"))
+		return out.String()
+	}
+	write := func(name, data string) {
+		f, err := mfs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(data))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+	}
+
+	assert.Equal(t, "<p>&lt;hi&gt;</p>", render("index"))
+	first, err := reloader.Templates()
+	assert.Nil(t, err)
+	second, err := reloader.Templates()
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+
+	write("/views/index.html", `{{define "index"}}<h1>{{.}}</h1>{{end}}`)
+	assert.Equal(t, "<h1>&lt;hi&gt;</h1>", render("index"))
+
+	write("/views/other.html", `{{define "other"}}other{{end}}`)
+	assert.Equal(t, "other", render("other"))
+
+	// a broken template keeps the last parsed ones until it is fixed
+	write("/views/index.html", `{{define "index"}}{{.}`)
+	tmpl, err := reloader.Templates()
+	assert.NotNil(t, err)
+	assert.NotNil(t, tmpl.Lookup("other"))
+	write("/views/index.html", `{{define "index"}}fixed{{end}}`)
+	assert.Equal(t, "fixed", render("index"))
+
+	assert.Nil(t, mfs.Remove("/views/other.html"))
+	tmpl, err = reloader.Templates()
+	assert.Nil(t, err)
+	assert.Nil(t, tmpl.Lookup("other"))
+
+	text := NewTemplateReloader(mfs, texttemplate.ParseFS, "views/index.html")
+	textTmpl, err := text.Templates()
+	assert.Nil(t, err)
+	assert.NotNil(t, textTmpl.Lookup("index"))
+}
@@ -0,0 +1,95 @@
+package memfs
+
+import (
+	"time"
+)
+
+// Times are the times of an entry other than its modification time, kept when the
+// filesystem was created WithTimes. They are in the SysInfo of a FileInfo, as of the call
+// returning the FileInfo.
+type Times struct {
+	Access time.Time // of the last read of the file or listing of the directory
+	Change time.Time // of the last change of the content, the entries or the attributes
+	Birth  time.Time // of the creation of the entry
+}
+
+// WithTimes makes the filesystem keep the access, change and birth times of the entries,
+// like the atime, ctime and btime of stat(2), so code relying on them can be tested. The
+// times of the entries the filesystem didn't create, like those of images or of forks of
+// filesystems not keeping them, are zero until they change, their birth time is unknown.
+func WithTimes() Option {
+	return func(f *FS) {
+		f.keepTimes = true
+	}
+}
+
+// WithoutAtime makes the filesystem created WithTimes leave the access times unchanged
+// by reads, like the noatime mount option, sparing the cost of updating them on every
+// read. Chtimes still changes them.
+func WithoutAtime() Option {
+	return func(f *FS) {
+		f.noAtime = true
+	}
+}
+
+// timesOf returns a copy of the times of n, nil when they are not kept.
+func (f *FS) timesOf(n *fsNode) *Times {
+	if !f.baseFS().keepTimes {
+		return nil
+	}
+	t := new(Times)
+	if e := n.target().getExt(); e != nil {
+		e.mutex.Lock()
+		*t = e.times
+		e.mutex.Unlock()
+	}
+	return t
+}
+
+// setTimes calls set with the times of n when they are kept.
+func (f *FS) setTimes(n *fsNode, set func(t *Times)) {
+	if !f.baseFS().keepTimes {
+		return
+	}
+	e := n.extension()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	set(&e.times)
+}
+
+// born records the creation of n, at now.
+func (f *FS) born(n *fsNode, now time.Time) {
+	f.setTimes(n, func(t *Times) {
+		*t = Times{Access: now, Change: now, Birth: now}
+	})
+}
+
+// accessed records a read of n, unless created WithoutAtime.
+func (f *FS) accessed(n *fsNode) {
+	base := f.baseFS()
+	if !base.keepTimes || base.noAtime {
+		return
+	}
+	now := f.now()
+	f.setTimes(n, func(t *Times) {
+		t.Access = now
+	})
+}
+
+// changed records a change of the content, the entries or the attributes of n.
+func (f *FS) changed(n *fsNode) {
+	if !f.baseFS().keepTimes {
+		return
+	}
+	now := f.now()
+	f.setTimes(n, func(t *Times) {
+		t.Change = now
+	})
+}
+
+// setAccessTime sets the access time of n, for Chtimes.
+func (f *FS) setAccessTime(n *fsNode, atime time.Time) {
+	f.setTimes(n, func(t *Times) {
+		t.Access = atime
+	})
+}
@@ -0,0 +1,80 @@
+package memfs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_Times(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mfs := New(WithTimes(), WithClock(NewStepClock(start, time.Second)))
+	times := func(path string) Times {
+		fi, err := mfs.Stat(path)
+		assert.Nil(t, err)
+		return *fi.Sys().(*SysInfo).Times
+	}
+
+	assert.Nil(t, mfs.WriteFile("/file", []byte("content"), 0644))
+	created := times("/file")
+	assert.False(t, created.Birth.IsZero())
+	assert.Equal(t, created.Birth, created.Access)
+
+	// reads change the access time
+	_, err := mfs.ReadFile("/file")
+	assert.Nil(t, err)
+	read := times("/file")
+	assert.True(t, read.Access.After(created.Access))
+	assert.Equal(t, created.Change, read.Change)
+
+	// writes and attributes the change time
+	assert.Nil(t, mfs.AppendFile("/file", []byte("more"), 0644))
+	written := times("/file")
+	assert.True(t, written.Change.After(read.Change))
+	assert.Equal(t, read.Access, written.Access)
+	assert.Nil(t, mfs.Chmod("/file", 0600))
+	assert.True(t, times("/file").Change.After(written.Change))
+	assert.Equal(t, created.Birth, times("/file").Birth)
+
+	// listing a directory changes its access time, its entries its change time
+	root := times("/")
+	_, err = mfs.ReadDir("/")
+	assert.Nil(t, err)
+	assert.True(t, times("/").Access.After(root.Access))
+	assert.Nil(t, mfs.Mkdir("/dir", 0755))
+	assert.True(t, times("/").Change.After(root.Change))
+
+	atime := start.Add(-time.Hour)
+	assert.Nil(t, mfs.Chtimes("/file", atime, time.Time{}))
+	assert.Equal(t, atime, times("/file").Access)
+
+	// through handles and listings
+	f, err := mfs.Open("/file")
+	assert.Nil(t, err)
+	_, err = io.ReadAll(f)
+	assert.Nil(t, err)
+	fi, err := f.Stat()
+	assert.Nil(t, err)
+	assert.True(t, fi.Sys().(*SysInfo).Times.Access.After(atime))
+	assert.Nil(t, f.Close())
+	entries, err := mfs.ReadDir("/")
+	assert.Nil(t, err)
+	for _, e := range entries {
+		info, err := e.Info()
+		assert.Nil(t, err)
+		assert.NotNil(t, info.Sys().(*SysInfo).Times, e.Name())
+	}
+
+	// the access times stay unchanged WithoutAtime
+	mfs = New(WithTimes(), WithoutAtime(), WithClock(NewStepClock(start, time.Second)))
+	assert.Nil(t, mfs.WriteFile("/file", []byte("content"), 0644))
+	created = times("/file")
+	_, err = mfs.ReadFile("/file")
+	assert.Nil(t, err)
+	assert.Equal(t, created, times("/file"))
+
+	fi, err = New().Stat("/")
+	assert.Nil(t, err)
+	assert.Nil(t, fi.Sys().(*SysInfo).Times)
+}
@@ -0,0 +1,124 @@
+package memfs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithTrash makes Remove and RemoveAll move entries to a trash, out of the tree, from
+// where Restore puts them back until EmptyTrash drops them. Files in the trash stay
+// readable through the handles open on them.
+func WithTrash() Option {
+	return func(f *FS) {
+		f.trash = new(trash)
+	}
+}
+
+// TrashEntry describes an entry in the trash.
+type TrashEntry struct {
+	Path    string // the absolute path of the entry when it was removed
+	IsDir   bool
+	Removed time.Time
+}
+
+// trash holds the entries removed from the tree, with the directory they were removed from.
+type trash struct {
+	mutex   sync.Mutex
+	entries []trashed
+}
+
+type trashed struct {
+	parent  *fsNode
+	node    *fsNode
+	path    string
+	removed time.Time
+}
+
+// moveToTrash takes the entry out of its parent into the trash, the caller must hold the parent lock.
+func (f *FS) moveToTrash(parent, entry *fsNode, path string) {
+	parent.removeEntry(entry.name)
+	f.invalidateLookups()
+	f.touch(parent)
+	t := f.baseFS().trash
+	t.mutex.Lock()
+	t.entries = append(t.entries, trashed{parent: parent, node: entry, path: f.getAbsolutePath(path), removed: f.now()})
+	t.mutex.Unlock()
+}
+
+// Trash returns the entries in the trash, in the order they were removed. It returns nil
+// when the filesystem was not created WithTrash.
+func (f *FS) Trash() []TrashEntry {
+	t := f.baseFS().trash
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	entries := make([]TrashEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		entries = append(entries, TrashEntry{Path: e.path, IsDir: e.node.isDir(), Removed: e.removed})
+	}
+	return entries
+}
+
+// Restore puts back the entry last removed at path, which must not exist, into the
+// directory it was removed from. The directory is found by path, so an entry removed
+// from a directory renamed since is restored in the directory under its new name.
+func (f *FS) Restore(path string) error {
+	t := f.baseFS().trash
+	if t == nil {
+		return fmt.Errorf("no trash: %s: %w", path, os.ErrInvalid)
+	}
+	parentNode, entryNode, missingPath, err := f.getEntry(path)
+	if err != nil {
+		return err
+	}
+	if entryNode == nil && missingPath == "" {
+		return fmt.Errorf("cannot restore root: %s: %w", path, os.ErrInvalid)
+	}
+	if entryNode != nil {
+		return fmt.Errorf("path already exists: %s: %w", path, os.ErrExist)
+	}
+	if strings.Contains(missingPath, "/") {
+		return fmt.Errorf("path does not exist: %s: %w", path, os.ErrNotExist)
+	}
+	if err = f.checkAccess(path, parentNode, accessWrite|accessExecute); err != nil {
+		return err
+	}
+
+	parentNode.lock()
+	defer parentNode.unlock()
+	if _, exists := parentNode.entries.get(missingPath); exists {
+		return fmt.Errorf("path already exists: %s: %w", path, os.ErrExist)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i := len(t.entries) - 1; i >= 0; i-- {
+		if e := t.entries[i]; e.parent == parentNode && e.node.name == missingPath {
+			t.entries = append(t.entries[:i], t.entries[i+1:]...)
+			parentNode.entries.set(e.node)
+			f.touch(parentNode)
+			return nil
+		}
+	}
+	return fmt.Errorf("not in trash: %s: %w", path, os.ErrNotExist)
+}
+
+// EmptyTrash removes the entries in the trash for good, handles open on their files
+// can no longer be used.
+func (f *FS) EmptyTrash() {
+	t := f.baseFS().trash
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	entries := t.entries
+	t.entries = nil
+	t.mutex.Unlock()
+	for _, e := range entries {
+		e.node.unlinkAll()
+	}
+}
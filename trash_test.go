@@ -0,0 +1,73 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+)
+
+func Test_Trash(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mfs, err := NewFromMap(map[string][]byte{
+		"/dir/file":       []byte("first"),
+		"/dir/sub/nested": []byte("nested"),
+	}, WithTrash(), WithClock(NewStepClock(start, time.Second)))
+	assert.Nil(t, err)
+
+	open, err := mfs.Open("/dir/file")
+	assert.Nil(t, err)
+	defer open.Close()
+
+	assert.Nil(t, mfs.Remove("/dir/file"))
+	_, err = mfs.Stat("/dir/file")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	// handles open on a trashed file still read it
+	data, err := io.ReadAll(open)
+	assert.Nil(t, err)
+	assert.Equal(t, "first", string(data))
+
+	// the entry removed last at a path is restored first
+	f, err := mfs.Create("/dir/file")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte("second"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	assert.Nil(t, mfs.Remove("/dir/file"))
+	assert.Nil(t, mfs.RemoveAll("/dir/sub"))
+
+	trash := mfs.Trash()
+	assert.Len(t, trash, 3)
+	assert.Equal(t, "/dir/file", trash[0].Path)
+	assert.Equal(t, "/dir/sub", trash[2].Path)
+	assert.True(t, trash[2].IsDir)
+	assert.True(t, trash[0].Removed.Before(trash[1].Removed))
+
+	assert.Nil(t, mfs.Restore("/dir/file"))
+	assert.Equal(t, "second", readAll(t, mfs, "/dir/file"))
+	assert.True(t, errors.Is(mfs.Restore("/dir/file"), fs.ErrExist))
+
+	assert.Nil(t, mfs.Restore("/dir/sub"))
+	assert.Equal(t, "nested", readAll(t, mfs, "/dir/sub/nested"))
+
+	assert.True(t, errors.Is(mfs.Restore("/dir/missing"), fs.ErrNotExist))
+	assert.True(t, errors.Is(mfs.Restore("/missing/file"), fs.ErrNotExist))
+
+	// entries follow their directory when it is renamed
+	assert.Nil(t, mfs.Remove("/dir/file"))
+	assert.Nil(t, mfs.Rename("/dir", "/moved"))
+	assert.True(t, errors.Is(mfs.Restore("/dir/file"), fs.ErrNotExist))
+	assert.Len(t, mfs.Trash(), 2)
+
+	mfs.EmptyTrash()
+	assert.Len(t, mfs.Trash(), 0)
+	assert.True(t, errors.Is(mfs.Restore("/moved/file"), fs.ErrNotExist))
+	_, err = open.Stat()
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	plain := New()
+	assert.Nil(t, plain.Trash())
+	assert.True(t, errors.Is(plain.Restore("/file"), fs.ErrInvalid))
+}
@@ -0,0 +1,43 @@
+package memfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// DumpTree writes a listing of root and every entry beneath it to w, one entry per
+// line with its mode, size and path, in lexical order.
+func (f *FS) DumpTree(w io.Writer, root string) error {
+	_, entryNode, missingPath, err := f.getEntry(root)
+	if err != nil {
+		return err
+	}
+	if missingPath != "" {
+		return fmt.Errorf("path does not exist: %s: %w", root, os.ErrNotExist)
+	}
+	if entryNode == nil {
+		entryNode = f.root
+	}
+	return entryNode.dumpTree(w, f.getAbsolutePath(root), f.fromSlash)
+}
+
+// dumpTree writes the listing of the node at path, a path with "/" separators shown with fromSlash.
+func (f *fsNode) dumpTree(w io.Writer, path string, fromSlash func(string) string) error {
+	info := FileInfo{node: f}
+	if _, err := fmt.Fprintf(w, "%s %8d %s\n", info.Mode(), info.Size(), fromSlash(path)); err != nil {
+		return err
+	}
+	for _, name := range f.getEntryNames() {
+		f.lock()
+		e, exists := f.entries.get(name)
+		f.unlock()
+		if !exists {
+			continue
+		}
+		if err := e.dumpTree(w, joinPath(path, name), fromSlash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
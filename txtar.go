@@ -0,0 +1,71 @@
+package memfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+
+	"golang.org/x/tools/txtar"
+)
+
+// FromTxtar returns a filesystem holding the files of the archive, at the root. A file
+// whose name ends with "/" stands for an empty directory. The comment of the archive is
+// ignored.
+//
+//	mfs, err := memfs.FromTxtar(txtar.Parse(data))
+func FromTxtar(archive *txtar.Archive, opts ...Option) (*FS, error) {
+	entries := make(map[string]Entry, len(archive.Files))
+	for _, file := range archive.Files {
+		name := strings.TrimSuffix(file.Name, "/")
+		if name == "" || !fs.ValidPath(path.Clean(name)) {
+			return nil, fmt.Errorf("invalid name: %s: %w", file.Name, os.ErrInvalid)
+		}
+		if strings.HasSuffix(file.Name, "/") {
+			entries["/"+name] = Entry{Mode: fs.ModeDir}
+		} else {
+			entries["/"+name] = Entry{Data: file.Data}
+		}
+	}
+	return NewFromEntries(entries, opts...)
+}
+
+// ToTxtar returns an archive of the files beneath root, named relative to root with "/"
+// separators, in path order. Empty directories are written as files named with a trailing
+// "/". The archive format ends the content of every file with a newline, txtar.Format
+// adds one to the files which don't end with one.
+func (f *FS) ToTxtar(root string) (*txtar.Archive, error) {
+	fi, err := f.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s: %w", root, syscall.ENOTDIR)
+	}
+	fsys := f.DirFS(root)
+	archive := new(txtar.Archive)
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			entries, err := fs.ReadDir(fsys, name)
+			if err == nil && len(entries) == 0 && name != "." {
+				archive.Files = append(archive.Files, txtar.File{Name: name + "/"})
+			}
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		archive.Files = append(archive.Files, txtar.File{Name: name, Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
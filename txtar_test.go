@@ -0,0 +1,48 @@
+package memfs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/txtar"
+	"io/fs"
+	"testing"
+)
+
+func Test_Txtar(t *testing.T) {
+	archive := txtar.Parse([]byte(`fixture comment
+-- go.mod --
+module example.com/m
+-- pkg/pkg.go --
+package pkg
+-- empty/ --
+`))
+	mfs, err := FromTxtar(archive)
+	assert.Nil(t, err)
+	assert.Equal(t, "module example.com/m\n", readAll(t, mfs, "/go.mod"))
+	assert.Equal(t, "package pkg\n", readAll(t, mfs, "/pkg/pkg.go"))
+	fi, err := mfs.Stat("/empty")
+	assert.Nil(t, err)
+	assert.True(t, fi.IsDir())
+
+	exported, err := mfs.ToTxtar("/")
+	assert.Nil(t, err)
+	var names []string
+	for _, file := range exported.Files {
+		names = append(names, file.Name)
+	}
+	// the temporary and working directories New creates are empty directories as well
+	assert.Contains(t, names, "empty/")
+	assert.Contains(t, names, "go.mod")
+	assert.Contains(t, names, "pkg/pkg.go")
+
+	exported, err = mfs.ToTxtar("/pkg")
+	assert.Nil(t, err)
+	assert.Equal(t, "-- pkg.go --\npackage pkg\n", string(txtar.Format(exported)))
+
+	_, err = mfs.ToTxtar("/go.mod")
+	assert.NotNil(t, err)
+	_, err = mfs.ToTxtar("/missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = FromTxtar(&txtar.Archive{Files: []txtar.File{{Name: "../escape"}}})
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+}
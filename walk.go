@@ -0,0 +1,237 @@
+package memfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrTooDeep is wrapped by the errors for entries deeper than the limit set WithMaxDepth.
+var ErrTooDeep = errors.New("tree too deep")
+
+// checkDepth returns an error wrapping ErrTooDeep when depth is beyond the limit set WithMaxDepth.
+func (f *FS) checkDepth(path string, depth int) error {
+	if limit := f.baseFS().maxDepth; limit > 0 && depth > limit {
+		return fmt.Errorf("more than %d levels deep: %s: %w", limit, path, ErrTooDeep)
+	}
+	return nil
+}
+
+// walkDir is a directory waiting to have its entries walked.
+type walkDir struct {
+	path  string
+	node  *fsNode
+	depth int
+}
+
+type walkError struct {
+	path string
+	err  error
+}
+
+// WalkDir walks the tree rooted at root like fs.WalkDir, calling fn for every entry in
+// lexical order, each directory before its entries, with the paths joined to root by the
+// separator of the filesystem. fs.SkipDir and fs.SkipAll behave as they do for fs.WalkDir.
+func (f *FS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	parentNode, entryNode, missingPath, err := f.getEntry(root)
+	if err == nil && missingPath != "" {
+		err = fmt.Errorf("path does not exist: %s: %w", root, os.ErrNotExist)
+	}
+	if err != nil {
+		err = fn(root, nil, err)
+		if errors.Is(err, fs.SkipAll) || errors.Is(err, fs.SkipDir) {
+			return nil
+		}
+		return err
+	}
+	if entryNode == nil {
+		// the root dir
+		entryNode = parentNode
+	}
+
+	// the directories being walked, with the next of their entries to visit
+	type walkFrame struct {
+		path     string
+		children []*fsNode
+		next     int
+		depth    int
+	}
+	var stack []walkFrame
+
+	// visit calls fn for the entry n, and pushes the directories fn doesn't skip. Like the
+	// walk of fs.WalkDir, it returns fs.SkipDir when the rest of the parent is to be skipped.
+	visit := func(path string, n *fsNode, depth int) error {
+		d := DirEntry{node: n, fs: f}
+		err := f.checkDepth(path, depth)
+		if err != nil {
+			err = fn(path, d, err)
+		} else if err = fn(path, d, nil); err == nil && n.isDir() {
+			if err = f.checkAccess(path, n, accessRead|accessExecute); err == nil {
+				n.lock()
+				children := n.entries.list()
+				n.unlock()
+				stack = append(stack, walkFrame{path: path, children: children, depth: depth})
+				return nil
+			}
+			// fn is called a second time for a directory which cannot be read
+			err = fn(path, d, err)
+		}
+		if n.isDir() && errors.Is(err, fs.SkipDir) {
+			return nil
+		}
+		return err
+	}
+
+	err = visit(root, entryNode, 0)
+	for err == nil && len(stack) > 0 {
+		dir := &stack[len(stack)-1]
+		if dir.next == len(dir.children) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		child := dir.children[dir.next]
+		dir.next++
+		if err = visit(f.join(dir.path, child.name), child, dir.depth+1); errors.Is(err, fs.SkipDir) {
+			// a file skipped the rest of its directory, nothing was pushed
+			stack, err = stack[:len(stack)-1], nil
+		}
+	}
+	if errors.Is(err, fs.SkipAll) || errors.Is(err, fs.SkipDir) {
+		return nil
+	}
+	return err
+}
+
+// WalkParallel walks the tree rooted at root like fs.WalkDir, but with workers goroutines
+// walking separate directories at the same time, so fn must be safe for concurrent use.
+// Entries of a directory are visited in lexical order, after the directory itself, but
+// there is no order between directories. An error returned by fn doesn't stop the walk,
+// the subtree of a directory is skipped when fn returns an error for it, and fs.SkipAll
+// stops the walk. The errors are returned joined in path order, whatever order they
+// happened in.
+func (f *FS) WalkParallel(root string, workers int, fn fs.WalkDirFunc) error {
+	if workers < 1 || f.baseFS().noLock {
+		workers = 1
+	}
+
+	var (
+		mutex   sync.Mutex
+		cond    = sync.NewCond(&mutex)
+		queue   []walkDir
+		pending int // directories queued or being walked
+		errs    []walkError
+		stopped atomic.Bool
+	)
+
+	// visit calls fn for an entry, it reports whether the entry is a directory to walk.
+	visit := func(path string, n *fsNode, err error) bool {
+		if stopped.Load() {
+			return false
+		}
+		err = fn(path, DirEntry{node: n, fs: f}, err)
+		switch {
+		case err == nil:
+			return n.isDir()
+		case errors.Is(err, fs.SkipAll):
+			stopped.Store(true)
+		case errors.Is(err, fs.SkipDir):
+		default:
+			mutex.Lock()
+			errs = append(errs, walkError{path: path, err: err})
+			mutex.Unlock()
+		}
+		return false
+	}
+
+	parentNode, entryNode, missingPath, err := f.getEntry(root)
+	if err == nil && missingPath != "" {
+		err = fmt.Errorf("path does not exist: %s: %w", root, os.ErrNotExist)
+	}
+	if err != nil {
+		err = fn(root, nil, err)
+		if errors.Is(err, fs.SkipAll) || errors.Is(err, fs.SkipDir) {
+			return nil
+		}
+		return err
+	}
+	if entryNode == nil {
+		// the root dir
+		entryNode = parentNode
+	}
+	if visit(root, entryNode, nil) {
+		queue, pending = append(queue, walkDir{path: root, node: entryNode}), 1
+	}
+
+	walk := func(dir walkDir) {
+		if err := f.checkAccess(dir.path, dir.node, accessRead|accessExecute); err != nil {
+			visit(dir.path, dir.node, err)
+			return
+		}
+		dir.node.lock()
+		children := dir.node.entries.list()
+		dir.node.unlock()
+
+		var subdirs []walkDir
+		for _, child := range children {
+			path := f.join(dir.path, child.name)
+			if err := f.checkDepth(path, dir.depth+1); err != nil {
+				visit(path, child, err)
+				continue
+			}
+			if visit(path, child, nil) {
+				subdirs = append(subdirs, walkDir{path: path, node: child, depth: dir.depth + 1})
+			}
+		}
+		if len(subdirs) > 0 {
+			mutex.Lock()
+			queue = append(queue, subdirs...)
+			pending += len(subdirs)
+			mutex.Unlock()
+			cond.Broadcast()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mutex.Lock()
+				for len(queue) == 0 && pending > 0 {
+					cond.Wait()
+				}
+				if pending == 0 {
+					mutex.Unlock()
+					return
+				}
+				dir := queue[len(queue)-1]
+				queue = queue[:len(queue)-1]
+				mutex.Unlock()
+
+				if !stopped.Load() {
+					walk(dir)
+				}
+
+				mutex.Lock()
+				pending--
+				if pending == 0 {
+					cond.Broadcast()
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].path < errs[j].path })
+	joined := make([]error, len(errs))
+	for i, e := range errs {
+		joined[i] = e.err
+	}
+	return errors.Join(joined...)
+}
@@ -0,0 +1,216 @@
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func walkFixture(t *testing.T) *FS {
+	files := make(map[string][]byte)
+	for d := 0; d < 10; d++ {
+		for s := 0; s < 5; s++ {
+			for i := 0; i < 3; i++ {
+				files[fmt.Sprintf("/tree/d%d/s%d/f%d", d, s, i)] = []byte("x")
+			}
+		}
+	}
+	mfs, err := NewFromMap(files)
+	assert.Nil(t, err)
+	return mfs
+}
+
+func Test_WalkParallel(t *testing.T) {
+	mfs := walkFixture(t)
+
+	var mutex sync.Mutex
+	var visited []string
+	err := mfs.WalkParallel("/tree", 8, func(path string, d fs.DirEntry, err error) error {
+		assert.Nil(t, err)
+		mutex.Lock()
+		visited = append(visited, path)
+		mutex.Unlock()
+		return nil
+	})
+	assert.Nil(t, err)
+
+	var expected []string
+	err = fs.WalkDir(mfs.IOFS(), "tree", func(path string, d fs.DirEntry, err error) error {
+		expected = append(expected, "/"+path)
+		return err
+	})
+	assert.Nil(t, err)
+	sort.Strings(visited)
+	assert.Equal(t, expected, visited)
+	assert.Len(t, visited, 1+10+50+150)
+
+	// errors are returned in path order
+	for i := 0; i < 5; i++ {
+		err = mfs.WalkParallel("/tree", 8, func(path string, d fs.DirEntry, err error) error {
+			if strings.HasSuffix(path, "f1") {
+				return errors.New(path)
+			}
+			return nil
+		})
+		assert.Equal(t, 50, len(err.(interface{ Unwrap() []error }).Unwrap()))
+		assert.True(t, strings.HasPrefix(err.Error(), "/tree/d0/s0/f1\n/tree/d0/s1/f1\n"))
+	}
+}
+
+func Test_WalkParallel_Skip(t *testing.T) {
+	mfs := walkFixture(t)
+
+	var count atomic.Int32
+	err := mfs.WalkParallel("/tree", 4, func(path string, d fs.DirEntry, err error) error {
+		count.Add(1)
+		if d.IsDir() && strings.HasPrefix(d.Name(), "s") {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1+10+50), count.Load())
+
+	// a failing directory is not walked into
+	count.Store(0)
+	err = mfs.WalkParallel("/tree/d0", 4, func(path string, d fs.DirEntry, err error) error {
+		count.Add(1)
+		if path == "/tree/d0/s0" {
+			return os.ErrPermission
+		}
+		return nil
+	})
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.Equal(t, int32(1+5+4*3), count.Load())
+
+	count.Store(0)
+	err = mfs.WalkParallel("/tree", 1, func(path string, d fs.DirEntry, err error) error {
+		if count.Add(1) == 5 {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(5), count.Load())
+
+	err = mfs.WalkParallel("/missing", 4, func(path string, d fs.DirEntry, err error) error {
+		assert.Nil(t, d)
+		return err
+	})
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// unreadable directories are reported to fn
+	assert.Nil(t, mfs.MkdirAll("/locked/inner", 0))
+	var denied error
+	err = mfs.As(1000, 1000).WalkParallel("/locked", 2, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			denied = err
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(denied, os.ErrPermission))
+}
+
+func Test_WalkDir(t *testing.T) {
+	mfs := walkFixture(t)
+
+	// the same walk as fs.WalkDir
+	var walked, expected []string
+	assert.Nil(t, mfs.WalkDir("/tree/d1", func(path string, d fs.DirEntry, err error) error {
+		walked = append(walked, path)
+		return err
+	}))
+	assert.Nil(t, fs.WalkDir(mfs.DirFS("/tree"), "d1", func(path string, d fs.DirEntry, err error) error {
+		expected = append(expected, "/tree/"+path)
+		return err
+	}))
+	assert.Equal(t, 1+5+5*3, len(walked))
+	assert.Equal(t, expected, walked)
+
+	// skipping a directory, the rest of a directory from a file, and all
+	walked = nil
+	assert.Nil(t, mfs.WalkDir("/tree/d2", func(path string, d fs.DirEntry, err error) error {
+		walked = append(walked, path)
+		switch path {
+		case "/tree/d2/s0":
+			return fs.SkipDir
+		case "/tree/d2/s1/f1":
+			return fs.SkipDir
+		case "/tree/d2/s3":
+			return fs.SkipAll
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"/tree/d2", "/tree/d2/s0", "/tree/d2/s1", "/tree/d2/s1/f0", "/tree/d2/s1/f1",
+		"/tree/d2/s2", "/tree/d2/s2/f0", "/tree/d2/s2/f1", "/tree/d2/s2/f2", "/tree/d2/s3"}, walked)
+
+	err := mfs.WalkDir("/tree", func(path string, d fs.DirEntry, err error) error {
+		if path == "/tree/d0/s1/f2" {
+			return os.ErrPermission
+		}
+		return nil
+	})
+	assert.True(t, errors.Is(err, os.ErrPermission))
+
+	err = mfs.WalkDir("/missing", func(path string, d fs.DirEntry, err error) error {
+		assert.Nil(t, d)
+		return err
+	})
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// unreadable directories are reported to fn a second time
+	assert.Nil(t, mfs.MkdirAll("/locked/inner", 0))
+	walked = nil
+	err = mfs.As(1000, 1000).WalkDir("/locked", func(path string, d fs.DirEntry, err error) error {
+		walked = append(walked, fmt.Sprint(path, " ", err != nil))
+		return err
+	})
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.Equal(t, []string{"/locked false", "/locked true"}, walked)
+}
+
+func Test_Max_Depth(t *testing.T) {
+	deep := "/deep" + strings.Repeat("/d", 2000)
+	mfs := New()
+	assert.Nil(t, mfs.MkdirAll(deep, 0755))
+	var buf bytes.Buffer
+	assert.Nil(t, mfs.ExportTar(&buf, "/deep"))
+	assert.Nil(t, mfs.RemoveAll("/deep"))
+	_, err := mfs.Stat("/deep")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	mfs = New(WithMaxDepth(3))
+	assert.Nil(t, mfs.MkdirAll("/deep/1/2/3/4", 0755))
+	assert.Nil(t, mfs.MkdirAll("/shallow/1/2", 0755))
+
+	err = mfs.WalkParallel("/deep", 2, func(path string, d fs.DirEntry, err error) error {
+		return err
+	})
+	assert.True(t, errors.Is(err, ErrTooDeep))
+	assert.Nil(t, mfs.WalkParallel("/shallow", 2, func(path string, d fs.DirEntry, err error) error {
+		return err
+	}))
+
+	assert.True(t, errors.Is(mfs.ExportTar(&buf, "/deep"), ErrTooDeep))
+	assert.Nil(t, mfs.ExportTar(&buf, "/shallow"))
+	assert.True(t, errors.Is(mfs.WriteImage(&buf, "/deep", false), ErrTooDeep))
+	snapshot := mfs.Snapshot()
+	defer snapshot.Release()
+	assert.Nil(t, mfs.MkdirAll("/new/1/2/3", 0755))
+	_, err = mfs.ExportDelta(&buf, snapshot)
+	assert.True(t, errors.Is(err, ErrTooDeep))
+
+	assert.True(t, errors.Is(mfs.RemoveAll("/deep"), ErrTooDeep))
+	_, err = mfs.Stat("/deep/1/2/3/4")
+	assert.Nil(t, err)
+	assert.Nil(t, mfs.RemoveAll("/shallow"))
+}
@@ -0,0 +1,318 @@
+// Package wasifs adapts a memfs.FS to the filesystem interfaces of the wazero WebAssembly
+// runtime, so WASI guests run by a test see a fully in memory filesystem controlled by
+// the host test:
+//
+//	mfs := memfs.New()
+//	fsConfig := wazero.NewFSConfig().(sysfs.FSConfig).WithSysFSMount(wasifs.New(mfs, "/sandbox"), "/")
+//	moduleConfig := wazero.NewModuleConfig().WithFSConfig(fsConfig)
+package wasifs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/mbordner/memfs"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/experimental/sysfs"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// FS implements the wazero experimental sys.FS over a directory of a memfs.FS.
+// Symbolic links, Chmod and Utimens are not supported and report ENOSYS.
+type FS struct {
+	experimentalsys.UnimplementedFS
+	fs   *memfs.FS
+	root string
+}
+
+// New returns a wazero filesystem exposing dir of fsys as the root of the mount.
+func New(fsys *memfs.FS, dir string) *FS {
+	return &FS{fs: fsys, root: path.Clean("/" + dir)}
+}
+
+func (w *FS) join(name string) string {
+	return path.Join(w.root, path.Clean("/"+name))
+}
+
+func (w *FS) OpenFile(name string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	var osFlag int
+	switch flag & (experimentalsys.O_RDONLY | experimentalsys.O_RDWR | experimentalsys.O_WRONLY) {
+	case experimentalsys.O_RDWR:
+		osFlag = os.O_RDWR
+	case experimentalsys.O_WRONLY:
+		osFlag = os.O_WRONLY
+	default:
+		osFlag = os.O_RDONLY
+	}
+	if flag&experimentalsys.O_CREAT != 0 {
+		osFlag |= os.O_CREATE
+	}
+	if flag&experimentalsys.O_EXCL != 0 {
+		osFlag |= os.O_EXCL
+	}
+	if flag&experimentalsys.O_TRUNC != 0 {
+		osFlag |= os.O_TRUNC
+	}
+
+	o := &opener{w: w, flag: osFlag, perm: perm}
+	f, errno := (&sysfs.AdaptFS{FS: o}).OpenFile(name, flag, perm)
+	if errno != 0 {
+		return nil, errno
+	}
+	// a directory is reopened to rewind it, which must not create or truncate anything
+	o.flag = os.O_RDONLY
+	if flag&experimentalsys.O_DIRECTORY != 0 {
+		if dir, errno := f.IsDir(); errno != 0 || !dir {
+			_ = f.Close()
+			if errno == 0 {
+				errno = experimentalsys.ENOTDIR
+			}
+			return nil, errno
+		}
+	}
+
+	// appending is emulated by the adapter, as WASI allows it to be toggled on an open file
+	return &file{File: f, handle: o.opened, appending: flag&experimentalsys.O_APPEND != 0}, 0
+}
+
+func (w *FS) Stat(name string) (sys.Stat_t, experimentalsys.Errno) {
+	fi, err := w.fs.Stat(w.join(name))
+	if err != nil {
+		return sys.Stat_t{}, toErrno(err)
+	}
+	return toStat(fi), 0
+}
+
+// Lstat is the same as Stat, as memfs has no symbolic links.
+func (w *FS) Lstat(name string) (sys.Stat_t, experimentalsys.Errno) {
+	return w.Stat(name)
+}
+
+func (w *FS) Mkdir(name string, perm fs.FileMode) experimentalsys.Errno {
+	return toErrno(w.fs.Mkdir(w.join(name), perm))
+}
+
+func (w *FS) Rename(from, to string) experimentalsys.Errno {
+	return toErrno(w.fs.Rename(w.join(from), w.join(to)))
+}
+
+func (w *FS) Rmdir(name string) experimentalsys.Errno {
+	p := w.join(name)
+	fi, err := w.fs.Stat(p)
+	if err != nil {
+		return toErrno(err)
+	}
+	if !fi.IsDir() {
+		return experimentalsys.ENOTDIR
+	}
+	entries, err := w.fs.ReadDir(p)
+	if err != nil {
+		return toErrno(err)
+	}
+	if len(entries) > 0 {
+		return experimentalsys.ENOTEMPTY
+	}
+	return toErrno(w.fs.Remove(p))
+}
+
+func (w *FS) Unlink(name string) experimentalsys.Errno {
+	p := w.join(name)
+	fi, err := w.fs.Stat(p)
+	if err != nil {
+		return toErrno(err)
+	}
+	if fi.IsDir() {
+		return experimentalsys.EISDIR
+	}
+	return toErrno(w.fs.Remove(p))
+}
+
+// opener is the fs.FS handed to wazero's fs.File adapter, which provides reading,
+// seeking and directory listing of the opened handles.
+type opener struct {
+	w      *FS
+	flag   int
+	perm   fs.FileMode
+	opened *handle // the last handle opened
+}
+
+func (o *opener) Open(name string) (fs.File, error) {
+	f, err := o.w.fs.OpenFile(o.w.join(name), o.flag, o.perm)
+	if err != nil {
+		return nil, osError(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, osError(err)
+	}
+	o.opened = &handle{f: f, dir: fi.IsDir()}
+	return o.opened, nil
+}
+
+// handle is a memfs.File with the errors and directory semantics wazero expects.
+type handle struct {
+	f       *memfs.File
+	dir     bool
+	entries []fs.DirEntry // remaining entries of a directory being read
+	listed  bool
+}
+
+func (h *handle) Stat() (fs.FileInfo, error) {
+	fi, err := h.f.Stat()
+	if err != nil {
+		return nil, osError(err)
+	}
+	return fi, nil
+}
+
+func (h *handle) Read(p []byte) (int, error) {
+	if h.dir {
+		return 0, experimentalsys.EISDIR
+	}
+	n, err := h.f.Read(p)
+	return n, osError(err)
+}
+
+func (h *handle) ReadAt(p []byte, off int64) (int, error) {
+	if h.dir {
+		return 0, experimentalsys.EISDIR
+	}
+	n, err := h.f.ReadAt(p, off)
+	return n, osError(err)
+}
+
+func (h *handle) Seek(offset int64, whence int) (int64, error) {
+	if h.dir {
+		return 0, experimentalsys.EISDIR
+	}
+	n, err := h.f.Seek(offset, whence)
+	return n, osError(err)
+}
+
+func (h *handle) Write(p []byte) (int, error) {
+	if h.dir {
+		return 0, experimentalsys.EISDIR
+	}
+	n, err := h.f.Write(p)
+	return n, osError(err)
+}
+
+func (h *handle) WriteAt(p []byte, off int64) (int, error) {
+	if h.dir {
+		return 0, experimentalsys.EISDIR
+	}
+	n, err := h.f.WriteAt(p, off)
+	return n, osError(err)
+}
+
+func (h *handle) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !h.listed {
+		entries, err := h.f.ReadDir(-1)
+		if err != nil {
+			return nil, osError(err)
+		}
+		h.entries, h.listed = entries, true
+	}
+	if n <= 0 || n > len(h.entries) {
+		n = len(h.entries)
+	}
+	entries := h.entries[:n]
+	h.entries = h.entries[n:]
+	return entries, nil
+}
+
+func (h *handle) Close() error {
+	return osError(h.f.Close())
+}
+
+type file struct {
+	experimentalsys.File
+	handle    *handle
+	appending bool
+}
+
+// Stat reports the inode and links of the memfs entry, which wazero's adapter leaves out.
+func (f *file) Stat() (sys.Stat_t, experimentalsys.Errno) {
+	fi, err := f.handle.f.Stat()
+	if err != nil {
+		return sys.Stat_t{}, toErrno(err)
+	}
+	return toStat(fi), 0
+}
+
+func (f *file) IsAppend() bool {
+	return f.appending
+}
+
+func (f *file) SetAppend(enable bool) experimentalsys.Errno {
+	if dir, errno := f.IsDir(); errno != 0 {
+		return errno
+	} else if dir {
+		return experimentalsys.EISDIR
+	}
+	f.appending = enable
+	return 0
+}
+
+func (f *file) Write(buf []byte) (int, experimentalsys.Errno) {
+	if f.appending {
+		if _, errno := f.Seek(0, io.SeekEnd); errno != 0 {
+			return 0, errno
+		}
+	}
+	return f.File.Write(buf)
+}
+
+func toStat(fi fs.FileInfo) sys.Stat_t {
+	mtim := fi.ModTime().UnixNano()
+	st := sys.Stat_t{
+		Mode:  fi.Mode(),
+		Nlink: 1,
+		Size:  fi.Size(),
+		Atim:  mtim,
+		Mtim:  mtim,
+		Ctim:  mtim,
+	}
+	if info, ok := fi.Sys().(*memfs.SysInfo); ok {
+		st.Ino, st.Nlink = info.Ino, uint64(info.Nlink)
+		if info.Times != nil {
+			st.Atim, st.Ctim = info.Times.Access.UnixNano(), info.Times.Change.UnixNano()
+		}
+	}
+	return st
+}
+
+// osError converts errors returned by memfs to an Errno, leaving io.EOF as is.
+func osError(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return toErrno(err)
+}
+
+// toErrno converts errors returned by memfs to the errno a guest expects.
+func toErrno(err error) experimentalsys.Errno {
+	var errno syscall.Errno
+	switch {
+	case err == nil || errors.Is(err, io.EOF):
+		return 0
+	case errors.As(err, &errno):
+		return experimentalsys.UnwrapOSError(errno)
+	case errors.Is(err, fs.ErrNotExist):
+		return experimentalsys.ENOENT
+	case errors.Is(err, fs.ErrExist):
+		return experimentalsys.EEXIST
+	case errors.Is(err, fs.ErrPermission):
+		return experimentalsys.EACCES
+	case errors.Is(err, fs.ErrClosed):
+		return experimentalsys.EBADF
+	case errors.Is(err, fs.ErrInvalid):
+		return experimentalsys.EINVAL
+	}
+	return experimentalsys.EIO
+}
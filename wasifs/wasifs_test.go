@@ -0,0 +1,181 @@
+package wasifs
+
+import (
+	"github.com/mbordner/memfs"
+	"github.com/stretchr/testify/assert"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func writeFile(mfs *memfs.FS, name string, data []byte, perm fs.FileMode) error {
+	f, err := mfs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+func readFile(mfs *memfs.FS, name string) ([]byte, error) {
+	f, err := mfs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func newTestFS(t *testing.T) (*memfs.FS, *FS) {
+	mfs := memfs.New()
+	assert.Nil(t, mfs.MkdirAll("/sandbox/dir", 0755))
+	assert.Nil(t, writeFile(mfs, "/sandbox/dir/a.txt", []byte("hello"), 0644))
+	return mfs, New(mfs, "/sandbox")
+}
+
+func Test_OpenFile_ReadWrite(t *testing.T) {
+	mfs, w := newTestFS(t)
+
+	f, errno := w.OpenFile("/new.txt", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0644)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	n, errno := f.Write([]byte("hello world"))
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, 11, n)
+
+	off, errno := f.Seek(6, io.SeekStart)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, int64(6), off)
+	buf := make([]byte, 10)
+	n, errno = f.Read(buf)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, "world", string(buf[:n]))
+
+	n, errno = f.Pread(buf[:5], 0)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, "hello", string(buf[:n]))
+	n, errno = f.Pwrite([]byte("HELLO"), 0)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, 5, n)
+
+	st, errno := f.Stat()
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, int64(11), st.Size)
+	assert.NotZero(t, st.Ino)
+	assert.Equal(t, uint64(1), st.Nlink)
+	pathSt, errno := w.Stat("/new.txt")
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, st.Ino, pathSt.Ino)
+	assert.Equal(t, experimentalsys.Errno(0), f.Close())
+
+	data, err := readFile(mfs, "/sandbox/new.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "HELLO world", string(data))
+
+	_, errno = w.OpenFile("/new.txt", experimentalsys.O_RDWR|experimentalsys.O_CREAT|experimentalsys.O_EXCL, 0644)
+	assert.Equal(t, experimentalsys.EEXIST, errno)
+	_, errno = w.OpenFile("/missing.txt", experimentalsys.O_RDONLY, 0)
+	assert.Equal(t, experimentalsys.ENOENT, errno)
+	_, errno = w.OpenFile("/new.txt", experimentalsys.O_RDONLY|experimentalsys.O_DIRECTORY, 0)
+	assert.Equal(t, experimentalsys.ENOTDIR, errno)
+}
+
+func Test_OpenFile_Append(t *testing.T) {
+	mfs, w := newTestFS(t)
+
+	f, errno := w.OpenFile("/dir/a.txt", experimentalsys.O_WRONLY|experimentalsys.O_APPEND, 0)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.True(t, f.IsAppend())
+	_, errno = f.Write([]byte(" world"))
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+
+	assert.Equal(t, experimentalsys.Errno(0), f.SetAppend(false))
+	_, errno = f.Seek(0, io.SeekStart)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	_, errno = f.Write([]byte("J"))
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, experimentalsys.Errno(0), f.Close())
+
+	data, err := readFile(mfs, "/sandbox/dir/a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "Jello world", string(data))
+
+	f, errno = w.OpenFile("/dir/a.txt", experimentalsys.O_WRONLY|experimentalsys.O_TRUNC, 0)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, experimentalsys.Errno(0), f.Close())
+	data, err = readFile(mfs, "/sandbox/dir/a.txt")
+	assert.Nil(t, err)
+	assert.Empty(t, data)
+}
+
+func Test_Readdir(t *testing.T) {
+	mfs, w := newTestFS(t)
+	assert.Nil(t, writeFile(mfs, "/sandbox/dir/b.txt", []byte("b"), 0644))
+	assert.Nil(t, mfs.Mkdir("/sandbox/dir/c", 0755))
+
+	f, errno := w.OpenFile("/dir", experimentalsys.O_RDONLY|experimentalsys.O_DIRECTORY, 0)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	isDir, errno := f.IsDir()
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.True(t, isDir)
+
+	dirents, errno := f.Readdir(2)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, 2, len(dirents))
+	dirents, errno = f.Readdir(2)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, 1, len(dirents))
+	dirents, errno = f.Readdir(2)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Empty(t, dirents)
+
+	_, errno = f.Seek(0, io.SeekStart)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	dirents, errno = f.Readdir(-1)
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	names := map[string]fs.FileMode{}
+	for _, d := range dirents {
+		names[d.Name] = d.Type
+	}
+	assert.Equal(t, map[string]fs.FileMode{"a.txt": 0, "b.txt": 0, "c": fs.ModeDir}, names)
+
+	_, errno = f.Read(make([]byte, 1))
+	assert.Equal(t, experimentalsys.EISDIR, errno)
+	assert.Equal(t, experimentalsys.EISDIR, f.SetAppend(true))
+	assert.Equal(t, experimentalsys.Errno(0), f.Close())
+}
+
+func Test_FS_Operations(t *testing.T) {
+	mfs, w := newTestFS(t)
+
+	st, errno := w.Stat("/dir")
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.True(t, st.Mode.IsDir())
+	assert.NotZero(t, st.Ino)
+	assert.Equal(t, uint64(2), st.Nlink)
+	st, errno = w.Lstat("/dir/a.txt")
+	assert.Equal(t, experimentalsys.Errno(0), errno)
+	assert.Equal(t, int64(5), st.Size)
+	_, errno = w.Stat("/missing")
+	assert.Equal(t, experimentalsys.ENOENT, errno)
+
+	assert.Equal(t, experimentalsys.Errno(0), w.Mkdir("/other", 0755))
+	assert.Equal(t, experimentalsys.EEXIST, w.Mkdir("/other", 0755))
+	assert.Equal(t, experimentalsys.Errno(0), w.Rename("/dir/a.txt", "/other/b.txt"))
+	_, err := mfs.Stat("/sandbox/other/b.txt")
+	assert.Nil(t, err)
+
+	assert.Equal(t, experimentalsys.ENOTEMPTY, w.Rmdir("/other"))
+	assert.Equal(t, experimentalsys.ENOTDIR, w.Rmdir("/other/b.txt"))
+	assert.Equal(t, experimentalsys.EISDIR, w.Unlink("/other"))
+	assert.Equal(t, experimentalsys.Errno(0), w.Unlink("/other/b.txt"))
+	assert.Equal(t, experimentalsys.Errno(0), w.Rmdir("/other"))
+	assert.Equal(t, experimentalsys.ENOENT, w.Unlink("/other/b.txt"))
+
+	// paths cannot escape the mounted directory
+	assert.Nil(t, writeFile(mfs, "/outside.txt", []byte("x"), 0644))
+	_, errno = w.Stat("/../outside.txt")
+	assert.Equal(t, experimentalsys.ENOENT, errno)
+}